@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// API route prefixes. New clients use /api/v1; the unversioned /api paths are
+// kept as aliases for existing integrations.
+const (
+	apiV1Prefix     = "/api/v1"
+	apiLegacyPrefix = "/api"
+)
+
+// openAPISpec is the OpenAPI 3 contract for the /api/v1 routes
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// registerAPIRoutes mounts the JSON API under /api/v1 and the legacy /api prefix
+func (s *Server) registerAPIRoutes() {
+	for _, prefix := range []string{apiV1Prefix, apiLegacyPrefix} {
+		// Search with pagination, sort/filters and exports
+		s.mux.HandleFunc(prefix+"/restaurants", s.handleRestaurants)
+
+		// Progressive search results as Server-Sent Events
+		s.mux.HandleFunc(prefix+"/restaurants/stream", s.handleRestaurantsStream)
+
+		// Places along a route (encoded polyline or GeoJSON LineString)
+		s.mux.HandleFunc(prefix+"/route", s.handleRouteSearch)
+
+		// Place details (Google place ID or osm/{type}/{id}), fetched on demand
+		s.mux.HandleFunc(prefix+"/places/", s.handlePlaceDetails)
+
+		// Google Places photos with permanent disk storage
+		s.mux.HandleFunc(prefix+"/photo", s.handlePhoto)
+
+		// Google spend per SKU and day, plus budget status
+		s.mux.HandleFunc(prefix+"/costs", s.handleCosts)
+
+		// Provider health, Telegram liveness, cache and photo store stats
+		s.mux.HandleFunc(prefix+"/status", s.handleStatus)
+	}
+
+	s.mux.HandleFunc(apiV1Prefix+"/openapi.json", s.handleOpenAPI)
+}
+
+// trimAPIPrefix strips the versioned or legacy API prefix from a request path
+func trimAPIPrefix(path string) string {
+	if rest, ok := strings.CutPrefix(path, apiV1Prefix+"/"); ok {
+		return "/" + rest
+	}
+	return strings.TrimPrefix(path, apiLegacyPrefix)
+}
+
+// handleOpenAPI serves the OpenAPI document (public, no API key needed)
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !s.cors.Apply(w, r, "GET, OPTIONS") {
+		return
+	}
+
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(openAPISpec)
+}
+
+// handleRestaurants serves /api/v1/restaurants: a search by coordinates, place
+// name or area (GET query parameters or POST JSON body), or a cursor page
+func (s *Server) handleRestaurants(w http.ResponseWriter, r *http.Request) {
+	// Enable CORS for allowed origins
+	if !s.cors.Apply(w, r, "GET, POST, OPTIONS") {
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "POST" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Partners are rate limited per key, anonymous clients per IP
+	clientKey, ok := s.authorizeAPIRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Get lat/lon/categories/keyword from query params or JSON body
+	var params SearchParams
+	var page, limit int = 1, 20 // Default pagination: page 1, 20 items per page
+	var filters ResultFilters
+	var format string
+
+	if r.Method == "GET" {
+		// A cursor pages through an existing snapshot; no other parameters are needed
+		if token := r.URL.Query().Get("cursor"); token != "" {
+			serveCursorPage(w, s.snapshots, token)
+			return
+		}
+
+		var apiErr *APIError
+		params, filters, page, limit, apiErr = parseSearchQuery(r.URL.Query())
+		if apiErr != nil {
+			writeAPIError(w, http.StatusBadRequest, apiErr.Code, apiErr.Message)
+			return
+		}
+		format = r.URL.Query().Get("format")
+	} else {
+		var req struct {
+			Lat        float64         `json:"lat"`
+			Lon        float64         `json:"lon"`
+			Query      string          `json:"q"`          // place name or address, used when lat/lon are omitted
+			BBox       []float64       `json:"bbox"`       // [minLon, minLat, maxLon, maxLat]
+			Polygon    json.RawMessage `json:"polygon"`    // GeoJSON Polygon (or Feature) to search within
+			Categories []string        `json:"categories"` // array of categories
+			Category   string          `json:"category"`   // legacy single category
+			Keyword    string          `json:"keyword"`
+			Page       int             `json:"page"`   // pagination: page number
+			Limit      int             `json:"limit"`  // pagination: items per page
+			Cursor     string          `json:"cursor"` // pagination: opaque cursor from a previous response
+			Format     string          `json:"format"` // json (default), geojson, csv, kml or gpx
+
+			// Sort and filters (same names as the query parameters)
+			Sort          string  `json:"sort"`
+			MinRating     float64 `json:"min_rating"`
+			MinReviews    int     `json:"min_reviews"`
+			PriceMin      int     `json:"price_min"`
+			PriceMax      int     `json:"price_max"`
+			MaxDistance   float64 `json:"max_distance"`
+			OpenNow       bool    `json:"open_now"`
+			ExcludeChains bool    `json:"exclude_chains"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON body")
+			return
+		}
+		if req.Cursor != "" {
+			serveCursorPage(w, s.snapshots, req.Cursor)
+			return
+		}
+		params.Lat = req.Lat
+		params.Lon = req.Lon
+		params.Keyword = req.Keyword
+		format = req.Format
+		if len(req.Polygon) > 0 || len(req.BBox) > 0 {
+			var area *SearchArea
+			var err error
+			if len(req.Polygon) > 0 {
+				area, err = parseGeoJSONPolygon(req.Polygon)
+			} else if len(req.BBox) != 4 {
+				err = fmt.Errorf("bbox must be [minLon, minLat, maxLon, maxLat]")
+			} else {
+				area, err = newBBoxArea(req.BBox[0], req.BBox[1], req.BBox[2], req.BBox[3])
+			}
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidArea, err.Error())
+				return
+			}
+			params.setArea(area)
+		} else if req.Lat == 0 && req.Lon == 0 {
+			params.Place = strings.TrimSpace(req.Query)
+			if len(params.Place) > maxGeocodeQueryLength {
+				writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("q must be at most %d characters", maxGeocodeQueryLength))
+				return
+			}
+		}
+
+		// Parse pagination from JSON
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 && req.Limit <= 100 {
+			limit = req.Limit
+		}
+
+		// Support both array and single category
+		if len(req.Categories) > 0 {
+			for _, c := range req.Categories {
+				params.Categories = append(params.Categories, FoodCategory(c))
+			}
+		} else if req.Category != "" && req.Category != "all" {
+			params.Categories = []FoodCategory{FoodCategory(req.Category)}
+		}
+
+		filters = ResultFilters{
+			Sort:          strings.ToLower(strings.TrimSpace(req.Sort)),
+			MinRating:     req.MinRating,
+			MinReviews:    req.MinReviews,
+			PriceMin:      req.PriceMin,
+			PriceMax:      req.PriceMax,
+			MaxDistance:   req.MaxDistance,
+			OpenNow:       req.OpenNow,
+			ExcludeChains: req.ExcludeChains,
+		}
+		if err := filters.validate(); err != nil {
+			writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+			return
+		}
+	}
+
+	format, err := validExportFormat(format)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	// Resolve q to coordinates first
+	location, ok := s.resolveSearchLocation(r.Context(), w, &params)
+	if !ok {
+		return
+	}
+
+	if !validCoordinates(params.Lat, params.Lon) {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidCoordinates, "lat must be within [-90, 90] and lon within [-180, 180]")
+		return
+	}
+
+	s.metrics.SearchStarted(EntryHTTP)
+
+	// Get all restaurants (from cache or fresh search)
+	var allRestaurants []Restaurant
+	var stats SearchStats
+	var source cacheVersion
+
+	// Check cache first (only for point searches without keyword filter for now)
+	if params.cacheable() {
+		if cached, cachedStats, version, found := s.cache.GetVersioned(params.Lat, params.Lon); found {
+			ctxLogger(r.Context()).Info("API cache hit", logKeyLat, params.Lat, logKeyLon, params.Lon)
+			allRestaurants = cached
+			stats = *cachedStats
+			source = version
+		}
+	}
+
+	// If not cached, fetch fresh results
+	if allRestaurants == nil {
+		if !s.allowSearch(w, r, clientKey, params) {
+			return
+		}
+		result, err := s.search.Search(r.Context(), params)
+		if err != nil {
+			ctxLogger(r.Context()).Error("Error finding restaurants", "error", err)
+			budgetExceeded, _ := s.costs.BudgetExceeded()
+			writeSearchError(w, s.apiProvider, budgetExceeded, err)
+			return
+		}
+		allRestaurants = result.Restaurants
+		stats = result.Stats
+
+		// Cache the results (only for point searches without keyword filter).
+		// A search cut short by the client going away is partial, so it isn't cached.
+		if params.cacheable() && r.Context().Err() == nil {
+			source = s.cache.Set(params.Lat, params.Lon, allRestaurants, stats)
+		} else {
+			// Uncached searches get a snapshot of their own
+			source = cacheVersion{Key: fmt.Sprintf("uncached:%d", s.now().UnixNano())}
+		}
+	}
+
+	// Apply sort and filters to the merged list before paginating
+	allRestaurants = applyResultFilters(allRestaurants, filters)
+
+	// Keep the list as a snapshot so cursors page through a consistent result set
+	key := snapshotKey(source, params, filters)
+	s.snapshots.Put(key, source.Version, allRestaurants, stats, filters)
+
+	// Exports contain the whole filtered list; pagination only applies to JSON
+	if format != FormatJSON {
+		meta := ExportMetadata{
+			GeneratedAt: s.now(),
+			Lat:         params.Lat,
+			Lon:         params.Lon,
+			Location:    location,
+			Filters:     filters,
+			Stats:       stats,
+		}
+		if err := writeExport(w, format, allRestaurants, meta); err != nil {
+			ctxLogger(r.Context()).Error("[EXPORT] Error writing export", "format", format, "error", err)
+		}
+		return
+	}
+
+	page = clampPage(page, limit, len(allRestaurants))
+	paginatedResult := paginateResults(key, source.Version, allRestaurants, stats, filters, (page-1)*limit, limit)
+	paginatedResult.Location = location
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(paginatedResult)
+}
+
+// allowSearch charges a fresh search for params against the client's search
+// limit, writing the error response if it is not allowed
+func (s *Server) allowSearch(w http.ResponseWriter, r *http.Request, clientKey string, params SearchParams) bool {
+	cost := s.searchCost(params)
+	if ok, retryAfter := s.limits.AllowSearches(clientKey, cost); !ok {
+		ctxLogger(r.Context()).Warn("[RATELIMIT] Search limit exceeded", "client", clientKey, "searches", cost)
+		writeRateLimited(w, retryAfter)
+		return false
+	}
+	return true
+}
+
+// handlePhoto proxies Google Places photos with permanent disk storage.
+// Photos are saved indefinitely to avoid repeated API costs
+// Google Places Photo API pricing: $7.00 per 1,000 requests = $0.007 (0.7 cents) per photo
+//
+// IMPORTANT: Photos are stored by place_id (stable restaurant identifier), NOT by photo_reference
+// This ensures we never call the API twice for the same restaurant, even if Google
+// returns different photo_references over time.
+func (s *Server) handlePhoto(w http.ResponseWriter, r *http.Request) {
+	if !s.cors.Apply(w, r, "GET, OPTIONS") {
+		return
+	}
+
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Count the response by the X-Photo-Source it was served with
+	defer func() { s.metrics.PhotoServed(w.Header().Get("X-Photo-Source")) }()
+
+	// Stored photos and placeholders are free; only a Google fetch counts
+	// against the client's request limit, so a page of thumbnails still loads
+	clientKey, ok := s.authenticateAPIRequest(w, r)
+	if !ok {
+		return
+	}
+
+	photoRef := r.URL.Query().Get("photo_reference")
+	placeID := r.URL.Query().Get("place_id")
+
+	// Require place_id for proper caching by restaurant
+	if placeID == "" {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeMissingParameter, "place_id parameter is required")
+		return
+	}
+
+	// Additional photos from /api/places/{id} are stored next to the main one
+	photoIndex := 0
+	if v := r.URL.Query().Get("index"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 9 {
+			writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "index must be between 0 and 9")
+			return
+		}
+		photoIndex = n
+	}
+
+	// Handle generic placeholder photo request
+	// This is used for restaurants without photos or with low rating/few reviews
+	// to avoid unnecessary Google API calls ($0.007 per photo)
+	if photoRef == genericPhotoReference || photoRef == "" {
+		ctxLogger(r.Context()).Debug("[PHOTO][GENERIC] Serving generic placeholder image", "place_id", placeID)
+		placeholderData, err := getOrCreateGenericPlaceholder(s.photos)
+		if err != nil {
+			ctxLogger(r.Context()).Error("[PHOTO][GENERIC] Failed to generate placeholder", "error", err)
+			writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate placeholder image")
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("X-Photo-Source", "generic")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Photo-Source")
+		w.Write(placeholderData)
+		return
+	}
+
+	// Use place_id as filename - this is stable and unique per restaurant
+	// This ensures we only fetch ONE photo per restaurant, ever
+	// Sanitize place_id to be safe for filesystem (remove any path separators)
+	safeePlaceID := strings.ReplaceAll(placeID, "/", "_")
+	safeePlaceID = strings.ReplaceAll(safeePlaceID, "\\", "_")
+	filename := safeePlaceID + ".jpg"
+	if photoIndex > 0 {
+		filename = fmt.Sprintf("%s_%d.jpg", safeePlaceID, photoIndex)
+	}
+
+	// Check if photo is already stored (permanent storage)
+	if data, modTime, ok := s.photos.Get(filename); ok {
+		// Serve from disk - FREE, no API cost!
+		ctxLogger(r.Context()).Debug("[PHOTO][DISK] Serving from disk", "file", filename, "bytes", len(data))
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("X-Photo-Source", "disk")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Photo-Source")
+		http.ServeContent(w, r, filename, modTime, bytes.NewReader(data))
+		return
+	}
+
+	// Photo not on disk - need to fetch from Google API
+	ctxLogger(r.Context()).Debug("[PHOTO][API] Not on disk, fetching from Google", "file", filename)
+
+	if s.mapsAPIKey == "" {
+		writeAPIError(w, http.StatusServiceUnavailable, ErrCodeNotConfigured, "Google Maps API key not configured")
+		return
+	}
+	if !s.allowRequest(w, r, clientKey) {
+		return
+	}
+
+	// Spend cap reached - serve the placeholder without saving it, so the
+	// real photo is fetched once the budget resets
+	if exceeded, reason := s.costs.BudgetExceeded(); exceeded {
+		ctxLogger(r.Context()).Debug("[PHOTO][COSTS] Serving generic placeholder", "reason", reason, "file", filename)
+		serveGenericPlaceholderOnError(w, s.photos)
+		return
+	}
+
+	// Fetch photo from Google Places Photo API
+	// Cost: $7.00 per 1,000 requests = $0.007 per request
+	query := url.Values{"maxwidth": {"400"}, "photoreference": {photoRef}, "key": {s.mapsAPIKey}}
+	req, err := http.NewRequestWithContext(r.Context(), "GET", s.photoAPIURL+"?"+query.Encode(), nil)
+	if err != nil {
+		ctxLogger(r.Context()).Error("[PHOTO][API] Failed to create request", "file", filename, "error", err)
+		serveGenericPlaceholderOnError(w, s.photos)
+		return
+	}
+
+	resp, err := s.photoClient.Do(req)
+	if err != nil && r.Context().Err() != nil {
+		// The client went away, so the photo may well be fine next time
+		ctxLogger(r.Context()).Debug("[PHOTO][API] Fetch canceled", "file", filename, "error", err)
+		return
+	}
+	if err != nil {
+		ctxLogger(r.Context()).Warn("[PHOTO][API] Fetch failed, saving generic placeholder", "file", filename, "error", err)
+		// Save generic placeholder so we don't keep trying this photo reference
+		saveGenericPlaceholderForFailedPhoto(s.photos, filename)
+		serveGenericPlaceholderOnError(w, s.photos)
+		return
+	}
+	defer resp.Body.Close()
+	s.costs.Record(SKUPlacePhoto)
+
+	if resp.StatusCode != http.StatusOK {
+		ctxLogger(r.Context()).Warn("[PHOTO][API] Fetch failed, saving generic placeholder", "file", filename, "status", resp.StatusCode)
+		// Save generic placeholder so we don't keep trying this photo reference
+		saveGenericPlaceholderForFailedPhoto(s.photos, filename)
+		serveGenericPlaceholderOnError(w, s.photos)
+		return
+	}
+
+	// Read the photo into memory
+	photoData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ctxLogger(r.Context()).Warn("[PHOTO][API] Failed to read photo, saving generic placeholder", "file", filename, "error", err)
+		saveGenericPlaceholderForFailedPhoto(s.photos, filename)
+		serveGenericPlaceholderOnError(w, s.photos)
+		return
+	}
+
+	// Check if we got actual image data (sometimes API returns empty or error HTML)
+	if len(photoData) < 1000 {
+		ctxLogger(r.Context()).Warn("[PHOTO][API] Photo too small to be valid, saving generic placeholder", "file", filename, "bytes", len(photoData))
+		saveGenericPlaceholderForFailedPhoto(s.photos, filename)
+		serveGenericPlaceholderOnError(w, s.photos)
+		return
+	}
+
+	ctxLogger(r.Context()).Debug("[PHOTO][API] Fetched from Google", "file", filename, "bytes", len(photoData), "cost_usd", 0.007)
+
+	// Save to disk permanently (don't fail request if this doesn't work)
+	if err := s.photos.Put(filename, photoData); err != nil {
+		ctxLogger(r.Context()).Error("[PHOTO][DISK] Failed to save photo", "file", filename, "error", err)
+	} else {
+		ctxLogger(r.Context()).Debug("[PHOTO][DISK] Saved", "file", filename, "bytes", len(photoData))
+	}
+
+	// Serve the photo
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "image/jpeg"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("X-Photo-Source", "api")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Photo-Source")
+	w.Write(photoData)
+}
+
+// handleCosts serves Google spend per SKU and day, plus budget status
+func (s *Server) handleCosts(w http.ResponseWriter, r *http.Request) {
+	if !s.cors.Apply(w, r, "GET, OPTIONS") {
+		return
+	}
+
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := s.authorizeAPIRequest(w, r); !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.costs.Report())
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAPI returns a server without providers or Telegram and its mux
+func newTestAPI(t *testing.T) (*Server, *http.ServeMux) {
+	t.Helper()
+	server := newTestServer(t, &fakeSearch{})
+	return server, server.mux
+}
+
+// openAPIDoc is the parsed embedded OpenAPI document
+type openAPIDoc map[string]interface{}
+
+func loadOpenAPI(t *testing.T) openAPIDoc {
+	t.Helper()
+	var doc openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return doc
+}
+
+// resolve follows a local "#/components/schemas/..." reference
+func (doc openAPIDoc) resolve(schema map[string]interface{}) map[string]interface{} {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema
+	}
+	var node interface{} = map[string]interface{}(doc)
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		node = node.(map[string]interface{})[part]
+	}
+	return doc.resolve(node.(map[string]interface{}))
+}
+
+// validate checks value against the subset of JSON Schema used in openapi.json
+func (doc openAPIDoc) validate(schema map[string]interface{}, value interface{}, path string) []string {
+	schema = doc.resolve(schema)
+	var errs []string
+
+	for _, sub := range asSlice(schema["allOf"]) {
+		errs = append(errs, doc.validate(sub.(map[string]interface{}), value, path)...)
+	}
+	if value == nil {
+		if schema["nullable"] == true || schema["type"] == nil {
+			return errs
+		}
+		return append(errs, path+": null is not allowed")
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: want object, got %T", path, value))
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for _, name := range asSlice(schema["required"]) {
+			if _, found := obj[name.(string)]; !found {
+				errs = append(errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		for name, v := range obj {
+			if prop, found := props[name]; found {
+				errs = append(errs, doc.validate(prop.(map[string]interface{}), v, path+"."+name)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					errs = append(errs, fmt.Sprintf("%s: undocumented property %q", path, name))
+				}
+			case map[string]interface{}:
+				errs = append(errs, doc.validate(extra, v, path+"."+name)...)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: want array, got %T", path, value))
+		}
+		for i, item := range items {
+			errs = append(errs, doc.validate(schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: want string, got %T", path, value))
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %q is not a date-time", path, s))
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: want %s, got %T", path, schema["type"], value))
+		}
+		if _, err := n.Int64(); err != nil && schema["type"] == "integer" {
+			errs = append(errs, fmt.Sprintf("%s: %s is not an integer", path, n))
+		}
+		f, _ := n.Float64()
+		if min, ok := schema["minimum"].(float64); ok && f < min {
+			errs = append(errs, fmt.Sprintf("%s: %s is below the minimum %v", path, n, min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && f > max {
+			errs = append(errs, fmt.Sprintf("%s: %s is above the maximum %v", path, n, max))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, fmt.Sprintf("%s: want boolean, got %T", path, value))
+		}
+	}
+
+	if enum := asSlice(schema["enum"]); enum != nil {
+		found := false
+		for _, allowed := range enum {
+			if allowed == value {
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+	return errs
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+// checkResponse validates a recorded response against the documented response
+// for the operation (status code, content type and, for JSON, the body schema)
+func checkResponse(t *testing.T, doc openAPIDoc, specPath, method string, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	operation, ok := doc["paths"].(map[string]interface{})[specPath].(map[string]interface{})[strings.ToLower(method)].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s %s is not documented", method, specPath)
+	}
+	response, ok := operation["responses"].(map[string]interface{})[fmt.Sprint(rec.Code)].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s %s: status %d is not documented (body %s)", method, specPath, rec.Code, rec.Body.String())
+	}
+
+	mediaType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("%s %s: bad Content-Type %q", method, specPath, rec.Header().Get("Content-Type"))
+	}
+	content, ok := response["content"].(map[string]interface{})[mediaType].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s %s: content type %s is not documented for status %d", method, specPath, mediaType, rec.Code)
+	}
+	if mediaType != "application/json" {
+		return
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(rec.Body.Bytes()))
+	dec.UseNumber()
+	var body interface{}
+	if err := dec.Decode(&body); err != nil {
+		t.Fatalf("%s %s: invalid JSON body: %v", method, specPath, err)
+	}
+	for _, e := range doc.validate(content["schema"].(map[string]interface{}), body, "body") {
+		t.Errorf("%s %s (%d): %s", method, specPath, rec.Code, e)
+	}
+}
+
+func serve(h http.Handler, method, target string, body string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body != "" {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOpenAPIDocumentServed(t *testing.T) {
+	_, mux := newTestAPI(t)
+	doc := loadOpenAPI(t)
+
+	rec := serve(mux, "GET", "/api/v1/openapi.json", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !bytes.Equal(rec.Body.Bytes(), openAPISpec) {
+		t.Error("served document differs from the embedded spec")
+	}
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("openapi = %v", doc["openapi"])
+	}
+
+	// Every $ref must resolve
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := n["$ref"].(string); ok {
+				name := strings.TrimPrefix(ref, "#/components/schemas/")
+				if _, found := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})[name]; !found {
+					t.Errorf("unresolved $ref %q", ref)
+				}
+			}
+			for _, v := range n {
+				walk(v)
+			}
+		case []interface{}:
+			for _, v := range n {
+				walk(v)
+			}
+		}
+	}
+	walk(map[string]interface{}(doc))
+}
+
+func TestDocumentedPathsAreRouted(t *testing.T) {
+	_, mux := newTestAPI(t)
+	doc := loadOpenAPI(t)
+
+	for specPath, item := range doc["paths"].(map[string]interface{}) {
+		concrete := strings.ReplaceAll(specPath, "{placeId}", "osm/node/1")
+		prefixes := []string{apiV1Prefix, apiLegacyPrefix}
+		// Paths with their own servers (the probes) are served from the root
+		if servers, ok := item.(map[string]interface{})["servers"].([]interface{}); ok {
+			prefixes = []string{strings.TrimSuffix(servers[0].(map[string]interface{})["url"].(string), "/")}
+		}
+		for _, prefix := range prefixes {
+			if prefix == apiLegacyPrefix && specPath == "/openapi.json" {
+				continue
+			}
+			req := httptest.NewRequest("OPTIONS", prefix+concrete, nil)
+			if _, pattern := mux.Handler(req); pattern == "" {
+				t.Errorf("%s%s is documented but not routed", prefix, concrete)
+			}
+		}
+	}
+}
+
+func TestRestaurantsResponsesMatchSpec(t *testing.T) {
+	bot, mux := newTestAPI(t)
+	doc := loadOpenAPI(t)
+
+	open := true
+	restaurants := []Restaurant{
+		{Name: "Trattoria", Rating: 4.6, ReviewCount: 320, PriceLevel: 2, Type: "restaurant", Latitude: 52.5201, Longitude: 13.4051, Address: "Mitte", Distance: 0.05, PlaceID: "ChIJ1234567890", OpenNow: &open},
+		{Name: "Café Kranz", Rating: 0, Type: "cafe", Latitude: 52.5210, Longitude: 13.4060, Address: "Mitte", Distance: 0.2, Brand: "Kranz", OSMID: "node/42"},
+		{Name: "Imbiss", Rating: 4.1, ReviewCount: 12, Latitude: 52.5190, Longitude: 13.4040, Address: "Mitte", Distance: 0.3},
+	}
+	stats := SearchStats{OSMResultsTotal: 3, TotalBeforeDedup: 3, TotalAfterDedup: 3,
+		Providers: []ProviderOutcome{{Provider: "osm", Status: OutcomeOK, LatencyMs: 120, Results: 3}}}
+	bot.cache.Set(52.52, 13.405, restaurants, stats)
+
+	for _, prefix := range []string{apiV1Prefix, apiLegacyPrefix} {
+		rec := serve(mux, "GET", prefix+"/restaurants?lat=52.52&lon=13.405&limit=2&sort=distance", "")
+		checkResponse(t, doc, "/restaurants", "GET", rec)
+
+		var page PaginatedSearchResult
+		json.Unmarshal(rec.Body.Bytes(), &page)
+		if len(page.Restaurants) != 2 || page.Pagination.NextCursor == "" {
+			t.Fatalf("%s: unexpected page %+v", prefix, page.Pagination)
+		}
+
+		rec = serve(mux, "GET", prefix+"/restaurants?cursor="+url.QueryEscape(page.Pagination.NextCursor), "")
+		checkResponse(t, doc, "/restaurants", "GET", rec)
+	}
+
+	rec := serve(mux, "POST", "/api/v1/restaurants", `{"lat":52.52,"lon":13.405,"sort":"rating","open_now":true}`)
+	checkResponse(t, doc, "/restaurants", "POST", rec)
+
+	rec = serve(mux, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405&format=geojson", "")
+	checkResponse(t, doc, "/restaurants", "GET", rec)
+	rec = serve(mux, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405&format=csv", "")
+	checkResponse(t, doc, "/restaurants", "GET", rec)
+
+	// Errors use the documented envelope
+	errorCases := []struct {
+		method, target, body string
+		status               int
+	}{
+		{"GET", "/api/v1/restaurants", "", http.StatusBadRequest},
+		{"GET", "/api/v1/restaurants?lat=95&lon=13", "", http.StatusBadRequest},
+		{"GET", "/api/v1/restaurants?lat=52.52&lon=13.405&sort=random", "", http.StatusBadRequest},
+		{"GET", "/api/v1/restaurants?lat=52.52&lon=13.405&format=xls", "", http.StatusBadRequest},
+		{"GET", "/api/v1/restaurants?cursor=bogus", "", http.StatusBadRequest},
+		{"GET", "/api/v1/restaurants?bbox=1,2,3", "", http.StatusBadRequest},
+		{"POST", "/api/v1/restaurants", `{"lat":`, http.StatusBadRequest},
+		{"POST", "/api/v1/restaurants", `{"q":"Alexanderplatz"}`, http.StatusServiceUnavailable},
+	}
+	for _, tc := range errorCases {
+		rec := serve(mux, tc.method, tc.target, tc.body)
+		if rec.Code != tc.status {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.target, rec.Code, tc.status)
+			continue
+		}
+		checkResponse(t, doc, "/restaurants", tc.method, rec)
+	}
+}
+
+func TestOtherResponsesMatchSpec(t *testing.T) {
+	bot, mux := newTestAPI(t)
+	doc := loadOpenAPI(t)
+
+	open := false
+	bot.details.Set(&PlaceDetails{
+		ID: "osm/node/42", Source: "osm", Name: "Café Kranz", Latitude: 52.521, Longitude: 13.406,
+		Website: "https://example.com", MapsURL: "https://www.openstreetmap.org/node/42", Cuisine: "coffee_shop",
+		OpenNow: &open, OpeningHours: []string{"Mo-Fr 08:00-18:00"}, Tags: map[string]string{"amenity": "cafe"},
+		FetchedAt: time.Now().UTC(),
+	}, "osm/node/42")
+	bot.details.Set(&PlaceDetails{
+		ID: "ChIJ1234567890", Source: "google", Name: "Trattoria", Latitude: 52.5201, Longitude: 13.4051,
+		Rating: 4.6, ReviewCount: 320, PriceLevel: 2, Types: []string{"restaurant"},
+		Reviews:   []PlaceReview{{Author: "Ana", Rating: 5, Text: "Great", Time: 1700000000}},
+		Photos:    []PlacePhoto{{Reference: "ref", Width: 400, Height: 300, URL: placePhotoURL("ChIJ1234567890", "ref", 0)}},
+		FetchedAt: time.Now().UTC(),
+	}, "ChIJ1234567890")
+	bot.costs.Record(SKUNearbySearch)
+	bot.providerHealth.Record("osm", 120*time.Millisecond, nil)
+	bot.providerHealth.Record("osm", 15*time.Second, context.DeadlineExceeded)
+
+	cases := []struct {
+		method, target, specPath, body string
+		status                         int
+	}{
+		{"GET", "/api/v1/places/osm/node/42", "/places/{placeId}", "", http.StatusOK},
+		{"GET", "/api/places/ChIJ1234567890", "/places/{placeId}", "", http.StatusOK},
+		{"GET", "/api/v1/places/osm/street/1", "/places/{placeId}", "", http.StatusBadRequest},
+		{"GET", "/api/v1/places/ChIJ0000000000", "/places/{placeId}", "", http.StatusServiceUnavailable},
+		{"GET", "/api/v1/costs", "/costs", "", http.StatusOK},
+		{"GET", "/api/v1/status", "/status", "", http.StatusOK},
+		{"GET", "/healthz", "/healthz", "", http.StatusOK},
+		{"GET", "/readyz", "/readyz", "", http.StatusOK},
+		{"GET", "/api/v1/photo", "/photo", "", http.StatusBadRequest},
+		{"GET", "/api/v1/photo?place_id=x&photo_reference=ref&index=12", "/photo", "", http.StatusBadRequest},
+		{"GET", "/api/v1/route", "/route", "", http.StatusBadRequest},
+		{"POST", "/api/v1/route", "/route", `{"line":{"type":"Point","coordinates":[13.4,52.5]}}`, http.StatusBadRequest},
+		{"GET", "/api/v1/restaurants/stream?lat=500&lon=0", "/restaurants/stream", "", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rec := serve(mux, tc.method, tc.target, tc.body)
+		if rec.Code != tc.status {
+			t.Errorf("%s %s: status %d, want %d (body %s)", tc.method, tc.target, rec.Code, tc.status, rec.Body.String())
+			continue
+		}
+		checkResponse(t, doc, tc.specPath, tc.method, rec)
+	}
+}
+
+func TestUnauthorizedResponseMatchesSpec(t *testing.T) {
+	bot, mux := newTestAPI(t)
+	doc := loadOpenAPI(t)
+	bot.auth = NewAPIKeyAuth([]APIKey{{Name: "partner", Key: "secret"}}, false)
+
+	rec := serve(mux, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, want 401", rec.Code)
+	}
+	checkResponse(t, doc, "/restaurants", "GET", rec)
+
+	// The spec itself stays public
+	if rec := serve(mux, "GET", "/api/v1/openapi.json", ""); rec.Code != http.StatusOK {
+		t.Errorf("openapi.json status %d, want 200", rec.Code)
+	}
+}
+
+func TestAreaSearchWithDefaultLimits(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(3)}
+	server := newTestServer(t, search)
+	limits, err := NewClientRateLimits(DefaultConfig().RateLimits)
+	if err != nil {
+		t.Fatalf("NewClientRateLimits: %v", err)
+	}
+	server.limits = limits
+	server.apiProvider = "google"
+
+	// A 6×6 km viewport needs all four tiles when nothing limits them
+	viewport := &SearchArea{Kind: "bbox", MinLat: 52.50, MinLon: 13.35, MaxLat: 52.554, MaxLon: 13.44}
+	if n := len(viewport.Tiles(maxAreaTiles)); n != maxAreaTiles {
+		t.Fatalf("viewport needs %d tiles, want %d", n, maxAreaTiles)
+	}
+	rec := serve(server, "GET", "/api/v1/restaurants?bbox=13.35,52.50,13.44,52.554", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("4-tile area with default limits: %d %s", rec.Code, rec.Body.String())
+	}
+	if cost := server.searchCost(SearchParams{Area: viewport}); cost != defaultSearchBurst {
+		t.Errorf("viewport costs %d searches, want the burst of %d", cost, defaultSearchBurst)
+	}
+
+	// The largest allowed area is searchable too, once the client's tokens refill
+	server.limits = &ClientRateLimits{cached: limits.cached, search: NewRateLimiter(defaultSearchRatePerMinute, defaultSearchBurst)}
+	rec = serve(server, "GET", "/api/v1/restaurants?bbox=13.25,52.42,13.54,52.59", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("20 km area with default limits: %d %s", rec.Code, rec.Body.String())
+	}
+	if search.callCount() != 2 {
+		t.Errorf("%d searches, want 2", search.callCount())
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxAreaSpanMeters   = 20000 // Longest side of an area's bounding box
+	maxAreaTiles        = 4     // Google searches per area (each runs the full category search), fewer if the search burst is lower
+	maxPolygonVertices  = 500
+	minPolygonArea      = 1e-10 // square degrees, about 1 m²; less is rounding on collinear vertices
+	maxGoogleRadius     = 50000 // Google NearbySearch/TextSearch radius limit
+	metersPerDegreeLat  = 111320.0
+	defaultSearchRadius = 2000 // meters around a point
+)
+
+// LatLon is a WGS84 coordinate
+type LatLon struct {
+	Lat float64
+	Lon float64
+}
+
+// SearchArea is a bounding box or polygon to search instead of a radius around a point
+type SearchArea struct {
+	Kind                           string   // "bbox" or "polygon"
+	Ring                           []LatLon // Polygon outer ring (closed), nil for bboxes
+	MinLat, MinLon, MaxLat, MaxLon float64
+}
+
+// areaTile is one circular Google search covering part of an area
+type areaTile struct {
+	Lat, Lon float64
+	Radius   int // meters
+}
+
+// parseBBox parses "minLon,minLat,maxLon,maxLat"
+func parseBBox(value string) (*SearchArea, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+	var coords [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+		}
+		coords[i] = v
+	}
+	return newBBoxArea(coords[0], coords[1], coords[2], coords[3])
+}
+
+// newBBoxArea validates and builds a bounding box area
+func newBBoxArea(minLon, minLat, maxLon, maxLat float64) (*SearchArea, error) {
+	if !validCoordinates(minLat, minLon) || !validCoordinates(maxLat, maxLon) {
+		return nil, fmt.Errorf("bbox coordinates out of range")
+	}
+	if minLat >= maxLat || minLon >= maxLon {
+		return nil, fmt.Errorf("bbox min values must be less than max values (areas crossing the antimeridian are not supported)")
+	}
+	area := &SearchArea{Kind: "bbox", MinLat: minLat, MinLon: minLon, MaxLat: maxLat, MaxLon: maxLon}
+	return area, area.checkSize()
+}
+
+// parseGeoJSONPolygon parses a GeoJSON Polygon geometry or a Feature containing one.
+// Only the outer ring is used; holes are ignored.
+func parseGeoJSONPolygon(raw json.RawMessage) (*SearchArea, error) {
+	var geo struct {
+		Type        string          `json:"type"`
+		Coordinates [][][]float64   `json:"coordinates"`
+		Geometry    json.RawMessage `json:"geometry"`
+	}
+	if err := json.Unmarshal(raw, &geo); err != nil {
+		return nil, fmt.Errorf("polygon must be a GeoJSON Polygon")
+	}
+	if geo.Type == "Feature" {
+		if len(geo.Geometry) == 0 {
+			return nil, fmt.Errorf("polygon feature has no geometry")
+		}
+		return parseGeoJSONPolygon(geo.Geometry)
+	}
+	if geo.Type != "Polygon" || len(geo.Coordinates) == 0 {
+		return nil, fmt.Errorf("polygon must be a GeoJSON Polygon")
+	}
+
+	outer := geo.Coordinates[0]
+	if len(outer) > maxPolygonVertices {
+		return nil, fmt.Errorf("polygon must have at most %d vertices", maxPolygonVertices)
+	}
+	area := &SearchArea{Kind: "polygon", MinLat: 90, MinLon: 180, MaxLat: -90, MaxLon: -180}
+	for _, position := range outer {
+		if len(position) < 2 || !validCoordinates(position[1], position[0]) {
+			return nil, fmt.Errorf("polygon positions must be [lon, lat] within range")
+		}
+		p := LatLon{Lat: position[1], Lon: position[0]}
+		area.Ring = append(area.Ring, p)
+		area.MinLat, area.MaxLat = math.Min(area.MinLat, p.Lat), math.Max(area.MaxLat, p.Lat)
+		area.MinLon, area.MaxLon = math.Min(area.MinLon, p.Lon), math.Max(area.MaxLon, p.Lon)
+	}
+	if len(area.Ring) > 0 && area.Ring[0] != area.Ring[len(area.Ring)-1] {
+		area.Ring = append(area.Ring, area.Ring[0])
+	}
+	if len(area.Ring) < 4 {
+		return nil, fmt.Errorf("polygon must have at least 3 distinct vertices")
+	}
+	if area.MaxLon-area.MinLon > 180 {
+		return nil, fmt.Errorf("polygons crossing the antimeridian are not supported")
+	}
+	// Collinear vertices have a bounding box but enclose nothing
+	if area.MinLat >= area.MaxLat || area.MinLon >= area.MaxLon || ringArea(area.Ring) < minPolygonArea {
+		return nil, fmt.Errorf("polygon has no area")
+	}
+	return area, area.checkSize()
+}
+
+// ringArea returns the area of a closed ring in square degrees (shoelace formula)
+func ringArea(ring []LatLon) float64 {
+	sum := 0.0
+	for i := 1; i < len(ring); i++ {
+		sum += ring[i-1].Lon*ring[i].Lat - ring[i].Lon*ring[i-1].Lat
+	}
+	return math.Abs(sum) / 2
+}
+
+// checkSize rejects areas too large to search
+func (a *SearchArea) checkSize() error {
+	width, height := a.spanMeters()
+	if width > maxAreaSpanMeters || height > maxAreaSpanMeters {
+		return fmt.Errorf("area too large: sides must be at most %d km", maxAreaSpanMeters/1000)
+	}
+	return nil
+}
+
+// spanMeters returns the approximate width and height of the bounding box
+func (a *SearchArea) spanMeters() (float64, float64) {
+	lat, _ := a.Center()
+	width := (a.MaxLon - a.MinLon) * metersPerDegreeLat * math.Cos(lat*math.Pi/180)
+	height := (a.MaxLat - a.MinLat) * metersPerDegreeLat
+	return width, height
+}
+
+// Center returns the center of the bounding box
+func (a *SearchArea) Center() (float64, float64) {
+	return (a.MinLat + a.MaxLat) / 2, (a.MinLon + a.MaxLon) / 2
+}
+
+// BoundingRadius returns the radius in meters of the circle around Center that covers the area
+func (a *SearchArea) BoundingRadius() int {
+	width, height := a.spanMeters()
+	return int(math.Ceil(math.Hypot(width, height) / 2))
+}
+
+// Contains reports whether a point lies inside the area
+func (a *SearchArea) Contains(lat, lon float64) bool {
+	if lat < a.MinLat || lat > a.MaxLat || lon < a.MinLon || lon > a.MaxLon {
+		return false
+	}
+	if a.Kind != "polygon" {
+		return true
+	}
+	// Ray casting on the outer ring
+	inside := false
+	for i, j := 0, len(a.Ring)-1; i < len(a.Ring); j, i = i, i+1 {
+		pi, pj := a.Ring[i], a.Ring[j]
+		if (pi.Lat > lat) != (pj.Lat > lat) &&
+			lon < (pj.Lon-pi.Lon)*(lat-pi.Lat)/(pj.Lat-pi.Lat)+pi.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// OverpassFilter returns the Overpass QL spatial filter for the area
+func (a *SearchArea) OverpassFilter() string {
+	if a.Kind != "polygon" {
+		return fmt.Sprintf("(%.6f,%.6f,%.6f,%.6f)", a.MinLat, a.MinLon, a.MaxLat, a.MaxLon)
+	}
+	points := make([]string, 0, len(a.Ring)-1)
+	for _, p := range a.Ring[:len(a.Ring)-1] {
+		points = append(points, fmt.Sprintf("%.6f %.6f", p.Lat, p.Lon))
+	}
+	return fmt.Sprintf(`(poly:"%s")`, strings.Join(points, " "))
+}
+
+// Tiles splits the area into at most maxTiles circular searches. Cells use the
+// default search radius when possible and grow when more tiles would be needed;
+// cells that do not touch a polygon are skipped.
+func (a *SearchArea) Tiles(maxTiles int) []areaTile {
+	width, height := a.spanMeters()
+
+	// Cells of side r*sqrt(2) are exactly covered by a circle of radius r
+	cell := defaultSearchRadius * math.Sqrt2
+	cols := int(math.Max(1, math.Ceil(width/cell)))
+	rows := int(math.Max(1, math.Ceil(height/cell)))
+	if cols*rows > maxTiles {
+		// Pick the grid with the smallest cells that fits in maxTiles
+		best := math.MaxFloat64
+		for c := 1; c <= maxTiles; c++ {
+			r := maxTiles / c
+			if size := math.Hypot(width/float64(c), height/float64(r)); size < best {
+				best, cols, rows = size, c, r
+			}
+		}
+	}
+
+	cellWidth, cellHeight := width/float64(cols), height/float64(rows)
+	radius := int(math.Ceil(math.Hypot(cellWidth, cellHeight) / 2))
+	if radius < defaultSearchRadius/4 {
+		radius = defaultSearchRadius / 4
+	}
+	if radius > maxGoogleRadius {
+		radius = maxGoogleRadius
+	}
+
+	latStep := (a.MaxLat - a.MinLat) / float64(rows)
+	lonStep := (a.MaxLon - a.MinLon) / float64(cols)
+	var tiles []areaTile
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			minLat, minLon := a.MinLat+float64(row)*latStep, a.MinLon+float64(col)*lonStep
+			if !a.touchesCell(minLat, minLon, minLat+latStep, minLon+lonStep) {
+				continue
+			}
+			tiles = append(tiles, areaTile{Lat: minLat + latStep/2, Lon: minLon + lonStep/2, Radius: radius})
+		}
+	}
+	if len(tiles) == 0 {
+		lat, lon := a.Center()
+		tiles = append(tiles, areaTile{Lat: lat, Lon: lon, Radius: a.BoundingRadius()})
+	}
+	return tiles
+}
+
+// touchesCell approximates whether the area overlaps a grid cell: the area contains
+// the cell's center or a corner, or a polygon vertex lies in the cell
+func (a *SearchArea) touchesCell(minLat, minLon, maxLat, maxLon float64) bool {
+	if a.Kind != "polygon" {
+		return true
+	}
+	probes := []LatLon{
+		{(minLat + maxLat) / 2, (minLon + maxLon) / 2},
+		{minLat, minLon}, {minLat, maxLon}, {maxLat, minLon}, {maxLat, maxLon},
+	}
+	for _, p := range probes {
+		if a.Contains(p.Lat, p.Lon) {
+			return true
+		}
+	}
+	for _, p := range a.Ring {
+		if p.Lat >= minLat && p.Lat <= maxLat && p.Lon >= minLon && p.Lon <= maxLon {
+			return true
+		}
+	}
+	return false
+}
+
+// areaTileLimit returns how many Google tiles an area search may use. It never
+// exceeds the searches one request may make, so every area up to
+// maxAreaSpanMeters stays searchable; fewer tiles just get a larger radius.
+func (rb *RestaurantBot) areaTileLimit() int {
+	if max := rb.limits.MaxSearches(); max > 0 && max < maxAreaTiles {
+		return max
+	}
+	return maxAreaTiles
+}
+
+// searchCost returns how many searches params counts as against a client's
+// search limit: an area searched on Google runs the full category search for
+// every tile, unless the spend cap already keeps Google out
+func (rb *RestaurantBot) searchCost(params SearchParams) int {
+	if params.Area == nil || (rb.apiProvider != "google" && rb.apiProvider != "both") {
+		return 1
+	}
+	if exceeded, _ := rb.costs.BudgetExceeded(); exceeded {
+		return 1
+	}
+	return len(params.Area.Tiles(rb.areaTileLimit()))
+}
+
+// clipToArea keeps the restaurants inside the area
+func clipToArea(restaurants []Restaurant, area *SearchArea) []Restaurant {
+	clipped := make([]Restaurant, 0, len(restaurants))
+	for _, r := range restaurants {
+		if area.Contains(r.Latitude, r.Longitude) {
+			clipped = append(clipped, r)
+		}
+	}
+	return clipped
+}
+
+// findNearbyRestaurantsGoogleAreaWithStats runs the point search for every tile of
+// params.Area in parallel and merges the results, clipped to the area
+func (rb *RestaurantBot) findNearbyRestaurantsGoogleAreaWithStats(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	type result struct {
+		searchResult *SearchResult
+		err          error
+		tile         string
+	}
+
+	tiles := params.Area.Tiles(rb.areaTileLimit())
+	ctxLogger(ctx).Debug("[Search] Area search", "tiles", len(tiles), "radius", tiles[0].Radius)
+
+	resultsChan := make(chan result, len(tiles))
+	for i, tile := range tiles {
+		go func(name string, tile areaTile) {
+			tileParams := params
+			tileParams.Area = nil
+			tileParams.Lat, tileParams.Lon, tileParams.Radius = tile.Lat, tile.Lon, tile.Radius
+			sr, err := rb.findNearbyRestaurantsGoogleWithStats(ctx, tileParams)
+			resultsChan <- result{searchResult: sr, err: err, tile: name}
+		}(fmt.Sprintf("tile%d", i+1), tile)
+	}
+
+	var allRestaurants []Restaurant
+	var errs []string
+	stats := SearchStats{}
+	for range tiles {
+		res := <-resultsChan
+		if res.err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", res.tile, res.err))
+			var failure *ProviderFailureError
+			if errors.As(res.err, &failure) {
+				for _, f := range failure.Failures {
+					f.Provider = strings.Replace(f.Provider, "google", "google:"+res.tile, 1)
+					stats.ProviderErrors = append(stats.ProviderErrors, f)
+				}
+			} else {
+				stats.ProviderErrors = append(stats.ProviderErrors, newProviderError("google:"+res.tile, res.err))
+			}
+			stats.SubQueries = append(stats.SubQueries, newProviderOutcome("google:"+res.tile, 0, 0, res.err))
+			ctxLogger(ctx).Error("[Search] Area tile search failed", "tile", res.tile, "error", res.err)
+			continue
+		}
+
+		tileStats := res.searchResult.Stats
+		stats.GooglePagesSearched += tileStats.GooglePagesSearched
+		stats.GoogleSearchQueries += tileStats.GoogleSearchQueries
+		stats.GoogleResultsRaw += tileStats.GoogleResultsRaw
+		stats.GoogleResultsFiltered += tileStats.GoogleResultsFiltered
+		for _, f := range tileStats.ProviderErrors {
+			f.Provider = strings.Replace(f.Provider, "google", "google:"+res.tile, 1)
+			stats.ProviderErrors = append(stats.ProviderErrors, f)
+		}
+		for _, q := range tileStats.SubQueries {
+			q.Provider = strings.Replace(q.Provider, "google", "google:"+res.tile, 1)
+			stats.SubQueries = append(stats.SubQueries, q)
+		}
+		allRestaurants = append(allRestaurants, res.searchResult.Restaurants...)
+	}
+
+	if len(allRestaurants) == 0 && len(errs) == len(tiles) {
+		return nil, &ProviderFailureError{
+			Failures: stats.ProviderErrors,
+			cause:    fmt.Sprintf("all area tiles failed: %s", strings.Join(errs, "; ")),
+		}
+	}
+
+	stats.TotalBeforeDedup = len(allRestaurants)
+	restaurants := clipToArea(rb.deduplicate(ctx, allRestaurants), params.Area)
+	for i := range restaurants {
+		restaurants[i].Distance = calculateDistance(params.Lat, params.Lon, restaurants[i].Latitude, restaurants[i].Longitude)
+	}
+	rb.rank(ctx, restaurants)
+	stats.TotalAfterDedup = len(restaurants)
+
+	return &SearchResult{
+		Restaurants: restaurants,
+		Stats:       stats,
+	}, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// mustPolygon parses a GeoJSON polygon that the test knows is valid
+func mustPolygon(t *testing.T, geojson string) *SearchArea {
+	t.Helper()
+	area, err := parseGeoJSONPolygon([]byte(geojson))
+	if err != nil {
+		t.Fatalf("parseGeoJSONPolygon: %v", err)
+	}
+	return area
+}
+
+func TestParseBBox(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{"13.40,52.51,13.42,52.52", ""},
+		{" 13.40 , 52.51 , 13.42 , 52.52 ", ""},
+		{"-0.15,51.49,-0.10,51.52", ""},
+		{"179.95,-16.80,179.99,-16.77", ""}, // next to the antimeridian
+		{"1,2,3", "minLon,minLat,maxLon,maxLat"},
+		{"1,2,3,4,5", "minLon,minLat,maxLon,maxLat"},
+		{"a,52.51,13.42,52.52", "minLon,minLat,maxLon,maxLat"},
+		{"13.40,91,13.42,92", "out of range"},
+		{"NaN,52.51,13.42,52.52", "out of range"},
+		{"13.42,52.51,13.40,52.52", "less than"},
+		{"13.40,52.52,13.42,52.51", "less than"},
+		{"13.40,52.51,13.40,52.52", "less than"},     // no width
+		{"179.9,-16.8,-179.9,-16.7", "antimeridian"}, // crossing it
+		{"13.0,52.0,13.5,52.5", "too large"},
+	}
+	for _, tt := range tests {
+		area, err := parseBBox(tt.value)
+		if tt.wantErr == "" {
+			if err != nil || area.Kind != "bbox" {
+				t.Errorf("parseBBox(%q) = %+v, %v", tt.value, area, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseBBox(%q) error %v, want %q", tt.value, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseGeoJSONPolygon(t *testing.T) {
+	tests := []struct {
+		name, geojson, wantErr string
+		wantRing               int
+	}{
+		{"closed", `{"type":"Polygon","coordinates":[[[13.40,52.51],[13.42,52.51],[13.42,52.52],[13.40,52.51]]]}`, "", 4},
+		{"closed automatically", `{"type":"Polygon","coordinates":[[[13.40,52.51],[13.42,52.51],[13.42,52.52]]]}`, "", 4},
+		{"feature with a hole", `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[13.40,52.51],[13.42,52.51],[13.42,52.52],[13.40,52.52],[13.40,52.51]],[[13.41,52.515],[13.411,52.515],[13.411,52.516],[13.41,52.515]]]}}`, "", 5},
+		{"not a polygon", `{"type":"Point","coordinates":[13.4,52.5]}`, "GeoJSON Polygon", 0},
+		{"no rings", `{"type":"Polygon","coordinates":[]}`, "GeoJSON Polygon", 0},
+		{"feature without geometry", `{"type":"Feature"}`, "no geometry", 0},
+		{"invalid JSON", `{"type":`, "GeoJSON Polygon", 0},
+		{"two vertices", `{"type":"Polygon","coordinates":[[[13.40,52.51],[13.42,52.52],[13.40,52.51]]]}`, "at least 3", 0},
+		{"all vertices equal", `{"type":"Polygon","coordinates":[[[13.4,52.5],[13.4,52.5],[13.4,52.5],[13.4,52.5]]]}`, "no area", 0},
+		{"collinear", `{"type":"Polygon","coordinates":[[[13.40,52.50],[13.41,52.51],[13.42,52.52],[13.40,52.50]]]}`, "no area", 0},
+		{"flat", `{"type":"Polygon","coordinates":[[[13.40,52.5],[13.41,52.5],[13.42,52.5]]]}`, "no area", 0},
+		{"position without latitude", `{"type":"Polygon","coordinates":[[[13.40],[13.41,52.5],[13.42,52.52]]]}`, "[lon, lat]", 0},
+		{"latitude out of range", `{"type":"Polygon","coordinates":[[[13.40,95],[13.41,52.5],[13.42,52.52]]]}`, "[lon, lat]", 0},
+		{"across the antimeridian", `{"type":"Polygon","coordinates":[[[179.9,-16.8],[-179.9,-16.8],[-179.9,-16.7],[179.9,-16.7]]]}`, "antimeridian", 0},
+		{"too large", `{"type":"Polygon","coordinates":[[[13.0,52.0],[13.5,52.0],[13.5,52.5]]]}`, "too large", 0},
+		{"too many vertices", `{"type":"Polygon","coordinates":[[` + strings.Repeat("[13.4,52.5],", maxPolygonVertices) + `[13.4,52.5]]]}`, "at most", 0},
+	}
+	for _, tt := range tests {
+		area, err := parseGeoJSONPolygon([]byte(tt.geojson))
+		if tt.wantErr == "" {
+			if err != nil || area.Kind != "polygon" || len(area.Ring) != tt.wantRing || area.Ring[0] != area.Ring[len(area.Ring)-1] {
+				t.Errorf("%s: %+v, %v", tt.name, area, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAreaContains(t *testing.T) {
+	bbox, _ := parseBBox("13.40,52.51,13.42,52.52")
+	// A U shape open to the north: the notch between the arms is outside
+	u := mustPolygon(t, `{"type":"Polygon","coordinates":[[[13.40,52.50],[13.43,52.50],[13.43,52.53],[13.42,52.53],[13.42,52.51],[13.41,52.51],[13.41,52.53],[13.40,52.53]]]}`)
+
+	tests := []struct {
+		name     string
+		area     *SearchArea
+		lat, lon float64
+		want     bool
+	}{
+		{"bbox center", bbox, 52.515, 13.41, true},
+		{"bbox corner", bbox, 52.51, 13.40, true},
+		{"bbox edge", bbox, 52.52, 13.41, true},
+		{"west of the bbox", bbox, 52.515, 13.39, false},
+		{"north of the bbox", bbox, 52.53, 13.41, false},
+		{"U base", u, 52.505, 13.415, true},
+		{"U left arm", u, 52.52, 13.405, true},
+		{"U right arm", u, 52.52, 13.425, true},
+		{"U notch", u, 52.52, 13.415, false},
+		{"outside the U bounding box", u, 52.54, 13.415, false},
+	}
+	for _, tt := range tests {
+		if got := tt.area.Contains(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("%s: Contains(%v, %v) = %v, want %v", tt.name, tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestAreaOverpassFilter(t *testing.T) {
+	bbox, _ := parseBBox("13.4,52.51,13.42,52.52")
+	if got := bbox.OverpassFilter(); got != "(52.510000,13.400000,52.520000,13.420000)" {
+		t.Errorf("bbox filter = %s", got)
+	}
+	triangle := mustPolygon(t, `{"type":"Polygon","coordinates":[[[13.40,52.51],[13.42,52.51],[13.42,52.52]]]}`)
+	if got := triangle.OverpassFilter(); got != `(poly:"52.510000 13.400000 52.510000 13.420000 52.520000 13.420000")` {
+		t.Errorf("polygon filter = %s", got)
+	}
+}
+
+func TestAreaTiles(t *testing.T) {
+	small, _ := parseBBox("13.40,52.51,13.41,52.515")
+	large, _ := parseBBox("13.30,52.45,13.55,52.60") // about 17 by 17 km
+	// An L shape: the north-east quarter of its bounding box is empty
+	l := mustPolygon(t, `{"type":"Polygon","coordinates":[[[13.30,52.45],[13.55,52.45],[13.55,52.52],[13.42,52.52],[13.42,52.60],[13.30,52.60]]]}`)
+
+	tests := []struct {
+		name      string
+		area      *SearchArea
+		wantTiles int
+	}{
+		{"small bbox", small, 1},
+		{"large bbox", large, maxAreaTiles},
+		{"L shape", l, 3},
+	}
+	for _, tt := range tests {
+		tiles := tt.area.Tiles(maxAreaTiles)
+		if len(tiles) != tt.wantTiles {
+			t.Errorf("%s: %d tiles, want %d", tt.name, len(tiles), tt.wantTiles)
+			continue
+		}
+		// Every point of the area is within some tile's radius
+		for i := 0; i <= 20; i++ {
+			for j := 0; j <= 20; j++ {
+				lat := tt.area.MinLat + (tt.area.MaxLat-tt.area.MinLat)*float64(i)/20
+				lon := tt.area.MinLon + (tt.area.MaxLon-tt.area.MinLon)*float64(j)/20
+				if !tt.area.Contains(lat, lon) {
+					continue
+				}
+				covered := false
+				for _, tile := range tiles {
+					if calculateDistance(lat, lon, tile.Lat, tile.Lon)*1000 <= float64(tile.Radius)+1 {
+						covered = true
+					}
+				}
+				if !covered {
+					t.Errorf("%s: %v,%v is not covered by %+v", tt.name, lat, lon, tiles)
+				}
+			}
+		}
+	}
+	if tiles := small.Tiles(maxAreaTiles); tiles[0].Radius < defaultSearchRadius/4 {
+		t.Errorf("small area radius %d m", tiles[0].Radius)
+	}
+}
+
+func TestAreaSearchChargesEveryGoogleTile(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(3)}
+	server := newTestServer(t, search)
+	server.limits = &ClientRateLimits{cached: NewRateLimiter(600, 100), search: NewRateLimiter(1, 6)}
+	large := "/api/v1/restaurants?bbox=13.30,52.45,13.55,52.60"
+	large4 := &SearchArea{Kind: "bbox", MinLat: 52.45, MinLon: 13.30, MaxLat: 52.60, MaxLon: 13.55}
+
+	// OpenStreetMap searches an area in one query
+	if server.searchCost(SearchParams{}) != 1 || server.searchCost(SearchParams{Area: large4}) != 1 {
+		t.Error("OpenStreetMap area search costs more than one search")
+	}
+
+	server.apiProvider = "both"
+	decodePage(t, serve(server, "GET", large, ""))
+	rec := serve(server, "GET", large+"&keyword=vegan", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second 4-tile area with 2 tokens left: %d %s", rec.Code, rec.Body.String())
+	}
+	if search.callCount() != 1 {
+		t.Errorf("%d searches, want 1", search.callCount())
+	}
+
+	// A lower burst covers the area with fewer, larger tiles instead of rejecting it
+	server.limits = &ClientRateLimits{cached: NewRateLimiter(600, 100), search: NewRateLimiter(1, 2)}
+	decodePage(t, serve(server, "GET", large, ""))
+	if tiles := large4.Tiles(server.areaTileLimit()); len(tiles) != 2 || tiles[0].Radius <= large4.Tiles(maxAreaTiles)[0].Radius {
+		t.Errorf("tiles with a burst of 2: %+v", tiles)
+	}
+	if rec := serve(server, "GET", "/api/v1/restaurants?bbox=13.40,52.51,13.41,52.515", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("search with no tokens left: %d", rec.Code)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKey is a named partner key with an optional daily request quota
+type APIKey struct {
+	Name       string
+	Key        string
+	DailyQuota int // 0 means unlimited
+}
+
+// APIKeyAuth authenticates API requests by key and enforces per-key daily quotas.
+// A nil *APIKeyAuth disables authentication.
+type APIKeyAuth struct {
+	mu             sync.Mutex
+	keys           []APIKey
+	allowAnonymous bool           // allow requests without a key (rate limited by IP)
+	usage          map[string]int // key name -> requests today
+	usageDate      string         // UTC day the usage counters belong to
+	now            func() time.Time
+}
+
+// authError describes why a request was rejected
+type authError struct {
+	status     int
+	code       string
+	message    string
+	retryAfter time.Duration
+}
+
+// parseAPIKeys parses "name:key[:dailyQuota]" entries separated by commas
+func parseAPIKeys(value string) ([]APIKey, error) {
+	var keys []APIKey
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid API key entry %q: expected name:key[:dailyQuota]", entry)
+		}
+		key := APIKey{Name: parts[0], Key: parts[1]}
+		if len(parts) == 3 {
+			quota, err := strconv.Atoi(parts[2])
+			if err != nil || quota < 0 {
+				return nil, fmt.Errorf("invalid daily quota for API key %q: %q", parts[0], parts[2])
+			}
+			key.DailyQuota = quota
+		}
+		if seen[key.Name] {
+			return nil, fmt.Errorf("duplicate API key name %q", key.Name)
+		}
+		seen[key.Name] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// NewAPIKeyAuth creates an authenticator for the given keys. Returns nil (no
+// authentication) if no keys are configured.
+func NewAPIKeyAuth(keys []APIKey, allowAnonymous bool) *APIKeyAuth {
+	if len(keys) == 0 {
+		return nil
+	}
+	return &APIKeyAuth{
+		keys:           keys,
+		allowAnonymous: allowAnonymous,
+		usage:          make(map[string]int),
+		now:            time.Now,
+	}
+}
+
+// requestAPIKey extracts the key from the X-API-Key header or api_key query parameter
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// Authenticate checks the request's API key and counts it against the key's quota.
+// It returns the matched key (nil for anonymous requests) or an error to send back.
+func (a *APIKeyAuth) Authenticate(r *http.Request) (*APIKey, *authError) {
+	if a == nil {
+		return nil, nil
+	}
+
+	provided := requestAPIKey(r)
+	if provided == "" {
+		if a.allowAnonymous {
+			return nil, nil
+		}
+		return nil, &authError{status: http.StatusUnauthorized, code: ErrCodeUnauthorized, message: "API key required (X-API-Key header or api_key parameter)"}
+	}
+
+	var matched *APIKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(a.keys[i].Key), []byte(provided)) == 1 {
+			matched = &a.keys[i]
+			break
+		}
+	}
+	if matched == nil {
+		return nil, &authError{status: http.StatusUnauthorized, code: ErrCodeUnauthorized, message: "Invalid API key"}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Reset counters at UTC midnight
+	now := a.now().UTC()
+	today := now.Format("2006-01-02")
+	if a.usageDate != today {
+		a.usage = make(map[string]int)
+		a.usageDate = today
+	}
+
+	if matched.DailyQuota > 0 && a.usage[matched.Name] >= matched.DailyQuota {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return matched, &authError{
+			status:     http.StatusTooManyRequests,
+			code:       ErrCodeQuotaExceeded,
+			message:    fmt.Sprintf("Daily quota of %d requests exceeded for API key %q", matched.DailyQuota, matched.Name),
+			retryAfter: midnight.Sub(now),
+		}
+	}
+	a.usage[matched.Name]++
+	return matched, nil
+}
+
+// write sends the authentication error response
+func (e *authError) write(w http.ResponseWriter) {
+	if e.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(e.retryAfter)))
+	}
+	if e.status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `APIKey header="X-API-Key"`)
+	}
+	writeAPIError(w, e.status, e.code, e.message)
+}
+
+// CORSPolicy controls which browser origins may call the API.
+// An empty allow-list or "*" allows any origin.
+type CORSPolicy struct {
+	allowAll bool
+	origins  map[string]bool
+}
+
+// NewCORSPolicy builds a policy from a comma-separated origin list
+func NewCORSPolicy(value string) *CORSPolicy {
+	policy := &CORSPolicy{origins: make(map[string]bool)}
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			policy.allowAll = true
+			continue
+		}
+		policy.origins[strings.ToLower(origin)] = true
+	}
+	if len(policy.origins) == 0 {
+		policy.allowAll = true
+	}
+	return policy
+}
+
+// Apply sets the CORS response headers. It returns false if the request has been
+// fully handled (preflight answered or origin rejected) and the handler should stop.
+func (p *CORSPolicy) Apply(w http.ResponseWriter, r *http.Request, methods string) bool {
+	origin := r.Header.Get("Origin")
+	allowed := p == nil || p.allowAll || p.origins[strings.ToLower(origin)]
+
+	if allowed {
+		if p == nil || p.allowAll {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
+	}
+
+	if r.Method == "OPTIONS" {
+		if !allowed {
+			ctxLogger(r.Context()).Warn("[CORS] Rejected preflight", "origin", origin)
+			writeAPIError(w, http.StatusForbidden, ErrCodeOriginNotAllowed, "Origin not allowed")
+			return false
+		}
+		w.WriteHeader(http.StatusOK)
+		return false
+	}
+
+	// Non-browser clients send no Origin header; browsers from other origins are rejected
+	if origin != "" && !allowed {
+		ctxLogger(r.Context()).Warn("[CORS] Rejected request", "origin", origin)
+		writeAPIError(w, http.StatusForbidden, ErrCodeOriginNotAllowed, "Origin not allowed")
+		return false
+	}
+	return true
+}
+
+// AccessConfig configures API key authentication and CORS
+type AccessConfig struct {
+	APIKeys            string // comma-separated name:key[:dailyQuota] entries; empty disables auth
+	AllowAnonymous     bool   // keep key-less access (rate limited by IP) when keys are set
+	CORSAllowedOrigins string // comma-separated origins; empty or "*" allows any origin
+	MetricsToken       string // bearer token for /metrics; empty uses API key authentication
+}
+
+// NewAPIAccess creates the authenticator and CORS policy for cfg
+func NewAPIAccess(cfg AccessConfig) (*APIKeyAuth, *CORSPolicy, error) {
+	keys, err := parseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewAPIKeyAuth(keys, cfg.AllowAnonymous), NewCORSPolicy(cfg.CORSAllowedOrigins), nil
+}
+
+// authorizeAPIRequest authenticates the request and applies the per-request rate
+// limit. It returns the client's rate limit key (per API key for partners, per IP
+// otherwise), or false if an error response has already been sent.
+func (rb *RestaurantBot) authorizeAPIRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	clientKey, ok := rb.authenticateAPIRequest(w, r)
+	if !ok || !rb.allowRequest(w, r, clientKey) {
+		return "", false
+	}
+	return clientKey, true
+}
+
+// authenticateAPIRequest authenticates the request without rate limiting it,
+// for handlers that only charge the limit for some requests
+func (rb *RestaurantBot) authenticateAPIRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	apiKey, authErr := rb.auth.Authenticate(r)
+	if authErr != nil {
+		authErr.write(w)
+		return "", false
+	}
+	if apiKey != nil {
+		return "key:" + apiKey.Name, true
+	}
+	return rb.limits.HTTPClientKey(r), true
+}
+
+// authenticateMetricsRequest guards /metrics, which exposes Google spend. With a
+// metrics token it requires "Authorization: Bearer <token>", so scrapes do not
+// use up a partner key's quota; otherwise it authenticates like the API.
+func (rb *RestaurantBot) authenticateMetricsRequest(w http.ResponseWriter, r *http.Request) bool {
+	if rb.metricsToken == "" {
+		_, ok := rb.authenticateAPIRequest(w, r)
+		return ok
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(rb.metricsToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		writeAPIError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Metrics token required (Authorization: Bearer header)")
+		return false
+	}
+	return true
+}
+
+// allowRequest charges the per-request rate limit, writing the error response
+// if it is exceeded
+func (rb *RestaurantBot) allowRequest(w http.ResponseWriter, r *http.Request, clientKey string) bool {
+	if ok, retryAfter := rb.limits.AllowRequest(clientKey); !ok {
+		ctxLogger(r.Context()).Warn("[RATELIMIT] Request limit exceeded", "client", clientKey)
+		writeRateLimited(w, retryAfter)
+		return false
+	}
+	return true
+}
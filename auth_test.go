@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := parseAPIKeys(" web:abc , partner:def:100,")
+	if err != nil {
+		t.Fatalf("parseAPIKeys: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != (APIKey{Name: "web", Key: "abc"}) || keys[1] != (APIKey{Name: "partner", Key: "def", DailyQuota: 100}) {
+		t.Errorf("keys = %+v", keys)
+	}
+
+	for _, value := range []string{"web", "web:", ":abc", "web:abc:ten", "web:abc:-1", "web:abc:1:2", "web:abc,web:def"} {
+		if _, err := parseAPIKeys(value); err == nil {
+			t.Errorf("%q: accepted", value)
+		}
+	}
+}
+
+func TestAPIKeyAuthQuota(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)}
+	auth := NewAPIKeyAuth([]APIKey{{Name: "partner", Key: "p-key", DailyQuota: 2}, {Name: "web", Key: "w-key"}}, false)
+	auth.now = clock.Now
+
+	authenticate := func(key string) (*APIKey, *authError) {
+		r := httptest.NewRequest("GET", "/api/restaurants", nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		return auth.Authenticate(r)
+	}
+
+	for i := 0; i < 2; i++ {
+		if key, err := authenticate("p-key"); err != nil || key.Name != "partner" {
+			t.Fatalf("request %d: key %+v, error %+v", i+1, key, err)
+		}
+	}
+	_, err := authenticate("p-key")
+	if err == nil || err.status != http.StatusTooManyRequests || err.code != ErrCodeQuotaExceeded {
+		t.Fatalf("over quota: %+v", err)
+	}
+	if err.retryAfter != time.Hour {
+		t.Errorf("retry after %v, want the hour until midnight UTC", err.retryAfter)
+	}
+	rec := httptest.NewRecorder()
+	err.write(rec)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "3600" {
+		t.Errorf("response %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// Other keys have their own quota, and unlimited keys have none
+	for i := 0; i < 5; i++ {
+		if _, err := authenticate("w-key"); err != nil {
+			t.Fatalf("unlimited key refused: %+v", err)
+		}
+	}
+
+	// Quotas reset at UTC midnight
+	clock.Advance(time.Hour)
+	if _, err := authenticate("p-key"); err != nil {
+		t.Errorf("quota not reset at midnight: %+v", err)
+	}
+
+	// Rejected keys never count against anyone's quota
+	for _, key := range []string{"", "wrong"} {
+		_, err := authenticate(key)
+		if err == nil || err.status != http.StatusUnauthorized {
+			t.Errorf("key %q: %+v, want 401", key, err)
+			continue
+		}
+		rec := httptest.NewRecorder()
+		err.write(rec)
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("key %q: no WWW-Authenticate header", key)
+		}
+	}
+
+	// The api_key parameter works too
+	r := httptest.NewRequest("GET", "/api/restaurants?api_key=w-key", nil)
+	if key, err := auth.Authenticate(r); err != nil || key.Name != "web" {
+		t.Errorf("api_key parameter: key %+v, error %+v", key, err)
+	}
+
+	anonymous := NewAPIKeyAuth([]APIKey{{Name: "web", Key: "w-key"}}, true)
+	if key, err := anonymous.Authenticate(httptest.NewRequest("GET", "/", nil)); key != nil || err != nil {
+		t.Errorf("anonymous request: key %+v, error %+v", key, err)
+	}
+	if NewAPIKeyAuth(nil, false) != nil {
+		t.Error("authentication enabled without keys")
+	}
+}
+
+func TestCORSPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     string
+		method      string
+		origin      string
+		wantProceed bool
+		wantStatus  int    // status written when the request doesn't proceed
+		wantOrigin  string // Access-Control-Allow-Origin
+	}{
+		{"any origin by default", "", "GET", "https://evil.example", true, 0, "*"},
+		{"wildcard", "https://a.example,*", "GET", "https://evil.example", true, 0, "*"},
+		{"listed origin", "https://a.example, https://b.example/", "GET", "https://b.example", true, 0, "https://b.example"},
+		{"origin case ignored", "https://A.example", "GET", "https://a.EXAMPLE", true, 0, "https://a.EXAMPLE"},
+		{"unlisted origin", "https://a.example", "GET", "https://evil.example", false, http.StatusForbidden, ""},
+		{"non-browser client", "https://a.example", "GET", "", true, 0, ""},
+		{"preflight from listed origin", "https://a.example", "OPTIONS", "https://a.example", false, http.StatusOK, "https://a.example"},
+		{"preflight from unlisted origin", "https://a.example", "OPTIONS", "https://evil.example", false, http.StatusForbidden, ""},
+		{"preflight with any origin allowed", "", "OPTIONS", "https://evil.example", false, http.StatusOK, "*"},
+	}
+	for _, tt := range tests {
+		policy := NewCORSPolicy(tt.allowed)
+		r := httptest.NewRequest(tt.method, "/api/restaurants", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		rec := httptest.NewRecorder()
+		proceed := policy.Apply(rec, r, "GET, OPTIONS")
+
+		if proceed != tt.wantProceed {
+			t.Errorf("%s: proceed = %v, want %v", tt.name, proceed, tt.wantProceed)
+		}
+		if !proceed && rec.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		if rec.Code == http.StatusForbidden && decodeError(t, rec).Code != ErrCodeOriginNotAllowed {
+			t.Errorf("%s: body %s", tt.name, rec.Body.String())
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+		if tt.wantOrigin != "" && !strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "X-API-Key") {
+			t.Errorf("%s: X-API-Key not an allowed header", tt.name)
+		}
+		if vary := rec.Header().Get("Vary") == "Origin"; vary != (tt.wantOrigin != "" && tt.wantOrigin != "*") {
+			t.Errorf("%s: Vary %q", tt.name, rec.Header().Get("Vary"))
+		}
+	}
+}
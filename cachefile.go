@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultCacheFile persists the location cache across restarts
+const defaultCacheFile = "/restaurant/cache.json"
+
+// CacheEntry is a location cache entry as saved to the cache file and exported
+type CacheEntry struct {
+	Lat         float64      `json:"lat"`
+	Lon         float64      `json:"lon"`
+	Restaurants []Restaurant `json:"restaurants"`
+	Stats       SearchStats  `json:"stats"`
+	ExpiresAt   time.Time    `json:"expiresAt"`
+}
+
+// cacheDump is the cache file and export format
+type cacheDump struct {
+	SavedAt time.Time    `json:"savedAt"`
+	Entries []CacheEntry `json:"entries"`
+}
+
+// Entries returns every entry, including expired ones not yet cleaned up
+func (lc *LocationCache) Entries() []CacheEntry {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	entries := make([]CacheEntry, 0, len(lc.items))
+	for _, item := range lc.items {
+		entries = append(entries, CacheEntry{
+			Lat:         item.lat,
+			Lon:         item.lon,
+			Restaurants: item.restaurants,
+			Stats:       item.stats,
+			ExpiresAt:   item.expiresAt,
+		})
+	}
+	return entries
+}
+
+// Restore adds unexpired entries, replacing cached ones within the cache
+// radius, and returns how many were added
+func (lc *LocationCache) Restore(entries []CacheEntry) int {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	now := lc.now()
+	restored := 0
+	for _, e := range entries {
+		if !now.Before(e.ExpiresAt) || !validCoordinates(e.Lat, e.Lon) {
+			continue
+		}
+		lc.storeLocked(e.Lat, e.Lon, e.Restaurants, e.Stats, e.ExpiresAt)
+		restored++
+	}
+	return restored
+}
+
+// Purge removes expired entries, or every entry if all is set, and returns how many were removed
+func (lc *LocationCache) Purge(all bool) int {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	now := lc.now()
+	kept := lc.items[:0]
+	for _, item := range lc.items {
+		if !all && now.Before(item.expiresAt) {
+			kept = append(kept, item)
+		}
+	}
+	removed := len(lc.items) - len(kept)
+	lc.items = kept
+	return removed
+}
+
+// Load restores the entries saved in the cache file; a missing file is empty
+func (lc *LocationCache) Load() (int, error) {
+	if lc.file == "" {
+		return 0, nil
+	}
+	dump, err := readCacheFile(lc.file)
+	if err != nil {
+		return 0, err
+	}
+	return lc.Restore(dump.Entries), nil
+}
+
+// Save writes the unexpired entries to the cache file
+func (lc *LocationCache) Save() error {
+	if lc.file == "" {
+		return nil
+	}
+	now := lc.now()
+	dump := cacheDump{SavedAt: now, Entries: []CacheEntry{}}
+	for _, e := range lc.Entries() {
+		if now.Before(e.ExpiresAt) {
+			dump.Entries = append(dump.Entries, e)
+		}
+	}
+	data, err := json.Marshal(dump)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(lc.file), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	// Write to a temp file first so a crash never leaves a truncated cache
+	tmpPath := lc.file + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmpPath, lc.file); err != nil {
+		return fmt.Errorf("failed to replace cache file: %w", err)
+	}
+	slog.Info("[CACHE] Saved entries", "entries", len(dump.Entries), "file", lc.file)
+	return nil
+}
+
+// readCacheFile reads a cache file or export; a missing file has no entries
+func readCacheFile(path string) (*cacheDump, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &cacheDump{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+	defer f.Close()
+	dump, err := readCacheDump(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cache file %s: %w", path, err)
+	}
+	return dump, nil
+}
+
+// readCacheDump decodes a cache file or export
+func readCacheDump(r io.Reader) (*cacheDump, error) {
+	var dump cacheDump
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return nil, err
+	}
+	return &dump, nil
+}
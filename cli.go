@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/png" // photos verify also accepts PNGs
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// photoTempMaxAge is how old a partial photo write must be before photos gc
+// removes it; younger ones may belong to a running server
+const photoTempMaxAge = time.Hour
+
+const commandUsage = `Usage: restaurant-bot <command> [flags]
+
+Commands:
+  search --lat L --lon L    search like the API and print a table (or --format json)
+  cache stats               show what the cache file holds
+  cache purge [--expired]   empty the cache file (or only drop expired entries)
+  cache export [--out F]    write the cache entries as JSON
+  cache import FILE         merge exported entries into the cache file
+  photos gc                 remove partial and empty photos (and old ones with --older-than)
+  photos verify [--delete]  find photos that are not readable images
+  config check              validate the configuration
+
+Every command also takes the server's configuration flags (see restaurant-bot -h).
+A running bot rewrites the cache file on shutdown, so change it while the bot is stopped.
+`
+
+// isCommand reports whether name selects an offline command instead of serving
+func isCommand(name string) bool {
+	switch name {
+	case "search", "cache", "photos", "config", "help":
+		return true
+	}
+	return false
+}
+
+// runCommand runs an offline command and returns the process exit code
+func runCommand(args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	c := &cli{stdout: stdout, stderr: stderr, getenv: getenv, setLogger: true}
+	err := c.run(args)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	default:
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+}
+
+// cli runs the offline commands with the same configuration, search and storage code as the server
+type cli struct {
+	stdout, stderr io.Writer
+	getenv         func(string) string
+	setLogger      bool // send the default logger to stderr as configured
+}
+
+func (c *cli) run(args []string) error {
+	name, args := args[0], args[1:]
+	if name == "cache" || name == "photos" || name == "config" {
+		if len(args) == 0 {
+			return fmt.Errorf("%s needs a subcommand (see restaurant-bot help)", name)
+		}
+		name, args = name+" "+args[0], args[1:]
+	}
+	switch name {
+	case "help":
+		fmt.Fprint(c.stdout, commandUsage)
+		return nil
+	case "search":
+		return c.search(args)
+	case "cache stats":
+		return c.cacheStats(args)
+	case "cache purge":
+		return c.cachePurge(args)
+	case "cache export":
+		return c.cacheExport(args)
+	case "cache import":
+		return c.cacheImport(args)
+	case "photos gc":
+		return c.photosGC(args)
+	case "photos verify":
+		return c.photosVerify(args)
+	case "config check":
+		return c.configCheck(args)
+	}
+	return fmt.Errorf("unknown command %q (see restaurant-bot help)", name)
+}
+
+// config loads the configuration for command name. fs holds the configuration
+// flags plus the command's own, which flags registers. strict commands fail on
+// an invalid configuration; the others only on values that do not parse.
+func (c *cli) config(name, usage string, args []string, strict bool, flags func(fs *flag.FlagSet)) (*Config, *flag.FlagSet, error) {
+	cfg := DefaultConfig()
+	fs := cfg.flagSet("restaurant-bot " + name)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: restaurant-bot %s %s\n\n", name, usage)
+		fs.PrintDefaults()
+	}
+	if flags != nil {
+		flags(fs)
+	}
+	errs, err := cfg.load(fs, args, c.getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strict {
+		errs = append(errs, cfg.check()...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if c.setLogger {
+		slog.SetDefault(slog.New(NewLogHandler(c.stderr, cfg.Log)))
+	}
+	return cfg, fs, nil
+}
+
+// flagAlias is a second name for another flag of the same set; setting it
+// counts as setting that flag, so it still overrides the environment
+type flagAlias struct {
+	fs   *flag.FlagSet
+	name string
+}
+
+func (a flagAlias) String() string {
+	if a.fs == nil {
+		return ""
+	}
+	return a.fs.Lookup(a.name).Value.String()
+}
+
+func (a flagAlias) Set(value string) error {
+	return a.fs.Set(a.name, value)
+}
+
+// search runs one search through the cache and providers like the API does
+func (c *cli) search(args []string) error {
+	query := map[string]*string{}
+	var limit int
+	var format string
+	var fresh bool
+	cfg, fs, err := c.config("search", "--lat L --lon L | --q PLACE [flags]", args, true, func(fs *flag.FlagSet) {
+		for _, name := range []string{"lat", "lon", "q", "bbox", "categories", "keyword", "sort", "min_rating", "min_reviews", "max_distance", "open_now"} {
+			query[name] = fs.String(strings.ReplaceAll(name, "_", "-"), "", "same as the API's "+name+" parameter")
+		}
+		fs.Var(flagAlias{fs, "api-provider"}, "provider", "alias of --api-provider")
+		fs.IntVar(&limit, "limit", 20, "number of restaurants to print, 0 for all")
+		fs.StringVar(&format, "format", "table", "table or json")
+		fs.BoolVar(&fresh, "fresh", false, "skip the cache file and always search the providers")
+	})
+	if err != nil {
+		return err
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid --format %q: must be table or json", format)
+	}
+
+	// The flags become API query parameters, so both validate them the same way
+	q := url.Values{}
+	fs.Visit(func(f *flag.Flag) {
+		name := strings.ReplaceAll(f.Name, "-", "_")
+		if _, ok := query[name]; ok {
+			q.Set(name, f.Value.String())
+		}
+	})
+	params, filters, _, _, apiErr := parseSearchQuery(q)
+	if apiErr != nil {
+		return errors.New(apiErr.Message)
+	}
+
+	cfg.Telegram.Enabled = false
+	bot, err := NewRestaurantBotFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	defer bot.tracing.Shutdown(context.Background())
+
+	if params.Place != "" {
+		location, err := bot.geocoder.Geocode(ctx, params.Place)
+		if err != nil {
+			return fmt.Errorf("geocoding %q: %w", params.Place, err)
+		}
+		params.Lat, params.Lon = location.Lat, location.Lon
+		fmt.Fprintf(c.stderr, "Searching near %s\n", location.Name)
+	}
+	if !validCoordinates(params.Lat, params.Lon) {
+		return errors.New("lat must be within [-90, 90] and lon within [-180, 180]")
+	}
+
+	var result *SearchResult
+	if params.cacheable() && !fresh {
+		if _, err := bot.cache.Load(); err != nil {
+			return err
+		}
+		if restaurants, stats, found := bot.cache.Get(params.Lat, params.Lon); found {
+			result = &SearchResult{Restaurants: restaurants, Stats: *stats}
+		}
+	}
+	if result == nil {
+		result, err = bot.Search(ctx, params)
+		if flushErr := bot.costs.Flush(); flushErr != nil {
+			fmt.Fprintf(c.stderr, "Warning: %v\n", flushErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	restaurants := applyResultFilters(result.Restaurants, filters)
+	if limit > 0 && len(restaurants) > limit {
+		restaurants = restaurants[:limit]
+	}
+	if format == "json" {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(SearchResult{Restaurants: restaurants, Stats: result.Stats})
+	}
+	return writeRestaurantTable(c.stdout, restaurants, result.Stats)
+}
+
+// writeRestaurantTable prints restaurants as an aligned table with a summary line
+func writeRestaurantTable(w io.Writer, restaurants []Restaurant, stats SearchStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tNAME\tSOURCE\tRATING\tREVIEWS\tDISTANCE\tTYPE")
+	for i, r := range restaurants {
+		source := "osm"
+		if strings.HasPrefix(r.PlaceID, fakePlaceIDPrefix) {
+			source = "fake"
+		} else if r.PlaceID != "" {
+			source = "google"
+		}
+		rating := "-"
+		if r.Rating > 0 {
+			rating = fmt.Sprintf("%.1f", r.Rating)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%s\t%s\n", i+1, r.Name, source, rating, r.ReviewCount, formatDistance(r.Distance), r.Type)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	origin := "fresh search"
+	if stats.CachedResult {
+		origin = "from cache"
+	}
+	fmt.Fprintf(w, "\n%d of %d restaurants (%s; %d Google pages, %d OSM results)\n",
+		len(restaurants), stats.TotalAfterDedup, origin, stats.GooglePagesSearched, stats.OSMResultsTotal)
+	if stats.Notice != "" {
+		fmt.Fprintf(w, "Note: %s\n", stats.Notice)
+	}
+	return nil
+}
+
+// persistedCache loads the configured cache file into a cache
+func persistedCache(cfg *Config) (*LocationCache, error) {
+	if cfg.Cache.File == "" {
+		return nil, errors.New("CACHE_FILE is empty, so the cache is not persisted")
+	}
+	cache := NewLocationCache()
+	cache.configure(cfg.Cache)
+	if _, err := cache.Load(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func (c *cli) cacheStats(args []string) error {
+	cfg, _, err := c.config("cache stats", "", args, false, nil)
+	if err != nil {
+		return err
+	}
+	if cfg.Cache.File == "" {
+		return errors.New("CACHE_FILE is empty, so the cache is not persisted")
+	}
+	dump, err := readCacheFile(cfg.Cache.File)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	expired, restaurants := 0, 0
+	var oldest, newest time.Time
+	for _, e := range dump.Entries {
+		if !now.Before(e.ExpiresAt) {
+			expired++
+		}
+		restaurants += len(e.Restaurants)
+		if oldest.IsZero() || e.ExpiresAt.Before(oldest) {
+			oldest = e.ExpiresAt
+		}
+		if e.ExpiresAt.After(newest) {
+			newest = e.ExpiresAt
+		}
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "File:\t%s\n", cfg.Cache.File)
+	if !dump.SavedAt.IsZero() {
+		fmt.Fprintf(tw, "Saved:\t%s\n", dump.SavedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(tw, "Entries:\t%d (%d expired)\n", len(dump.Entries), expired)
+	fmt.Fprintf(tw, "Restaurants:\t%d\n", restaurants)
+	if len(dump.Entries) > 0 {
+		fmt.Fprintf(tw, "Expiry:\t%s to %s\n", oldest.Format(time.RFC3339), newest.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func (c *cli) cachePurge(args []string) error {
+	var expiredOnly bool
+	cfg, _, err := c.config("cache purge", "[--expired]", args, false, func(fs *flag.FlagSet) {
+		fs.BoolVar(&expiredOnly, "expired", false, "only remove expired entries")
+	})
+	if err != nil {
+		return err
+	}
+	dump, err := readCacheFile(cfg.Cache.File)
+	if err != nil {
+		return err
+	}
+	// Loading drops expired entries
+	cache, err := persistedCache(cfg)
+	if err != nil {
+		return err
+	}
+	if !expiredOnly {
+		cache.Purge(true)
+	}
+	if err := cache.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Removed %d of %d entries from %s\n", len(dump.Entries)-cache.Len(), len(dump.Entries), cfg.Cache.File)
+	return nil
+}
+
+func (c *cli) cacheExport(args []string) error {
+	var out string
+	cfg, _, err := c.config("cache export", "[--out FILE]", args, false, func(fs *flag.FlagSet) {
+		fs.StringVar(&out, "out", "", "file to write instead of stdout")
+	})
+	if err != nil {
+		return err
+	}
+	cache, err := persistedCache(cfg)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cacheDump{SavedAt: time.Now(), Entries: cache.Entries()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if out == "" {
+		_, err = c.stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "Exported %d entries to %s\n", cache.Len(), out)
+	return nil
+}
+
+func (c *cli) cacheImport(args []string) error {
+	cfg, fs, err := c.config("cache import", "FILE", args, false, nil)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("cache import needs exactly one file")
+	}
+	imported, err := readCacheFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cache, err := persistedCache(cfg)
+	if err != nil {
+		return err
+	}
+	restored := cache.Restore(imported.Entries)
+	if err := cache.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Imported %d entries (%d expired or invalid skipped); %s now holds %d\n",
+		restored, len(imported.Entries)-restored, cfg.Cache.File, cache.Len())
+	return nil
+}
+
+// photoStore opens the configured photo directory. Unlike NewDiskPhotoStore it
+// leaves temp files alone, since a running server may be writing them.
+func photoStore(cfg *Config) *DiskPhotoStore {
+	return &DiskPhotoStore{dir: cfg.Photos.Dir}
+}
+
+func (c *cli) photosGC(args []string) error {
+	var olderThan time.Duration
+	var dryRun bool
+	cfg, _, err := c.config("photos gc", "[--older-than D] [--dry-run]", args, false, func(fs *flag.FlagSet) {
+		fs.DurationVar(&olderThan, "older-than", 0, "also remove photos stored longer ago than this, e.g. 2160h; 0 keeps them")
+		fs.BoolVar(&dryRun, "dry-run", false, "only list what would be removed")
+	})
+	if err != nil {
+		return err
+	}
+	store := photoStore(cfg)
+	files, err := store.List()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	removed, freed := 0, int64(0)
+	for _, f := range files {
+		var reason string
+		switch {
+		case f.Partial && now.Sub(f.ModTime) > photoTempMaxAge:
+			reason = "partial write"
+		case f.Partial:
+			continue
+		case f.Size == 0:
+			reason = "empty"
+		case olderThan > 0 && now.Sub(f.ModTime) > olderThan:
+			reason = "older than " + olderThan.String()
+		default:
+			continue
+		}
+		if !dryRun {
+			if err := store.Remove(f.Name); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(c.stdout, "%s (%s)\n", f.Name, reason)
+		removed++
+		freed += f.Size
+	}
+	verb := "Removed"
+	if dryRun {
+		verb = "Would remove"
+	}
+	fmt.Fprintf(c.stdout, "%s %d of %d files (%d bytes) in %s\n", verb, removed, len(files), freed, cfg.Photos.Dir)
+	return nil
+}
+
+func (c *cli) photosVerify(args []string) error {
+	var remove bool
+	cfg, _, err := c.config("photos verify", "[--delete]", args, false, func(fs *flag.FlagSet) {
+		fs.BoolVar(&remove, "delete", false, "delete unreadable photos so they are fetched again")
+	})
+	if err != nil {
+		return err
+	}
+	store := photoStore(cfg)
+	files, err := store.List()
+	if err != nil {
+		return err
+	}
+	checked, bad := 0, 0
+	for _, f := range files {
+		if f.Partial {
+			continue
+		}
+		checked++
+		data, _, ok := store.Get(f.Name)
+		reason := "empty or unreadable"
+		if ok {
+			_, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err == nil {
+				continue
+			}
+			reason = err.Error()
+		}
+		bad++
+		if remove {
+			if err := store.Remove(f.Name); err != nil {
+				return err
+			}
+			reason += ", deleted"
+		}
+		fmt.Fprintf(c.stdout, "%s: %s\n", f.Name, reason)
+	}
+	fmt.Fprintf(c.stdout, "Checked %d photos in %s, %d bad\n", checked, cfg.Photos.Dir, bad)
+	if bad > 0 && !remove {
+		return fmt.Errorf("%d photos are not readable images (rerun with --delete to remove them)", bad)
+	}
+	return nil
+}
+
+func (c *cli) configCheck(args []string) error {
+	cfg, _, err := c.config("config check", "", args, true, nil)
+	if err != nil {
+		return err
+	}
+	if cfg.Provider == "both" && cfg.GoogleMapsAPIKey == "" {
+		fmt.Fprintln(c.stdout, "Warning: API_PROVIDER is both but GOOGLE_MAPS_API_KEY is not set, only OSM will be used")
+	}
+	fmt.Fprintln(c.stdout, "Configuration OK")
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runCLI runs a command with env (API_PROVIDER defaults to osm), ignoring any ./.env
+func runCLI(t *testing.T, env map[string]string, args ...string) (string, error) {
+	t.Helper()
+	previous := dotenvPath
+	dotenvPath = filepath.Join(t.TempDir(), ".env")
+	defer func() { dotenvPath = previous }()
+	var stdout, stderr bytes.Buffer
+	c := &cli{stdout: &stdout, stderr: &stderr, getenv: func(name string) string {
+		if value, ok := env[name]; ok {
+			return value
+		}
+		if name == "API_PROVIDER" {
+			return "osm"
+		}
+		return ""
+	}}
+	err := c.run(args)
+	return stdout.String(), err
+}
+
+// writeCacheFile saves entries as a cache file and returns its path
+func writeCacheFile(t *testing.T, entries ...CacheEntry) string {
+	t.Helper()
+	cache := NewLocationCache()
+	cache.file = filepath.Join(t.TempDir(), "cache.json")
+	cache.Restore(entries)
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return cache.file
+}
+
+func TestCacheFileRoundTrip(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	cache := NewLocationCache()
+	cache.now = clock.Now
+	cache.file = filepath.Join(t.TempDir(), "nested", "cache.json")
+	cache.Set(52.52, 13.405, makeRestaurants(3), SearchStats{TotalAfterDedup: 3})
+	cache.Set(48.85, 2.35, makeRestaurants(1), SearchStats{TotalAfterDedup: 1})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	restored := NewLocationCache()
+	restored.now = clock.Now
+	restored.file = cache.file
+	if n, err := restored.Load(); err != nil || n != 2 {
+		t.Fatalf("Load() = %d, %v; want 2 entries", n, err)
+	}
+	restaurants, stats, found := restored.Get(52.52, 13.405)
+	if !found || len(restaurants) != 3 || stats.TotalAfterDedup != 3 || !stats.CachedResult {
+		t.Errorf("restored entry = %d restaurants, %+v, %v", len(restaurants), stats, found)
+	}
+
+	// Entries that expired while the bot was down are not restored
+	clock.Advance(defaultCacheTTL + time.Minute)
+	expired := NewLocationCache()
+	expired.now = clock.Now
+	expired.file = cache.file
+	if n, err := expired.Load(); err != nil || n != 0 {
+		t.Errorf("Load() after expiry = %d, %v; want 0", n, err)
+	}
+
+	// A missing file is an empty cache
+	expired.file = filepath.Join(t.TempDir(), "missing.json")
+	if n, err := expired.Load(); err != nil || n != 0 {
+		t.Errorf("Load() of missing file = %d, %v", n, err)
+	}
+}
+
+func TestCLISearchUsesCacheFile(t *testing.T) {
+	file := writeCacheFile(t, CacheEntry{
+		Lat: 52.52, Lon: 13.405, Restaurants: makeRestaurants(5),
+		Stats: SearchStats{TotalAfterDedup: 5}, ExpiresAt: time.Now().Add(time.Hour),
+	})
+	env := map[string]string{"CACHE_FILE": file}
+
+	out, err := runCLI(t, env, "search", "--lat", "52.52", "--lon", "13.405", "--min-rating", "4.2", "--sort", "distance", "--limit", "2")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	for _, want := range []string{"NAME", "Place 02", "Place 03", "2 of 5 restaurants (from cache"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Place 01") || strings.Contains(out, "Place 04") {
+		t.Errorf("filters or limit not applied:\n%s", out)
+	}
+
+	out, err = runCLI(t, env, "search", "--lat", "52.52", "--lon", "13.405", "--provider", "osm", "--format", "json")
+	if err != nil {
+		t.Fatalf("search --format json: %v", err)
+	}
+	var result SearchResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(result.Restaurants) != 5 || !result.Stats.CachedResult {
+		t.Errorf("json result = %d restaurants, cached %v", len(result.Restaurants), result.Stats.CachedResult)
+	}
+
+	for _, args := range [][]string{
+		{"search"},
+		{"search", "--lat", "91", "--lon", "0"},
+		{"search", "--lat", "52.52", "--lon", "13.405", "--format", "xml"},
+		{"search", "--lat", "52.52", "--lon", "13.405", "--provider", "bing"},
+	} {
+		if _, err := runCLI(t, env, args...); err == nil {
+			t.Errorf("%v accepted", args)
+		}
+	}
+}
+
+func TestCLICacheCommands(t *testing.T) {
+	now := time.Now()
+	file := writeCacheFile(t,
+		CacheEntry{Lat: 52.52, Lon: 13.405, Restaurants: makeRestaurants(3), ExpiresAt: now.Add(time.Hour)},
+		CacheEntry{Lat: 48.85, Lon: 2.35, Restaurants: makeRestaurants(2), ExpiresAt: now.Add(2 * time.Hour)},
+	)
+	env := map[string]string{"CACHE_FILE": file}
+
+	out, err := runCLI(t, env, "cache", "stats")
+	if err != nil {
+		t.Fatalf("cache stats: %v", err)
+	}
+	if !strings.Contains(out, "2 (0 expired)") || !strings.Contains(out, "Restaurants:  5") {
+		t.Errorf("cache stats:\n%s", out)
+	}
+
+	export := filepath.Join(t.TempDir(), "export.json")
+	if _, err := runCLI(t, env, "cache", "export", "--out", export); err != nil {
+		t.Fatalf("cache export: %v", err)
+	}
+	out, err = runCLI(t, env, "cache", "purge")
+	if err != nil || !strings.Contains(out, "Removed 2 of 2 entries") {
+		t.Fatalf("cache purge: %q, %v", out, err)
+	}
+
+	out, err = runCLI(t, env, "cache", "import", export)
+	if err != nil || !strings.Contains(out, "Imported 2 entries") {
+		t.Fatalf("cache import: %q, %v", out, err)
+	}
+	cache := NewLocationCache()
+	cache.file = file
+	if n, err := cache.Load(); err != nil || n != 2 || !cache.Has(48.85, 2.35) {
+		t.Errorf("cache file after import: %d entries, %v", n, err)
+	}
+
+	if _, err := runCLI(t, env, "cache", "import"); err == nil {
+		t.Error("cache import without a file accepted")
+	}
+	if _, err := runCLI(t, nil, "cache", "stats", "--cache-file", ""); err == nil {
+		t.Error("cache stats without a cache file accepted")
+	}
+}
+
+func TestCLIPhotosGCAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	jpeg, err := generateGenericPlaceholderImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"good.jpg":                      jpeg,
+		"corrupt.jpg":                   []byte("<html>quota exceeded</html>"),
+		"empty.jpg":                     nil,
+		photoTempPrefix + "old.jpg-1":   jpeg[:10],
+		photoTempPrefix + "fresh.jpg-2": jpeg[:10],
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * photoTempMaxAge)
+	os.Chtimes(filepath.Join(dir, photoTempPrefix+"old.jpg-1"), old, old)
+	env := map[string]string{"PHOTO_DIR": dir}
+
+	out, err := runCLI(t, env, "photos", "gc", "--dry-run")
+	if err != nil || !strings.Contains(out, "Would remove 2 of 5 files") {
+		t.Fatalf("photos gc --dry-run: %q, %v", out, err)
+	}
+	out, err = runCLI(t, env, "photos", "gc")
+	if err != nil || !strings.Contains(out, "Removed 2 of 5 files") {
+		t.Fatalf("photos gc: %q, %v", out, err)
+	}
+	for name, want := range map[string]bool{"good.jpg": true, "empty.jpg": false, photoTempPrefix + "old.jpg-1": false, photoTempPrefix + "fresh.jpg-2": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+
+	out, err = runCLI(t, env, "photos", "verify")
+	if err == nil || !strings.Contains(out, "corrupt.jpg") || strings.Contains(out, "good.jpg") {
+		t.Errorf("photos verify: %q, %v", out, err)
+	}
+	if _, err := runCLI(t, env, "photos", "verify", "--delete"); err != nil {
+		t.Errorf("photos verify --delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "corrupt.jpg")); err == nil {
+		t.Error("corrupt photo not deleted")
+	}
+}
+
+func TestCLIConfigCheck(t *testing.T) {
+	out, err := runCLI(t, nil, "config", "check")
+	if err != nil || !strings.Contains(out, "Configuration OK") {
+		t.Errorf("config check: %q, %v", out, err)
+	}
+	_, err = runCLI(t, map[string]string{"API_PROVIDER": "google", "CACHE_TTL": "soon"}, "config", "check")
+	if err == nil || !strings.Contains(err.Error(), "GOOGLE_MAPS_API_KEY") || !strings.Contains(err.Error(), "CACHE_TTL") {
+		t.Errorf("config check of an invalid configuration: %v", err)
+	}
+
+	if _, err := runCLI(t, nil, "config"); err == nil {
+		t.Error("config without a subcommand accepted")
+	}
+	if _, err := runCLI(t, nil, "cache", "shrink"); err == nil {
+		t.Error("unknown subcommand accepted")
+	}
+}
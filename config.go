@@ -0,0 +1,570 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dotenvPath is loaded automatically when no config file is given
+var dotenvPath = ".env"
+
+// redactedValue replaces secrets in --print-config output
+const redactedValue = "[redacted]"
+
+// Config is the complete bot configuration. It is loaded once at startup by
+// LoadConfig; every field can be set from a flag, the environment or a file.
+type Config struct {
+	Provider         string // "google", "osm", "both" or "fake"
+	GoogleMapsAPIKey string
+	Telegram         TelegramConfig
+	RequestTimeout   time.Duration // bound on each provider call
+	Cache            CacheConfig
+	Photos           PhotoConfig
+	Geocoder         GeocoderConfig
+	Costs            CostConfig
+	RateLimits       RateLimitConfig
+	Access           AccessConfig
+	HTTP             HTTPServerConfig
+	Log              LogConfig
+	Tracing          TracingConfig
+	Fixtures         FixtureConfig
+	Fake             FakeProviderConfig
+}
+
+// TelegramConfig enables the Telegram bot
+type TelegramConfig struct {
+	Enabled bool
+	Token   string
+}
+
+// CacheConfig tunes the search result cache
+type CacheConfig struct {
+	TTL          time.Duration // how long results are served from cache
+	DegradedTTL  time.Duration // TTL of partial results (a provider failed)
+	RadiusMeters float64       // searches this close to a cached one reuse it
+	File         string        // saved on shutdown and loaded at startup; empty disables
+}
+
+// PhotoConfig sets where photos are stored and which restaurants get real ones
+type PhotoConfig struct {
+	Dir        string
+	MinRating  float64 // rated restaurants below this get the generic photo
+	MinReviews int     // restaurants with fewer reviews get the generic photo
+}
+
+// DefaultConfig returns the configuration used when nothing is set
+func DefaultConfig() *Config {
+	return &Config{
+		Provider:       "google",
+		RequestTimeout: defaultRequestTimeout,
+		Cache: CacheConfig{
+			TTL:          defaultCacheTTL,
+			DegradedTTL:  defaultDegradedCacheTTL,
+			RadiusMeters: defaultCacheRadiusMeters,
+			File:         defaultCacheFile,
+		},
+		Photos: PhotoConfig{
+			Dir:        defaultPhotoDir,
+			MinRating:  defaultMinRatingForPhoto,
+			MinReviews: defaultMinReviewsForPhoto,
+		},
+		Geocoder: GeocoderConfig{Provider: "nominatim", NominatimURL: defaultNominatimURL},
+		Costs:    CostConfig{LedgerPath: defaultCostLedgerPath},
+		RateLimits: RateLimitConfig{
+			CachedPerMinute:     defaultCachedRatePerMinute,
+			CachedBurst:         defaultCachedBurst,
+			SearchPerMinute:     defaultSearchRatePerMinute,
+			SearchBurst:         defaultSearchBurst,
+			RoutePerMinute:      defaultRouteRatePerMinute,
+			RouteBurst:          defaultRouteBurst,
+			TrustedProxyHeaders: strings.Join(defaultTrustedProxyHeaders, ","),
+		},
+		HTTP: HTTPServerConfig{
+			Port:              8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   25 * time.Second,
+		},
+		Log:      LogConfig{Format: "text", Coordinates: CoordinatesExact},
+		Tracing:  TracingConfig{Exporter: "none", SampleRatio: 1, ServiceName: defaultServiceName},
+		Fixtures: FixtureConfig{Dir: defaultFixtureDir},
+	}
+}
+
+// setting binds one Config field to its flag, config file key and environment variable
+type setting struct {
+	name   string // flag name and config file key
+	env    string
+	usage  string
+	secret bool
+	value  any // pointer to the Config field
+}
+
+// settings lists every configurable field of c
+func (c *Config) settings() []setting {
+	return []setting{
+		{"api-provider", "API_PROVIDER", "restaurant provider: google, osm, both, or fake for made-up places", false, &c.Provider},
+		{"google-maps-api-key", "GOOGLE_MAPS_API_KEY", "Google Maps API key", true, &c.GoogleMapsAPIKey},
+		{"enable-telegram-bot", "ENABLE_TELEGRAM_BOT", "run the Telegram bot", false, &c.Telegram.Enabled},
+		{"telegram-bot-token", "TELEGRAM_BOT_TOKEN", "Telegram bot token", true, &c.Telegram.Token},
+		{"request-timeout", "REQUEST_TIMEOUT", "timeout of each provider request", false, &c.RequestTimeout},
+
+		{"cache-ttl", "CACHE_TTL", "how long search results are cached", false, &c.Cache.TTL},
+		{"cache-degraded-ttl", "CACHE_DEGRADED_TTL", "how long partial results (a provider failed) are cached", false, &c.Cache.DegradedTTL},
+		{"cache-radius-meters", "CACHE_RADIUS_METERS", "searches within this distance reuse a cached result", false, &c.Cache.RadiusMeters},
+		{"cache-file", "CACHE_FILE", "file the cache is saved to on shutdown and loaded from at startup; empty disables", false, &c.Cache.File},
+
+		{"photo-dir", "PHOTO_DIR", "directory of downloaded photos", false, &c.Photos.Dir},
+		{"photo-min-rating", "PHOTO_MIN_RATING", "rated restaurants below this get the generic photo", false, &c.Photos.MinRating},
+		{"photo-min-reviews", "PHOTO_MIN_REVIEWS", "restaurants with fewer reviews get the generic photo", false, &c.Photos.MinReviews},
+
+		{"geocoder", "GEOCODER", "geocoder for place names: nominatim or google", false, &c.Geocoder.Provider},
+		{"nominatim-url", "NOMINATIM_URL", "base URL of a Nominatim-compatible instance", false, &c.Geocoder.NominatimURL},
+
+		{"google-cost-ledger-path", "GOOGLE_COST_LEDGER_PATH", "file of persisted per-day Google spend", false, &c.Costs.LedgerPath},
+		{"google-daily-budget-usd", "GOOGLE_DAILY_BUDGET_USD", "daily Google spend cap in USD, 0 = unlimited", false, &c.Costs.DailyBudgetUSD},
+		{"google-monthly-budget-usd", "GOOGLE_MONTHLY_BUDGET_USD", "monthly Google spend cap in USD, 0 = unlimited", false, &c.Costs.MonthlyBudgetUSD},
+
+		{"rate-limit-cached-per-minute", "RATE_LIMIT_CACHED_PER_MINUTE", "requests per minute per client, 0 disables", false, &c.RateLimits.CachedPerMinute},
+		{"rate-limit-cached-burst", "RATE_LIMIT_CACHED_BURST", "request burst per client", false, &c.RateLimits.CachedBurst},
+		{"rate-limit-search-per-minute", "RATE_LIMIT_SEARCH_PER_MINUTE", "fresh searches per minute per client, 0 disables", false, &c.RateLimits.SearchPerMinute},
+		{"rate-limit-search-burst", "RATE_LIMIT_SEARCH_BURST", "fresh search burst per client", false, &c.RateLimits.SearchBurst},
+		{"rate-limit-route-per-minute", "RATE_LIMIT_ROUTE_PER_MINUTE", "uncached route samples per minute per client, 0 disables", false, &c.RateLimits.RoutePerMinute},
+		{"rate-limit-route-burst", "RATE_LIMIT_ROUTE_BURST", "uncached route sample burst per client", false, &c.RateLimits.RouteBurst},
+		{"trusted-proxies", "TRUSTED_PROXIES", "comma-separated IPs/CIDRs whose forwarding headers are trusted", false, &c.RateLimits.TrustedProxies},
+		{"trusted-proxy-headers", "TRUSTED_PROXY_HEADERS", "comma-separated client IP headers set by trusted proxies", false, &c.RateLimits.TrustedProxyHeaders},
+
+		{"api-keys", "API_KEYS", "comma-separated name:key[:dailyQuota] entries; empty disables auth", true, &c.Access.APIKeys},
+		{"api-allow-anonymous", "API_ALLOW_ANONYMOUS", "keep key-less access when API keys are set", false, &c.Access.AllowAnonymous},
+		{"cors-allowed-origins", "CORS_ALLOWED_ORIGINS", "comma-separated browser origins; empty or * allows any", false, &c.Access.CORSAllowedOrigins},
+		{"metrics-token", "METRICS_TOKEN", "bearer token required on /metrics; empty uses API key auth", true, &c.Access.MetricsToken},
+
+		{"http-port", "HTTP_PORT", "HTTP server port", false, &c.HTTP.Port},
+		{"http-read-header-timeout", "HTTP_READ_HEADER_TIMEOUT", "HTTP read header timeout, 0 disables", false, &c.HTTP.ReadHeaderTimeout},
+		{"http-read-timeout", "HTTP_READ_TIMEOUT", "HTTP read timeout, 0 disables", false, &c.HTTP.ReadTimeout},
+		{"http-write-timeout", "HTTP_WRITE_TIMEOUT", "HTTP write timeout (also bounds streams), 0 disables", false, &c.HTTP.WriteTimeout},
+		{"http-idle-timeout", "HTTP_IDLE_TIMEOUT", "HTTP keep-alive idle timeout, 0 disables", false, &c.HTTP.IdleTimeout},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "how long in-flight work gets to finish on shutdown", false, &c.HTTP.ShutdownTimeout},
+
+		{"log-level", "LOG_LEVEL", "debug, info, warn or error", false, &c.Log.Level},
+		{"log-format", "LOG_FORMAT", "text or json", false, &c.Log.Format},
+		{"log-coordinates", "LOG_COORDINATES", "coordinates in logs: exact, coarse or hidden", false, &c.Log.Coordinates},
+		{"log-trace-place", "LOG_TRACE_PLACE", "place name or ID to log at every search stage", false, &c.Log.TracePlace},
+
+		{"tracing-exporter", "TRACING_EXPORTER", "span exporter: none or otlp", false, &c.Tracing.Exporter},
+		{"tracing-sample-ratio", "TRACING_SAMPLE_RATIO", "fraction of new traces to record, 0-1", false, &c.Tracing.SampleRatio},
+		{"otel-service-name", "OTEL_SERVICE_NAME", "service name on exported spans", false, &c.Tracing.ServiceName},
+		{"provider-fixtures", "PROVIDER_FIXTURES", "record provider calls to fixture files, or replay them offline: record or replay", false, &c.Fixtures.Mode},
+		{"provider-fixtures-dir", "PROVIDER_FIXTURES_DIR", "directory of provider fixture files", false, &c.Fixtures.Dir},
+		{"fake-latency", "FAKE_LATENCY", "delay of each search with API_PROVIDER=fake", false, &c.Fake.Latency},
+		{"fake-failure-rate", "FAKE_FAILURE_RATE", "fraction of searches with API_PROVIDER=fake that fail, 0-1", false, &c.Fake.FailureRate},
+	}
+}
+
+// flagSet registers a flag for each of c's settings
+func (c *Config) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	for _, s := range c.settings() {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		switch v := s.value.(type) {
+		case *string:
+			fs.StringVar(v, s.name, *v, usage)
+		case *bool:
+			fs.BoolVar(v, s.name, *v, usage)
+		case *int:
+			fs.IntVar(v, s.name, *v, usage)
+		case *float64:
+			fs.Float64Var(v, s.name, *v, usage)
+		case *time.Duration:
+			fs.DurationVar(v, s.name, *v, usage)
+		case *slog.Level:
+			fs.TextVar(v, s.name, *v, usage)
+		default:
+			panic(fmt.Sprintf("setting %s has unsupported type %T", s.name, v))
+		}
+	}
+	return fs
+}
+
+// LoadConfig builds the configuration from, in increasing precedence: defaults,
+// a config file (--config or CONFIG_FILE, else ./.env if present), environment
+// variables from getenv and the command-line args. It reports whether
+// --print-config was given. All invalid settings are reported together.
+func LoadConfig(args []string, getenv func(string) string) (cfg *Config, printConfig bool, err error) {
+	cfg = DefaultConfig()
+	fs := cfg.flagSet("restaurant-bot")
+	fs.BoolVar(&printConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: restaurant-bot [flags]\n       restaurant-bot <command> [flags] (see restaurant-bot help)\n\n")
+		fmt.Fprintf(fs.Output(), "Flags override environment variables, which override the config file.\n\n")
+		fs.PrintDefaults()
+	}
+	errs, err := cfg.load(fs, args, getenv)
+	if err != nil {
+		return nil, false, err
+	}
+	if fs.NArg() > 0 {
+		return nil, false, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if err := errors.Join(append(errs, cfg.check()...)...); err != nil {
+		return nil, false, err
+	}
+	return cfg, printConfig, nil
+}
+
+// load parses args with fs, which holds c's flags and possibly a command's own,
+// then applies the config file and getenv to the settings no flag was given for.
+// Invalid setting values are returned as errs; err reports bad flags or an
+// unreadable config file.
+func (c *Config) load(fs *flag.FlagSet, args []string, getenv func(string) string) (errs []error, err error) {
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "YAML (.yaml/.yml) or .env config file (env CONFIG_FILE)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	fromFlag := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { fromFlag[f.Name] = true })
+
+	path := *configFile
+	if path == "" {
+		if _, statErr := os.Stat(dotenvPath); statErr == nil {
+			path = dotenvPath
+		}
+	}
+	var fileValues map[string]string
+	if path != "" {
+		if fileValues, err = readConfigFile(path, c.settings()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range c.settings() {
+		if fromFlag[s.name] {
+			continue
+		}
+		if value, ok := fileValues[s.name]; ok {
+			if err := fs.Set(s.name, strings.TrimSpace(value)); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q in %s: %s", s.name, value, path, s.expected()))
+			}
+		}
+		if value := getenv(s.env); value != "" {
+			if err := fs.Set(s.name, strings.TrimSpace(value)); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q: %s", s.env, value, s.expected()))
+			}
+		}
+	}
+	return errs, nil
+}
+
+// expected describes the values a setting of this kind accepts
+func (s setting) expected() string {
+	switch s.value.(type) {
+	case *bool:
+		return "must be true or false"
+	case *int:
+		return "must be an integer"
+	case *float64:
+		return "must be a number"
+	case *time.Duration:
+		return "must be a duration like 30s"
+	case *slog.Level:
+		return "must be debug, info, warn or error"
+	}
+	return "invalid value"
+}
+
+// check lowercases the enumerated settings and returns every problem with c.
+// Settings that failed to parse keep their defaults, so checking them is still useful.
+func (c *Config) check() []error {
+	for _, field := range []*string{&c.Provider, &c.Geocoder.Provider, &c.Log.Format, &c.Log.Coordinates, &c.Tracing.Exporter, &c.Fixtures.Mode} {
+		*field = strings.ToLower(*field)
+	}
+
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	oneOf := func(env, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		invalid("invalid %s %q: must be %s", env, value, strings.Join(allowed, ", "))
+	}
+
+	oneOf("API_PROVIDER", c.Provider, "google", "osm", "both", "fake")
+	if c.Provider == "google" && c.GoogleMapsAPIKey == "" {
+		invalid("GOOGLE_MAPS_API_KEY is required when API_PROVIDER is google")
+	}
+	if c.Telegram.Enabled && c.Telegram.Token == "" {
+		invalid("TELEGRAM_BOT_TOKEN is required when ENABLE_TELEGRAM_BOT is true")
+	}
+
+	for _, s := range c.settings() {
+		negative := false
+		switch v := s.value.(type) {
+		case *int:
+			negative = *v < 0
+		case *float64:
+			negative = *v < 0
+		case *time.Duration:
+			negative = *v < 0
+		}
+		if negative {
+			invalid("invalid %s: must not be negative", s.env)
+		}
+	}
+	if c.RequestTimeout == 0 {
+		invalid("invalid REQUEST_TIMEOUT: must be positive")
+	}
+	if c.Photos.MinRating > 5 {
+		invalid("invalid PHOTO_MIN_RATING %v: ratings go up to 5", c.Photos.MinRating)
+	}
+	if c.Photos.Dir == "" {
+		invalid("PHOTO_DIR must not be empty")
+	}
+
+	oneOf("GEOCODER", c.Geocoder.Provider, "nominatim", "google")
+	if c.Geocoder.Provider == "google" && c.GoogleMapsAPIKey == "" {
+		invalid("GEOCODER=google requires GOOGLE_MAPS_API_KEY")
+	}
+	if _, err := url.ParseRequestURI(c.Geocoder.NominatimURL); err != nil {
+		invalid("invalid NOMINATIM_URL %q: %v", c.Geocoder.NominatimURL, err)
+	}
+
+	if _, err := parseTrustedProxies(c.RateLimits.TrustedProxies); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := parseAPIKeys(c.Access.APIKeys); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.HTTP.Port < 1 || c.HTTP.Port > 65535 {
+		invalid("invalid HTTP_PORT %d: must be between 1 and 65535", c.HTTP.Port)
+	}
+	oneOf("LOG_FORMAT", c.Log.Format, "text", "json")
+	oneOf("LOG_COORDINATES", c.Log.Coordinates, CoordinatesExact, CoordinatesCoarse, CoordinatesHidden)
+	oneOf("TRACING_EXPORTER", c.Tracing.Exporter, "none", "otlp")
+	if c.Tracing.SampleRatio > 1 {
+		invalid("invalid TRACING_SAMPLE_RATIO %v: must be between 0 and 1", c.Tracing.SampleRatio)
+	}
+	if c.Fake.Latency < 0 {
+		invalid("invalid FAKE_LATENCY %s: must not be negative", c.Fake.Latency)
+	}
+	if c.Fake.FailureRate < 0 || c.Fake.FailureRate > 1 {
+		invalid("invalid FAKE_FAILURE_RATE %v: must be between 0 and 1", c.Fake.FailureRate)
+	}
+	if c.Fixtures.Mode != "" {
+		oneOf("PROVIDER_FIXTURES", c.Fixtures.Mode, FixturesRecord, FixturesReplay)
+		if c.Fixtures.Dir == "" {
+			invalid("PROVIDER_FIXTURES_DIR is required when PROVIDER_FIXTURES is set")
+		}
+	}
+	return errs
+}
+
+// readConfigFile reads setting values from a YAML file (keyed by setting name,
+// nested maps joined with "-") or a .env file (keyed by environment variable)
+func readConfigFile(path string, settings []setting) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	known := make(map[string]string) // setting name by name and env
+	for _, s := range settings {
+		known[s.name] = s.name
+		known[s.env] = s.name
+	}
+
+	values := make(map[string]string)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		raw := make(map[string]string)
+		if len(doc.Content) > 0 {
+			if err := flattenYAML(doc.Content[0], "", raw); err != nil {
+				return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+			}
+		}
+		for key, value := range raw {
+			name, ok := known[key]
+			if !ok {
+				return nil, fmt.Errorf("unknown setting %q in %s", key, path)
+			}
+			values[name] = value
+		}
+	default:
+		raw, err := parseDotenv(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		// .env files are shared with docker compose and friends, so other keys are ignored
+		for key, value := range raw {
+			if name, ok := known[key]; ok {
+				values[name] = value
+			}
+		}
+	}
+	return values, nil
+}
+
+// flattenYAML collects the scalars under node into values, joining nested keys
+// with "-"; lists of scalars become comma-separated values
+func flattenYAML(node *yaml.Node, prefix string, values map[string]string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if prefix != "" {
+				key = prefix + "-" + key
+			}
+			if err := flattenYAML(node.Content[i+1], key, values); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: %s must be a list of values", item.Line, prefix)
+			}
+			items = append(items, item.Value)
+		}
+		values[prefix] = strings.Join(items, ",")
+	case yaml.ScalarNode:
+		if prefix == "" {
+			return fmt.Errorf("line %d: expected a map of settings", node.Line)
+		}
+		values[prefix] = node.Value
+	default:
+		return fmt.Errorf("line %d: unsupported value for %s", node.Line, prefix)
+	}
+	return nil
+}
+
+// parseDotenv parses KEY=value lines; values may be quoted, and unquoted values
+// end at " #"
+func parseDotenv(data string) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=value", line)
+		}
+		value = strings.TrimSpace(value)
+		if n := len(value); n >= 2 && (value[0] == '"' || value[0] == '\'') && value[n-1] == value[0] {
+			value = value[1 : n-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, scanner.Err()
+}
+
+// Print writes c as a YAML config file that LoadConfig accepts, with secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	fs := c.flagSet("print")
+	for _, s := range c.settings() {
+		value := fs.Lookup(s.name).Value.String()
+		if s.secret && value != "" {
+			value = redactedValue
+		}
+		if _, ok := s.value.(*string); ok {
+			quoted, err := yaml.Marshal(value)
+			if err != nil {
+				return err
+			}
+			value = strings.TrimSpace(string(quoted))
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s # %s\n", s.name, value, s.env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewRestaurantBotFromConfig creates the bot with everything cfg configures:
+// Telegram (when enabled), providers, tunables, cost ledger, rate limits,
+// access control, tracing and the geocoder
+func NewRestaurantBotFromConfig(cfg *Config) (*RestaurantBot, error) {
+	telegramToken := ""
+	if cfg.Telegram.Enabled {
+		telegramToken = cfg.Telegram.Token
+	}
+	bot, err := NewRestaurantBot(telegramToken, cfg.GoogleMapsAPIKey, cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	bot.applyConfig(cfg)
+
+	// Google spend accounting and caps
+	if bot.costs, err = NewCostLedger(cfg.Costs.LedgerPath, cfg.Costs.DailyBudgetUSD, cfg.Costs.MonthlyBudgetUSD); err != nil {
+		return nil, fmt.Errorf("failed to create cost ledger: %w", err)
+	}
+	// Per-client rate limits for the HTTP API and Telegram bot
+	if bot.limits, err = NewClientRateLimits(cfg.RateLimits); err != nil {
+		return nil, fmt.Errorf("failed to configure rate limits: %w", err)
+	}
+	// API key authentication and CORS origin allow-list
+	if bot.auth, bot.cors, err = NewAPIAccess(cfg.Access); err != nil {
+		return nil, fmt.Errorf("failed to configure API access: %w", err)
+	}
+	bot.metricsToken = cfg.Access.MetricsToken
+	// OpenTelemetry tracing, set up before anything that makes provider calls
+	if bot.tracing, err = NewTracingFromConfig(cfg.Tracing); err != nil {
+		return nil, fmt.Errorf("failed to configure tracing: %w", err)
+	}
+	// Recorded provider responses instead of live calls, set up before the geocoder
+	fixtures, err := NewFixtures(cfg.Fixtures)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure provider fixtures: %w", err)
+	}
+	if err := bot.useFixtures(fixtures); err != nil {
+		return nil, err
+	}
+	// Geocoding for q= searches and the /near command
+	if bot.geocoder, err = NewGeocoder(cfg.Geocoder, bot.mapsClient, bot.costs, bot.metrics, bot.providerTransport(EndpointNominatim)); err != nil {
+		return nil, fmt.Errorf("failed to configure geocoder: %w", err)
+	}
+	return bot, nil
+}
+
+// applyConfig sets the cache, photo and timeout tunables and the place trace on rb
+func (rb *RestaurantBot) applyConfig(cfg *Config) {
+	rb.requestTimeout = cfg.RequestTimeout
+	rb.photos = cfg.Photos
+	rb.fake = cfg.Fake
+	rb.cache.configure(cfg.Cache)
+	rb.trace = NewPlaceTrace(cfg.Log.TracePlace)
+}
+
+// configure sets the cache tunables and file
+func (lc *LocationCache) configure(cfg CacheConfig) {
+	lc.ttl = cfg.TTL
+	lc.degradedTTL = cfg.DegradedTTL
+	lc.radiusMeters = cfg.RadiusMeters
+	lc.file = cfg.File
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadTestConfig loads the configuration from env and args, ignoring any ./.env.
+// API_PROVIDER defaults to osm so no Google key is needed.
+func loadTestConfig(t *testing.T, env map[string]string, args ...string) (*Config, error) {
+	t.Helper()
+	previous := dotenvPath
+	dotenvPath = filepath.Join(t.TempDir(), ".env")
+	t.Cleanup(func() { dotenvPath = previous })
+	getenv := func(name string) string {
+		if value, ok := env[name]; ok {
+			return value
+		}
+		if name == "API_PROVIDER" {
+			return "osm"
+		}
+		return ""
+	}
+	cfg, _, err := LoadConfig(args, getenv)
+	return cfg, err
+}
+
+// writeFile writes content to name in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg, err := loadTestConfig(t, nil)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := DefaultConfig()
+	want.Provider = "osm"
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v\nwant %+v", cfg, want)
+	}
+
+	names, envs := map[string]bool{}, map[string]bool{}
+	for _, s := range cfg.settings() {
+		if names[s.name] || envs[s.env] {
+			t.Errorf("setting %s (%s) is listed twice", s.name, s.env)
+		}
+		names[s.name], envs[s.env] = true, true
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	file := writeFile(t, "bot.yaml", `
+cache:
+  ttl: 1h
+  radius-meters: 50
+photo:
+  min-reviews: 10
+http-port: 9000
+cors-allowed-origins:
+  - https://a.example
+  - https://b.example
+`)
+	env := map[string]string{"CONFIG_FILE": file, "CACHE_TTL": "2h", "HTTP_PORT": "9200"}
+	cfg, err := loadTestConfig(t, env, "--http-port", "9100")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Cache.TTL != 2*time.Hour {
+		t.Errorf("env should override the file: cache TTL %s", cfg.Cache.TTL)
+	}
+	if cfg.Cache.RadiusMeters != 50 || cfg.Photos.MinReviews != 10 || cfg.Access.CORSAllowedOrigins != "https://a.example,https://b.example" {
+		t.Errorf("file values not applied: %+v %+v %+v", cfg.Cache, cfg.Photos, cfg.Access)
+	}
+	if cfg.HTTP.Port != 9100 {
+		t.Errorf("flags should override env and file: port %d", cfg.HTTP.Port)
+	}
+	if cfg.Cache.DegradedTTL != defaultDegradedCacheTTL {
+		t.Errorf("unset values should keep their default: %s", cfg.Cache.DegradedTTL)
+	}
+}
+
+func TestConfigDotenv(t *testing.T) {
+	content := `# comment
+export API_PROVIDER=both
+GOOGLE_MAPS_API_KEY="key with spaces"
+LOG_LEVEL=debug   # inline comment
+COMPOSE_PROJECT_NAME=ignored
+`
+	path := writeFile(t, ".env", content)
+	// Loaded automatically from the working directory...
+	previous := dotenvPath
+	dotenvPath = path
+	defer func() { dotenvPath = previous }()
+	cfg, _, err := LoadConfig(nil, func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Provider != "both" || cfg.GoogleMapsAPIKey != "key with spaces" || cfg.Log.Level.String() != "DEBUG" {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	// ...or named with --config
+	cfg, err = loadTestConfig(t, map[string]string{"API_PROVIDER": ""}, "--config", path)
+	if err != nil || cfg.Provider != "both" {
+		t.Errorf("--config %s: %+v, %v", path, cfg, err)
+	}
+}
+
+func TestConfigValidationReportsAllErrors(t *testing.T) {
+	_, err := loadTestConfig(t, map[string]string{
+		"CACHE_TTL":           "forever",
+		"HTTP_PORT":           "http",
+		"ENABLE_TELEGRAM_BOT": "yes please",
+	})
+	if err == nil {
+		t.Fatal("invalid values accepted")
+	}
+	for _, want := range []string{"CACHE_TTL", "HTTP_PORT", "ENABLE_TELEGRAM_BOT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+
+	_, err = loadTestConfig(t, map[string]string{
+		"API_PROVIDER":        "google",
+		"ENABLE_TELEGRAM_BOT": "true",
+		"PHOTO_MIN_RATING":    "7",
+		"TRUSTED_PROXIES":     "not-an-ip",
+		"CACHE_RADIUS_METERS": "-5",
+	})
+	if err == nil {
+		t.Fatal("invalid configuration accepted")
+	}
+	for _, want := range []string{"GOOGLE_MAPS_API_KEY", "TELEGRAM_BOT_TOKEN", "PHOTO_MIN_RATING", "not-an-ip", "CACHE_RADIUS_METERS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+
+	file := writeFile(t, "bot.yml", "cache:\n  tll: 1h\n")
+	if _, err := loadTestConfig(t, nil, "--config", file); err == nil || !strings.Contains(err.Error(), "cache-tll") {
+		t.Errorf("unknown YAML key: %v", err)
+	}
+}
+
+func TestPrintConfigRedactsSecrets(t *testing.T) {
+	env := map[string]string{
+		"API_PROVIDER":        "google",
+		"GOOGLE_MAPS_API_KEY": "g-secret",
+		"ENABLE_TELEGRAM_BOT": "true",
+		"TELEGRAM_BOT_TOKEN":  "t-secret",
+		"API_KEYS":            "partner:k-secret",
+		"PHOTO_DIR":           "/data/photos",
+	}
+	cfg, err := loadTestConfig(t, env)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	if strings.Contains(out.String(), "secret") {
+		t.Errorf("secrets printed:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "photo-dir: /data/photos # PHOTO_DIR") {
+		t.Errorf("missing photo-dir:\n%s", out.String())
+	}
+
+	// The output is a config file that, with the secrets from the environment,
+	// loads back to the same configuration
+	secrets := map[string]string{"API_PROVIDER": ""}
+	for _, name := range []string{"GOOGLE_MAPS_API_KEY", "TELEGRAM_BOT_TOKEN", "API_KEYS"} {
+		secrets[name] = env[name]
+	}
+	printed, err := loadTestConfig(t, secrets, "--config", writeFile(t, "printed.yaml", out.String()))
+	if err != nil {
+		t.Fatalf("loading printed config: %v", err)
+	}
+	if !reflect.DeepEqual(printed, cfg) {
+		t.Errorf("printed config = %+v\nwant %+v", printed, cfg)
+	}
+}
+
+func TestApplyConfigTunables(t *testing.T) {
+	cfg, err := loadTestConfig(t, map[string]string{
+		"REQUEST_TIMEOUT":     "3s",
+		"CACHE_TTL":           "1h",
+		"CACHE_RADIUS_METERS": "100",
+		"PHOTO_MIN_RATING":    "3",
+		"PHOTO_MIN_REVIEWS":   "0",
+	})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	bot, err := NewRestaurantBot("", "", "osm")
+	if err != nil {
+		t.Fatalf("NewRestaurantBot: %v", err)
+	}
+	if !bot.photos.shouldUseGenericPhoto("ref", 3.5, 0) {
+		t.Error("default thresholds should give a 3.5-rated place without reviews the generic photo")
+	}
+
+	bot.applyConfig(cfg)
+	if bot.requestTimeout != 3*time.Second || bot.cache.ttl != time.Hour || bot.cache.radiusMeters != 100 {
+		t.Errorf("tunables not applied: timeout %s, cache %s/%vm", bot.requestTimeout, bot.cache.ttl, bot.cache.radiusMeters)
+	}
+	if bot.photos.shouldUseGenericPhoto("ref", 3.5, 0) {
+		t.Error("configured thresholds should allow a real photo")
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCostLedgerPath   = "/restaurant/costs.json" // Path to persisted per-day Google spend totals
+	costLedgerFlushInterval = 30 * time.Second         // How often dirty ledger totals are written to disk
+	costLedgerRetentionDays = 400                      // Daily totals older than this are dropped
+)
+
+// GoogleSKU identifies a billable Google Maps Platform request type
+type GoogleSKU string
+
+const (
+	SKUNearbySearch GoogleSKU = "nearby_search"
+	SKUTextSearch   GoogleSKU = "text_search"
+	SKUPlacePhoto   GoogleSKU = "place_photo"
+	SKUPlaceDetails GoogleSKU = "place_details"
+	SKUGeocoding    GoogleSKU = "geocoding"
+)
+
+// googleSKUPrices lists the price in USD of a single request per SKU
+// Places API pricing: $32.00 per 1,000 searches, $7.00 per 1,000 photos,
+// $25.00 per 1,000 details requests (basic + contact + atmosphere fields),
+// $5.00 per 1,000 geocoding requests
+var googleSKUPrices = map[GoogleSKU]float64{
+	SKUNearbySearch: 0.032,
+	SKUTextSearch:   0.032,
+	SKUPlacePhoto:   0.007,
+	SKUPlaceDetails: 0.025,
+	SKUGeocoding:    0.005,
+}
+
+// DailyCost holds the Google request counts and spend for a single UTC day
+type DailyCost struct {
+	Date     string            `json:"date"`     // UTC day in YYYY-MM-DD format
+	Requests map[GoogleSKU]int `json:"requests"` // Number of billable requests per SKU
+	CostUSD  float64           `json:"costUsd"`  // Estimated spend for the day
+}
+
+// CostReport is the JSON document served by /api/costs
+type CostReport struct {
+	Today              DailyCost             `json:"today"`
+	MonthToDateUSD     float64               `json:"monthToDateUsd"`
+	DailyBudgetUSD     float64               `json:"dailyBudgetUsd,omitempty"`   // 0 means unlimited
+	MonthlyBudgetUSD   float64               `json:"monthlyBudgetUsd,omitempty"` // 0 means unlimited
+	BudgetExceeded     bool                  `json:"budgetExceeded"`
+	BudgetExceededNote string                `json:"budgetExceededNote,omitempty"`
+	Prices             map[GoogleSKU]float64 `json:"prices"`
+	Days               []DailyCost           `json:"days"` // Most recent first
+}
+
+// CostLedger counts billable Google requests by SKU and enforces spend caps.
+// Totals are kept per UTC day and periodically persisted to disk so they
+// survive restarts.
+type CostLedger struct {
+	mu            sync.Mutex
+	flushMu       sync.Mutex // serializes writes of the ledger file
+	path          string     // empty disables persistence
+	days          map[string]*DailyCost
+	session       map[GoogleSKU]int // requests since start, for metrics
+	dailyBudget   float64
+	monthlyBudget float64
+	dirty         bool
+	now           func() time.Time
+}
+
+// CostConfig sets where Google spend is persisted and the spend caps
+type CostConfig struct {
+	LedgerPath       string
+	DailyBudgetUSD   float64 // 0 = unlimited
+	MonthlyBudgetUSD float64 // 0 = unlimited
+}
+
+// NewCostLedger creates a ledger, loading previously persisted totals from path.
+// A zero budget disables that cap.
+func NewCostLedger(path string, dailyBudget, monthlyBudget float64) (*CostLedger, error) {
+	if dailyBudget < 0 || monthlyBudget < 0 {
+		return nil, fmt.Errorf("budgets must not be negative")
+	}
+
+	cl := &CostLedger{
+		path:          path,
+		days:          make(map[string]*DailyCost),
+		session:       make(map[GoogleSKU]int),
+		dailyBudget:   dailyBudget,
+		monthlyBudget: monthlyBudget,
+		now:           time.Now,
+	}
+
+	if path != "" {
+		if err := cl.load(); err != nil {
+			return nil, err
+		}
+		// Start flush goroutine
+		go cl.flushLoop()
+	}
+
+	return cl, nil
+}
+
+// load reads persisted daily totals, ignoring a missing file
+func (cl *CostLedger) load() error {
+	data, err := os.ReadFile(cl.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cost ledger %s: %w", cl.path, err)
+	}
+
+	var days []DailyCost
+	if err := json.Unmarshal(data, &days); err != nil {
+		return fmt.Errorf("failed to decode cost ledger %s: %w", cl.path, err)
+	}
+	for i := range days {
+		day := days[i]
+		if day.Requests == nil {
+			day.Requests = make(map[GoogleSKU]int)
+		}
+		cl.days[day.Date] = &day
+	}
+	slog.Info("[COSTS] Loaded Google spend", "days", len(days), "file", cl.path)
+	return nil
+}
+
+// flushLoop periodically persists dirty totals
+func (cl *CostLedger) flushLoop() {
+	ticker := time.NewTicker(costLedgerFlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := cl.Flush(); err != nil {
+			slog.Error("[COSTS] Failed to save Google spend", "error", err)
+		}
+	}
+}
+
+// Flush writes the ledger to disk if it changed since the last flush
+func (cl *CostLedger) Flush() error {
+	if cl.path == "" {
+		return nil
+	}
+	cl.flushMu.Lock()
+	defer cl.flushMu.Unlock()
+
+	cl.mu.Lock()
+	if !cl.dirty {
+		cl.mu.Unlock()
+		return nil
+	}
+	days := cl.sortedDaysLocked()
+	cl.dirty = false
+	cl.mu.Unlock()
+
+	data, err := json.MarshalIndent(days, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cost ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cl.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cost ledger directory: %w", err)
+	}
+	// Write to a temp file first so a crash never leaves a truncated ledger
+	tmpPath := cl.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cost ledger: %w", err)
+	}
+	if err := os.Rename(tmpPath, cl.path); err != nil {
+		return fmt.Errorf("failed to replace cost ledger: %w", err)
+	}
+	return nil
+}
+
+// Record counts one billable request for the given SKU
+func (cl *CostLedger) Record(sku GoogleSKU) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	date := cl.now().UTC().Format("2006-01-02")
+	day, ok := cl.days[date]
+	if !ok {
+		day = &DailyCost{Date: date, Requests: make(map[GoogleSKU]int)}
+		cl.days[date] = day
+		cl.pruneLocked()
+	}
+	day.Requests[sku]++
+	day.CostUSD += googleSKUPrices[sku]
+	cl.session[sku]++
+	cl.dirty = true
+}
+
+// SessionRequests returns the billable requests per SKU since the process started
+func (cl *CostLedger) SessionRequests() map[GoogleSKU]int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	requests := make(map[GoogleSKU]int, len(cl.session))
+	for sku, n := range cl.session {
+		requests[sku] = n
+	}
+	return requests
+}
+
+// pruneLocked drops days older than the retention window
+func (cl *CostLedger) pruneLocked() {
+	cutoff := cl.now().UTC().AddDate(0, 0, -costLedgerRetentionDays).Format("2006-01-02")
+	for date := range cl.days {
+		if date < cutoff {
+			delete(cl.days, date)
+		}
+	}
+}
+
+// BudgetExceeded reports whether the daily or monthly spend cap has been reached,
+// along with a human-readable reason
+func (cl *CostLedger) BudgetExceeded() (bool, string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.budgetExceededLocked()
+}
+
+func (cl *CostLedger) budgetExceededLocked() (bool, string) {
+	today := cl.todayLocked()
+	if cl.dailyBudget > 0 && today.CostUSD >= cl.dailyBudget {
+		return true, fmt.Sprintf("daily Google budget of $%.2f reached ($%.2f spent today)", cl.dailyBudget, today.CostUSD)
+	}
+	month := cl.monthToDateLocked()
+	if cl.monthlyBudget > 0 && month >= cl.monthlyBudget {
+		return true, fmt.Sprintf("monthly Google budget of $%.2f reached ($%.2f spent this month)", cl.monthlyBudget, month)
+	}
+	return false, ""
+}
+
+// Report returns a snapshot of the ledger for the /api/costs endpoint
+func (cl *CostLedger) Report() CostReport {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	exceeded, note := cl.budgetExceededLocked()
+	days := cl.sortedDaysLocked()
+	// Most recent first
+	sort.Slice(days, func(i, j int) bool { return days[i].Date > days[j].Date })
+
+	return CostReport{
+		Today:              cl.todayLocked(),
+		MonthToDateUSD:     cl.monthToDateLocked(),
+		DailyBudgetUSD:     cl.dailyBudget,
+		MonthlyBudgetUSD:   cl.monthlyBudget,
+		BudgetExceeded:     exceeded,
+		BudgetExceededNote: note,
+		Prices:             googleSKUPrices,
+		Days:               days,
+	}
+}
+
+// todayLocked returns a copy of today's totals (zero value if none recorded yet)
+func (cl *CostLedger) todayLocked() DailyCost {
+	date := cl.now().UTC().Format("2006-01-02")
+	if day, ok := cl.days[date]; ok {
+		return copyDailyCost(day)
+	}
+	return DailyCost{Date: date, Requests: map[GoogleSKU]int{}}
+}
+
+func (cl *CostLedger) monthToDateLocked() float64 {
+	month := cl.now().UTC().Format("2006-01")
+	total := 0.0
+	for date, day := range cl.days {
+		if date[:7] == month {
+			total += day.CostUSD
+		}
+	}
+	return total
+}
+
+// sortedDaysLocked returns copies of all days in ascending date order
+func (cl *CostLedger) sortedDaysLocked() []DailyCost {
+	days := make([]DailyCost, 0, len(cl.days))
+	for _, day := range cl.days {
+		days = append(days, copyDailyCost(day))
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+func copyDailyCost(day *DailyCost) DailyCost {
+	requests := make(map[GoogleSKU]int, len(day.Requests))
+	for sku, n := range day.Requests {
+		requests[sku] = n
+	}
+	return DailyCost{Date: day.Date, Requests: requests, CostUSD: day.CostUSD}
+}
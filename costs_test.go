@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLedger creates an unpersisted ledger on clock
+func newTestLedger(t *testing.T, daily, monthly float64, clock *testClock) *CostLedger {
+	t.Helper()
+	cl, err := NewCostLedger("", daily, monthly)
+	if err != nil {
+		t.Fatalf("NewCostLedger: %v", err)
+	}
+	cl.now = clock.Now
+	return cl
+}
+
+func TestCostLedgerBudgetCutoff(t *testing.T) {
+	tests := []struct {
+		name            string
+		daily, monthly  float64
+		searches        int
+		wantExceeded    bool
+		wantReasonStart string
+	}{
+		{"unlimited", 0, 0, 1000, false, ""},
+		{"under the daily cap", 0.10, 0, 3, false, ""}, // $0.096
+		{"at the daily cap", 0.096, 0, 3, true, "daily Google budget of $0.10"},
+		{"over the daily cap", 0.10, 0, 4, true, "daily"},
+		{"under the monthly cap", 0, 1, 31, false, ""}, // $0.992
+		{"over the monthly cap", 0, 1, 32, true, "monthly Google budget of $1.00"},
+		{"daily reported before monthly", 0.05, 0.05, 2, true, "daily"},
+	}
+	for _, tt := range tests {
+		clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+		cl := newTestLedger(t, tt.daily, tt.monthly, clock)
+		for i := 0; i < tt.searches; i++ {
+			cl.Record(SKUNearbySearch)
+		}
+		exceeded, reason := cl.BudgetExceeded()
+		if exceeded != tt.wantExceeded || !strings.HasPrefix(reason, tt.wantReasonStart) {
+			t.Errorf("%s: exceeded=%v %q, want %v %q", tt.name, exceeded, reason, tt.wantExceeded, tt.wantReasonStart)
+		}
+		if report := cl.Report(); report.BudgetExceeded != exceeded || report.BudgetExceededNote != reason {
+			t.Errorf("%s: report %+v disagrees with BudgetExceeded", tt.name, report)
+		}
+	}
+
+	if _, err := NewCostLedger("", -1, 0); err == nil {
+		t.Error("negative daily budget accepted")
+	}
+}
+
+func TestCostLedgerRollover(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 31, 23, 59, 0, 0, time.UTC)}
+	cl := newTestLedger(t, 0.05, 0.10, clock)
+
+	cl.Record(SKUNearbySearch)
+	cl.Record(SKUPlacePhoto)
+	if exceeded, _ := cl.BudgetExceeded(); exceeded {
+		t.Fatal("$0.039 exceeds a $0.05 daily cap")
+	}
+	cl.Record(SKUNearbySearch)
+	if exceeded, reason := cl.BudgetExceeded(); !exceeded || !strings.Contains(reason, "daily") {
+		t.Fatalf("$0.071 today: %v %q", exceeded, reason)
+	}
+
+	// Midnight UTC starts a new day and, here, a new month
+	clock.Advance(2 * time.Minute)
+	if exceeded, reason := cl.BudgetExceeded(); exceeded {
+		t.Errorf("new day and month still exceeded: %q", reason)
+	}
+	report := cl.Report()
+	if report.Today.Date != "2026-11-01" || report.Today.CostUSD != 0 || report.MonthToDateUSD != 0 {
+		t.Errorf("after midnight: today %+v, month to date %v", report.Today, report.MonthToDateUSD)
+	}
+	if len(report.Days) != 1 || report.Days[0].Date != "2026-10-31" || report.Days[0].Requests[SKUNearbySearch] != 2 {
+		t.Errorf("days = %+v", report.Days)
+	}
+
+	// The monthly cap counts every day of the month, not just today
+	for day := 0; day < 4; day++ {
+		cl.Record(SKUNearbySearch)
+		clock.Advance(24 * time.Hour)
+	}
+	if exceeded, reason := cl.BudgetExceeded(); !exceeded || !strings.Contains(reason, "monthly") {
+		t.Errorf("$0.128 this month: %v %q", exceeded, reason)
+	}
+	if got := cl.SessionRequests()[SKUNearbySearch]; got != 6 {
+		t.Errorf("%d session searches, want 6", got)
+	}
+}
+
+func TestCostLedgerFlushAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "costs", "ledger.json")
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+
+	cl, err := NewCostLedger(path, 1, 0)
+	if err != nil {
+		t.Fatalf("NewCostLedger: %v", err)
+	}
+	cl.now = clock.Now
+	if err := cl.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("an unchanged ledger was written")
+	}
+
+	cl.Record(SKUNearbySearch)
+	cl.Record(SKUGeocoding)
+	if err := cl.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	// Nothing changed since, so the next flush leaves the file alone
+	os.Remove(path)
+	if err := cl.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("a clean ledger was written again")
+	}
+	cl.Record(SKUNearbySearch)
+	if err := cl.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	reloaded, err := NewCostLedger(path, 1, 0)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	reloaded.now = clock.Now
+	today := reloaded.Report().Today
+	if today.Requests[SKUNearbySearch] != 2 || today.Requests[SKUGeocoding] != 1 || today.CostUSD < 0.0689 || today.CostUSD > 0.0691 {
+		t.Errorf("reloaded today = %+v", today)
+	}
+	// Restarting doesn't reset the spend cap, but the session counters start over
+	reloaded.Record(SKUPlaceDetails)
+	if got := reloaded.SessionRequests(); len(got) != 1 || got[SKUPlaceDetails] != 1 {
+		t.Errorf("session requests after restart = %v", got)
+	}
+
+	// Days past the retention window are dropped when a new day starts
+	clock.Advance((costLedgerRetentionDays + 1) * 24 * time.Hour)
+	reloaded.Record(SKUNearbySearch)
+	if days := reloaded.Report().Days; len(days) != 1 {
+		t.Errorf("%d days kept, want only today", len(days))
+	}
+
+	os.WriteFile(path, []byte("{not json"), 0644)
+	if _, err := NewCostLedger(path, 0, 0); err == nil || !strings.Contains(err.Error(), "decode") {
+		t.Errorf("corrupt ledger: %v", err)
+	}
+}
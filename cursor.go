@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotTTL        = 30 * time.Minute // How long a cursor stays valid after its last use
+	maxResultSnapshots = 1000             // Oldest snapshots are evicted beyond this
+)
+
+// cacheVersion identifies one version of a LocationCache entry. The version
+// changes every time the entry is replaced (e.g. by a background refresh).
+type cacheVersion struct {
+	Key     string
+	Version uint64
+}
+
+// resultSnapshot is an immutable, filtered and sorted result list that cursors page through
+type resultSnapshot struct {
+	restaurants []Restaurant
+	stats       SearchStats
+	filters     ResultFilters
+	version     uint64
+	expiresAt   time.Time
+}
+
+// pageCursor is the decoded form of the opaque cursor token
+type pageCursor struct {
+	Snapshot string `json:"s"` // snapshot key
+	Version  uint64 `json:"v"` // cache entry version the snapshot was built from
+	Offset   int    `json:"o"`
+	Limit    int    `json:"l"`
+}
+
+// encodeCursor returns the opaque token for a cursor
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses an opaque cursor token
+func decodeCursor(token string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, fmt.Errorf("malformed cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Snapshot == "" {
+		return c, fmt.Errorf("malformed cursor")
+	}
+	if c.Offset < 0 || c.Limit <= 0 || c.Limit > 100 {
+		return c, fmt.Errorf("malformed cursor")
+	}
+	return c, nil
+}
+
+// snapshotKey derives a stable key for the result list of a search. Clients
+// requesting the same cache version with the same filters share a snapshot.
+func snapshotKey(source cacheVersion, params SearchParams, filters ResultFilters) string {
+	categories := make([]string, len(params.Categories))
+	for i, c := range params.Categories {
+		categories[i] = string(c)
+	}
+	filterJSON, _ := json.Marshal(filters)
+	raw := fmt.Sprintf("%s|%d|%s|%s|%s", source.Key, source.Version,
+		strings.Join(categories, ","), strings.ToLower(params.Keyword), filterJSON)
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:12])
+}
+
+// SnapshotStore keeps result snapshots so cursor pagination stays consistent
+// even if the underlying cache entry is refreshed between pages
+type SnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string]*resultSnapshot
+	now       func() time.Time
+	counters  cacheCounters
+}
+
+// NewSnapshotStore creates an empty snapshot store
+func NewSnapshotStore() *SnapshotStore {
+	store := &SnapshotStore{
+		snapshots: make(map[string]*resultSnapshot),
+		now:       time.Now,
+	}
+	// Start cleanup goroutine
+	go store.cleanup()
+	return store
+}
+
+// cleanup removes expired snapshots
+func (ss *SnapshotStore) cleanup() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		ss.mu.Lock()
+		now := ss.now()
+		for key, snap := range ss.snapshots {
+			if now.After(snap.expiresAt) {
+				delete(ss.snapshots, key)
+				ss.counters.evictions.Add(1)
+			}
+		}
+		ss.mu.Unlock()
+	}
+}
+
+// Put stores a snapshot under key (keeping an existing one with the same key)
+func (ss *SnapshotStore) Put(key string, version uint64, restaurants []Restaurant, stats SearchStats, filters ResultFilters) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	now := ss.now()
+	if existing, ok := ss.snapshots[key]; ok && now.Before(existing.expiresAt) {
+		existing.expiresAt = now.Add(snapshotTTL)
+		return
+	}
+
+	// Evict the snapshot closest to expiry when full
+	if len(ss.snapshots) >= maxResultSnapshots {
+		var oldestKey string
+		var oldest time.Time
+		for k, snap := range ss.snapshots {
+			if oldestKey == "" || snap.expiresAt.Before(oldest) {
+				oldestKey, oldest = k, snap.expiresAt
+			}
+		}
+		delete(ss.snapshots, oldestKey)
+		ss.counters.evictions.Add(1)
+	}
+
+	ss.snapshots[key] = &resultSnapshot{
+		restaurants: restaurants,
+		stats:       stats,
+		filters:     filters,
+		version:     version,
+		expiresAt:   now.Add(snapshotTTL),
+	}
+}
+
+// Len returns the number of stored snapshots
+func (ss *SnapshotStore) Len() int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return len(ss.snapshots)
+}
+
+// Get returns the snapshot a cursor refers to, extending its lifetime.
+// Returns false if it has expired or no longer matches the cursor's version.
+func (ss *SnapshotStore) Get(c pageCursor) (*resultSnapshot, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	snap, ok := ss.snapshots[c.Snapshot]
+	now := ss.now()
+	if !ok || now.After(snap.expiresAt) || snap.version != c.Version {
+		ss.counters.lookup(false)
+		return nil, false
+	}
+	ss.counters.lookup(true)
+	snap.expiresAt = now.Add(snapshotTTL)
+	return snap, true
+}
+
+// clampPage limits a requested page number to the pages of totalItems results
+func clampPage(page, limit, totalItems int) int {
+	totalPages := (totalItems + limit - 1) / limit // Ceiling division
+	if totalPages == 0 {
+		totalPages = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	return page
+}
+
+// paginateResults builds the response page starting at offset, including
+// cursors that point back into the same snapshot
+func paginateResults(key string, version uint64, restaurants []Restaurant, stats SearchStats, filters ResultFilters, offset, limit int) PaginatedSearchResult {
+	totalItems := len(restaurants)
+	totalPages := (totalItems + limit - 1) / limit // Ceiling division
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	// Calculate slice indices
+	startIdx := offset
+	if startIdx > totalItems {
+		startIdx = totalItems
+	}
+	endIdx := startIdx + limit
+	if endIdx > totalItems {
+		endIdx = totalItems
+	}
+
+	pagination := Pagination{
+		Page:       startIdx/limit + 1,
+		Limit:      limit,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+		HasNext:    endIdx < totalItems,
+		HasPrev:    startIdx > 0,
+	}
+	if pagination.HasNext {
+		pagination.NextCursor = encodeCursor(pageCursor{Snapshot: key, Version: version, Offset: endIdx, Limit: limit})
+	}
+	if pagination.HasPrev {
+		prevOffset := startIdx - limit
+		if prevOffset < 0 {
+			prevOffset = 0
+		}
+		pagination.PrevCursor = encodeCursor(pageCursor{Snapshot: key, Version: version, Offset: prevOffset, Limit: limit})
+	}
+
+	return PaginatedSearchResult{
+		Restaurants: restaurants[startIdx:endIdx],
+		Stats:       stats,
+		Pagination:  pagination,
+		Filters:     filters,
+	}
+}
+
+// serveCursorPage answers a request that continues from a pagination cursor
+func serveCursorPage(w http.ResponseWriter, store *SnapshotStore, token string) {
+	cursor, err := decodeCursor(token)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidCursor, "Invalid cursor")
+		return
+	}
+	snap, ok := store.Get(cursor)
+	if !ok {
+		writeAPIError(w, http.StatusGone, ErrCodeCursorExpired,
+			"The result set for this cursor has expired, please repeat the search")
+		return
+	}
+
+	result := paginateResults(cursor.Snapshot, cursor.Version, snap.restaurants, snap.stats, snap.filters, cursor.Offset, cursor.Limit)
+	result.Stats.CachedResult = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
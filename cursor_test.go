@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestClampPage(t *testing.T) {
+	tests := []struct {
+		page, limit, total, want int
+	}{
+		{1, 20, 0, 1},
+		{3, 20, 0, 1},
+		{1, 20, 45, 1},
+		{3, 20, 45, 3},
+		{4, 20, 45, 3},
+		{2, 20, 40, 2},
+		{3, 20, 40, 2},
+		{99, 1, 1, 1},
+		{1 << 62, 100, 45, 1}, // no overflow when the handler computes the offset
+	}
+	for _, tt := range tests {
+		if got := clampPage(tt.page, tt.limit, tt.total); got != tt.want {
+			t.Errorf("clampPage(%d, %d, %d) = %d, want %d", tt.page, tt.limit, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestPaginateResultsProperties(t *testing.T) {
+	// Walking the pages of n results by page number, as the handler does, and by
+	// next cursor both return every result once, in order
+	prop := func(n, l uint8) bool {
+		total, limit := int(n), int(l)%100+1
+		restaurants := makeRestaurants(total)
+
+		first := paginateResults("key", 1, restaurants, SearchStats{}, ResultFilters{}, 0, limit)
+		pages := first.Pagination.TotalPages
+		if pages != clampPage(total/limit+2, limit, total) || pages < 1 || (pages-1)*limit > total || pages*limit < total {
+			return false
+		}
+
+		var byPage []Restaurant
+		for page := 1; page <= pages; page++ {
+			result := paginateResults("key", 1, restaurants, SearchStats{}, ResultFilters{}, (page-1)*limit, limit)
+			p := result.Pagination
+			if p.Page != page || p.TotalItems != total || p.HasPrev != (page > 1) || p.HasNext != (page < pages) ||
+				(p.NextCursor != "") != p.HasNext || (p.PrevCursor != "") != p.HasPrev {
+				return false
+			}
+			byPage = append(byPage, result.Restaurants...)
+		}
+
+		var byCursor []Restaurant
+		result := first
+		for {
+			byCursor = append(byCursor, result.Restaurants...)
+			if result.Pagination.NextCursor == "" {
+				break
+			}
+			c, err := decodeCursor(result.Pagination.NextCursor)
+			if err != nil || c.Limit != limit {
+				return false
+			}
+			result = paginateResults(c.Snapshot, c.Version, restaurants, SearchStats{}, ResultFilters{}, c.Offset, c.Limit)
+		}
+
+		if len(byPage) != total || len(byCursor) != total {
+			return false
+		}
+		for i, r := range restaurants {
+			if byPage[i].PlaceID != r.PlaceID || byCursor[i].PlaceID != r.PlaceID {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSnapshotStoreExpiry(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	store := NewSnapshotStore()
+	store.now = clock.Now
+	get := func(key string, version uint64) bool {
+		_, ok := store.Get(pageCursor{Snapshot: key, Version: version, Limit: 10})
+		return ok
+	}
+
+	store.Put("a", 1, makeRestaurants(3), SearchStats{}, ResultFilters{Sort: SortScore})
+	if !get("a", 1) {
+		t.Fatal("fresh snapshot not found")
+	}
+	if get("a", 2) {
+		t.Error("snapshot returned for another cache version")
+	}
+	if get("b", 1) {
+		t.Error("unknown snapshot returned")
+	}
+
+	// Each use extends the lifetime, so a cursor being paged through stays valid
+	for i := 0; i < 3; i++ {
+		clock.Advance(snapshotTTL - time.Minute)
+		if !get("a", 1) {
+			t.Fatalf("snapshot expired %d minutes after its last use", int(snapshotTTL/time.Minute)-1)
+		}
+	}
+	clock.Advance(snapshotTTL + time.Second)
+	if get("a", 1) {
+		t.Error("snapshot still valid after the TTL")
+	}
+
+	// Putting a live key keeps its list; putting an expired key replaces it
+	store.Put("b", 1, makeRestaurants(3), SearchStats{}, ResultFilters{})
+	store.Put("b", 1, makeRestaurants(5), SearchStats{}, ResultFilters{})
+	if snap, _ := store.Get(pageCursor{Snapshot: "b", Version: 1, Limit: 10}); len(snap.restaurants) != 3 {
+		t.Errorf("live snapshot replaced: %d restaurants", len(snap.restaurants))
+	}
+	store.Put("a", 1, makeRestaurants(5), SearchStats{}, ResultFilters{})
+	if snap, ok := store.Get(pageCursor{Snapshot: "a", Version: 1, Limit: 10}); !ok || len(snap.restaurants) != 5 {
+		t.Error("expired snapshot not replaced")
+	}
+
+	hits, misses, _ := store.counters.Counts()
+	if hits != 6 || misses != 3 {
+		t.Errorf("hits=%d misses=%d, want 6 and 3", hits, misses)
+	}
+}
+
+func TestSnapshotStoreEvictsClosestToExpiry(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	store := NewSnapshotStore()
+	store.now = clock.Now
+
+	for i := 0; i < maxResultSnapshots; i++ {
+		store.Put(fmt.Sprint(i), 1, nil, SearchStats{}, ResultFilters{})
+		clock.Advance(time.Millisecond)
+	}
+	// Using the first snapshot makes the second the one closest to expiry
+	if _, ok := store.Get(pageCursor{Snapshot: "0", Version: 1, Limit: 10}); !ok {
+		t.Fatal("first snapshot missing")
+	}
+	store.Put("new", 1, nil, SearchStats{}, ResultFilters{})
+
+	if store.Len() != maxResultSnapshots {
+		t.Errorf("%d snapshots stored, want %d", store.Len(), maxResultSnapshots)
+	}
+	for key, want := range map[string]bool{"0": true, "1": false, "2": true, "new": true} {
+		if _, ok := store.Get(pageCursor{Snapshot: key, Version: 1, Limit: 10}); ok != want {
+			t.Errorf("snapshot %s present=%v, want %v", key, ok, want)
+		}
+	}
+	if _, _, evictions := store.counters.Counts(); evictions != 1 {
+		t.Errorf("%d evictions, want 1", evictions)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	valid := pageCursor{Snapshot: "abc", Version: 3, Offset: 20, Limit: 10}
+	if got, err := decodeCursor(encodeCursor(valid)); err != nil || got != valid {
+		t.Errorf("round trip: %+v, %v", got, err)
+	}
+	for _, token := range []string{
+		"",
+		"not base64!",
+		"bm90IGpzb24",
+		encodeCursor(pageCursor{Version: 1, Limit: 10}),
+		encodeCursor(pageCursor{Snapshot: "abc", Offset: -1, Limit: 10}),
+		encodeCursor(pageCursor{Snapshot: "abc", Limit: 0}),
+		encodeCursor(pageCursor{Snapshot: "abc", Limit: 101}),
+	} {
+		if _, err := decodeCursor(token); err == nil {
+			t.Errorf("%q: accepted", token)
+		}
+	}
+}
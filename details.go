@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"googlemaps.github.io/maps"
+)
+
+const (
+	placeDetailsTTL       = 24 * time.Hour // How long fetched place details are served from memory
+	maxCachedPlaceDetails = 5000           // Entries closest to expiry are evicted beyond this
+)
+
+// placeDetailsFields is the Place Details field mask. It stays within the
+// Basic, Contact and Atmosphere data SKUs (see SKUPlaceDetails).
+var placeDetailsFields = []maps.PlaceDetailsFieldMask{
+	// Basic
+	maps.PlaceDetailsFieldMaskPlaceID,
+	maps.PlaceDetailsFieldMaskName,
+	maps.PlaceDetailsFieldMaskFormattedAddress,
+	maps.PlaceDetailsFieldMaskGeometryLocation,
+	maps.PlaceDetailsFieldMaskBusinessStatus,
+	maps.PlaceDetailsFieldMaskTypes,
+	maps.PlaceDetailsFieldMaskURL,
+	maps.PlaceDetailsFieldMaskPhotos,
+	// Contact
+	maps.PlaceDetailsFieldMaskFormattedPhoneNumber,
+	maps.PlaceDetailsFieldMaskInternationalPhoneNumber,
+	maps.PlaceDetailsFieldMaskWebsite,
+	maps.PlaceDetailsFieldMaskOpeningHours,
+	// Atmosphere
+	maps.PlaceDetailsFieldMaskRatings,
+	maps.PlaceDetailsFieldMaskUserRatingsTotal,
+	maps.PlaceDetailsFieldMaskPriceLevel,
+	maps.PlaceDetailsFieldMaskReviews,
+	maps.PlaceDetailsFieldMaskEditorialSummary,
+}
+
+// googlePlaceIDPattern matches Google place IDs (also used to reject junk before paying for a lookup)
+var googlePlaceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{10,300}$`)
+
+var errPlaceNotFound = errors.New("place not found")
+
+// PlaceReview is a single user review in PlaceDetails
+type PlaceReview struct {
+	Author       string `json:"author"`
+	Rating       int    `json:"rating"`
+	Text         string `json:"text,omitempty"`
+	RelativeTime string `json:"relativeTime,omitempty"` // e.g. "2 months ago"
+	Time         int64  `json:"time,omitempty"`         // Unix seconds
+	Language     string `json:"language,omitempty"`
+}
+
+// PlacePhoto is a photo of a place, servable through /api/photo
+type PlacePhoto struct {
+	Reference string `json:"reference"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	URL       string `json:"url"` // /api/v1/photo URL for this photo
+}
+
+// PlaceDetails is the JSON document served by /api/places/{id}
+type PlaceDetails struct {
+	ID                 string            `json:"id"`     // Google place ID or "osm/node/123"
+	Source             string            `json:"source"` // "google" or "osm"
+	Name               string            `json:"name"`
+	Address            string            `json:"address,omitempty"`
+	Latitude           float64           `json:"lat"`
+	Longitude          float64           `json:"lon"`
+	Phone              string            `json:"phone,omitempty"`
+	InternationalPhone string            `json:"internationalPhone,omitempty"`
+	Website            string            `json:"website,omitempty"`
+	MapsURL            string            `json:"mapsUrl,omitempty"` // Google Maps or openstreetmap.org page
+	Rating             float64           `json:"rating,omitempty"`
+	ReviewCount        int               `json:"reviewCount,omitempty"`
+	PriceLevel         int               `json:"priceLevel,omitempty"`
+	Types              []string          `json:"types,omitempty"`
+	Cuisine            string            `json:"cuisine,omitempty"`
+	Summary            string            `json:"summary,omitempty"`
+	BusinessStatus     string            `json:"businessStatus,omitempty"`
+	OpenNow            *bool             `json:"openNow,omitempty"`      // Open when the details were fetched
+	OpeningHours       []string          `json:"openingHours,omitempty"` // Google weekday text, or the raw OSM opening_hours value
+	Reviews            []PlaceReview     `json:"reviews,omitempty"`
+	Photos             []PlacePhoto      `json:"photos,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`         // Raw OSM tags
+	Attributions       []string          `json:"attributions,omitempty"` // HTML attributions required by Google
+	FetchedAt          time.Time         `json:"fetchedAt"`
+	Cached             bool              `json:"cached"`
+}
+
+type placeDetailsEntry struct {
+	details   *PlaceDetails
+	expiresAt time.Time
+}
+
+// PlaceDetailsCache stores fetched place details separately from search results
+type PlaceDetailsCache struct {
+	mu       sync.RWMutex
+	items    map[string]placeDetailsEntry
+	now      func() time.Time
+	counters cacheCounters
+}
+
+// NewPlaceDetailsCache creates an empty details cache
+func NewPlaceDetailsCache() *PlaceDetailsCache {
+	cache := &PlaceDetailsCache{
+		items: make(map[string]placeDetailsEntry),
+		now:   time.Now,
+	}
+	// Start cleanup goroutine
+	go cache.cleanup()
+	return cache
+}
+
+// cleanup removes expired entries
+func (pc *PlaceDetailsCache) cleanup() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		pc.mu.Lock()
+		now := pc.now()
+		for id, entry := range pc.items {
+			if now.After(entry.expiresAt) {
+				delete(pc.items, id)
+				pc.counters.evictions.Add(1)
+			}
+		}
+		pc.mu.Unlock()
+	}
+}
+
+// Len returns the number of cached entries
+func (pc *PlaceDetailsCache) Len() int {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return len(pc.items)
+}
+
+// Get returns unexpired details for a place ID
+func (pc *PlaceDetailsCache) Get(id string) (*PlaceDetails, bool) {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	entry, ok := pc.items[id]
+	if !ok || pc.now().After(entry.expiresAt) {
+		pc.counters.lookup(false)
+		return nil, false
+	}
+	pc.counters.lookup(true)
+	return entry.details, true
+}
+
+// Set stores details under one or more IDs (Google may return a newer canonical ID)
+func (pc *PlaceDetailsCache) Set(details *PlaceDetails, ids ...string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	now := pc.now()
+	for _, id := range ids {
+		if _, exists := pc.items[id]; !exists && len(pc.items) >= maxCachedPlaceDetails {
+			var oldestID string
+			var oldest time.Time
+			for k, entry := range pc.items {
+				if oldestID == "" || entry.expiresAt.Before(oldest) {
+					oldestID, oldest = k, entry.expiresAt
+				}
+			}
+			delete(pc.items, oldestID)
+			pc.counters.evictions.Add(1)
+		}
+		pc.items[id] = placeDetailsEntry{details: details, expiresAt: now.Add(placeDetailsTTL)}
+	}
+}
+
+// parsePlacePath splits the path after /api/places/ into a source and ID.
+// Google: "<placeID>". OSM: "osm/node/123", "osm/way/123" or "osm/relation/123".
+// Fake provider: "fake_<cell>_<cell>_<index>".
+func parsePlacePath(path string) (source, elemType string, osmID int64, err error) {
+	path = strings.Trim(path, "/")
+	if strings.HasPrefix(path, fakePlaceIDPrefix) {
+		return "fake", "", 0, nil
+	}
+	if rest, ok := strings.CutPrefix(path, "osm/"); ok {
+		parts := strings.Split(rest, "/")
+		if len(parts) != 2 || (parts[0] != "node" && parts[0] != "way" && parts[0] != "relation") {
+			return "", "", 0, fmt.Errorf("OSM places must be addressed as osm/{node|way|relation}/{id}")
+		}
+		osmID, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || osmID <= 0 {
+			return "", "", 0, fmt.Errorf("invalid OSM element id %q", parts[1])
+		}
+		return "osm", parts[0], osmID, nil
+	}
+	if !googlePlaceIDPattern.MatchString(path) {
+		return "", "", 0, fmt.Errorf("invalid place id")
+	}
+	return "google", "", 0, nil
+}
+
+// placePhotoURL returns the /api/v1/photo URL for the index-th photo of a place
+func placePhotoURL(placeID, photoRef string, index int) string {
+	photoURL := fmt.Sprintf(apiV1Prefix+"/photo?place_id=%s&photo_reference=%s", url.QueryEscape(placeID), url.QueryEscape(photoRef))
+	if index > 0 {
+		photoURL += fmt.Sprintf("&index=%d", index)
+	}
+	return photoURL
+}
+
+// fetchGooglePlaceDetails calls Google Place Details for a single place, fetched at now
+func (rb *RestaurantBot) fetchGooglePlaceDetails(ctx context.Context, placeID string, now time.Time) (*PlaceDetails, error) {
+	ctx, cancel := context.WithTimeout(ctx, rb.requestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	place, err := rb.mapsClient.PlaceDetails(ctx, &maps.PlaceDetailsRequest{
+		PlaceID: placeID,
+		Fields:  placeDetailsFields,
+	})
+	rb.metrics.ObserveProviderCall(EndpointPlaceDetails, time.Since(start), err)
+	if err != nil {
+		msg := err.Error()
+		if strings.Contains(msg, "NOT_FOUND") || strings.Contains(msg, "INVALID_REQUEST") {
+			return nil, errPlaceNotFound
+		}
+		return nil, err
+	}
+	rb.costs.Record(SKUPlaceDetails)
+
+	details := &PlaceDetails{
+		ID:                 place.PlaceID,
+		Source:             "google",
+		Name:               place.Name,
+		Address:            place.FormattedAddress,
+		Latitude:           place.Geometry.Location.Lat,
+		Longitude:          place.Geometry.Location.Lng,
+		Phone:              place.FormattedPhoneNumber,
+		InternationalPhone: place.InternationalPhoneNumber,
+		Website:            place.Website,
+		MapsURL:            place.URL,
+		Rating:             float64(place.Rating),
+		ReviewCount:        place.UserRatingsTotal,
+		PriceLevel:         place.PriceLevel,
+		Types:              place.Types,
+		BusinessStatus:     place.BusinessStatus,
+		OpenNow:            placeOpenNow(place.OpeningHours),
+		Attributions:       place.HTMLAttributions,
+		FetchedAt:          now.UTC(),
+	}
+	if details.ID == "" {
+		details.ID = placeID
+	}
+	if place.EditorialSummary != nil {
+		details.Summary = place.EditorialSummary.Overview
+	}
+	if place.OpeningHours != nil {
+		details.OpeningHours = place.OpeningHours.WeekdayText
+	}
+	for _, review := range place.Reviews {
+		details.Reviews = append(details.Reviews, PlaceReview{
+			Author:       review.AuthorName,
+			Rating:       review.Rating,
+			Text:         review.Text,
+			RelativeTime: review.RelativeTimeDescription,
+			Time:         int64(review.Time),
+			Language:     review.Language,
+		})
+	}
+	for i, photo := range place.Photos {
+		details.Photos = append(details.Photos, PlacePhoto{
+			Reference: photo.PhotoReference,
+			Width:     photo.Width,
+			Height:    photo.Height,
+			URL:       placePhotoURL(details.ID, photo.PhotoReference, i),
+		})
+	}
+	return details, nil
+}
+
+// fetchOSMPlaceDetails looks up a single OSM element with all its tags, fetched at now
+func (rb *RestaurantBot) fetchOSMPlaceDetails(ctx context.Context, elemType string, osmID int64, now time.Time) (*PlaceDetails, error) {
+	ctx, cancel := context.WithTimeout(ctx, rb.requestTimeout)
+	defer cancel()
+
+	query := fmt.Sprintf("[out:json][timeout:15];%s(%d);out center tags;", elemType, osmID)
+	req, err := http.NewRequestWithContext(ctx, "POST", overpassAPIURL, strings.NewReader(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: rb.requestTimeout, Transport: rb.providerTransport(EndpointOverpass)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("overpass API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("overpass API returned status %d", resp.StatusCode)
+	}
+
+	var overpassResp struct {
+		Elements []struct {
+			Type   string  `json:"type"`
+			ID     int64   `json:"id"`
+			Lat    float64 `json:"lat,omitempty"`
+			Lon    float64 `json:"lon,omitempty"`
+			Center struct {
+				Lat float64 `json:"lat"`
+				Lon float64 `json:"lon"`
+			} `json:"center,omitempty"`
+			Tags map[string]string `json:"tags"`
+		} `json:"elements"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&overpassResp); err != nil {
+		return nil, fmt.Errorf("failed to decode overpass response: %w", err)
+	}
+	if len(overpassResp.Elements) == 0 {
+		return nil, errPlaceNotFound
+	}
+
+	elem := overpassResp.Elements[0]
+	tags := elem.Tags
+	details := &PlaceDetails{
+		ID:        fmt.Sprintf("osm/%s/%d", elemType, osmID),
+		Source:    "osm",
+		Name:      tags["name"],
+		Latitude:  elem.Lat,
+		Longitude: elem.Lon,
+		Phone:     firstTag(tags, "phone", "contact:phone"),
+		Website:   firstTag(tags, "website", "contact:website", "url"),
+		MapsURL:   fmt.Sprintf("https://www.openstreetmap.org/%s/%d", elemType, osmID),
+		Cuisine:   formatTypeString(tags["cuisine"]),
+		OpenNow:   osmOpenNow(tags["opening_hours"]),
+		Tags:      tags,
+		FetchedAt: now.UTC(),
+	}
+	if elem.Type != "node" {
+		details.Latitude = elem.Center.Lat
+		details.Longitude = elem.Center.Lon
+	}
+	if details.Name == "" {
+		details.Name = formatAmenityType(tags["amenity"])
+	}
+	if rating, err := strconv.ParseFloat(tags["rating"], 64); err == nil {
+		details.Rating = rating
+	}
+	if hours := tags["opening_hours"]; hours != "" {
+		details.OpeningHours = []string{hours}
+	}
+	if amenity := tags["amenity"]; amenity != "" {
+		details.Types = append(details.Types, amenity)
+	}
+
+	street := strings.TrimSpace(tags["addr:street"] + " " + tags["addr:housenumber"])
+	city := strings.TrimSpace(tags["addr:postcode"] + " " + tags["addr:city"])
+	var addressParts []string
+	for _, part := range []string{street, city} {
+		if part != "" {
+			addressParts = append(addressParts, part)
+		}
+	}
+	details.Address = strings.Join(addressParts, ", ")
+
+	return details, nil
+}
+
+// firstTag returns the first non-empty OSM tag of the given keys
+func firstTag(tags map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := tags[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// handlePlaceDetails serves /api/places/{placeID} and /api/places/osm/{type}/{id}.
+// Details are fetched on demand and cached for placeDetailsTTL.
+func (s *Server) handlePlaceDetails(w http.ResponseWriter, r *http.Request) {
+	if !s.cors.Apply(w, r, "GET, OPTIONS") {
+		return
+	}
+
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	clientKey, ok := s.authorizeAPIRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(trimAPIPrefix(r.URL.Path), "/places/"), "/")
+	source, elemType, osmID, err := parsePlacePath(id)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	if cached, found := s.details.Get(id); found {
+		ctxLogger(r.Context()).Debug("[DETAILS] Cache hit", "place_id", id)
+		result := *cached
+		result.Cached = true
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	var details *PlaceDetails
+	switch source {
+	case "google":
+		if s.mapsClient == nil {
+			writeAPIError(w, http.StatusServiceUnavailable, ErrCodeNotConfigured, "Google Maps API key not configured")
+			return
+		}
+		if exceeded, reason := s.costs.BudgetExceeded(); exceeded {
+			ctxLogger(r.Context()).Debug("[DETAILS][COSTS] Not fetching details", "reason", reason, "place_id", id)
+			writeAPIError(w, http.StatusServiceUnavailable, ErrCodeBudgetExceeded, "Google budget exhausted, place details are unavailable")
+			return
+		}
+		// A details fetch bills several Google SKUs, so it counts as a fresh search
+		if ok, retryAfter := s.limits.AllowSearch(clientKey); !ok {
+			ctxLogger(r.Context()).Warn("[RATELIMIT] Search limit exceeded", "client", clientKey, "place_id", id)
+			writeRateLimited(w, retryAfter)
+			return
+		}
+		details, err = s.fetchGooglePlaceDetails(r.Context(), id, s.now())
+	case "osm":
+		details, err = s.fetchOSMPlaceDetails(r.Context(), elemType, osmID, s.now())
+	case "fake":
+		details, err = fakePlaceDetails(id, s.now())
+	}
+
+	if errors.Is(err, errPlaceNotFound) {
+		writeAPIError(w, http.StatusNotFound, ErrCodeNotFound, "Place not found")
+		return
+	}
+	if err != nil {
+		ctxLogger(r.Context()).Error("[DETAILS] Error fetching details", "place_id", id, "error", err)
+		writeAPIError(w, http.StatusBadGateway, ErrCodeProviderUnavailable,
+			"Place details are currently unavailable, please try again later", newProviderError(source, err))
+		return
+	}
+
+	ctxLogger(r.Context()).Debug("[DETAILS] Fetched details", "source", source, "place_id", id, "name", details.Name)
+	s.details.Set(details, id, details.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(details)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParsePlacePath(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantSource string
+		wantType   string
+		wantID     int64
+		wantErr    bool
+	}{
+		{"ChIJN1t_tDeuEmsRUsoyG83frY4", "google", "", 0, false},
+		{"/ChIJN1t_tDeuEmsRUsoyG83frY4/", "google", "", 0, false},
+		{"osm/node/123", "osm", "node", 123, false},
+		{"osm/way/45", "osm", "way", 45, false},
+		{"osm/relation/6", "osm", "relation", 6, false},
+		{fakePlaceIDPrefix + "1_2_3", "fake", "", 0, false},
+		{"short", "", "", 0, true},
+		{"ChIJ../../etc/passwd", "", "", 0, true},
+		{"osm/area/1", "", "", 0, true},
+		{"osm/node/-1", "", "", 0, true},
+		{"osm/node/abc", "", "", 0, true},
+		{"osm/node/1/2", "", "", 0, true},
+	}
+	for _, tt := range tests {
+		source, elemType, id, err := parsePlacePath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v, want error %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if source != tt.wantSource || elemType != tt.wantType || id != tt.wantID {
+			t.Errorf("%q: %s %s %d, want %s %s %d", tt.path, source, elemType, id, tt.wantSource, tt.wantType, tt.wantID)
+		}
+	}
+}
+
+func TestPlaceDetailsCacheExpiry(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	cache := NewPlaceDetailsCache()
+	cache.now = clock.Now
+
+	details := &PlaceDetails{ID: "new-id", Name: "Trattoria"}
+	cache.Set(details, "old-id", "new-id")
+	for _, id := range []string{"old-id", "new-id"} {
+		if got, ok := cache.Get(id); !ok || got != details {
+			t.Errorf("%s: not cached", id)
+		}
+	}
+
+	// Unlike snapshots, reading details does not extend their lifetime
+	clock.Advance(placeDetailsTTL - time.Minute)
+	cache.Get("old-id")
+	clock.Advance(2 * time.Minute)
+	if _, ok := cache.Get("old-id"); ok {
+		t.Error("details served after the TTL")
+	}
+
+	hits, misses, _ := cache.counters.Counts()
+	if hits != 3 || misses != 1 {
+		t.Errorf("hits=%d misses=%d, want 3 and 1", hits, misses)
+	}
+}
+
+func TestPlaceDetailsCacheEvictsClosestToExpiry(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	cache := NewPlaceDetailsCache()
+	cache.now = clock.Now
+
+	for i := 0; i < maxCachedPlaceDetails; i++ {
+		cache.Set(&PlaceDetails{}, fmt.Sprint(i))
+		clock.Advance(time.Millisecond)
+	}
+	// Refetching a cached place replaces it without evicting anything
+	cache.Set(&PlaceDetails{}, "0")
+	if _, _, evictions := cache.counters.Counts(); evictions != 0 {
+		t.Fatalf("%d evictions when replacing an entry", evictions)
+	}
+
+	cache.Set(&PlaceDetails{}, "new")
+	if cache.Len() != maxCachedPlaceDetails {
+		t.Errorf("%d entries, want %d", cache.Len(), maxCachedPlaceDetails)
+	}
+	for id, want := range map[string]bool{"0": true, "1": false, "2": true, "new": true} {
+		if _, ok := cache.Get(id); ok != want {
+			t.Errorf("%s present=%v, want %v", id, ok, want)
+		}
+	}
+}
+
+func TestServerPlaceDetailsCache(t *testing.T) {
+	server, clock := newTestServerWithClock(t, &fakeSearch{})
+	const id = "ChIJN1t_tDeuEmsRUsoyG83frY4"
+	server.details.Set(&PlaceDetails{ID: "ChIJcanonicalPlaceId", Source: "google", Name: "Trattoria"}, id, "ChIJcanonicalPlaceId")
+
+	// The test server has no Google client, so only cache hits can succeed
+	for _, placeID := range []string{id, "ChIJcanonicalPlaceId"} {
+		rec := serve(server, "GET", "/api/v1/places/"+placeID, "")
+		var details PlaceDetails
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&details) != nil {
+			t.Fatalf("%s: %d %s", placeID, rec.Code, rec.Body.String())
+		}
+		if !details.Cached || details.Name != "Trattoria" {
+			t.Errorf("%s: %+v", placeID, details)
+		}
+	}
+	// Marking a response as cached must not change the stored entry
+	if cached, _ := server.details.Get(id); cached.Cached {
+		t.Error("cached entry was modified")
+	}
+
+	clock.Advance(placeDetailsTTL + time.Minute)
+	rec := serve(server, "GET", "/api/v1/places/"+id, "")
+	if rec.Code != http.StatusServiceUnavailable || decodeError(t, rec).Code != ErrCodeNotConfigured {
+		t.Errorf("expired details: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServerGooglePlaceDetailsChargesSearch(t *testing.T) {
+	var requests int
+	bot := newGoogleTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		id := r.URL.Query().Get("placeid")
+		if id == "" {
+			id = r.URL.Query().Get("place_id")
+		}
+		fmt.Fprintf(w, `{"status":"OK","result":{"place_id":%q,"name":"Trattoria"}}`, id)
+	})
+	fetchedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	server := NewServer(bot, &fakeSearch{}, newMemoryPhotoStore())
+	server.now = func() time.Time { return fetchedAt }
+	server.limits = &ClientRateLimits{cached: NewRateLimiter(600, 100), search: NewRateLimiter(1, 1)}
+
+	rec := serve(server, "GET", "/api/v1/places/ChIJfirstPlaceIdentifier", "")
+	var details PlaceDetails
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&details) != nil {
+		t.Fatalf("first place: %d %s", rec.Code, rec.Body.String())
+	}
+	if details.Cached || !details.FetchedAt.Equal(fetchedAt) {
+		t.Errorf("FetchedAt %v cached %v, want %v from the server clock", details.FetchedAt, details.Cached, fetchedAt)
+	}
+
+	// The search token is spent, but cached details stay free
+	if rec := serve(server, "GET", "/api/v1/places/ChIJfirstPlaceIdentifier", ""); rec.Code != http.StatusOK {
+		t.Errorf("cached place: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = serve(server, "GET", "/api/v1/places/ChIJsecondPlaceIdentifier", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("second place: %d %s", rec.Code, rec.Body.String())
+	}
+	if requests != 1 {
+		t.Errorf("%d Google requests, want 1", requests)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Stable machine-readable error codes returned by the HTTP API
+const (
+	ErrCodeInvalidRequest      = "invalid_request"
+	ErrCodeInvalidCoordinates  = "invalid_coordinates"
+	ErrCodeInvalidArea         = "invalid_area"
+	ErrCodeInvalidRoute        = "invalid_route"
+	ErrCodeMissingParameter    = "missing_parameter"
+	ErrCodeInvalidCursor       = "invalid_cursor"
+	ErrCodeCursorExpired       = "cursor_expired"
+	ErrCodeMethodNotAllowed    = "method_not_allowed"
+	ErrCodeNotFound            = "not_found"
+	ErrCodeLocationNotFound    = "location_not_found"
+	ErrCodeUnauthorized        = "unauthorized"
+	ErrCodeQuotaExceeded       = "quota_exceeded"
+	ErrCodeOriginNotAllowed    = "origin_not_allowed"
+	ErrCodeRateLimited         = "rate_limited"
+	ErrCodeBudgetExceeded      = "budget_exceeded"
+	ErrCodeProviderUnavailable = "provider_unavailable"
+	ErrCodeNotConfigured       = "not_configured"
+	ErrCodeInternal            = "internal_error"
+)
+
+// Provider failure codes used in ProviderError
+const (
+	ProviderErrTimeout  = "timeout"
+	ProviderErrUpstream = "upstream_error"
+	ProviderErrBudget   = "budget_exceeded" // the spend cap stopped a sub-query before all its pages
+)
+
+// ProviderError describes the failure of one provider or provider sub-query.
+// Messages are generic; raw upstream errors are only logged.
+type ProviderError struct {
+	Provider string `json:"provider"` // e.g. "google", "osm", "google:cafe"
+	Code     string `json:"code"`     // "timeout" or "upstream_error"
+	Message  string `json:"message"`
+}
+
+// ProviderFailureError is returned by searches when every provider (or every
+// required sub-query) failed
+type ProviderFailureError struct {
+	Failures []ProviderError
+	cause    string // raw upstream errors, for logs only
+}
+
+func (e *ProviderFailureError) Error() string {
+	return e.cause
+}
+
+// newProviderError classifies a raw provider error without exposing its text
+func newProviderError(provider string, err error) ProviderError {
+	if isTimeoutError(err) {
+		return ProviderError{Provider: provider, Code: ProviderErrTimeout, Message: "provider did not respond in time"}
+	}
+	return ProviderError{Provider: provider, Code: ProviderErrUpstream, Message: "provider request failed"}
+}
+
+// newBudgetProviderError reports results cut short by the Google spend cap
+func newBudgetProviderError(provider string) ProviderError {
+	return ProviderError{Provider: provider, Code: ProviderErrBudget, Message: "Google budget reached before all results were fetched"}
+}
+
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// The maps client flattens some errors to strings
+	return strings.Contains(strings.ToLower(err.Error()), "deadline exceeded")
+}
+
+// APIError is the JSON error envelope returned by all /api endpoints:
+//
+//	{"error": {"code": "invalid_coordinates", "message": "...", "details": [...]}}
+type APIError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Details []ProviderError `json:"details,omitempty"`
+}
+
+type apiErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// writeAPIError sends a JSON error envelope with the given status
+func writeAPIError(w http.ResponseWriter, status int, code, message string, details ...ProviderError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiErrorResponse{
+		Error: APIError{Code: code, Message: message, Details: details},
+	})
+}
+
+// writeSearchError maps a search failure to an API error without leaking upstream text
+func writeSearchError(w http.ResponseWriter, provider string, budgetExceeded bool, err error) {
+	status, apiErr := searchAPIError(provider, budgetExceeded, err)
+	writeAPIError(w, status, apiErr.Code, apiErr.Message, apiErr.Details...)
+}
+
+// searchAPIError builds the status and error envelope for a failed search
+func searchAPIError(provider string, budgetExceeded bool, err error) (int, APIError) {
+	var failure *ProviderFailureError
+	details := []ProviderError{}
+	if errors.As(err, &failure) {
+		details = failure.Failures
+	} else {
+		details = append(details, newProviderError(provider, err))
+	}
+
+	if budgetExceeded {
+		return http.StatusServiceUnavailable, APIError{
+			Code:    ErrCodeBudgetExceeded,
+			Message: "Google budget exhausted and fallback provider unavailable",
+			Details: details,
+		}
+	}
+	return http.StatusBadGateway, APIError{
+		Code:    ErrCodeProviderUnavailable,
+		Message: "Restaurant data providers are currently unavailable, please try again later",
+		Details: details,
+	}
+}
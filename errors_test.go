@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestSearchAPIError(t *testing.T) {
+	failures := []ProviderError{
+		{Provider: "google:cafe", Code: ProviderErrTimeout, Message: "provider did not respond in time"},
+		{Provider: "osm", Code: ProviderErrUpstream, Message: "provider request failed"},
+	}
+	tests := []struct {
+		name           string
+		budgetExceeded bool
+		err            error
+		wantStatus     int
+		wantCode       string
+		wantDetails    []ProviderError
+	}{
+		{"upstream error", false, errors.New("REQUEST_DENIED: key sk-secret is invalid"), http.StatusBadGateway, ErrCodeProviderUnavailable,
+			[]ProviderError{{Provider: "google", Code: ProviderErrUpstream, Message: "provider request failed"}}},
+		{"context deadline", false, fmt.Errorf("nearby search: %w", context.DeadlineExceeded), http.StatusBadGateway, ErrCodeProviderUnavailable,
+			[]ProviderError{{Provider: "google", Code: ProviderErrTimeout, Message: "provider did not respond in time"}}},
+		{"network timeout", false, &timeoutError{}, http.StatusBadGateway, ErrCodeProviderUnavailable,
+			[]ProviderError{{Provider: "google", Code: ProviderErrTimeout, Message: "provider did not respond in time"}}},
+		{"flattened deadline", false, errors.New("maps: Context Deadline Exceeded"), http.StatusBadGateway, ErrCodeProviderUnavailable,
+			[]ProviderError{{Provider: "google", Code: ProviderErrTimeout, Message: "provider did not respond in time"}}},
+		{"every provider failed", false, fmt.Errorf("search: %w", &ProviderFailureError{Failures: failures, cause: "raw"}), http.StatusBadGateway, ErrCodeProviderUnavailable,
+			failures},
+		{"budget exhausted", true, errors.New("overpass: 504"), http.StatusServiceUnavailable, ErrCodeBudgetExceeded,
+			[]ProviderError{{Provider: "google", Code: ProviderErrUpstream, Message: "provider request failed"}}},
+	}
+	for _, tt := range tests {
+		status, apiErr := searchAPIError("google", tt.budgetExceeded, tt.err)
+		if status != tt.wantStatus || apiErr.Code != tt.wantCode {
+			t.Errorf("%s: %d %s, want %d %s", tt.name, status, apiErr.Code, tt.wantStatus, tt.wantCode)
+		}
+		if !reflect.DeepEqual(apiErr.Details, tt.wantDetails) {
+			t.Errorf("%s: details %+v, want %+v", tt.name, apiErr.Details, tt.wantDetails)
+		}
+
+		rec := httptest.NewRecorder()
+		writeSearchError(rec, "google", tt.budgetExceeded, tt.err)
+		if rec.Code != tt.wantStatus || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: response %d %q", tt.name, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if got := decodeError(t, rec); !reflect.DeepEqual(got, apiErr) {
+			t.Errorf("%s: body %+v, want %+v", tt.name, got, apiErr)
+		}
+		// Upstream error text is only logged, never sent to clients
+		if body := rec.Body.String(); strings.Contains(body, tt.err.Error()) || strings.Contains(body, "raw") {
+			t.Errorf("%s: body leaks the upstream error: %s", tt.name, body)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats supported by /api/restaurants?format=
+const (
+	FormatJSON    = "json" // PaginatedSearchResult (default)
+	FormatGeoJSON = "geojson"
+	FormatCSV     = "csv"
+	FormatKML     = "kml"
+	FormatGPX     = "gpx"
+)
+
+var exportContentTypes = map[string]string{
+	FormatGeoJSON: "application/geo+json",
+	FormatCSV:     "text/csv; charset=utf-8",
+	FormatKML:     "application/vnd.google-earth.kml+xml",
+	FormatGPX:     "application/gpx+xml",
+}
+
+// exportNamespace qualifies restaurant fields in GPX extensions
+const exportNamespace = "https://github.com/telegram-restaurant-bot/gpx/1"
+
+// ExportMetadata describes the search an export was produced from
+type ExportMetadata struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Lat         float64        `json:"lat"` // search center
+	Lon         float64        `json:"lon"`
+	Location    *GeocodeResult `json:"location,omitempty"`
+	Filters     ResultFilters  `json:"filters"`
+	Stats       SearchStats    `json:"stats"`
+}
+
+// validExportFormat normalizes a format parameter; "" means JSON
+func validExportFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		return FormatJSON, nil
+	}
+	if format != FormatJSON && exportContentTypes[format] == "" {
+		return "", fmt.Errorf("format must be one of json, geojson, csv, kml, gpx")
+	}
+	return format, nil
+}
+
+// exportField is one Restaurant field rendered as text
+type exportField struct {
+	Name  string
+	Value string
+}
+
+// restaurantFields lists every Restaurant field by its JSON name, so exports
+// pick up new fields automatically
+func restaurantFields(r Restaurant) []exportField {
+	v := reflect.ValueOf(r)
+	t := v.Type()
+	fields := make([]exportField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		fields = append(fields, exportField{Name: name, Value: exportValue(v.Field(i))})
+	}
+	return fields
+}
+
+// restaurantProperties maps every Restaurant field (including empty ones, so all
+// features share one schema) to its JSON value; nil pointers become null
+func restaurantProperties(r Restaurant) map[string]interface{} {
+	v := reflect.ValueOf(r)
+	t := v.Type()
+	props := make(map[string]interface{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				props[name] = nil
+				continue
+			}
+			field = field.Elem()
+		}
+		props[name] = field.Interface()
+	}
+	return props
+}
+
+// exportValue formats a field value; nil pointers become empty strings
+func exportValue(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// exportFilename builds the download name, e.g. restaurants-52.520000_13.405000-20261018T120000Z.csv
+func exportFilename(meta ExportMetadata, format string) string {
+	return fmt.Sprintf("restaurants-%.6f_%.6f-%s.%s", meta.Lat, meta.Lon, meta.GeneratedAt.UTC().Format("20060102T150405Z"), format)
+}
+
+// exportStatsSummary is the part of the search stats sent in the X-Search-Stats
+// header; the per-provider and sub-query outcomes can grow to tens of KB for
+// area searches, more than proxies accept in a header
+type exportStatsSummary struct {
+	TotalAfterDedup int  `json:"totalAfterDedup"`
+	CachedResult    bool `json:"cachedResult"`
+	BudgetExceeded  bool `json:"budgetExceeded"`
+	Degraded        bool `json:"degraded"`
+}
+
+// writeExport sends restaurants in the given non-JSON format as a download.
+// A summary of the search stats is also sent as JSON in the X-Search-Stats
+// header, since CSV has no place for them.
+func writeExport(w http.ResponseWriter, format string, restaurants []Restaurant, meta ExportMetadata) error {
+	statsJSON, _ := json.Marshal(exportStatsSummary{
+		TotalAfterDedup: meta.Stats.TotalAfterDedup,
+		CachedResult:    meta.Stats.CachedResult,
+		BudgetExceeded:  meta.Stats.BudgetExceeded,
+		Degraded:        meta.Stats.Degraded,
+	})
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exportFilename(meta, format)))
+	w.Header().Set("X-Search-Stats", string(statsJSON))
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, X-Search-Stats")
+
+	switch format {
+	case FormatGeoJSON:
+		return writeGeoJSON(w, restaurants, meta)
+	case FormatCSV:
+		return writeCSV(w, restaurants)
+	case FormatKML:
+		return writeKML(w, restaurants, meta)
+	case FormatGPX:
+		return writeGPX(w, restaurants, meta)
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+// writeGeoJSON writes a FeatureCollection of Point features; the search metadata
+// is a foreign member of the collection
+func writeGeoJSON(w http.ResponseWriter, restaurants []Restaurant, meta ExportMetadata) error {
+	type feature struct {
+		Type     string `json:"type"`
+		Geometry struct {
+			Type        string     `json:"type"`
+			Coordinates [2]float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+	collection := struct {
+		Type     string         `json:"type"`
+		Metadata ExportMetadata `json:"metadata"`
+		Features []feature      `json:"features"`
+	}{Type: "FeatureCollection", Metadata: meta, Features: make([]feature, 0, len(restaurants))}
+
+	for _, r := range restaurants {
+		f := feature{Type: "Feature", Properties: restaurantProperties(r)}
+		f.Geometry.Type = "Point"
+		f.Geometry.Coordinates = [2]float64{r.Longitude, r.Latitude}
+		collection.Features = append(collection.Features, f)
+	}
+	return json.NewEncoder(w).Encode(collection)
+}
+
+// csvFormulaPrefixes start a formula in spreadsheet applications
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvCell neutralises a value a spreadsheet would run as a formula, such as a
+// place named =HYPERLINK(...), by prefixing it with a quote. Numbers are left
+// alone so negative coordinates stay numeric.
+func csvCell(value string) string {
+	if value == "" || !strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return "'" + value
+}
+
+// writeCSV writes one row per restaurant with a header of field names
+func writeCSV(w http.ResponseWriter, restaurants []Restaurant) error {
+	cw := csv.NewWriter(w)
+	var header []string
+	for _, f := range restaurantFields(Restaurant{}) {
+		header = append(header, f.Name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range restaurants {
+		fields := restaurantFields(r)
+		row := make([]string, len(fields))
+		for i, f := range fields {
+			row[i] = csvCell(f.Value)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPlacemark struct {
+	Name         string    `xml:"name"`
+	Description  string    `xml:"description,omitempty"`
+	ExtendedData []kmlData `xml:"ExtendedData>Data"`
+	Coordinates  string    `xml:"Point>coordinates"`
+}
+
+// writeKML writes a KML document with one placemark per restaurant
+func writeKML(w http.ResponseWriter, restaurants []Restaurant, meta ExportMetadata) error {
+	type kmlDocument struct {
+		XMLName  xml.Name `xml:"kml"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Document struct {
+			Name         string         `xml:"name"`
+			Description  string         `xml:"description"`
+			ExtendedData []kmlData      `xml:"ExtendedData>Data"`
+			Placemarks   []kmlPlacemark `xml:"Placemark"`
+		} `xml:"Document"`
+	}
+
+	doc := kmlDocument{Xmlns: "http://www.opengis.net/kml/2.2"}
+	doc.Document.Name = exportTitle(meta)
+	doc.Document.Description = exportSummary(restaurants, meta)
+	doc.Document.ExtendedData = exportMetadataData(meta)
+	for _, r := range restaurants {
+		placemark := kmlPlacemark{
+			Name:        r.Name,
+			Description: r.Address,
+			Coordinates: fmt.Sprintf("%s,%s", strconv.FormatFloat(r.Longitude, 'f', -1, 64), strconv.FormatFloat(r.Latitude, 'f', -1, 64)),
+		}
+		for _, f := range restaurantFields(r) {
+			placemark.ExtendedData = append(placemark.ExtendedData, kmlData{Name: f.Name, Value: f.Value})
+		}
+		doc.Document.Placemarks = append(doc.Document.Placemarks, placemark)
+	}
+	return writeXML(w, doc)
+}
+
+// writeGPX writes a GPX 1.1 file with one waypoint per restaurant. All fields are
+// kept in waypoint extensions; search metadata goes into the metadata extensions.
+func writeGPX(w http.ResponseWriter, restaurants []Restaurant, meta ExportMetadata) error {
+	type gpxField struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	}
+	type gpxWaypoint struct {
+		Lat        float64    `xml:"lat,attr"`
+		Lon        float64    `xml:"lon,attr"`
+		Name       string     `xml:"name"`
+		Comment    string     `xml:"cmt,omitempty"`
+		Desc       string     `xml:"desc,omitempty"`
+		Type       string     `xml:"type,omitempty"`
+		Extensions []gpxField `xml:"extensions>rb:restaurant>field"`
+	}
+	type gpxDocument struct {
+		XMLName  xml.Name `xml:"gpx"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		XmlnsRB  string   `xml:"xmlns:rb,attr"`
+		Version  string   `xml:"version,attr"`
+		Creator  string   `xml:"creator,attr"`
+		Metadata struct {
+			Name       string     `xml:"name"`
+			Desc       string     `xml:"desc"`
+			Time       string     `xml:"time"`
+			Extensions []gpxField `xml:"extensions>rb:search>field"`
+		} `xml:"metadata"`
+		Waypoints []gpxWaypoint `xml:"wpt"`
+	}
+
+	doc := gpxDocument{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		XmlnsRB: exportNamespace,
+		Version: "1.1",
+		Creator: "telegram-restaurant-bot",
+	}
+	doc.Metadata.Name = exportTitle(meta)
+	doc.Metadata.Desc = exportSummary(restaurants, meta)
+	doc.Metadata.Time = meta.GeneratedAt.UTC().Format(time.RFC3339)
+	for _, d := range exportMetadataData(meta) {
+		doc.Metadata.Extensions = append(doc.Metadata.Extensions, gpxField{XMLName: xml.Name{Local: "rb:" + d.Name}, Value: d.Value})
+	}
+	for _, r := range restaurants {
+		wpt := gpxWaypoint{
+			Lat:     r.Latitude,
+			Lon:     r.Longitude,
+			Name:    r.Name,
+			Comment: r.Address,
+			Desc:    formatDistance(r.Distance) + " away",
+			Type:    r.Type,
+		}
+		if r.Rating > 0 {
+			wpt.Desc = fmt.Sprintf("Rating %.1f (%d reviews), %s", r.Rating, r.ReviewCount, wpt.Desc)
+		}
+		for _, f := range restaurantFields(r) {
+			wpt.Extensions = append(wpt.Extensions, gpxField{XMLName: xml.Name{Local: "rb:" + f.Name}, Value: f.Value})
+		}
+		doc.Waypoints = append(doc.Waypoints, wpt)
+	}
+	return writeXML(w, doc)
+}
+
+// writeXML writes an indented XML document with the XML declaration
+func writeXML(w http.ResponseWriter, doc interface{}) error {
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// exportTitle names the exported document
+func exportTitle(meta ExportMetadata) string {
+	if meta.Location != nil {
+		return "Restaurants near " + meta.Location.Name
+	}
+	return fmt.Sprintf("Restaurants near %.6f,%.6f", meta.Lat, meta.Lon)
+}
+
+// exportSummary is a one-line human-readable description of the search
+func exportSummary(restaurants []Restaurant, meta ExportMetadata) string {
+	summary := fmt.Sprintf("%d places, sorted by %s, generated %s", len(restaurants), meta.Filters.Sort, meta.GeneratedAt.UTC().Format(time.RFC3339))
+	if meta.Stats.Notice != "" {
+		summary += ". " + meta.Stats.Notice
+	}
+	return summary
+}
+
+// exportMetadataData flattens the search metadata into name/value pairs for KML and GPX
+func exportMetadataData(meta ExportMetadata) []kmlData {
+	filtersJSON, _ := json.Marshal(meta.Filters)
+	statsJSON, _ := json.Marshal(meta.Stats)
+	data := []kmlData{
+		{Name: "generatedAt", Value: meta.GeneratedAt.UTC().Format(time.RFC3339)},
+		{Name: "lat", Value: strconv.FormatFloat(meta.Lat, 'f', -1, 64)},
+		{Name: "lon", Value: strconv.FormatFloat(meta.Lon, 'f', -1, 64)},
+		{Name: "filters", Value: string(filtersJSON)},
+		{Name: "stats", Value: string(statsJSON)},
+	}
+	if meta.Location != nil {
+		data = append(data, kmlData{Name: "location", Value: meta.Location.Name})
+	}
+	return data
+}
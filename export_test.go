@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportRestaurants are places with names that need escaping in every format
+func exportRestaurants() []Restaurant {
+	open := true
+	return []Restaurant{
+		{Name: `=HYPERLINK("http://x")`, Rating: 4.5, ReviewCount: 120, Type: "Restaurant", Latitude: 52.52, Longitude: 13.405, Address: "Alexanderplatz 1", Distance: 0.2, OpenNow: &open},
+		{Name: "Fish & Chips <Deluxe>", Latitude: -33.87, Longitude: -151.21, Address: "@home, \"Sydney\"", Distance: 1.5},
+	}
+}
+
+func exportMeta() ExportMetadata {
+	return ExportMetadata{
+		GeneratedAt: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		Lat:         52.52,
+		Lon:         13.405,
+		Filters:     ResultFilters{Sort: SortRating},
+		Stats:       SearchStats{TotalAfterDedup: 2, SubQueries: make([]ProviderOutcome, 500)},
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Marietta", "Marietta"},
+		{`=HYPERLINK("http://x")`, `'=HYPERLINK("http://x")`},
+		{"+49 30 123", "'+49 30 123"},
+		{"-cmd|' /C calc'!A0", "'-cmd|' /C calc'!A0"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"-151.21", "-151.21"}, // numbers stay numeric
+		{"+4.5", "+4.5"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := csvCell(tt.in); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := writeCSV(rec, exportRestaurants()); err != nil {
+		t.Fatalf("writeCSV: %v", err)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want a header and 2 restaurants", len(rows))
+	}
+	column := make(map[string]int)
+	for i, name := range rows[0] {
+		column[name] = i
+	}
+	for _, name := range []string{"Name", "Rating", "Latitude", "Longitude", "Address", "OpenNow", "OSMID"} {
+		if _, ok := column[name]; !ok {
+			t.Errorf("no %s column in %q", name, rows[0])
+		}
+	}
+
+	first, second := rows[1], rows[2]
+	if got := first[column["Name"]]; got != `'=HYPERLINK("http://x")` {
+		t.Errorf("formula name exported as %q", got)
+	}
+	if first[column["Rating"]] != "4.5" || first[column["OpenNow"]] != "true" || first[column["OSMID"]] != "" {
+		t.Errorf("first row = %q", first)
+	}
+	if second[column["Name"]] != "Fish & Chips <Deluxe>" || second[column["Address"]] != `'@home, "Sydney"` || second[column["Longitude"]] != "-151.21" {
+		t.Errorf("second row = %q", second)
+	}
+}
+
+func TestWriteKML(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := writeKML(rec, exportRestaurants(), exportMeta()); err != nil {
+		t.Fatalf("writeKML: %v", err)
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, xml.Header) || !strings.Contains(body, "Fish &amp; Chips &lt;Deluxe&gt;") {
+		t.Errorf("KML not escaped:\n%s", body)
+	}
+
+	var doc struct {
+		Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid KML: %v", err)
+	}
+	if len(doc.Placemarks) != 2 {
+		t.Fatalf("%d placemarks, want 2", len(doc.Placemarks))
+	}
+	p := doc.Placemarks[1]
+	if p.Name != "Fish & Chips <Deluxe>" || p.Description != `@home, "Sydney"` || p.Coordinates != "-151.21,-33.87" {
+		t.Errorf("placemark = %+v", p)
+	}
+	data := make(map[string]string)
+	for _, d := range doc.Placemarks[0].ExtendedData {
+		data[d.Name] = d.Value
+	}
+	if data["Name"] != `=HYPERLINK("http://x")` || data["ReviewCount"] != "120" || data["OpenNow"] != "true" {
+		t.Errorf("extended data = %v", data)
+	}
+}
+
+func TestWriteGPX(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := writeGPX(rec, exportRestaurants(), exportMeta()); err != nil {
+		t.Fatalf("writeGPX: %v", err)
+	}
+
+	var doc struct {
+		Version  string `xml:"version,attr"`
+		Metadata struct {
+			Time string `xml:"time"`
+		} `xml:"metadata"`
+		Waypoints []struct {
+			Lat        float64 `xml:"lat,attr"`
+			Lon        float64 `xml:"lon,attr"`
+			Name       string  `xml:"name"`
+			Comment    string  `xml:"cmt"`
+			Desc       string  `xml:"desc"`
+			Restaurant struct {
+				Fields []struct {
+					XMLName xml.Name
+					Value   string `xml:",chardata"`
+				} `xml:",any"`
+			} `xml:"extensions>restaurant"`
+		} `xml:"wpt"`
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid GPX: %v\n%s", err, rec.Body.String())
+	}
+	if doc.Version != "1.1" || doc.Metadata.Time != "2026-10-18T12:00:00Z" || len(doc.Waypoints) != 2 {
+		t.Fatalf("GPX document = %+v", doc)
+	}
+	first, second := doc.Waypoints[0], doc.Waypoints[1]
+	if first.Lat != 52.52 || first.Lon != 13.405 || first.Desc != "Rating 4.5 (120 reviews), 200 m away" {
+		t.Errorf("first waypoint = %+v", first)
+	}
+	if second.Name != "Fish & Chips <Deluxe>" || second.Comment != `@home, "Sydney"` || second.Desc != "1.50 km away" {
+		t.Errorf("second waypoint = %+v", second)
+	}
+	fields := first.Restaurant.Fields
+	if len(fields) == 0 || fields[0].XMLName.Space != exportNamespace || fields[0].XMLName.Local != "Name" || fields[0].Value != `=HYPERLINK("http://x")` {
+		t.Errorf("extensions = %+v", fields)
+	}
+}
+
+func TestWriteExportStatsHeader(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := writeExport(rec, FormatCSV, exportRestaurants(), exportMeta()); err != nil {
+		t.Fatalf("writeExport: %v", err)
+	}
+	header := rec.Header().Get("X-Search-Stats")
+	var summary exportStatsSummary
+	if err := json.Unmarshal([]byte(header), &summary); err != nil || summary.TotalAfterDedup != 2 {
+		t.Errorf("X-Search-Stats = %q: %v", header, err)
+	}
+	// The sub-query outcomes stay out of the header
+	if len(header) > 200 {
+		t.Errorf("X-Search-Stats is %d bytes", len(header))
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="restaurants-52.520000_13.405000-20261018T120000Z.csv"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+}
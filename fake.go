@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The fake provider (API_PROVIDER=fake) makes up places instead of calling
+// Google or Overpass, for local development, demos and integration tests.
+// Places are generated per grid cell from a seed derived from the cell, so the
+// same coordinates always show the same places and overlapping searches agree.
+const (
+	fakeCellDegrees      = 0.01 // ~1.1 km north-south
+	fakeMaxPlacesPerCell = 6
+	fakeMaxResults       = 60 // like Google's three pages of a Nearby Search
+	fakePlaceIDPrefix    = "fake_"
+)
+
+// FakeProviderConfig tunes the fake provider
+type FakeProviderConfig struct {
+	Latency     time.Duration // added to every search
+	FailureRate float64       // fraction of searches that fail, 0-1
+}
+
+// errFakeFailure is the error of a search failed on purpose by FAKE_FAILURE_RATE
+var errFakeFailure = errors.New("fake provider: injected failure")
+
+// fakePlace is a generated place; the same cell and index always give the same one
+type fakePlace struct {
+	id          string
+	name        string
+	category    FoodCategory
+	cuisine     string
+	lat, lon    float64
+	rating      float64
+	reviews     int
+	priceLevel  int
+	street      string
+	houseNumber int
+	opens       int // hour of day
+	closes      int // hour of day, up to 24
+	vegetarian  bool
+}
+
+// fakeCuisine names restaurants of one cuisine: "<prefix> <word>"
+type fakeCuisine struct {
+	cuisine  string
+	prefixes []string
+	words    []string
+}
+
+var fakeCuisines = []fakeCuisine{
+	{"italian", []string{"Trattoria", "Osteria", "Ristorante"}, []string{"Da Marco", "Bella Napoli", "Il Giardino", "La Luna", "Toscana"}},
+	{"pizza", []string{"Pizzeria"}, []string{"Napoli", "Da Enzo", "Forno Rosso", "Margherita", "Vesuvio"}},
+	{"german", []string{"Gasthaus", "Wirtshaus"}, []string{"Lindenhof", "Zum Alten Fass", "Zum Goldenen Hirsch", "Zur Kastanie", "Am Markt"}},
+	{"turkish", []string{"Lokanta", "Restaurant"}, []string{"Anatolia", "Bosporus", "Kapadokya", "Lale", "Ege"}},
+	{"vietnamese", []string{"Pho", "Quán"}, []string{"Saigon", "Hanoi House", "Lotus", "Mekong", "Bamboo"}},
+	{"japanese", []string{"Sushi", "Ramen", "Izakaya"}, []string{"Sakura", "Kaito", "Hoshi", "Umami", "Mori"}},
+	{"indian", []string{"Restaurant", "Tandoori"}, []string{"Maharaja", "Taj", "Ganesha", "Masala", "Delhi Darbar"}},
+	{"mexican", []string{"Cantina", "Taquería"}, []string{"El Sol", "La Paloma", "Los Amigos", "Oaxaca", "Frida"}},
+	{"thai", []string{"Thai", "Restaurant"}, []string{"Orchidee", "Siam", "Bangkok", "Chiang Mai", "Kati"}},
+	{"greek", []string{"Taverna", "Restaurant"}, []string{"Olympia", "Mykonos", "Akropolis", "Santorini", "Zorbas"}},
+	{"burger", []string{"Burger"}, []string{"Bros", "Garage", "Republic", "Shack", "Kiez"}},
+	{"vegan", []string{"Kitchen", "Café"}, []string{"Grünzeug", "Sprout", "Roots", "Kohlrabi", "Green Table"}},
+}
+
+// fakeNames names places of the other categories: "<prefix> <word>"
+var fakeNames = map[FoodCategory]fakeCuisine{
+	CategoryCafe:      {"", []string{"Café", "Kaffeehaus", "Espresso Bar", "Rösterei"}, []string{"Linde", "Morgenrot", "Am Markt", "Bohne", "Milchschaum"}},
+	CategoryBar:       {"", []string{"Bar", "Kneipe", "Weinbar", "Cocktailbar"}, []string{"Zum Anker", "Nachtfalter", "Goldener Hahn", "Blaue Stunde", "Eckstein"}},
+	CategoryBakery:    {"", []string{"Bäckerei", "Backstube", "Konditorei"}, []string{"Sonnenkorn", "Krume", "Brezel & Co", "Am Brunnen", "Weizenfeld"}},
+	CategoryNightclub: {"", []string{"Club", "Klub"}, []string{"Nachtwerk", "Echo", "Kontrast", "Halle 9", "Unterdeck"}},
+}
+
+var fakeStreets = []string{"Hauptstraße", "Bahnhofstraße", "Marktplatz", "Kirchgasse", "Lindenallee", "Gartenweg", "Schulstraße", "Am Hafen", "Mühlenweg", "Rosenstraße"}
+
+// fakeCategories are weighted like a typical city center
+var fakeCategories = []struct {
+	category FoodCategory
+	weight   int
+}{
+	{CategoryRestaurant, 45},
+	{CategoryCafe, 18},
+	{CategoryBar, 12},
+	{CategoryTakeaway, 12},
+	{CategoryBakery, 8},
+	{CategoryNightclub, 5},
+}
+
+// fakeCell generates the places of the grid cell at latCell, lonCell (in units of fakeCellDegrees)
+func fakeCell(latCell, lonCell int) []fakePlace {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d,%d", latCell, lonCell)
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+	pick := func(values []string) string { return values[r.Intn(len(values))] }
+
+	places := make([]fakePlace, r.Intn(fakeMaxPlacesPerCell+1))
+	for i := range places {
+		p := fakePlace{
+			id:          fmt.Sprintf("%s%d_%d_%d", fakePlaceIDPrefix, latCell, lonCell, i),
+			lat:         (float64(latCell) + r.Float64()) * fakeCellDegrees,
+			lon:         (float64(lonCell) + r.Float64()) * fakeCellDegrees,
+			street:      pick(fakeStreets),
+			houseNumber: 1 + r.Intn(120),
+			opens:       7 + r.Intn(6),
+			closes:      18 + r.Intn(7),
+			priceLevel:  1 + r.Intn(4),
+		}
+
+		n := r.Intn(100)
+		for _, c := range fakeCategories {
+			if n < c.weight {
+				p.category = c.category
+				break
+			}
+			n -= c.weight
+		}
+		switch p.category {
+		case CategoryRestaurant, CategoryTakeaway:
+			c := fakeCuisines[r.Intn(len(fakeCuisines))]
+			p.cuisine = c.cuisine
+			p.name = pick(c.prefixes) + " " + pick(c.words)
+			if p.category == CategoryTakeaway {
+				p.name = pick(c.words) + " " + pick([]string{"Imbiss", "To Go", "Express"})
+				p.priceLevel = 1
+			}
+		default:
+			names := fakeNames[p.category]
+			p.name = pick(names.prefixes) + " " + pick(names.words)
+			if p.category == CategoryCafe || p.category == CategoryBakery {
+				p.priceLevel = 1 + r.Intn(2)
+			}
+		}
+		if p.category == CategoryNightclub {
+			p.opens, p.closes = 22, 24
+		}
+		p.vegetarian = p.cuisine == "vegan" || r.Intn(5) == 0
+
+		// One place in ten has no reviews yet; review counts spread from a handful to thousands
+		if r.Intn(10) > 0 {
+			p.rating = math.Round((3+r.Float64()*1.9)*10) / 10
+			p.reviews = 1 + int(math.Exp(r.Float64()*9))
+		}
+		places[i] = p
+	}
+	return places
+}
+
+// fakePlaceByID regenerates the place with a fake place ID
+func fakePlaceByID(id string) (fakePlace, bool) {
+	parts := strings.Split(strings.TrimPrefix(id, fakePlaceIDPrefix), "_")
+	if !strings.HasPrefix(id, fakePlaceIDPrefix) || len(parts) != 3 {
+		return fakePlace{}, false
+	}
+	var n [3]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return fakePlace{}, false
+		}
+		n[i] = v
+	}
+	places := fakeCell(n[0], n[1])
+	if n[2] < 0 || n[2] >= len(places) {
+		return fakePlace{}, false
+	}
+	return places[n[2]], true
+}
+
+// openAt reports whether the place is open at t
+func (p fakePlace) openAt(t time.Time) *bool {
+	open := t.Hour() >= p.opens && t.Hour() < p.closes
+	return &open
+}
+
+func (p fakePlace) typeName() string {
+	if p.cuisine != "" {
+		return formatTypeString(p.cuisine)
+	}
+	return formatTypeString(string(p.category))
+}
+
+func (p fakePlace) address() string {
+	return fmt.Sprintf("%s %d", p.street, p.houseNumber)
+}
+
+// matches reports whether the place is in one of categories (all if empty) and matches keyword
+func (p fakePlace) matches(categories []FoodCategory, keyword string) bool {
+	if len(categories) > 0 {
+		found := false
+		for _, c := range categories {
+			if c == p.category || (c == CategoryDelivery && (p.category == CategoryRestaurant || p.category == CategoryTakeaway)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	keyword = strings.ToLower(keyword)
+	if keyword == "" {
+		return true
+	}
+	if (keyword == "vegetarian" || keyword == "vegan") && p.vegetarian {
+		return true
+	}
+	return strings.Contains(strings.ToLower(p.name), keyword) || strings.Contains(p.cuisine, keyword)
+}
+
+// findNearbyRestaurantsFakeWithStats searches the generated places like the
+// OSM search does: around the point or within the area, nearest first up to
+// fakeMaxResults, ranked by rating
+func (rb *RestaurantBot) findNearbyRestaurantsFakeWithStats(ctx context.Context, params SearchParams) (result *SearchResult, err error) {
+	ctx, span := rb.tracing.Start(ctx, "search.fake")
+	defer func() { endSearchSpan(span, result, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, rb.requestTimeout)
+	defer cancel()
+	if err := sleepContext(ctx, rb.fake.Latency); err != nil {
+		return nil, fmt.Errorf("fake provider: %w", err)
+	}
+	if rb.fake.FailureRate > 0 && rand.Float64() < rb.fake.FailureRate {
+		return nil, errFakeFailure
+	}
+
+	minLat, maxLat, minLon, maxLon := params.Lat, params.Lat, params.Lon, params.Lon
+	radiusKm := float64(params.searchRadius()) / 1000
+	if params.Area != nil {
+		minLat, maxLat, minLon, maxLon = params.Area.MinLat, params.Area.MaxLat, params.Area.MinLon, params.Area.MaxLon
+	} else {
+		dLat := radiusKm / 111.32
+		dLon := dLat / math.Max(math.Cos(params.Lat*math.Pi/180), 0.01)
+		minLat, maxLat, minLon, maxLon = minLat-dLat, maxLat+dLat, minLon-dLon, maxLon+dLon
+	}
+
+	now := time.Now()
+	restaurants := make([]Restaurant, 0)
+	for latCell := int(math.Floor(minLat / fakeCellDegrees)); latCell <= int(math.Floor(maxLat/fakeCellDegrees)); latCell++ {
+		for lonCell := int(math.Floor(minLon / fakeCellDegrees)); lonCell <= int(math.Floor(maxLon/fakeCellDegrees)); lonCell++ {
+			for _, p := range fakeCell(latCell, lonCell) {
+				if !validCoordinates(p.lat, p.lon) || !p.matches(params.Categories, params.Keyword) {
+					continue
+				}
+				distance := calculateDistance(params.Lat, params.Lon, p.lat, p.lon)
+				if params.Area != nil && !params.Area.Contains(p.lat, p.lon) || params.Area == nil && distance > radiusKm {
+					continue
+				}
+				restaurants = append(restaurants, Restaurant{
+					Name:           p.name,
+					Rating:         p.rating,
+					ReviewCount:    p.reviews,
+					PriceLevel:     p.priceLevel,
+					Type:           p.typeName(),
+					Latitude:       p.lat,
+					Longitude:      p.lon,
+					Address:        p.address(),
+					Distance:       distance,
+					PhotoReference: genericPhotoReference,
+					PlaceID:        p.id,
+					OpenNow:        p.openAt(now),
+				})
+			}
+		}
+	}
+
+	sort.Slice(restaurants, func(i, j int) bool { return restaurants[i].Distance < restaurants[j].Distance })
+	if len(restaurants) > fakeMaxResults {
+		restaurants = restaurants[:fakeMaxResults]
+	}
+	sortRestaurantsByRating(restaurants)
+
+	stats := SearchStats{TotalBeforeDedup: len(restaurants), TotalAfterDedup: len(restaurants)}
+	ctxLogger(ctx).Debug("[FAKE] Search finished", "restaurants", len(restaurants))
+	return &SearchResult{Restaurants: restaurants, Stats: stats}, nil
+}
+
+// fakePlaceDetails serves /api/places/{id} for fake place IDs
+func fakePlaceDetails(id string, now time.Time) (*PlaceDetails, error) {
+	p, ok := fakePlaceByID(id)
+	if !ok {
+		return nil, errPlaceNotFound
+	}
+	return &PlaceDetails{
+		ID:           p.id,
+		Source:       "fake",
+		Name:         p.name,
+		Address:      p.address(),
+		Latitude:     p.lat,
+		Longitude:    p.lon,
+		Phone:        fmt.Sprintf("555-%04d", p.houseNumber*37%10000),
+		Website:      "https://example.com/" + strings.ReplaceAll(strings.ToLower(p.name), " ", "-"),
+		Rating:       p.rating,
+		ReviewCount:  p.reviews,
+		PriceLevel:   p.priceLevel,
+		Types:        []string{string(p.category)},
+		Cuisine:      p.cuisine,
+		Summary:      "Made-up place from the fake provider",
+		OpenNow:      p.openAt(now),
+		OpeningHours: []string{fmt.Sprintf("Mo-Su %02d:00-%02d:00", p.opens, p.closes)},
+		Photos: []PlacePhoto{{
+			Reference: genericPhotoReference,
+			URL:       placePhotoURL(p.id, genericPhotoReference, 0),
+		}},
+		FetchedAt: now,
+	}, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeBot returns a bot searching the fake provider
+func newFakeBot(t *testing.T) *RestaurantBot {
+	t.Helper()
+	bot, err := NewRestaurantBot("", "", "fake")
+	if err != nil {
+		t.Fatalf("NewRestaurantBot: %v", err)
+	}
+	return bot
+}
+
+func TestFakeProviderIsDeterministic(t *testing.T) {
+	params := SearchParams{Lat: 52.52, Lon: 13.405}
+	first, err := newFakeBot(t).Search(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	second, err := newFakeBot(t).Search(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(first.Restaurants) == 0 || !reflect.DeepEqual(first.Restaurants, second.Restaurants) {
+		t.Fatalf("searches at the same point differ: %d and %d results", len(first.Restaurants), len(second.Restaurants))
+	}
+
+	ids := map[string]bool{}
+	for _, r := range first.Restaurants {
+		ids[r.PlaceID] = true
+		if r.Distance > 2 || r.Name == "" || r.Type == "" || r.Address == "" || r.PhotoReference != genericPhotoReference {
+			t.Errorf("unrealistic place %+v", r)
+		}
+		if r.Rating != 0 && (r.Rating < 3 || r.Rating > 5 || r.ReviewCount == 0) {
+			t.Errorf("rating %.1f with %d reviews", r.Rating, r.ReviewCount)
+		}
+	}
+
+	// A search 200 m away sees mostly the same places
+	moved, err := newFakeBot(t).Search(context.Background(), SearchParams{Lat: 52.5218, Lon: 13.405})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	shared := 0
+	for _, r := range moved.Restaurants {
+		if ids[r.PlaceID] {
+			shared++
+		}
+	}
+	if shared < len(moved.Restaurants)/2 {
+		t.Errorf("only %d of %d places shared with a search 200 m away", shared, len(moved.Restaurants))
+	}
+}
+
+func TestFakeProviderFilters(t *testing.T) {
+	bot := newFakeBot(t)
+	cafes, err := bot.Search(context.Background(), SearchParams{Lat: -33.87, Lon: 151.21, Categories: []FoodCategory{CategoryCafe}})
+	if err != nil || len(cafes.Restaurants) == 0 {
+		t.Fatalf("Search cafes: %v, %+v", err, cafes)
+	}
+	for _, r := range cafes.Restaurants {
+		if r.Type != "Cafe" {
+			t.Errorf("cafe search returned %s (%s)", r.Name, r.Type)
+		}
+	}
+
+	vegan, err := bot.Search(context.Background(), SearchParams{Lat: -33.87, Lon: 151.21, Keyword: "vegan"})
+	if err != nil || len(vegan.Restaurants) == 0 {
+		t.Fatalf("Search vegan: %v, %+v", err, vegan)
+	}
+
+	area, err := parseBBox("151.20,-33.88,151.22,-33.86")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := SearchParams{}
+	params.setArea(area)
+	inArea, err := bot.Search(context.Background(), params)
+	if err != nil || len(inArea.Restaurants) == 0 {
+		t.Fatalf("Search area: %v, %+v", err, inArea)
+	}
+	for _, r := range inArea.Restaurants {
+		if !area.Contains(r.Latitude, r.Longitude) {
+			t.Errorf("%s at %v,%v is outside the area", r.Name, r.Latitude, r.Longitude)
+		}
+	}
+}
+
+func TestFakeProviderThroughAPI(t *testing.T) {
+	bot := newFakeBot(t)
+	server := NewServer(bot, bot, newMemoryPhotoStore())
+
+	rec := serve(server, "GET", "/api/v1/restaurants?lat=48.8566&lon=2.3522&limit=5", "")
+	page := decodePage(t, rec)
+	if len(page.Restaurants) != 5 {
+		t.Fatalf("%d restaurants, want 5", len(page.Restaurants))
+	}
+	place := page.Restaurants[0]
+
+	rec = serve(server, "GET", "/api/v1/places/"+place.PlaceID, "")
+	var details PlaceDetails
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&details) != nil {
+		t.Fatalf("details: %d %s", rec.Code, rec.Body.String())
+	}
+	if details.Source != "fake" || details.Name != place.Name || details.Rating != place.Rating || len(details.Photos) != 1 {
+		t.Errorf("details %+v do not match %+v", details, place)
+	}
+	if rec := serve(server, "GET", "/api/v1/places/"+fakePlaceIDPrefix+"1_2_99", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown fake place: %d", rec.Code)
+	}
+
+	rec = serve(server, "GET", details.Photos[0].URL, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("photo: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestFakeProviderLatencyAndFailures(t *testing.T) {
+	bot := newFakeBot(t)
+	bot.fake = FakeProviderConfig{FailureRate: 1}
+	server := NewServer(bot, bot, newMemoryPhotoStore())
+	rec := serve(server, "GET", "/api/v1/restaurants?lat=48.8566&lon=2.3522", "")
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), ErrCodeProviderUnavailable) {
+		t.Errorf("injected failure: %d %s", rec.Code, rec.Body.String())
+	}
+
+	bot.fake = FakeProviderConfig{Latency: 30 * time.Millisecond}
+	start := time.Now()
+	if _, err := bot.Search(context.Background(), SearchParams{Lat: 1, Lon: 1}); err != nil || time.Since(start) < 30*time.Millisecond {
+		t.Errorf("search with latency took %s: %v", time.Since(start), err)
+	}
+
+	// Latency beyond the request timeout is a provider timeout
+	bot.fake.Latency = time.Second
+	bot.requestTimeout = 10 * time.Millisecond
+	if _, err := bot.Search(context.Background(), SearchParams{Lat: 1, Lon: 1}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow search: %v", err)
+	}
+}
+
+func TestFakeProviderConfig(t *testing.T) {
+	cfg, err := loadTestConfig(t, map[string]string{"API_PROVIDER": "fake", "FAKE_LATENCY": "250ms", "FAKE_FAILURE_RATE": "0.1"})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	bot, err := NewRestaurantBotFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewRestaurantBotFromConfig: %v", err)
+	}
+	if bot.apiProvider != "fake" || bot.mapsClient != nil || bot.fake.Latency != 250*time.Millisecond || bot.fake.FailureRate != 0.1 {
+		t.Errorf("bot provider %s, fake %+v", bot.apiProvider, bot.fake)
+	}
+	if _, err := loadTestConfig(t, map[string]string{"API_PROVIDER": "fake", "FAKE_FAILURE_RATE": "1.5"}); err == nil {
+		t.Error("FAKE_FAILURE_RATE=1.5 accepted")
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sort orders supported by /api/restaurants
+const (
+	SortScore    = "score"    // Bayesian weighted rating (default)
+	SortDistance = "distance" // Closest first
+	SortRating   = "rating"   // Highest raw rating first
+	SortReviews  = "reviews"  // Most reviews first
+	SortPrice    = "price"    // Cheapest first, unknown price last
+)
+
+var validSortOrders = map[string]bool{
+	SortScore:    true,
+	SortDistance: true,
+	SortRating:   true,
+	SortReviews:  true,
+	SortPrice:    true,
+}
+
+// knownChainNames are normalized names of chains that OSM often lacks a brand tag for
+// and Google never marks as chains
+var knownChainNames = map[string]bool{
+	"mcdonald's":      true,
+	"mcdonalds":       true,
+	"burger king":     true,
+	"kfc":             true,
+	"subway":          true,
+	"starbucks":       true,
+	"domino's":        true,
+	"domino's pizza":  true,
+	"pizza hut":       true,
+	"dunkin'":         true,
+	"dunkin' donuts":  true,
+	"tim hortons":     true,
+	"taco bell":       true,
+	"wendy's":         true,
+	"five guys":       true,
+	"nando's":         true,
+	"chipotle":        true,
+	"costa coffee":    true,
+	"pret a manger":   true,
+	"vapiano":         true,
+	"nordsee":         true,
+	"dean&david":      true,
+	"dean & david":    true,
+	"papa john's":     true,
+	"popeyes":         true,
+	"little caesars":  true,
+	"dairy queen":     true,
+	"panda express":   true,
+	"hans im glück":   true,
+	"l'osteria":       true,
+	"block house":     true,
+	"backwerk":        true,
+	"ditsch":          true,
+	"le crobag":       true,
+	"coffee fellows":  true,
+	"espresso house":  true,
+	"tchibo":          true,
+	"jamie's italian": true,
+}
+
+// ResultFilters are the server-side sort and filter options applied to merged
+// results before pagination. Zero values mean "not set". It is echoed back in
+// the response so clients can see what was applied.
+type ResultFilters struct {
+	Sort          string  `json:"sort"`
+	MinRating     float64 `json:"minRating,omitempty"`
+	MinReviews    int     `json:"minReviews,omitempty"`
+	PriceMin      int     `json:"priceMin,omitempty"`    // 1-4, unknown price excluded when set
+	PriceMax      int     `json:"priceMax,omitempty"`    // 1-4, unknown price excluded when set
+	MaxDistance   float64 `json:"maxDistance,omitempty"` // meters
+	OpenNow       bool    `json:"openNow,omitempty"`     // only places known to be open at search time
+	ExcludeChains bool    `json:"excludeChains,omitempty"`
+}
+
+// parseResultFiltersQuery parses sort and filter query parameters
+func parseResultFiltersQuery(q url.Values) (ResultFilters, error) {
+	var f ResultFilters
+	var err error
+
+	f.Sort = strings.ToLower(strings.TrimSpace(q.Get("sort")))
+	if v := q.Get("min_rating"); v != "" {
+		if f.MinRating, err = strconv.ParseFloat(v, 64); err != nil {
+			return f, fmt.Errorf("invalid min_rating parameter")
+		}
+	}
+	if v := q.Get("min_reviews"); v != "" {
+		if f.MinReviews, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("invalid min_reviews parameter")
+		}
+	}
+	if v := q.Get("price_min"); v != "" {
+		if f.PriceMin, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("invalid price_min parameter")
+		}
+	}
+	if v := q.Get("price_max"); v != "" {
+		if f.PriceMax, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("invalid price_max parameter")
+		}
+	}
+	if v := q.Get("max_distance"); v != "" {
+		if f.MaxDistance, err = strconv.ParseFloat(v, 64); err != nil {
+			return f, fmt.Errorf("invalid max_distance parameter")
+		}
+	}
+	if v := q.Get("open_now"); v != "" {
+		if f.OpenNow, err = strconv.ParseBool(v); err != nil {
+			return f, fmt.Errorf("invalid open_now parameter")
+		}
+	}
+	if v := q.Get("exclude_chains"); v != "" {
+		if f.ExcludeChains, err = strconv.ParseBool(v); err != nil {
+			return f, fmt.Errorf("invalid exclude_chains parameter")
+		}
+	}
+
+	return f, f.validate()
+}
+
+// validate checks ranges and fills in the default sort order
+func (f *ResultFilters) validate() error {
+	if f.Sort == "" {
+		f.Sort = SortScore
+	}
+	if !validSortOrders[f.Sort] {
+		return fmt.Errorf("invalid sort %q: must be one of score, distance, rating, reviews, price", f.Sort)
+	}
+	if f.MinRating < 0 || f.MinRating > 5 {
+		return fmt.Errorf("min_rating must be between 0 and 5")
+	}
+	if f.MinReviews < 0 {
+		return fmt.Errorf("min_reviews must not be negative")
+	}
+	if f.PriceMin < 0 || f.PriceMin > 4 || f.PriceMax < 0 || f.PriceMax > 4 {
+		return fmt.Errorf("price_min and price_max must be between 1 and 4")
+	}
+	if f.PriceMin > 0 && f.PriceMax > 0 && f.PriceMin > f.PriceMax {
+		return fmt.Errorf("price_min must not be greater than price_max")
+	}
+	if f.MaxDistance < 0 {
+		return fmt.Errorf("max_distance must not be negative")
+	}
+	return nil
+}
+
+// isChainRestaurant reports whether a place belongs to a chain (OSM brand tag or known chain name)
+func isChainRestaurant(r Restaurant) bool {
+	if r.Brand != "" {
+		return true
+	}
+	name := strings.ToLower(strings.TrimSpace(r.Name))
+	name = strings.TrimPrefix(name, "[google] ")
+	name = strings.TrimPrefix(name, "[osm] ")
+	name = strings.ReplaceAll(name, "’", "'")
+	return knownChainNames[name]
+}
+
+// matches reports whether a restaurant passes all filters
+func (f ResultFilters) matches(r Restaurant) bool {
+	if f.MinRating > 0 && r.Rating < f.MinRating {
+		return false
+	}
+	if f.MinReviews > 0 && r.ReviewCount < f.MinReviews {
+		return false
+	}
+	if (f.PriceMin > 0 || f.PriceMax > 0) && r.PriceLevel == 0 {
+		return false
+	}
+	if f.PriceMin > 0 && r.PriceLevel < f.PriceMin {
+		return false
+	}
+	if f.PriceMax > 0 && r.PriceLevel > f.PriceMax {
+		return false
+	}
+	if f.MaxDistance > 0 && r.Distance*1000 > f.MaxDistance {
+		return false
+	}
+	if f.OpenNow && (r.OpenNow == nil || !*r.OpenNow) {
+		return false
+	}
+	if f.ExcludeChains && isChainRestaurant(r) {
+		return false
+	}
+	return true
+}
+
+// applyResultFilters filters and sorts restaurants. The input slice (which may be
+// shared with the cache) is never modified.
+func applyResultFilters(restaurants []Restaurant, f ResultFilters) []Restaurant {
+	filtered := make([]Restaurant, 0, len(restaurants))
+	for _, r := range restaurants {
+		if f.matches(r) {
+			filtered = append(filtered, r)
+		}
+	}
+
+	switch f.Sort {
+	case SortDistance:
+		sortRestaurantsByDistance(filtered, 0, 0)
+	case SortRating:
+		sort.SliceStable(filtered, func(i, j int) bool {
+			if filtered[i].Rating != filtered[j].Rating {
+				return filtered[i].Rating > filtered[j].Rating
+			}
+			return filtered[i].Distance < filtered[j].Distance
+		})
+	case SortReviews:
+		sort.SliceStable(filtered, func(i, j int) bool {
+			if filtered[i].ReviewCount != filtered[j].ReviewCount {
+				return filtered[i].ReviewCount > filtered[j].ReviewCount
+			}
+			return filtered[i].Distance < filtered[j].Distance
+		})
+	case SortPrice:
+		sort.SliceStable(filtered, func(i, j int) bool {
+			pi, pj := filtered[i].PriceLevel, filtered[j].PriceLevel
+			// Unknown price (0) sorts last
+			if (pi == 0) != (pj == 0) {
+				return pj == 0
+			}
+			if pi != pj {
+				return pi < pj
+			}
+			return filtered[i].Distance < filtered[j].Distance
+		})
+	default:
+		sortRestaurantsByRating(filtered)
+	}
+
+	return filtered
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// filterFixture returns restaurants whose order differs under every sort
+func filterFixture() []Restaurant {
+	open, closed := true, false
+	return []Restaurant{
+		{Name: "Trattoria", Rating: 4.8, ReviewCount: 2000, PriceLevel: 2, Distance: 0.4, OpenNow: &open},
+		{Name: "New Place", Rating: 5.0, ReviewCount: 1, Distance: 0.1},
+		{Name: "McDonald’s", Rating: 4.0, ReviewCount: 500, PriceLevel: 1, Distance: 0.2, OpenNow: &closed},
+		{Name: "Bistro", Rating: 4.5, ReviewCount: 50, PriceLevel: 4, Distance: 0.9, OpenNow: &open, Brand: "Bistro Group"},
+		{Name: "[OSM] Subway", Distance: 0.3},
+	}
+}
+
+func TestApplyResultFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters ResultFilters
+		want    []string
+	}{
+		{"weighted score", ResultFilters{Sort: SortScore}, []string{"Trattoria", "Bistro", "McDonald’s", "New Place", "[OSM] Subway"}},
+		{"distance", ResultFilters{Sort: SortDistance}, []string{"New Place", "McDonald’s", "[OSM] Subway", "Trattoria", "Bistro"}},
+		{"raw rating", ResultFilters{Sort: SortRating}, []string{"New Place", "Trattoria", "Bistro", "McDonald’s", "[OSM] Subway"}},
+		{"reviews", ResultFilters{Sort: SortReviews}, []string{"Trattoria", "McDonald’s", "Bistro", "New Place", "[OSM] Subway"}},
+		{"price with unknown last", ResultFilters{Sort: SortPrice}, []string{"McDonald’s", "Trattoria", "Bistro", "New Place", "[OSM] Subway"}},
+		{"min rating", ResultFilters{Sort: SortScore, MinRating: 4.5}, []string{"Trattoria", "Bistro", "New Place"}},
+		{"min reviews", ResultFilters{Sort: SortScore, MinReviews: 100}, []string{"Trattoria", "McDonald’s"}},
+		{"price range excludes unknown", ResultFilters{Sort: SortScore, PriceMin: 1, PriceMax: 2}, []string{"Trattoria", "McDonald’s"}},
+		{"price min only", ResultFilters{Sort: SortScore, PriceMin: 4}, []string{"Bistro"}},
+		{"max distance is inclusive", ResultFilters{Sort: SortScore, MaxDistance: 300}, []string{"McDonald’s", "New Place", "[OSM] Subway"}},
+		{"open now excludes unknown", ResultFilters{Sort: SortScore, OpenNow: true}, []string{"Trattoria", "Bistro"}},
+		{"exclude chains", ResultFilters{Sort: SortScore, ExcludeChains: true}, []string{"Trattoria", "New Place"}},
+		{"combined", ResultFilters{Sort: SortDistance, MinRating: 4, ExcludeChains: true}, []string{"New Place", "Trattoria"}},
+		{"nothing matches", ResultFilters{Sort: SortScore, MinRating: 5, MinReviews: 10}, []string{}},
+	}
+	for _, tt := range tests {
+		input := filterFixture()
+		result := applyResultFilters(input, tt.filters)
+		names := make([]string, len(result))
+		for i, r := range result {
+			names[i] = r.Name
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, names, tt.want)
+		}
+		// The input may be shared with the cache
+		if !reflect.DeepEqual(input, filterFixture()) {
+			t.Errorf("%s: input was modified", tt.name)
+		}
+	}
+}
+
+func TestParseResultFiltersQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    ResultFilters
+		wantErr bool
+	}{
+		{"", ResultFilters{Sort: SortScore}, false},
+		{"sort=Distance&min_rating=4.5&min_reviews=20&price_min=1&price_max=3&max_distance=800&open_now=true&exclude_chains=1",
+			ResultFilters{Sort: SortDistance, MinRating: 4.5, MinReviews: 20, PriceMin: 1, PriceMax: 3, MaxDistance: 800, OpenNow: true, ExcludeChains: true}, false},
+		{"sort=popularity", ResultFilters{}, true},
+		{"min_rating=high", ResultFilters{}, true},
+		{"min_rating=5.5", ResultFilters{}, true},
+		{"min_reviews=-1", ResultFilters{}, true},
+		{"price_min=5", ResultFilters{}, true},
+		{"price_min=3&price_max=2", ResultFilters{}, true},
+		{"max_distance=-10", ResultFilters{}, true},
+		{"open_now=maybe", ResultFilters{}, true},
+		{"exclude_chains=yes", ResultFilters{}, true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		got, err := parseResultFiltersQuery(q)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"googlemaps.github.io/maps"
+)
+
+// defaultFixtureDir is where provider fixtures are recorded and replayed from
+const defaultFixtureDir = "testdata/fixtures"
+
+// fixtureEndpointGoogle names the fixtures of the Google Maps client, which
+// shares one HTTP client between all Google APIs
+const fixtureEndpointGoogle = "google"
+
+// Fixture modes
+const (
+	FixturesRecord = "record"
+	FixturesReplay = "replay"
+)
+
+// fixtureSecretParams are query parameters holding credentials; they are left
+// out of fixture keys and their values are scrubbed from everything saved
+var fixtureSecretParams = []string{"key", "signature", "client"}
+
+// fixtureSecretHeaders are request headers holding credentials
+var fixtureSecretHeaders = []string{"Authorization", "X-Goog-Api-Key"}
+
+// FixtureConfig records provider calls to, or replays them from, fixture files
+type FixtureConfig struct {
+	Mode string // "" (live), "record" or "replay"
+	Dir  string
+}
+
+// Fixtures records provider HTTP exchanges into fixture files, or serves them
+// back without touching the network, so searches can be tested offline
+// against real payloads. A nil *Fixtures passes requests through.
+type Fixtures struct {
+	mode string
+	dir  string
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	recorded map[string]*fixture // recorded in this process, by file
+	served   map[string]int      // replayed responses per file
+}
+
+// fixture is one fixture file: a request and its responses in the order they
+// were received (a repeated request, like a page token retry, gets the next one)
+type fixture struct {
+	Request   fixtureRequest    `json:"request"`
+	Responses []fixtureResponse `json:"responses"`
+}
+
+type fixtureRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// fixtureResponse holds the body in one of three fields: JSON payloads as JSON,
+// so fixtures are readable and can be edited, other text as a string, and
+// binary bodies such as photos base64-encoded
+type fixtureResponse struct {
+	Status     int             `json:"status"`
+	Header     http.Header     `json:"header,omitempty"`
+	JSON       json.RawMessage `json:"json,omitempty"`
+	Body       string          `json:"body,omitempty"`
+	BodyBase64 []byte          `json:"bodyBase64,omitempty"`
+}
+
+// fixtureResponseHeaders are the response headers kept in fixtures
+var fixtureResponseHeaders = []string{"Content-Type", "Location"}
+
+// NewFixtures returns fixtures for cfg, or nil when provider calls go to the network
+func NewFixtures(cfg FixtureConfig) (*Fixtures, error) {
+	switch cfg.Mode {
+	case "":
+		return nil, nil
+	case FixturesRecord, FixturesReplay:
+	default:
+		return nil, fmt.Errorf("unknown fixture mode %q", cfg.Mode)
+	}
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("a fixture directory is required")
+	}
+	return &Fixtures{
+		mode:     cfg.Mode,
+		dir:      cfg.Dir,
+		base:     http.DefaultTransport,
+		recorded: make(map[string]*fixture),
+		served:   make(map[string]int),
+	}, nil
+}
+
+// Transport returns the transport provider calls to endpoint go through: the
+// network, or the fixtures when recording or replaying
+func (f *Fixtures) Transport(endpoint string) http.RoundTripper {
+	if f == nil {
+		return nil
+	}
+	return &fixtureTransport{fixtures: f, endpoint: endpoint}
+}
+
+// useFixtures sends provider calls, including the Google Maps client's, through f
+func (rb *RestaurantBot) useFixtures(f *Fixtures) error {
+	rb.fixtures = f
+	if f == nil || rb.mapsClient == nil {
+		return nil
+	}
+	client, err := maps.NewClient(maps.WithAPIKey(rb.mapsAPIKey), maps.WithHTTPClient(&http.Client{Transport: f.Transport(fixtureEndpointGoogle)}))
+	if err != nil {
+		return fmt.Errorf("failed to create maps client: %w", err)
+	}
+	rb.mapsClient = client
+	return nil
+}
+
+type fixtureTransport struct {
+	fixtures *Fixtures
+	endpoint string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	scrub := newFixtureScrubber(req)
+	request := fixtureRequest{Method: req.Method, URL: scrub(fixtureURL(req)), Body: scrub(string(body))}
+	sum := sha256.Sum256([]byte(request.Method + " " + request.URL + "\n" + request.Body))
+	name := t.endpoint + "-" + hex.EncodeToString(sum[:8]) + ".json"
+
+	if t.fixtures.mode == FixturesReplay {
+		return t.fixtures.replay(req, name, request)
+	}
+	return t.fixtures.record(req, name, request, scrub)
+}
+
+// replay answers req with the next response recorded for it
+func (f *Fixtures) replay(req *http.Request, name string, request fixtureRequest) (*http.Response, error) {
+	data, err := os.ReadFile(filepath.Join(f.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("no fixture for %s %s: %w", request.Method, request.URL, err)
+	}
+	var fx fixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", name, err)
+	}
+	if len(fx.Responses) == 0 {
+		return nil, fmt.Errorf("fixture %s has no responses", name)
+	}
+
+	f.mu.Lock()
+	i := f.served[name]
+	f.served[name]++
+	f.mu.Unlock()
+	// Once the recorded sequence is used up, the last response is repeated
+	if i >= len(fx.Responses) {
+		i = len(fx.Responses) - 1
+	}
+	r := fx.Responses[i]
+	body := []byte(r.Body)
+	switch {
+	case r.JSON != nil:
+		body = r.JSON
+	case r.BodyBase64 != nil:
+		body = r.BodyBase64
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// record sends req to the network and appends the scrubbed exchange to its fixture
+func (f *Fixtures) record(req *http.Request, name string, request fixtureRequest, scrub func(string) string) (*http.Response, error) {
+	resp, err := f.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	saved := fixtureResponse{Status: resp.StatusCode, Header: http.Header{}}
+	for _, h := range fixtureResponseHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			saved.Header.Set(h, scrub(v))
+		}
+	}
+	switch text := scrub(string(body)); {
+	case json.Valid([]byte(text)):
+		saved.JSON = json.RawMessage(text)
+	case utf8.Valid(body):
+		saved.Body = text
+	default:
+		saved.BodyBase64 = body
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// The first call in a run replaces what an earlier run recorded
+	fx := f.recorded[name]
+	if fx == nil {
+		fx = &fixture{Request: request}
+		f.recorded[name] = fx
+	}
+	fx.Responses = append(fx.Responses, saved)
+	if err := f.save(name, fx); err != nil {
+		ctxLogger(req.Context()).Error("[FIXTURES] Failed to record", "fixture", name, "error", err)
+	}
+	return resp, nil
+}
+
+// save writes a fixture file
+func (f *Fixtures) save(name string, fx *fixture) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // keep URLs and queries readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(fx); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(f.dir, name), buf.Bytes(), 0644)
+}
+
+// fixtureURL returns the request URL with the query sorted and credentials removed,
+// so a fixture matches regardless of parameter order or the key in use
+func fixtureURL(req *http.Request) string {
+	u := *req.URL
+	q := u.Query()
+	for _, p := range fixtureSecretParams {
+		q.Del(p)
+	}
+	u.RawQuery = q.Encode() // Encode sorts by key
+	return u.String()
+}
+
+// newFixtureScrubber returns a function replacing the credentials sent with
+// req wherever they appear, e.g. echoed in an error message
+func newFixtureScrubber(req *http.Request) func(string) string {
+	var secrets []string
+	q := req.URL.Query()
+	for _, p := range fixtureSecretParams {
+		secrets = append(secrets, q[p]...)
+	}
+	for _, h := range fixtureSecretHeaders {
+		if v := req.Header.Get(h); v != "" {
+			secrets = append(secrets, strings.TrimPrefix(v, "Bearer "))
+		}
+	}
+	// Longest first, so a secret containing another is replaced whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return func(s string) string {
+		for _, secret := range secrets {
+			if secret != "" {
+				s = strings.ReplaceAll(s, secret, redactedValue)
+			}
+		}
+		return s
+	}
+}
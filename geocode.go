@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"googlemaps.github.io/maps"
+)
+
+const (
+	defaultNominatimURL     = "https://nominatim.openstreetmap.org"
+	geocoderUserAgent       = "telegram-restaurant-bot/1.0" // Nominatim's usage policy requires an identifying User-Agent
+	geocodeCacheTTL         = 7 * 24 * time.Hour            // Place names rarely move
+	geocodeNotFoundCacheTTL = 1 * time.Hour                 // Unknown names are retried sooner
+	maxCachedGeocodes       = 10000                         // Entries closest to expiry are evicted beyond this
+	maxGeocodeQueryLength   = 200
+)
+
+// publicNominatimHost is the OpenStreetMap Foundation's instance, whose usage
+// policy allows at most one request per second
+const publicNominatimHost = "nominatim.openstreetmap.org"
+
+// publicNominatimLimit spaces requests to the public instance across the
+// whole process, however many geocoders use it
+var publicNominatimLimit = NewRateLimiter(60, 1)
+
+var (
+	errGeocodeNotFound       = errors.New("no location found")
+	errGeocodeBudgetExceeded = errors.New("google budget exhausted")
+)
+
+// GeocodeResult is a place name or address resolved to coordinates
+type GeocodeResult struct {
+	Query  string  `json:"query"`
+	Name   string  `json:"name"` // Display name / formatted address of the match
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Source string  `json:"source"` // "nominatim" or "google"
+}
+
+// Geocoder resolves free-text places to coordinates. It returns
+// errGeocodeNotFound if nothing matches.
+type Geocoder interface {
+	Geocode(ctx context.Context, query string) (*GeocodeResult, error)
+}
+
+// NominatimGeocoder queries a Nominatim-compatible /search endpoint (public or self-hosted)
+type NominatimGeocoder struct {
+	baseURL  string
+	client   *http.Client
+	throttle *RateLimiter // publicNominatimLimit for the public instance, nil when self-hosted
+}
+
+// NewNominatimGeocoder creates a geocoder for the Nominatim instance at baseURL
+func NewNominatimGeocoder(baseURL string) *NominatimGeocoder {
+	ng := &NominatimGeocoder{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: defaultRequestTimeout},
+	}
+	if u, err := url.Parse(baseURL); err == nil && strings.EqualFold(u.Hostname(), publicNominatimHost) {
+		ng.throttle = publicNominatimLimit
+	}
+	return ng
+}
+
+// Geocode returns the best Nominatim match for query
+func (ng *NominatimGeocoder) Geocode(ctx context.Context, query string) (*GeocodeResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ng.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", geocoderUserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	if err := ng.throttle.Wait(ctx, publicNominatimHost); err != nil {
+		return nil, fmt.Errorf("nominatim request failed: %w", err)
+	}
+	resp, err := ng.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nominatim returned status %d", resp.StatusCode)
+	}
+
+	var places []struct {
+		Lat         string `json:"lat"`
+		Lon         string `json:"lon"`
+		DisplayName string `json:"display_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return nil, fmt.Errorf("failed to decode nominatim response: %w", err)
+	}
+	if len(places) == 0 {
+		return nil, errGeocodeNotFound
+	}
+
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude in nominatim response: %q", places[0].Lat)
+	}
+	lon, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude in nominatim response: %q", places[0].Lon)
+	}
+	return &GeocodeResult{Query: query, Name: places[0].DisplayName, Lat: lat, Lon: lon, Source: "nominatim"}, nil
+}
+
+// GoogleGeocoder uses the Google Geocoding API (billed as SKUGeocoding)
+type GoogleGeocoder struct {
+	client  *maps.Client
+	costs   *CostLedger
+	metrics *Metrics
+}
+
+// Geocode returns the best Google Geocoding match for query
+func (gg *GoogleGeocoder) Geocode(ctx context.Context, query string) (*GeocodeResult, error) {
+	if exceeded, _ := gg.costs.BudgetExceeded(); exceeded {
+		return nil, errGeocodeBudgetExceeded
+	}
+
+	start := time.Now()
+	results, err := gg.client.Geocode(ctx, &maps.GeocodingRequest{Address: query})
+	gg.metrics.ObserveProviderCall(EndpointGoogleGeocoding, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	gg.costs.Record(SKUGeocoding)
+
+	if len(results) == 0 {
+		return nil, errGeocodeNotFound
+	}
+	location := results[0].Geometry.Location
+	return &GeocodeResult{Query: query, Name: results[0].FormattedAddress, Lat: location.Lat, Lon: location.Lng, Source: "google"}, nil
+}
+
+type geocodeCacheEntry struct {
+	result    *GeocodeResult // nil caches "not found"
+	expiresAt time.Time
+}
+
+// CachingGeocoder caches results (including misses) of another geocoder
+type CachingGeocoder struct {
+	geocoder Geocoder
+	mu       sync.RWMutex
+	items    map[string]geocodeCacheEntry
+	now      func() time.Time
+	counters cacheCounters
+}
+
+// NewCachingGeocoder wraps geocoder with an in-memory cache
+func NewCachingGeocoder(geocoder Geocoder) *CachingGeocoder {
+	cg := &CachingGeocoder{
+		geocoder: geocoder,
+		items:    make(map[string]geocodeCacheEntry),
+		now:      time.Now,
+	}
+	// Start cleanup goroutine
+	go cg.cleanup()
+	return cg
+}
+
+// cleanup removes expired entries
+func (cg *CachingGeocoder) cleanup() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		cg.mu.Lock()
+		now := cg.now()
+		for key, entry := range cg.items {
+			if now.After(entry.expiresAt) {
+				delete(cg.items, key)
+				cg.counters.evictions.Add(1)
+			}
+		}
+		cg.mu.Unlock()
+	}
+}
+
+// normalizeGeocodeQuery lowercases and collapses whitespace so equivalent queries share a cache entry
+func normalizeGeocodeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// Len returns the number of cached lookups
+func (cg *CachingGeocoder) Len() int {
+	cg.mu.RLock()
+	defer cg.mu.RUnlock()
+	return len(cg.items)
+}
+
+// Geocode answers from the cache or asks the wrapped geocoder. Upstream errors are not cached.
+func (cg *CachingGeocoder) Geocode(ctx context.Context, query string) (*GeocodeResult, error) {
+	key := normalizeGeocodeQuery(query)
+
+	cg.mu.RLock()
+	entry, found := cg.items[key]
+	cg.mu.RUnlock()
+	hit := found && cg.now().Before(entry.expiresAt)
+	cg.counters.lookup(hit)
+	if hit {
+		ctxLogger(ctx).Debug("[GEOCODE] Cache hit", logKeyPlace, query)
+		if entry.result == nil {
+			return nil, errGeocodeNotFound
+		}
+		result := *entry.result
+		result.Query = query
+		return &result, nil
+	}
+
+	result, err := cg.geocoder.Geocode(ctx, query)
+	ttl := geocodeCacheTTL
+	switch {
+	case errors.Is(err, errGeocodeNotFound):
+		ttl = geocodeNotFoundCacheTTL
+	case err != nil:
+		return nil, err
+	}
+
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+	if _, exists := cg.items[key]; !exists && len(cg.items) >= maxCachedGeocodes {
+		var oldestKey string
+		var oldest time.Time
+		for k, e := range cg.items {
+			if oldestKey == "" || e.expiresAt.Before(oldest) {
+				oldestKey, oldest = k, e.expiresAt
+			}
+		}
+		delete(cg.items, oldestKey)
+		cg.counters.evictions.Add(1)
+	}
+	cg.items[key] = geocodeCacheEntry{result: result, expiresAt: cg.now().Add(ttl)}
+	return result, err
+}
+
+// GeocoderConfig selects the geocoder
+type GeocoderConfig struct {
+	Provider     string // "nominatim" or "google"
+	NominatimURL string // base URL of a Nominatim-compatible instance
+}
+
+// NewGeocoder creates the (cached) geocoder for cfg. Nominatim requests go
+// through nominatimTransport.
+func NewGeocoder(cfg GeocoderConfig, mapsClient *maps.Client, costs *CostLedger, metrics *Metrics, nominatimTransport http.RoundTripper) (Geocoder, error) {
+	switch cfg.Provider {
+	case "", "nominatim":
+		if _, err := url.ParseRequestURI(cfg.NominatimURL); err != nil {
+			return nil, fmt.Errorf("invalid NOMINATIM_URL %q: %w", cfg.NominatimURL, err)
+		}
+		nominatim := NewNominatimGeocoder(cfg.NominatimURL)
+		nominatim.client.Transport = nominatimTransport
+		return NewCachingGeocoder(nominatim), nil
+	case "google":
+		if mapsClient == nil {
+			return nil, fmt.Errorf("GEOCODER=google requires GOOGLE_MAPS_API_KEY")
+		}
+		return NewCachingGeocoder(&GoogleGeocoder{client: mapsClient, costs: costs, metrics: metrics}), nil
+	default:
+		return nil, fmt.Errorf("invalid GEOCODER %q: must be nominatim or google", cfg.Provider)
+	}
+}
+
+// geocodeErrorResponse maps a geocoding failure to an API error status and envelope
+func geocodeErrorResponse(err error) (int, APIError) {
+	switch {
+	case errors.Is(err, errGeocodeNotFound):
+		return http.StatusNotFound, APIError{Code: ErrCodeLocationNotFound, Message: "No location found for q"}
+	case errors.Is(err, errGeocodeBudgetExceeded):
+		return http.StatusServiceUnavailable, APIError{Code: ErrCodeBudgetExceeded, Message: "Google budget exhausted, geocoding is unavailable"}
+	default:
+		return http.StatusBadGateway, APIError{
+			Code:    ErrCodeProviderUnavailable,
+			Message: "Geocoding is currently unavailable, please try again later",
+			Details: []ProviderError{newProviderError("geocoder", err)},
+		}
+	}
+}
+
+// resolveSearchLocation geocodes params.Place into params.Lat/Lon when the
+// search was given a place name instead of coordinates. It returns the match
+// (nil for coordinate searches), or false if an error response has been sent.
+func (rb *RestaurantBot) resolveSearchLocation(ctx context.Context, w http.ResponseWriter, params *SearchParams) (*GeocodeResult, bool) {
+	if params.Place == "" {
+		return nil, true
+	}
+	if rb.geocoder == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, ErrCodeNotConfigured, "Geocoding is not configured")
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rb.requestTimeout)
+	defer cancel()
+
+	location, err := rb.geocoder.Geocode(ctx, params.Place)
+	if err != nil {
+		if !errors.Is(err, errGeocodeNotFound) {
+			ctxLogger(ctx).Error("[GEOCODE] Error geocoding", logKeyPlace, params.Place, "error", err)
+		}
+		status, apiErr := geocodeErrorResponse(err)
+		writeAPIError(w, status, apiErr.Code, apiErr.Message, apiErr.Details...)
+		return nil, false
+	}
+	ctxLogger(ctx).Info("[GEOCODE] Resolved place", logKeyPlace, params.Place, logKeyLat, location.Lat, logKeyLon, location.Lon)
+	params.Lat, params.Lon = location.Lat, location.Lon
+	return location, true
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newNominatimStub serves Nominatim /search responses for a fixed set of places
+func newNominatimStub(t *testing.T, hits *int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.URL.Path != "/search" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if r.Header.Get("User-Agent") != geocoderUserAgent {
+			t.Errorf("User-Agent = %q, want %q", r.Header.Get("User-Agent"), geocoderUserAgent)
+		}
+		if got := r.URL.Query().Get("format"); got != "jsonv2" {
+			t.Errorf("format = %q, want jsonv2", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("q") {
+		case "Alexanderplatz", "alexanderplatz":
+			w.Write([]byte(`[{"lat":"52.5219184","lon":"13.4132147","display_name":"Alexanderplatz, Mitte, Berlin, Deutschland"}]`))
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+}
+
+func TestNominatimGeocoder(t *testing.T) {
+	var hits int32
+	server := newNominatimStub(t, &hits)
+	defer server.Close()
+
+	geocoder := NewNominatimGeocoder(server.URL + "/")
+
+	result, err := geocoder.Geocode(context.Background(), "Alexanderplatz")
+	if err != nil {
+		t.Fatalf("Geocode: %v", err)
+	}
+	if result.Lat != 52.5219184 || result.Lon != 13.4132147 {
+		t.Errorf("coordinates = %v,%v", result.Lat, result.Lon)
+	}
+	if result.Name != "Alexanderplatz, Mitte, Berlin, Deutschland" || result.Source != "nominatim" || result.Query != "Alexanderplatz" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if _, err := geocoder.Geocode(context.Background(), "Nowhere at all"); !errors.Is(err, errGeocodeNotFound) {
+		t.Errorf("unknown place: err = %v, want errGeocodeNotFound", err)
+	}
+
+	if _, err := geocoder.Geocode(context.Background(), "broken"); err == nil || errors.Is(err, errGeocodeNotFound) {
+		t.Errorf("server error: err = %v, want upstream error", err)
+	}
+}
+
+func TestNominatimThrottlesThePublicInstance(t *testing.T) {
+	if NewNominatimGeocoder(defaultNominatimURL).throttle != publicNominatimLimit || NewNominatimGeocoder("https://Nominatim.OpenStreetMap.org/").throttle != publicNominatimLimit {
+		t.Error("the public instance is not throttled")
+	}
+	if NewNominatimGeocoder("https://nominatim.example.com").throttle != nil {
+		t.Error("a self-hosted instance is throttled")
+	}
+
+	var hits int32
+	server := newNominatimStub(t, &hits)
+	defer server.Close()
+	geocoder := NewNominatimGeocoder(server.URL)
+	geocoder.throttle = NewRateLimiter(600, 1) // one request per 100ms
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := geocoder.Geocode(context.Background(), "Alexanderplatz"); err != nil {
+			t.Fatalf("Geocode: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 200ms", elapsed)
+	}
+
+	// A caller that can't wait gives up without a request
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := geocoder.Geocode(ctx, "Alexanderplatz"); !errors.Is(err, context.DeadlineExceeded) || atomic.LoadInt32(&hits) != 3 {
+		t.Errorf("err = %v after %d requests, want a deadline error after 3", err, hits)
+	}
+}
+
+func TestCachingGeocoder(t *testing.T) {
+	var hits int32
+	server := newNominatimStub(t, &hits)
+	defer server.Close()
+
+	geocoder := NewCachingGeocoder(NewNominatimGeocoder(server.URL))
+	ctx := context.Background()
+
+	for _, query := range []string{"Alexanderplatz", "  alexanderplatz ", "ALEXANDERPLATZ"} {
+		result, err := geocoder.Geocode(ctx, query)
+		if err != nil {
+			t.Fatalf("Geocode(%q): %v", query, err)
+		}
+		if result.Query != query {
+			t.Errorf("Query = %q, want %q", result.Query, query)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("upstream hits = %d, want 1 (normalized queries share a cache entry)", hits)
+	}
+
+	// Misses are cached too
+	for i := 0; i < 2; i++ {
+		if _, err := geocoder.Geocode(ctx, "Nowhere"); !errors.Is(err, errGeocodeNotFound) {
+			t.Fatalf("err = %v, want errGeocodeNotFound", err)
+		}
+	}
+	if hits != 2 {
+		t.Errorf("upstream hits = %d, want 2", hits)
+	}
+
+	// Upstream errors are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := geocoder.Geocode(ctx, "broken"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if hits != 4 {
+		t.Errorf("upstream hits = %d, want 4", hits)
+	}
+}
+
+func TestParseSearchQueryPlace(t *testing.T) {
+	params, _, _, _, apiErr := parseSearchQuery(url.Values{"q": {" Alexanderplatz "}})
+	if apiErr != nil {
+		t.Fatalf("unexpected error %+v", apiErr)
+	}
+	if params.Place != "Alexanderplatz" {
+		t.Errorf("Place = %q", params.Place)
+	}
+
+	// Coordinates take precedence over q
+	params, _, _, _, apiErr = parseSearchQuery(url.Values{"q": {"Alexanderplatz"}, "lat": {"1"}, "lon": {"2"}})
+	if apiErr != nil || params.Place != "" || params.Lat != 1 || params.Lon != 2 {
+		t.Errorf("params = %+v, err = %+v", params, apiErr)
+	}
+
+	if _, _, _, _, apiErr = parseSearchQuery(url.Values{}); apiErr == nil || apiErr.Code != ErrCodeMissingParameter {
+		t.Errorf("err = %+v, want missing_parameter", apiErr)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Coordinate logging modes (LOG_COORDINATES)
+const (
+	CoordinatesExact  = "exact"
+	CoordinatesCoarse = "coarse" // rounded to 2 decimals, about 1 km
+	CoordinatesHidden = "hidden"
+)
+
+// Attribute keys whose values are coordinates; LOG_COORDINATES applies to them
+const (
+	logKeyLat = "lat"
+	logKeyLon = "lon"
+)
+
+// logKeyPlace is the attribute key for user-typed place names and geocoder
+// queries. They can't be rounded, so they are only logged unless
+// LOG_COORDINATES is hidden.
+const logKeyPlace = "place"
+
+// requestIDHeader carries the request ID in and out of the HTTP API
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 64
+
+// LogConfig selects the log level, output format, coordinate redaction and place trace
+type LogConfig struct {
+	Level       slog.Level
+	Format      string // "text" or "json"
+	Coordinates string // "exact", "coarse" or "hidden"
+	TracePlace  string // place name or ID to trace through searches; empty disables
+}
+
+// NewLogHandler creates the slog handler for cfg writing to w
+func NewLogHandler(w io.Writer, cfg LogConfig) slog.Handler {
+	opts := &slog.HandlerOptions{Level: cfg.Level, ReplaceAttr: redactCoordinates(cfg.Coordinates)}
+	if cfg.Format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// redactCoordinates rounds or hides lat/lon attributes, and hides places
+// when coordinates are hidden
+func redactCoordinates(mode string) func(groups []string, a slog.Attr) slog.Attr {
+	if mode == CoordinatesExact {
+		return nil
+	}
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == logKeyPlace && mode == CoordinatesHidden {
+			return slog.String(a.Key, "redacted")
+		}
+		if a.Key != logKeyLat && a.Key != logKeyLon {
+			return a
+		}
+		if mode == CoordinatesHidden {
+			return slog.String(a.Key, "redacted")
+		}
+		if a.Value.Kind() == slog.KindFloat64 {
+			return slog.Float64(a.Key, math.Round(a.Value.Float64()*100)/100)
+		}
+		return a
+	}
+}
+
+type requestIDKey struct{}
+
+// withRequestID attaches a request ID to ctx for log correlation
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFrom returns ctx's request ID, or "" if there is none
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns a random 16-character hex ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts short client IDs made of letters, digits, '-', '_' and '.'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// ctxLogger returns the default logger annotated with ctx's request ID and,
+// while tracing, its trace ID
+func ctxLogger(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := requestIDFrom(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return logger
+}
+
+// PlaceTrace follows one place through searches (provider results, filtering,
+// dedup) at debug level, logging only that place at each stage
+type PlaceTrace struct {
+	spec string // exact place ID, or lowercased name substring
+}
+
+// NewPlaceTrace creates a trace for spec, or nil when spec is empty
+func NewPlaceTrace(spec string) *PlaceTrace {
+	if spec == "" {
+		return nil
+	}
+	return &PlaceTrace{spec: spec}
+}
+
+// Matches reports whether a place with this name or ID is traced
+func (pt *PlaceTrace) Matches(name, id string) bool {
+	if pt == nil {
+		return false
+	}
+	return (id != "" && id == pt.spec) || strings.Contains(strings.ToLower(name), strings.ToLower(pt.spec))
+}
+
+// Place logs a traced place at stage
+func (pt *PlaceTrace) Place(ctx context.Context, stage, name, id string, attrs ...any) {
+	if !pt.Matches(name, id) {
+		return
+	}
+	args := append([]any{"stage", stage, "name", name, "place_id", id}, attrs...)
+	ctxLogger(ctx).Debug("[TRACE] Place seen", args...)
+}
+
+// Restaurants logs every traced restaurant in restaurants at stage
+func (pt *PlaceTrace) Restaurants(ctx context.Context, stage string, restaurants []Restaurant) {
+	if pt == nil {
+		return
+	}
+	for _, r := range restaurants {
+		id := r.PlaceID
+		if id == "" {
+			id = r.OSMID
+		}
+		pt.Place(ctx, stage, r.Name, id, "rating", r.Rating, "reviews", r.ReviewCount, logKeyLat, r.Latitude, logKeyLon, r.Longitude)
+	}
+}
+
+// statusRecorder captures the response status for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps Server-Sent Events working through the recorder
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// quietPaths are polled constantly and left out of the access log and traces
+var quietPaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// withRequestLogging assigns each request an ID (or keeps a valid X-Request-ID),
+// echoes it in the response and writes one access log line. Only the path is
+// logged because query strings contain coordinates.
+func withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(withRequestID(r.Context(), id))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		if quietPaths[r.URL.Path] {
+			return
+		}
+		ctxLogger(r.Context()).Info("[HTTP] Request",
+			"method", r.Method, "path", r.URL.Path, "status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds())
+	})
+}
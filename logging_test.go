@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLogs sends the default logger to a JSON buffer for the rest of the test
+func captureLogs(t *testing.T, cfg LogConfig) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	cfg.Format = "json"
+	previous := slog.Default()
+	slog.SetDefault(slog.New(NewLogHandler(&buf, cfg)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logRecords decodes the JSON log lines in buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogConfigFromEnv(t *testing.T) {
+	loaded, err := loadTestConfig(t, map[string]string{
+		"LOG_LEVEL":       "DEBUG",
+		"LOG_FORMAT":      "json",
+		"LOG_COORDINATES": "coarse",
+		"LOG_TRACE_PLACE": " Baba ",
+	})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	cfg := loaded.Log
+	if cfg.Level != slog.LevelDebug || cfg.Format != "json" || cfg.Coordinates != CoordinatesCoarse || cfg.TracePlace != "Baba" {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	for name, value := range map[string]string{"LOG_LEVEL": "loud", "LOG_FORMAT": "xml", "LOG_COORDINATES": "fuzzy"} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadTestConfig(t, map[string]string{name: value}); err == nil {
+				t.Errorf("%s=%s accepted", name, value)
+			}
+		})
+	}
+}
+
+func TestCoordinateRedaction(t *testing.T) {
+	tests := []struct {
+		mode     string
+		lat, lon any
+		place    string
+	}{
+		{CoordinatesExact, 52.520008, 13.404954, "Alexanderplatz"},
+		{CoordinatesCoarse, 52.52, 13.4, "Alexanderplatz"},
+		{CoordinatesHidden, "redacted", "redacted", "redacted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			buf := captureLogs(t, LogConfig{Coordinates: tt.mode})
+			slog.Info("search", logKeyLat, 52.520008, logKeyLon, 13.404954, logKeyPlace, "Alexanderplatz", "radius", 1500)
+			record := logRecords(t, buf)[0]
+			if record["lat"] != tt.lat || record["lon"] != tt.lon {
+				t.Errorf("lat/lon = %v/%v, want %v/%v", record["lat"], record["lon"], tt.lat, tt.lon)
+			}
+			if record["place"] != tt.place {
+				t.Errorf("place = %v, want %v", record["place"], tt.place)
+			}
+			if record["radius"] != 1500.0 {
+				t.Errorf("other attributes changed: %v", record)
+			}
+		})
+	}
+}
+
+// loggingSearch logs from inside the search like the real pipeline does
+type loggingSearch struct{ fakeSearch }
+
+func (ls *loggingSearch) Search(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	ctxLogger(ctx).Info("[Search] sub-query", logKeyLat, params.Lat, logKeyLon, params.Lon)
+	return ls.fakeSearch.Search(ctx, params)
+}
+
+func TestRequestIDCorrelatesSearchLogs(t *testing.T) {
+	buf := captureLogs(t, LogConfig{})
+	server := newTestServer(t, &loggingSearch{})
+
+	req := httptest.NewRequest("GET", "/api/v1/restaurants?lat=52.52&lon=13.405", nil)
+	req.Header.Set(requestIDHeader, "client-42")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(requestIDHeader); got != "client-42" {
+		t.Errorf("response request ID %q, want client-42", got)
+	}
+	var sawSearch, sawAccess bool
+	for _, record := range logRecords(t, buf) {
+		if record["request_id"] != "client-42" {
+			t.Errorf("log line without request ID: %v", record)
+		}
+		switch record["msg"] {
+		case "[Search] sub-query":
+			sawSearch = true
+		case "[HTTP] Request":
+			sawAccess = true
+			if record["path"] != "/api/v1/restaurants" || record["status"] != 200.0 {
+				t.Errorf("access log = %v", record)
+			}
+		}
+	}
+	if !sawSearch || !sawAccess {
+		t.Errorf("missing log lines:\n%s", buf.String())
+	}
+}
+
+func TestRequestIDIsGeneratedForInvalidHeaders(t *testing.T) {
+	captureLogs(t, LogConfig{})
+	server := newTestServer(t, &fakeSearch{})
+
+	for _, incoming := range []string{"", "has spaces", strings.Repeat("x", maxRequestIDLength+1)} {
+		req := httptest.NewRequest("GET", "/healthz", nil)
+		req.Header.Set(requestIDHeader, incoming)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		got := rec.Header().Get(requestIDHeader)
+		if got == incoming || len(got) != 16 || !validRequestID(got) {
+			t.Errorf("incoming %q: response request ID %q", incoming, got)
+		}
+	}
+}
+
+func TestPlaceTrace(t *testing.T) {
+	var disabled *PlaceTrace
+	if disabled.Matches("Baba Ghanoush", "") {
+		t.Error("nil trace matched")
+	}
+
+	byName := NewPlaceTrace("baba")
+	if !byName.Matches("Café BABA", "") || byName.Matches("Pizza Roma", "baba-id") {
+		t.Error("name trace should match case-insensitive substrings of the name only")
+	}
+	byID := NewPlaceTrace("ChIJ123")
+	if !byID.Matches("Anything", "ChIJ123") || byID.Matches("Anything", "ChIJ1234") {
+		t.Error("ID trace should match the exact place ID")
+	}
+
+	buf := captureLogs(t, LogConfig{})
+	ctx := withRequestID(context.Background(), "req-1")
+	byName.Restaurants(ctx, "after_dedup", []Restaurant{
+		{Name: "Baba Kebab", PlaceID: "g1", Rating: 4.5},
+		{Name: "Noodle Bar", PlaceID: "g2"},
+		{Name: "Babaluu", OSMID: "node/7"},
+	})
+	// Traces are logged at debug level, so the default info threshold hides them
+	if buf.Len() != 0 {
+		t.Fatalf("trace logged below the configured level: %s", buf.String())
+	}
+
+	buf = captureLogs(t, LogConfig{Level: slog.LevelDebug})
+	byName.Restaurants(ctx, "after_dedup", []Restaurant{
+		{Name: "Baba Kebab", PlaceID: "g1", Rating: 4.5},
+		{Name: "Noodle Bar", PlaceID: "g2"},
+		{Name: "Babaluu", OSMID: "node/7"},
+	})
+	records := logRecords(t, buf)
+	if len(records) != 2 || records[0]["place_id"] != "g1" || records[1]["place_id"] != "node/7" {
+		t.Fatalf("trace records = %v", records)
+	}
+	if records[0]["stage"] != "after_dedup" || records[0]["request_id"] != "req-1" {
+		t.Errorf("trace record = %v", records[0])
+	}
+}
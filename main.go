@@ -4,39 +4,51 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/jpeg"
-	"io"
 	"log"
+	"log/slog"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"googlemaps.github.io/maps"
 )
 
 const (
 	telegramMaxMessageLength = 4096
 	maxRestaurantsPerMessage = 5
-	requestTimeout           = 10 * time.Second
-	cacheTTL                 = 48 * time.Hour      // Cache results for 48 hours
-	cacheRadiusMeters        = 20.0                // 20 meter radius for cache matching
-	photoCachePath           = "/restaurant/photo" // Path to permanent photo storage directory
+	overpassAPIURL           = "https://overpass-api.de/api/interpreter"
+
+	// Defaults of the tunables in Config
+	defaultRequestTimeout    = 10 * time.Second
+	defaultCacheTTL          = 48 * time.Hour      // Cache results for 48 hours
+	defaultDegradedCacheTTL  = 10 * time.Minute    // Cache partial results (a provider failed) only briefly
+	defaultCacheRadiusMeters = 20.0                // 20 meter radius for cache matching
+	defaultPhotoDir          = "/restaurant/photo" // Path to permanent photo storage directory
 
 	// Generic photo constants - used for restaurants that shouldn't trigger Google API calls
-	genericPhotoReference = "GENERIC"           // Special marker for generic/placeholder photo
-	minRatingForPhoto     = 4.0                 // Minimum rating to fetch real photo (below this = generic)
-	minReviewsForPhoto    = 5                   // Minimum reviews to fetch real photo (below this = generic)
-	genericPhotoFilename  = "generic_placeholder.jpg" // Filename for the generic placeholder image
+	genericPhotoReference     = "GENERIC"                 // Special marker for generic/placeholder photo
+	defaultMinRatingForPhoto  = 4.0                       // Minimum rating to fetch real photo (below this = generic)
+	defaultMinReviewsForPhoto = 5                         // Minimum reviews to fetch real photo (below this = generic)
+	genericPhotoFilename      = "generic_placeholder.jpg" // Filename for the generic placeholder image
 )
 
 // generateGenericPlaceholderImage creates a simple placeholder image for restaurants
@@ -81,12 +93,10 @@ func generateGenericPlaceholderImage() ([]byte, error) {
 }
 
 // getOrCreateGenericPlaceholder returns the generic placeholder image, creating it if needed.
-// The image is saved to disk for future use.
-func getOrCreateGenericPlaceholder() ([]byte, error) {
-	placeholderPath := filepath.Join(photoCachePath, genericPhotoFilename)
-
-	// Check if placeholder already exists on disk
-	if data, err := os.ReadFile(placeholderPath); err == nil && len(data) > 0 {
+// The image is saved to the photo store for future use.
+func getOrCreateGenericPlaceholder(photos PhotoStore) ([]byte, error) {
+	// Check if placeholder already exists in the store
+	if data, _, ok := photos.Get(genericPhotoFilename); ok {
 		return data, nil
 	}
 
@@ -96,15 +106,10 @@ func getOrCreateGenericPlaceholder() ([]byte, error) {
 		return nil, err
 	}
 
-	// Save to disk (create directory if needed)
-	if err := os.MkdirAll(photoCachePath, 0755); err != nil {
-		log.Printf("[PHOTO][GENERIC] Warning: could not create directory %s: %v", photoCachePath, err)
+	if err := photos.Put(genericPhotoFilename, data); err != nil {
+		slog.Warn("[PHOTO][GENERIC] Could not save placeholder", "error", err)
 	} else {
-		if err := os.WriteFile(placeholderPath, data, 0644); err != nil {
-			log.Printf("[PHOTO][GENERIC] Warning: could not save placeholder to disk: %v", err)
-		} else {
-			log.Printf("[PHOTO][GENERIC] Created and saved placeholder image: %s (%d bytes)", placeholderPath, len(data))
-		}
+		slog.Info("[PHOTO][GENERIC] Created placeholder image", "file", genericPhotoFilename, "bytes", len(data))
 	}
 
 	return data, nil
@@ -114,9 +119,9 @@ func getOrCreateGenericPlaceholder() ([]byte, error) {
 // instead of fetching a real photo from Google API.
 // Returns true if:
 // - Restaurant has no photo reference
-// - Restaurant rating is below minRatingForPhoto (4.0)
-// - Restaurant has fewer than minReviewsForPhoto (5) reviews
-func shouldUseGenericPhoto(photoRef string, rating float64, reviewCount int) bool {
+// - Restaurant rating is below MinRating (default 4.0)
+// - Restaurant has fewer than MinReviews (default 5) reviews
+func (pc PhotoConfig) shouldUseGenericPhoto(photoRef string, rating float64, reviewCount int) bool {
 	// No photo available
 	if photoRef == "" {
 		return true
@@ -126,45 +131,40 @@ func shouldUseGenericPhoto(photoRef string, rating float64, reviewCount int) boo
 		return true
 	}
 	// Low rating - don't waste API calls
-	if rating > 0 && rating < minRatingForPhoto {
+	if rating > 0 && rating < pc.MinRating {
 		return true
 	}
 	// Too few reviews - likely unreliable/new place
-	if reviewCount < minReviewsForPhoto {
+	if reviewCount < pc.MinReviews {
 		return true
 	}
 	return false
 }
 
-// saveGenericPlaceholderForFailedPhoto saves the generic placeholder image to the given path
+// saveGenericPlaceholderForFailedPhoto stores the generic placeholder image under filename
 // so that future requests for this photo reference won't call the Google API again.
 // This is called when the Google API fails to return a valid photo.
-func saveGenericPlaceholderForFailedPhoto(storedPath, filename string) {
-	placeholderData, err := getOrCreateGenericPlaceholder()
+func saveGenericPlaceholderForFailedPhoto(photos PhotoStore, filename string) {
+	placeholderData, err := getOrCreateGenericPlaceholder(photos)
 	if err != nil {
-		log.Printf("[PHOTO][FALLBACK][ERROR] Failed to get placeholder: %v", err)
-		return
-	}
-
-	if err := os.MkdirAll(photoCachePath, 0755); err != nil {
-		log.Printf("[PHOTO][FALLBACK][ERROR] Failed to create directory: %v", err)
+		slog.Error("[PHOTO][FALLBACK] Failed to get placeholder", "error", err)
 		return
 	}
 
-	if err := os.WriteFile(storedPath, placeholderData, 0644); err != nil {
-		log.Printf("[PHOTO][FALLBACK][ERROR] Failed to save placeholder to %s: %v", storedPath, err)
+	if err := photos.Put(filename, placeholderData); err != nil {
+		slog.Error("[PHOTO][FALLBACK] Failed to save placeholder", "file", filename, "error", err)
 	} else {
-		log.Printf("[PHOTO][FALLBACK] Saved generic placeholder as %s - future requests will use this (no API calls)", filename)
+		slog.Debug("[PHOTO][FALLBACK] Saved generic placeholder, future requests use it", "file", filename)
 	}
 }
 
 // serveGenericPlaceholderOnError serves the generic placeholder image when an API call fails.
 // This ensures the client still gets an image even when the Google API fails.
-func serveGenericPlaceholderOnError(w http.ResponseWriter) {
-	placeholderData, err := getOrCreateGenericPlaceholder()
+func serveGenericPlaceholderOnError(w http.ResponseWriter, photos PhotoStore) {
+	placeholderData, err := getOrCreateGenericPlaceholder(photos)
 	if err != nil {
-		log.Printf("[PHOTO][FALLBACK][ERROR] Failed to serve placeholder: %v", err)
-		http.Error(w, "Failed to load placeholder image", http.StatusInternalServerError)
+		slog.Error("[PHOTO][FALLBACK] Failed to serve placeholder", "error", err)
+		writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to load placeholder image")
 		return
 	}
 	w.Header().Set("Content-Type", "image/jpeg")
@@ -359,14 +359,48 @@ var validFoodTypes = map[string]bool{
 type RestaurantBot struct {
 	telegramBot *tgbotapi.BotAPI
 	mapsClient  *maps.Client
+	mapsAPIKey  string // for photo requests the maps client does not cover
 	cache       *LocationCache
-	apiProvider string // "google", "osm", or "both"
+	geocoder    Geocoder // resolves place names for q= and /near
+	details     *PlaceDetailsCache
+	snapshots   *SnapshotStore // result lists referenced by pagination cursors
+	costs       *CostLedger
+	limits      *ClientRateLimits // nil disables per-client rate limiting
+	auth        *APIKeyAuth       // nil disables API key authentication
+	cors        *CORSPolicy
+	apiProvider string // "google", "osm", "both" or "fake"
+
+	providerHealth *ProviderMonitor // outcome and latency of every provider search
+	telegramHealth *TelegramMonitor // liveness of the Telegram update loop
+	metrics        *Metrics         // Prometheus collectors served on /metrics
+	metricsToken   string           // bearer token required on /metrics, if set
+	trace          *PlaceTrace      // LOG_TRACE_PLACE debug trace; nil when disabled
+	tracing        *Tracing         // OpenTelemetry spans; nil when disabled
+	fixtures       *Fixtures        // recorded provider responses; nil calls providers live
+
+	requestTimeout time.Duration // bound on each provider call
+	photos         PhotoConfig   // photo directory and generic photo thresholds
+	fake           FakeProviderConfig
+
+	// Telegram handlers run in the background; Stop waits for them
+	handlersMu sync.Mutex
+	handlers   sync.WaitGroup
+	stopped    bool
+	stopping   chan struct{} // closed by Stop
 }
 
 // LocationCache stores cached restaurant results
 type LocationCache struct {
-	mu    sync.RWMutex
-	items []cacheItem
+	mu          sync.RWMutex
+	items       []cacheItem
+	nextVersion uint64 // incremented every time an entry is stored
+	now         func() time.Time
+	counters    cacheCounters
+
+	ttl          time.Duration // lifetime of complete results
+	degradedTTL  time.Duration // lifetime of partial results
+	radiusMeters float64       // how close a search must be to reuse an entry
+	file         string        // where Save and Load persist entries; empty keeps them in memory only
 }
 
 type cacheItem struct {
@@ -375,6 +409,12 @@ type cacheItem struct {
 	restaurants []Restaurant
 	stats       SearchStats
 	expiresAt   time.Time
+	version     uint64
+}
+
+// key identifies the cache entry's location
+func (item cacheItem) key() string {
+	return fmt.Sprintf("%.6f,%.6f", item.lat, item.lon)
 }
 
 // Restaurant represents a restaurant (unified format for different APIs)
@@ -390,6 +430,9 @@ type Restaurant struct {
 	Distance       float64 `json:"Distance"`
 	PhotoReference string  `json:"PhotoReference,omitempty"`
 	PlaceID        string  `json:"PlaceID,omitempty"`
+	OpenNow        *bool   `json:"OpenNow,omitempty"` // Open at search time, nil if unknown
+	Brand          string  `json:"Brand,omitempty"`   // Chain brand (OSM brand tag)
+	OSMID          string  `json:"OSMID,omitempty"`   // OSM element, e.g. "node/123" (details via /api/places/osm/node/123)
 }
 
 // SearchStats contains statistics about the search operation
@@ -402,6 +445,107 @@ type SearchStats struct {
 	TotalBeforeDedup      int  `json:"totalBeforeDedup"`      // Combined total before deduplication
 	TotalAfterDedup       int  `json:"totalAfterDedup"`       // Final count after deduplication
 	CachedResult          bool `json:"cachedResult"`          // True if results were returned from cache
+	BudgetExceeded        bool `json:"budgetExceeded"`        // True if Google was skipped because the spend cap was reached
+
+	ProviderErrors []ProviderError   `json:"providerErrors,omitempty"` // Failed providers/sub-queries when results are partial
+	Providers      []ProviderOutcome `json:"providers,omitempty"`      // Outcome per provider ("google", "osm")
+	SubQueries     []ProviderOutcome `json:"subQueries,omitempty"`     // Outcome per Google sub-query ("google:cafe", "google:text:food", ...)
+	Degraded       bool              `json:"degraded"`                 // True if any provider or sub-query failed (results incomplete)
+	Notice         string            `json:"notice,omitempty"`         // Human-readable explanation when degraded
+}
+
+// ProviderOutcome records how a provider or a single sub-query performed
+type ProviderOutcome struct {
+	Provider  string `json:"provider"`
+	Status    string `json:"status"` // "ok", "error" or "timeout"
+	LatencyMs int64  `json:"latencyMs"`
+	Results   int    `json:"results"`
+}
+
+// Provider outcome statuses
+const (
+	OutcomeOK      = "ok"
+	OutcomeError   = "error"
+	OutcomeTimeout = "timeout"
+)
+
+// providerDisplayNames are used in user-facing degradation notices
+var providerDisplayNames = map[string]string{
+	"google": "Google Maps",
+	"osm":    "OpenStreetMap",
+}
+
+// newProviderOutcome builds the outcome of a provider call
+func newProviderOutcome(provider string, latency time.Duration, results int, err error) ProviderOutcome {
+	status := OutcomeOK
+	if err != nil {
+		status = OutcomeError
+		if isTimeoutError(err) {
+			status = OutcomeTimeout
+		}
+	}
+	return ProviderOutcome{
+		Provider:  provider,
+		Status:    status,
+		LatencyMs: latency.Milliseconds(),
+		Results:   results,
+	}
+}
+
+// withFailures marks an outcome that still returned results as failed when some
+// of its result pages were lost, e.g. a later page timed out
+func (o ProviderOutcome) withFailures(failures []ProviderError) ProviderOutcome {
+	if len(failures) == 0 || o.Status != OutcomeOK {
+		return o
+	}
+	o.Status = OutcomeError
+	if failures[0].Code == ProviderErrTimeout {
+		o.Status = OutcomeTimeout
+	}
+	return o
+}
+
+// finalizeDegradation sets Degraded and Notice from the recorded outcomes
+func (s *SearchStats) finalizeDegradation() {
+	var failedProviders, okProviders []string
+	for _, p := range s.Providers {
+		if p.Status == OutcomeOK {
+			okProviders = append(okProviders, providerDisplayNames[p.Provider])
+		} else {
+			failedProviders = append(failedProviders, providerDisplayNames[p.Provider])
+		}
+	}
+	failedSubQueries := 0
+	for _, q := range s.SubQueries {
+		if q.Status != OutcomeOK {
+			failedSubQueries++
+		}
+	}
+	budgetCut := false
+	for _, e := range s.ProviderErrors {
+		if e.Code == ProviderErrBudget {
+			budgetCut = true
+		}
+	}
+
+	switch {
+	case s.BudgetExceeded:
+		s.Degraded = true
+		s.Notice = "Google Maps budget reached, showing OpenStreetMap results only"
+	case len(failedProviders) > 0 && len(okProviders) > 0:
+		s.Degraded = true
+		s.Notice = fmt.Sprintf("%s unavailable, showing %s results only",
+			strings.Join(failedProviders, " and "), strings.Join(okProviders, " and "))
+	case budgetCut:
+		s.Degraded = true
+		s.Notice = "Google Maps budget reached during the search, results may be incomplete"
+	case failedSubQueries > 0:
+		s.Degraded = true
+		s.Notice = fmt.Sprintf("%d of %d Google searches failed, results may be incomplete", failedSubQueries, len(s.SubQueries))
+	default:
+		s.Degraded = false
+		s.Notice = ""
+	}
 }
 
 // SearchResult contains both restaurants and statistics
@@ -412,25 +556,33 @@ type SearchResult struct {
 
 // PaginatedSearchResult extends SearchResult with pagination info
 type PaginatedSearchResult struct {
-	Restaurants []Restaurant `json:"restaurants"`
-	Stats       SearchStats  `json:"stats"`
-	Pagination  Pagination   `json:"pagination"`
+	Restaurants []Restaurant   `json:"restaurants"`
+	Stats       SearchStats    `json:"stats"`
+	Pagination  Pagination     `json:"pagination"`
+	Filters     ResultFilters  `json:"filters"`            // Sort and filters applied before pagination
+	Location    *GeocodeResult `json:"location,omitempty"` // Geocoded search location when searching by q
 }
 
 // Pagination contains pagination metadata
 type Pagination struct {
-	Page       int `json:"page"`       // Current page (1-indexed)
-	Limit      int `json:"limit"`      // Items per page
-	TotalItems int `json:"totalItems"` // Total number of items
-	TotalPages int `json:"totalPages"` // Total number of pages
-	HasNext    bool `json:"hasNext"`   // Whether there's a next page
-	HasPrev    bool `json:"hasPrev"`   // Whether there's a previous page
+	Page       int    `json:"page"`                 // Current page (1-indexed)
+	Limit      int    `json:"limit"`                // Items per page
+	TotalItems int    `json:"totalItems"`           // Total number of items
+	TotalPages int    `json:"totalPages"`           // Total number of pages
+	HasNext    bool   `json:"hasNext"`              // Whether there's a next page
+	HasPrev    bool   `json:"hasPrev"`              // Whether there's a previous page
+	NextCursor string `json:"nextCursor,omitempty"` // Opaque cursor for the next page of the same snapshot
+	PrevCursor string `json:"prevCursor,omitempty"` // Opaque cursor for the previous page of the same snapshot
 }
 
 // NewLocationCache creates a new location cache
 func NewLocationCache() *LocationCache {
 	cache := &LocationCache{
-		items: make([]cacheItem, 0),
+		items:        make([]cacheItem, 0),
+		now:          time.Now,
+		ttl:          defaultCacheTTL,
+		degradedTTL:  defaultDegradedCacheTTL,
+		radiusMeters: defaultCacheRadiusMeters,
 	}
 	// Start cleanup goroutine
 	go cache.cleanup()
@@ -443,13 +595,14 @@ func (lc *LocationCache) cleanup() {
 	defer ticker.Stop()
 	for range ticker.C {
 		lc.mu.Lock()
-		now := time.Now()
+		now := lc.now()
 		newItems := make([]cacheItem, 0, len(lc.items))
 		for _, item := range lc.items {
 			if now.Before(item.expiresAt) {
 				newItems = append(newItems, item)
 			}
 		}
+		lc.counters.evictions.Add(int64(len(lc.items) - len(newItems)))
 		lc.items = newItems
 		lc.mu.Unlock()
 	}
@@ -457,9 +610,15 @@ func (lc *LocationCache) cleanup() {
 
 // Get retrieves cached restaurants for a location within 20m radius
 func (lc *LocationCache) Get(lat, lon float64) ([]Restaurant, *SearchStats, bool) {
+	restaurants, stats, _, found := lc.GetVersioned(lat, lon)
+	return restaurants, stats, found
+}
+
+// GetVersioned is like Get but also returns which version of the cache entry matched
+func (lc *LocationCache) GetVersioned(lat, lon float64) ([]Restaurant, *SearchStats, cacheVersion, bool) {
 	lc.mu.RLock()
 	defer lc.mu.RUnlock()
-	now := time.Now()
+	now := lc.now()
 	for _, item := range lc.items {
 		if now.After(item.expiresAt) {
 			continue
@@ -467,44 +626,74 @@ func (lc *LocationCache) Get(lat, lon float64) ([]Restaurant, *SearchStats, bool
 		// Calculate distance in meters (calculateDistance returns km)
 		distanceKm := calculateDistance(lat, lon, item.lat, item.lon)
 		distanceMeters := distanceKm * 1000
-		if distanceMeters <= cacheRadiusMeters {
+		if distanceMeters <= lc.radiusMeters {
 			// Return a copy of stats with CachedResult set to true
 			cachedStats := item.stats
 			cachedStats.CachedResult = true
-			return item.restaurants, &cachedStats, true
+			lc.counters.lookup(true)
+			return item.restaurants, &cachedStats, cacheVersion{Key: item.key(), Version: item.version}, true
 		}
 	}
-	return nil, nil, false
+	lc.counters.lookup(false)
+	return nil, nil, cacheVersion{}, false
 }
 
-// Set stores restaurants in cache with their location and stats
-func (lc *LocationCache) Set(lat, lon float64, restaurants []Restaurant, stats SearchStats) {
+// Has reports whether an unexpired entry covers lat/lon without counting a lookup
+func (lc *LocationCache) Has(lat, lon float64) bool {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	now := lc.now()
+	for _, item := range lc.items {
+		if now.Before(item.expiresAt) && calculateDistance(lat, lon, item.lat, item.lon)*1000 <= lc.radiusMeters {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of entries, including expired ones not yet cleaned up
+func (lc *LocationCache) Len() int {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return len(lc.items)
+}
+
+// Set stores restaurants in cache with their location and stats, returning the new entry version.
+// Degraded (partial) results expire after degradedTTL instead of ttl.
+func (lc *LocationCache) Set(lat, lon float64, restaurants []Restaurant, stats SearchStats) cacheVersion {
 	lc.mu.Lock()
 	defer lc.mu.Unlock()
+	ttl := lc.ttl
+	if stats.Degraded {
+		ttl = lc.degradedTTL
+	}
+	return lc.storeLocked(lat, lon, restaurants, stats, lc.now().Add(ttl))
+}
+
+// storeLocked adds an entry, replacing the one within the cache radius if there is one
+func (lc *LocationCache) storeLocked(lat, lon float64, restaurants []Restaurant, stats SearchStats, expiresAt time.Time) cacheVersion {
+	lc.nextVersion++
+	item := cacheItem{
+		lat:         lat,
+		lon:         lon,
+		restaurants: restaurants,
+		stats:       stats,
+		expiresAt:   expiresAt,
+		version:     lc.nextVersion,
+	}
 	// Check if we already have a cache entry for this location (within radius)
-	for i, item := range lc.items {
-		distanceKm := calculateDistance(lat, lon, item.lat, item.lon)
+	for i, existing := range lc.items {
+		distanceKm := calculateDistance(lat, lon, existing.lat, existing.lon)
 		distanceMeters := distanceKm * 1000
-		if distanceMeters <= cacheRadiusMeters {
+		if distanceMeters <= lc.radiusMeters {
 			// Update existing entry
-			lc.items[i] = cacheItem{
-				lat:         lat,
-				lon:         lon,
-				restaurants: restaurants,
-				stats:       stats,
-				expiresAt:   time.Now().Add(cacheTTL),
-			}
-			return
+			lc.items[i] = item
+			return cacheVersion{Key: item.key(), Version: item.version}
 		}
 	}
 	// Add new entry
-	lc.items = append(lc.items, cacheItem{
-		lat:         lat,
-		lon:         lon,
-		restaurants: restaurants,
-		stats:       stats,
-		expiresAt:   time.Now().Add(cacheTTL),
-	})
+	lc.items = append(lc.items, item)
+	return cacheVersion{Key: item.key(), Version: item.version}
 }
 
 func NewRestaurantBot(telegramToken string, googleMapsAPIKey string, apiProvider string) (*RestaurantBot, error) {
@@ -537,12 +726,31 @@ func NewRestaurantBot(telegramToken string, googleMapsAPIKey string, apiProvider
 		apiProvider = "google"
 	}
 
-	return &RestaurantBot{
+	// In-memory ledger without caps; main replaces it with the configured one
+	costs, err := NewCostLedger("", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rb := &RestaurantBot{
 		telegramBot: bot,
 		mapsClient:  mapsClient,
+		mapsAPIKey:  googleMapsAPIKey,
 		cache:       NewLocationCache(),
+		details:     NewPlaceDetailsCache(),
+		snapshots:   NewSnapshotStore(),
+		costs:       costs,
 		apiProvider: apiProvider,
-	}, nil
+
+		providerHealth: NewProviderMonitor(),
+		telegramHealth: NewTelegramMonitor(),
+		stopping:       make(chan struct{}),
+
+		requestTimeout: defaultRequestTimeout,
+		photos:         DefaultConfig().Photos,
+	}
+	rb.metrics = NewMetrics(rb)
+	return rb, nil
 }
 
 func (rb *RestaurantBot) Start() error {
@@ -553,18 +761,31 @@ func (rb *RestaurantBot) Start() error {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
-	updates := rb.telegramBot.GetUpdatesChan(u)
+	updates := make(chan tgbotapi.Update, rb.telegramBot.Buffer)
+	go rb.pollUpdates(u, updates)
 
-	log.Printf("Bot started. Username: %s", rb.telegramBot.Self.UserName)
+	slog.Info("Bot started", "username", rb.telegramBot.Self.UserName)
 
-	for update := range updates {
+	for {
+		var update tgbotapi.Update
+		select {
+		case <-rb.stopping:
+			slog.Info("[SHUTDOWN] Telegram update loop stopped")
+			return nil
+		case u, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			update = u
+		}
 		if update.Message == nil {
 			continue
 		}
 
 		// Handle location messages
 		if update.Message.Location != nil {
-			go rb.handleLocation(update.Message)
+			msg := update.Message
+			rb.goHandle(func() { rb.handleLocation(msg) })
 			continue
 		}
 
@@ -575,6 +796,9 @@ func (rb *RestaurantBot) Start() error {
 				rb.sendWelcomeMessage(update.Message.Chat.ID)
 			case "help":
 				rb.sendHelpMessage(update.Message.Chat.ID)
+			case "near":
+				msg := update.Message
+				rb.goHandle(func() { rb.handleNear(msg) })
 			default:
 				rb.sendTextMessage(update.Message.Chat.ID, "Unknown command. Use /help to see available commands.")
 			}
@@ -583,20 +807,90 @@ func (rb *RestaurantBot) Start() error {
 			rb.sendTextMessage(update.Message.Chat.ID, "Please send your location to find nearby restaurants, or use /help for instructions.")
 		}
 	}
-
-	return nil
 }
 
 func (rb *RestaurantBot) handleLocation(msg *tgbotapi.Message) {
 	chatID := msg.Chat.ID
 	location := msg.Location
+	ctx := withRequestID(context.Background(), newRequestID())
+	ctx, span := rb.tracing.Start(ctx, "telegram.location", attribute.String("request.id", requestIDFrom(ctx)))
+	defer span.End()
+
+	ctxLogger(ctx).Info("Received location", "chat_id", chatID, logKeyLat, location.Latitude, logKeyLon, location.Longitude)
 
-	log.Printf("Received location from user %d: lat=%.6f, lon=%.6f", chatID, location.Latitude, location.Longitude)
+	clientKey := TelegramClientKey(chatID)
+	if ok, retryAfter := rb.limits.AllowRequest(clientKey); !ok {
+		ctxLogger(ctx).Warn("[RATELIMIT] Request limit exceeded", "client", clientKey)
+		rb.sendRateLimitedMessage(chatID, retryAfter)
+		return
+	}
+
+	rb.sendNearbyRestaurants(ctx, chatID, location.Latitude, location.Longitude)
+}
+
+// handleNear handles "/near <place>": geocodes the place and searches around it
+func (rb *RestaurantBot) handleNear(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	place := strings.TrimSpace(msg.CommandArguments())
+
+	if place == "" {
+		rb.sendTextMessage(chatID, "Usage: /near <place or address>, e.g. /near Alexanderplatz")
+		return
+	}
+	if len(place) > maxGeocodeQueryLength {
+		rb.sendTextMessage(chatID, "❌ That place name is too long.")
+		return
+	}
+
+	requestCtx := withRequestID(context.Background(), newRequestID())
+	requestCtx, span := rb.tracing.Start(requestCtx, "telegram.near", attribute.String("request.id", requestIDFrom(requestCtx)))
+	defer span.End()
+	ctxLogger(requestCtx).Info("Received /near", "chat_id", chatID, logKeyPlace, place)
+
+	clientKey := TelegramClientKey(chatID)
+	if ok, retryAfter := rb.limits.AllowRequest(clientKey); !ok {
+		ctxLogger(requestCtx).Warn("[RATELIMIT] Request limit exceeded", "client", clientKey)
+		rb.sendRateLimitedMessage(chatID, retryAfter)
+		return
+	}
+
+	if rb.geocoder == nil {
+		rb.sendTextMessage(chatID, "❌ Searching by place name is not available. Please share your location instead.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestCtx, rb.requestTimeout)
+	defer cancel()
+	location, err := rb.geocoder.Geocode(ctx, place)
+	if errors.Is(err, errGeocodeNotFound) {
+		rb.sendTextMessage(chatID, "😔 I couldn't find that place. Try a more specific name or address, or share your location.")
+		return
+	}
+	if err != nil {
+		ctxLogger(requestCtx).Error("[GEOCODE] Error geocoding", logKeyPlace, place, "error", err)
+		rb.sendTextMessage(chatID, "❌ Sorry, I couldn't look up that place at the moment. Please try again later.")
+		return
+	}
+
+	rb.sendTextMessage(chatID, "📍 "+escapeMarkdown(location.Name))
+	rb.sendNearbyRestaurants(requestCtx, chatID, location.Lat, location.Lon)
+}
+
+// sendNearbyRestaurants answers a Telegram search around lat/lon from the cache or a fresh search
+func (rb *RestaurantBot) sendNearbyRestaurants(ctx context.Context, chatID int64, lat, lon float64) {
+	clientKey := TelegramClientKey(chatID)
+	rb.metrics.SearchStarted(EntryTelegram)
 
 	// Check cache first
-	if cached, _, found := rb.cache.Get(location.Latitude, location.Longitude); found {
-		log.Printf("Cache hit for location %.6f,%.6f", location.Latitude, location.Longitude)
-		rb.sendRestaurantsFromCache(chatID, cached, location.Latitude, location.Longitude)
+	if cached, _, found := rb.cache.Get(lat, lon); found {
+		ctxLogger(ctx).Info("Cache hit", logKeyLat, lat, logKeyLon, lon)
+		rb.sendRestaurantsFromCache(chatID, cached, lat, lon)
+		return
+	}
+
+	if ok, retryAfter := rb.limits.AllowSearch(clientKey); !ok {
+		ctxLogger(ctx).Warn("[RATELIMIT] Search limit exceeded", "client", clientKey)
+		rb.sendRateLimitedMessage(chatID, retryAfter)
 		return
 	}
 
@@ -605,13 +899,13 @@ func (rb *RestaurantBot) handleLocation(msg *tgbotapi.Message) {
 
 	// Find nearby restaurants (default to all categories for Telegram)
 	params := SearchParams{
-		Lat:        location.Latitude,
-		Lon:        location.Longitude,
+		Lat:        lat,
+		Lon:        lon,
 		Categories: nil, // all categories
 	}
-	result, err := rb.findNearbyRestaurantsWithStats(params)
+	result, err := rb.findNearbyRestaurantsWithStats(ctx, params)
 	if err != nil {
-		log.Printf("Error finding restaurants: %v", err)
+		ctxLogger(ctx).Error("Error finding restaurants", "error", err)
 		rb.sendTextMessage(chatID, "❌ Sorry, I couldn't find restaurants at the moment. Please try again later.")
 		return
 	}
@@ -621,11 +915,17 @@ func (rb *RestaurantBot) handleLocation(msg *tgbotapi.Message) {
 		return
 	}
 
-	// Cache the results with stats
-	rb.cache.Set(location.Latitude, location.Longitude, result.Restaurants, result.Stats)
+	// Cache the results with stats, unless the search timed out and may be partial
+	if ctx.Err() == nil {
+		rb.cache.Set(lat, lon, result.Restaurants, result.Stats)
+	}
+
+	if result.Stats.Degraded {
+		rb.sendTextMessage(chatID, "⚠️ "+result.Stats.Notice)
+	}
 
 	// Send results
-	rb.sendRestaurantsFromCache(chatID, result.Restaurants, location.Latitude, location.Longitude)
+	rb.sendRestaurantsFromCache(chatID, result.Restaurants, lat, lon)
 }
 
 // SearchParams holds all search parameters
@@ -634,6 +934,30 @@ type SearchParams struct {
 	Lon        float64
 	Categories []FoodCategory // Multiple categories (e.g., ["restaurant", "cafe"])
 	Keyword    string         // Cuisine/keyword filter (e.g., "vegan", "italian")
+	Place      string         // Place name or address to geocode when no coordinates are given
+	Radius     int            // Search radius in meters around Lat/Lon, 0 = defaultSearchRadius
+	Area       *SearchArea    // Bounding box or polygon; when set, Lat/Lon is its center and results are clipped to it
+}
+
+// setArea searches within area, centered on it
+func (p *SearchParams) setArea(area *SearchArea) {
+	p.Area = area
+	p.Lat, p.Lon = area.Center()
+	p.Radius = area.BoundingRadius()
+}
+
+// cacheable reports whether results can be stored in the location cache, which
+// only holds unfiltered searches around a point
+func (p SearchParams) cacheable() bool {
+	return p.Keyword == "" && p.Area == nil
+}
+
+// searchRadius returns the radius to search around Lat/Lon
+func (p SearchParams) searchRadius() int {
+	if p.Radius > 0 {
+		return p.Radius
+	}
+	return defaultSearchRadius
 }
 
 func (rb *RestaurantBot) findNearbyRestaurants(lat, lon float64, category FoodCategory) ([]Restaurant, error) {
@@ -650,24 +974,69 @@ func (rb *RestaurantBot) findNearbyRestaurants(lat, lon float64, category FoodCa
 }
 
 func (rb *RestaurantBot) findNearbyRestaurantsWithParams(params SearchParams) ([]Restaurant, error) {
-	result, err := rb.findNearbyRestaurantsWithStats(params)
+	result, err := rb.findNearbyRestaurantsWithStats(context.Background(), params)
 	if err != nil {
 		return nil, err
 	}
 	return result.Restaurants, nil
 }
 
-func (rb *RestaurantBot) findNearbyRestaurantsWithStats(params SearchParams) (*SearchResult, error) {
-	switch rb.apiProvider {
+func (rb *RestaurantBot) findNearbyRestaurantsWithStats(ctx context.Context, params SearchParams) (result *SearchResult, err error) {
+	ctx, span := rb.tracing.Start(ctx, "search",
+		attribute.String("search.provider", rb.apiProvider),
+		attribute.Int("search.radius_m", params.searchRadius()),
+		attribute.Int("search.categories", len(params.Categories)),
+		attribute.String("search.keyword", params.Keyword),
+		attribute.Bool("search.area", params.Area != nil))
+	defer func() { endSearchSpan(span, result, err) }()
+
+	provider := rb.apiProvider
+	budgetExceeded := false
+	// Degrade to OSM only once the Google spend cap is reached
+	if provider == "google" || provider == "both" {
+		if exceeded, reason := rb.costs.BudgetExceeded(); exceeded {
+			ctxLogger(ctx).Warn("[COSTS] Budget reached, searching OpenStreetMap only", "reason", reason)
+			provider = "osm"
+			budgetExceeded = true
+		}
+	}
+
+	start := time.Now()
+	switch provider {
 	case "osm":
-		return rb.findNearbyRestaurantsOSMWithStats(params)
+		result, err = rb.findNearbyRestaurantsOSMWithStats(ctx, params)
+		reportSearchProgress(ctx, "osm", "osm", 1, 1, result, err)
 	case "both":
-		return rb.findNearbyRestaurantsBothWithStats(params)
+		result, err = rb.findNearbyRestaurantsBothWithStats(ctx, params)
+	case "fake":
+		result, err = rb.findNearbyRestaurantsFakeWithStats(ctx, params)
+		reportSearchProgress(ctx, "fake", "fake", 1, 1, result, err)
 	case "google":
 		fallthrough
 	default:
-		return rb.findNearbyRestaurantsGoogleWithStats(params)
+		provider = "google"
+		result, err = rb.findNearbyRestaurantsGoogleWithStats(ctx, params)
+	}
+	if provider != "both" {
+		rb.providerHealth.Record(provider, time.Since(start), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Single-provider searches record their own provider outcome here
+	if len(result.Stats.Providers) == 0 {
+		result.Stats.Providers = []ProviderOutcome{
+			newProviderOutcome(provider, time.Since(start), len(result.Restaurants), nil),
+		}
+	}
+	result.Stats.BudgetExceeded = budgetExceeded
+	result.Stats.finalizeDegradation()
+	span.SetAttributes(attribute.String("search.provider", provider), attribute.Bool("search.degraded", result.Stats.Degraded))
+	if result.Stats.Degraded {
+		ctxLogger(ctx).Warn("[Search] Degraded result", "notice", result.Stats.Notice)
 	}
+	return result, nil
 }
 
 // findNearbyRestaurantsBoth searches both providers in parallel and combines results
@@ -680,18 +1049,19 @@ func (rb *RestaurantBot) findNearbyRestaurantsBoth(lat, lon float64, category Fo
 }
 
 func (rb *RestaurantBot) findNearbyRestaurantsBothWithParams(params SearchParams) ([]Restaurant, error) {
-	result, err := rb.findNearbyRestaurantsBothWithStats(params)
+	result, err := rb.findNearbyRestaurantsBothWithStats(context.Background(), params)
 	if err != nil {
 		return nil, err
 	}
 	return result.Restaurants, nil
 }
 
-func (rb *RestaurantBot) findNearbyRestaurantsBothWithStats(params SearchParams) (*SearchResult, error) {
+func (rb *RestaurantBot) findNearbyRestaurantsBothWithStats(ctx context.Context, params SearchParams) (*SearchResult, error) {
 	type result struct {
 		searchResult *SearchResult
 		err          error
 		source       string
+		latency      time.Duration
 	}
 
 	resultsChan := make(chan result, 2)
@@ -702,26 +1072,37 @@ func (rb *RestaurantBot) findNearbyRestaurantsBothWithStats(params SearchParams)
 			resultsChan <- result{searchResult: &SearchResult{Restaurants: []Restaurant{}, Stats: SearchStats{}}, err: nil, source: "google"}
 			return
 		}
-		sr, err := rb.findNearbyRestaurantsGoogleWithStats(params)
-		resultsChan <- result{searchResult: sr, err: err, source: "google"}
+		start := time.Now()
+		sr, err := rb.findNearbyRestaurantsGoogleWithStats(ctx, params)
+		resultsChan <- result{searchResult: sr, err: err, source: "google", latency: time.Since(start)}
 	}()
 
 	// Search OpenStreetMap in parallel
 	go func() {
-		sr, err := rb.findNearbyRestaurantsOSMWithStats(params)
-		resultsChan <- result{searchResult: sr, err: err, source: "osm"}
+		start := time.Now()
+		sr, err := rb.findNearbyRestaurantsOSMWithStats(ctx, params)
+		reportSearchProgress(ctx, "osm", "osm", 1, 1, sr, err)
+		resultsChan <- result{searchResult: sr, err: err, source: "osm", latency: time.Since(start)}
 	}()
 
 	// Collect results from both providers
 	var allRestaurants []Restaurant
+	bySource := make(map[string][]Restaurant)
 	var errors []string
 	stats := SearchStats{}
 
 	for i := 0; i < 2; i++ {
 		res := <-resultsChan
+		resultCount := 0
+		if res.searchResult != nil {
+			resultCount = len(res.searchResult.Restaurants)
+		}
+		stats.Providers = append(stats.Providers, newProviderOutcome(res.source, res.latency, resultCount, res.err))
+		rb.providerHealth.Record(res.source, res.latency, res.err)
 		if res.err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", res.source, res.err))
-			log.Printf("Error from %s: %v", res.source, res.err)
+			stats.ProviderErrors = append(stats.ProviderErrors, newProviderError(res.source, res.err))
+			ctxLogger(ctx).Error("Provider search failed", "provider", res.source, "error", res.err)
 		} else if res.searchResult != nil {
 			// Merge stats
 			if res.source == "google" {
@@ -729,6 +1110,9 @@ func (rb *RestaurantBot) findNearbyRestaurantsBothWithStats(params SearchParams)
 				stats.GoogleSearchQueries = res.searchResult.Stats.GoogleSearchQueries
 				stats.GoogleResultsRaw = res.searchResult.Stats.GoogleResultsRaw
 				stats.GoogleResultsFiltered = res.searchResult.Stats.GoogleResultsFiltered
+				// Keep partial failures of Google sub-queries
+				stats.ProviderErrors = append(stats.ProviderErrors, res.searchResult.Stats.ProviderErrors...)
+				stats.SubQueries = res.searchResult.Stats.SubQueries
 			} else {
 				stats.OSMResultsTotal = res.searchResult.Stats.OSMResultsTotal
 			}
@@ -736,24 +1120,30 @@ func (rb *RestaurantBot) findNearbyRestaurantsBothWithStats(params SearchParams)
 			for j := range res.searchResult.Restaurants {
 				res.searchResult.Restaurants[j].Name = fmt.Sprintf("[%s] %s", strings.ToUpper(res.source), res.searchResult.Restaurants[j].Name)
 			}
-			allRestaurants = append(allRestaurants, res.searchResult.Restaurants...)
+			bySource[res.source] = res.searchResult.Restaurants
 		}
 	}
+	// Google first whichever answered first, so a place both found keeps
+	// Google's rating, reviews and photo when deduplicated
+	allRestaurants = append(bySource["google"], bySource["osm"]...)
 
 	// If both failed, return error
 	if len(allRestaurants) == 0 && len(errors) > 0 {
-		return nil, fmt.Errorf("all providers failed: %s", strings.Join(errors, "; "))
+		return nil, &ProviderFailureError{
+			Failures: stats.ProviderErrors,
+			cause:    fmt.Sprintf("all providers failed: %s", strings.Join(errors, "; ")),
+		}
 	}
 
 	stats.TotalBeforeDedup = len(allRestaurants)
 
 	// Deduplicate restaurants based on name and location (within 50m)
-	deduplicated := deduplicateRestaurants(allRestaurants)
+	deduplicated := rb.deduplicate(ctx, allRestaurants)
 
 	stats.TotalAfterDedup = len(deduplicated)
 
 	// Sort by rating (highest first), then by distance for same ratings
-	sortRestaurantsByRating(deduplicated)
+	rb.rank(ctx, deduplicated)
 
 	return &SearchResult{
 		Restaurants: deduplicated,
@@ -769,20 +1159,9 @@ func deduplicateRestaurants(restaurants []Restaurant) []Restaurant {
 
 	seen := make(map[string]bool)
 	var unique []Restaurant
-	const proximityThreshold = 0.0005 // ~50 meters
 
 	for _, r := range restaurants {
-		// Create a key based on normalized name and rounded coordinates
-		normalizedName := strings.ToLower(strings.TrimSpace(r.Name))
-		// Remove source prefix for deduplication
-		normalizedName = strings.TrimPrefix(normalizedName, "[google] ")
-		normalizedName = strings.TrimPrefix(normalizedName, "[osm] ")
-
-		// Round coordinates to proximity threshold
-		roundedLat := math.Round(r.Latitude/proximityThreshold) * proximityThreshold
-		roundedLon := math.Round(r.Longitude/proximityThreshold) * proximityThreshold
-		key := fmt.Sprintf("%s_%.6f_%.6f", normalizedName, roundedLat, roundedLon)
-
+		key := dedupKey(r)
 		if !seen[key] {
 			seen[key] = true
 			unique = append(unique, r)
@@ -792,6 +1171,38 @@ func deduplicateRestaurants(restaurants []Restaurant) []Restaurant {
 	return unique
 }
 
+// deduplicate is deduplicateRestaurants in a "dedup" span
+func (rb *RestaurantBot) deduplicate(ctx context.Context, restaurants []Restaurant) []Restaurant {
+	_, span := rb.tracing.Start(ctx, "dedup", attribute.Int("restaurants.before", len(restaurants)))
+	defer span.End()
+	unique := deduplicateRestaurants(restaurants)
+	span.SetAttributes(attribute.Int("restaurants.after", len(unique)))
+	return unique
+}
+
+// rank is sortRestaurantsByRating in a "rank" span
+func (rb *RestaurantBot) rank(ctx context.Context, restaurants []Restaurant) {
+	_, span := rb.tracing.Start(ctx, "rank", attribute.Int("restaurants", len(restaurants)))
+	defer span.End()
+	sortRestaurantsByRating(restaurants)
+}
+
+// dedupKey identifies a restaurant by normalized name and rounded coordinates
+func dedupKey(r Restaurant) string {
+	const proximityThreshold = 0.0005 // ~50 meters
+
+	// Create a key based on normalized name and rounded coordinates
+	normalizedName := strings.ToLower(strings.TrimSpace(r.Name))
+	// Remove source prefix for deduplication
+	normalizedName = strings.TrimPrefix(normalizedName, "[google] ")
+	normalizedName = strings.TrimPrefix(normalizedName, "[osm] ")
+
+	// Round coordinates to proximity threshold
+	roundedLat := math.Round(r.Latitude/proximityThreshold) * proximityThreshold
+	roundedLon := math.Round(r.Longitude/proximityThreshold) * proximityThreshold
+	return fmt.Sprintf("%s_%.6f_%.6f", normalizedName, roundedLat, roundedLon)
+}
+
 // sortRestaurantsByDistance sorts restaurants by distance from user location
 func sortRestaurantsByDistance(restaurants []Restaurant, userLat, userLon float64) {
 	for i := 0; i < len(restaurants)-1; i++ {
@@ -860,14 +1271,21 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogle(lat, lon float64, category
 }
 
 func (rb *RestaurantBot) findNearbyRestaurantsGoogleWithParams(params SearchParams) ([]Restaurant, error) {
-	result, err := rb.findNearbyRestaurantsGoogleWithStats(params)
+	result, err := rb.findNearbyRestaurantsGoogleWithStats(context.Background(), params)
 	if err != nil {
 		return nil, err
 	}
 	return result.Restaurants, nil
 }
 
-func (rb *RestaurantBot) findNearbyRestaurantsGoogleWithStats(params SearchParams) (*SearchResult, error) {
+func (rb *RestaurantBot) findNearbyRestaurantsGoogleWithStats(ctx context.Context, params SearchParams) (result *SearchResult, err error) {
+	ctx, span := rb.tracing.Start(ctx, "search.google")
+	defer func() { endSearchSpan(span, result, err) }()
+
+	if params.Area != nil {
+		return rb.findNearbyRestaurantsGoogleAreaWithStats(ctx, params)
+	}
+
 	// Resolve keyword if it's a known cuisine
 	keyword := params.Keyword
 	if kw, ok := cuisineKeywords[strings.ToLower(keyword)]; ok {
@@ -889,11 +1307,23 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleWithStats(params SearchParam
 		if !ok {
 			placeType = maps.PlaceTypeRestaurant
 		}
-		return rb.findNearbyRestaurantsGoogleByTypeWithStats(params.Lat, params.Lon, placeType, "")
+		start := time.Now()
+		sr, err := rb.findNearbyRestaurantsGoogleByTypeWithStats(ctx, params.Lat, params.Lon, params.searchRadius(), placeType, "")
+		name := "google:" + string(categoriesToSearch[0])
+		if err == nil && len(sr.Stats.ProviderErrors) > 0 {
+			// Some pages were lost: report it like a failed sub-query of a fan-out
+			for i := range sr.Stats.ProviderErrors {
+				sr.Stats.ProviderErrors[i].Provider = name
+			}
+			sr.Stats.SubQueries = append(sr.Stats.SubQueries,
+				newProviderOutcome(name, time.Since(start), len(sr.Restaurants), nil).withFailures(sr.Stats.ProviderErrors))
+		}
+		reportSearchProgress(ctx, "google", name, 1, 1, sr, err)
+		return sr, err
 	}
 
 	// Multiple categories or keyword search - search in parallel
-	return rb.findNearbyRestaurantsGoogleMultipleWithStats(params.Lat, params.Lon, categoriesToSearch, keyword)
+	return rb.findNearbyRestaurantsGoogleMultipleWithStats(ctx, params.Lat, params.Lon, params.searchRadius(), categoriesToSearch, keyword)
 }
 
 // findNearbyRestaurantsGoogleAll searches all food categories in parallel
@@ -903,18 +1333,19 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleAll(lat, lon float64) ([]Res
 
 // findNearbyRestaurantsGoogleMultiple searches multiple categories in parallel with optional keyword
 func (rb *RestaurantBot) findNearbyRestaurantsGoogleMultiple(lat, lon float64, categories []FoodCategory, keyword string) ([]Restaurant, error) {
-	result, err := rb.findNearbyRestaurantsGoogleMultipleWithStats(lat, lon, categories, keyword)
+	result, err := rb.findNearbyRestaurantsGoogleMultipleWithStats(context.Background(), lat, lon, defaultSearchRadius, categories, keyword)
 	if err != nil {
 		return nil, err
 	}
 	return result.Restaurants, nil
 }
 
-func (rb *RestaurantBot) findNearbyRestaurantsGoogleMultipleWithStats(lat, lon float64, categories []FoodCategory, keyword string) (*SearchResult, error) {
+func (rb *RestaurantBot) findNearbyRestaurantsGoogleMultipleWithStats(ctx context.Context, lat, lon float64, radius int, categories []FoodCategory, keyword string) (*SearchResult, error) {
 	type result struct {
 		searchResult *SearchResult
 		err          error
 		source       string
+		latency      time.Duration
 	}
 
 	// Check if we're searching for restaurants (include cuisine keyword searches and text search)
@@ -950,9 +1381,10 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleMultipleWithStats(lat, lon f
 	// Search all categories in parallel
 	for _, cat := range categories {
 		go func(c FoodCategory) {
+			start := time.Now()
 			placeType := categoryToGoogleType[c]
-			sr, err := rb.findNearbyRestaurantsGoogleByTypeWithStats(lat, lon, placeType, keyword)
-			resultsChan <- result{searchResult: sr, err: err, source: string(c)}
+			sr, err := rb.findNearbyRestaurantsGoogleByTypeWithStats(ctx, lat, lon, radius, placeType, keyword)
+			resultsChan <- result{searchResult: sr, err: err, source: string(c), latency: time.Since(start)}
 		}(cat)
 	}
 
@@ -961,8 +1393,9 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleMultipleWithStats(lat, lon f
 	if len(cuisineSearches) > 0 {
 		for _, cuisineKw := range cuisineSearches {
 			go func(kw string) {
-				sr, err := rb.findNearbyRestaurantsGoogleByTypeWithStats(lat, lon, maps.PlaceTypeRestaurant, kw)
-				resultsChan <- result{searchResult: sr, err: err, source: "cuisine:" + kw}
+				start := time.Now()
+				sr, err := rb.findNearbyRestaurantsGoogleByTypeWithStats(ctx, lat, lon, radius, maps.PlaceTypeRestaurant, kw)
+				resultsChan <- result{searchResult: sr, err: err, source: "cuisine:" + kw, latency: time.Since(start)}
 			}(cuisineKw)
 		}
 	}
@@ -971,8 +1404,9 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleMultipleWithStats(lat, lon f
 	if len(textSearchQueries) > 0 {
 		for _, query := range textSearchQueries {
 			go func(q string) {
-				sr, err := rb.findNearbyRestaurantsGoogleTextSearchWithStats(lat, lon, q)
-				resultsChan <- result{searchResult: sr, err: err, source: "text:" + q}
+				start := time.Now()
+				sr, err := rb.findNearbyRestaurantsGoogleTextSearchWithStats(ctx, lat, lon, radius, q)
+				resultsChan <- result{searchResult: sr, err: err, source: "text:" + q, latency: time.Since(start)}
 			}(query)
 		}
 	}
@@ -984,66 +1418,69 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleMultipleWithStats(lat, lon f
 		GoogleSearchQueries: totalSearches,
 	}
 
-	log.Printf("[Search] Waiting for %d search results...", totalSearches)
+	logger := ctxLogger(ctx)
+	logger.Debug("[Search] Waiting for search results", "searches", totalSearches)
 
 	for i := 0; i < totalSearches; i++ {
 		res := <-resultsChan
+		resultCount := 0
+		if res.searchResult != nil {
+			resultCount = len(res.searchResult.Restaurants)
+		}
+		outcome := newProviderOutcome("google:"+res.source, res.latency, resultCount, res.err)
+		if res.err == nil && res.searchResult != nil {
+			// A sub-query that lost later pages still counts as failed
+			failures := res.searchResult.Stats.ProviderErrors
+			for j := range failures {
+				failures[j].Provider = "google:" + res.source
+			}
+			outcome = outcome.withFailures(failures)
+			stats.ProviderErrors = append(stats.ProviderErrors, failures...)
+		}
+		stats.SubQueries = append(stats.SubQueries, outcome)
+		reportSearchProgress(ctx, "google", "google:"+res.source, i+1, totalSearches, res.searchResult, res.err)
 		if res.err != nil {
+			stats.ProviderErrors = append(stats.ProviderErrors, newProviderError("google:"+res.source, res.err))
 			// Log errors but don't fail for cuisine/text searches (they're supplementary)
 			if strings.HasPrefix(res.source, "cuisine:") || strings.HasPrefix(res.source, "text:") {
-				log.Printf("[Search] Supplementary search error from %s: %v", res.source, res.err)
+				logger.Warn("[Search] Supplementary search failed", "query", res.source, "error", res.err)
 			} else {
 				errors = append(errors, fmt.Sprintf("%s: %v", res.source, res.err))
-				log.Printf("[Search] Error from %s: %v", res.source, res.err)
+				logger.Error("[Search] Search failed", "query", res.source, "error", res.err)
 			}
 		} else if res.searchResult != nil {
-			log.Printf("[Search] Got %d results from %s", len(res.searchResult.Restaurants), res.source)
+			logger.Debug("[Search] Got results", "query", res.source, "results", len(res.searchResult.Restaurants))
 			// Aggregate stats
 			stats.GooglePagesSearched += res.searchResult.Stats.GooglePagesSearched
 			stats.GoogleResultsRaw += res.searchResult.Stats.GoogleResultsRaw
 			stats.GoogleResultsFiltered += res.searchResult.Stats.GoogleResultsFiltered
-			// Check if any result contains "baba"
-			for _, r := range res.searchResult.Restaurants {
-				if strings.Contains(strings.ToLower(r.Name), "baba") {
-					log.Printf("[Search] BABA found in %s results: name='%s', rating=%.1f, reviews=%d, placeID='%s'", 
-						res.source, r.Name, r.Rating, r.ReviewCount, r.PlaceID)
-				}
-			}
+			rb.trace.Restaurants(ctx, "results:"+res.source, res.searchResult.Restaurants)
 			allRestaurants = append(allRestaurants, res.searchResult.Restaurants...)
 		}
 	}
 
 	stats.TotalBeforeDedup = len(allRestaurants)
-	log.Printf("[Search] Total restaurants before dedup: %d", len(allRestaurants))
-
-	// Check for Baba before dedup
-	for _, r := range allRestaurants {
-		if strings.Contains(strings.ToLower(r.Name), "baba") {
-			log.Printf("[Search] BABA before dedup: name='%s', lat=%.6f, lon=%.6f, placeID='%s'", 
-				r.Name, r.Latitude, r.Longitude, r.PlaceID)
-		}
-	}
+	logger.Debug("[Search] Total restaurants before dedup", "restaurants", len(allRestaurants))
+	rb.trace.Restaurants(ctx, "before_dedup", allRestaurants)
 
 	// If all failed, return error
 	if len(allRestaurants) == 0 && len(errors) > 0 {
-		return nil, fmt.Errorf("all category searches failed: %s", strings.Join(errors, "; "))
+		return nil, &ProviderFailureError{
+			Failures: stats.ProviderErrors,
+			cause:    fmt.Sprintf("all category searches failed: %s", strings.Join(errors, "; ")),
+		}
 	}
 
 	// Deduplicate restaurants (same place might appear in multiple categories)
-	deduplicated := deduplicateRestaurants(allRestaurants)
+	deduplicated := rb.deduplicate(ctx, allRestaurants)
 
 	stats.TotalAfterDedup = len(deduplicated)
-	log.Printf("[Search] Total restaurants after dedup: %d", len(deduplicated))
-
-	// Check for Baba after dedup
-	for _, r := range deduplicated {
-		if strings.Contains(strings.ToLower(r.Name), "baba") {
-			log.Printf("[Search] BABA after dedup: name='%s', rating=%.1f, reviews=%d", r.Name, r.Rating, r.ReviewCount)
-		}
-	}
+	logger.Info("[Search] Google search finished", "searches", totalSearches,
+		"before_dedup", stats.TotalBeforeDedup, "after_dedup", len(deduplicated))
+	rb.trace.Restaurants(ctx, "after_dedup", deduplicated)
 
 	// Sort by rating (highest first), then by distance for same ratings
-	sortRestaurantsByRating(deduplicated)
+	rb.rank(ctx, deduplicated)
 
 	return &SearchResult{
 		Restaurants: deduplicated,
@@ -1054,15 +1491,18 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleMultipleWithStats(lat, lon f
 // findNearbyRestaurantsGoogleTextSearch uses Text Search API for more comprehensive results
 // Text Search can find restaurants that NearbySearch might miss
 func (rb *RestaurantBot) findNearbyRestaurantsGoogleTextSearch(lat, lon float64, query string) ([]Restaurant, error) {
-	result, err := rb.findNearbyRestaurantsGoogleTextSearchWithStats(lat, lon, query)
+	result, err := rb.findNearbyRestaurantsGoogleTextSearchWithStats(context.Background(), lat, lon, defaultSearchRadius, query)
 	if err != nil {
 		return nil, err
 	}
 	return result.Restaurants, nil
 }
 
-func (rb *RestaurantBot) findNearbyRestaurantsGoogleTextSearchWithStats(lat, lon float64, query string) (*SearchResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func (rb *RestaurantBot) findNearbyRestaurantsGoogleTextSearchWithStats(ctx context.Context, lat, lon float64, radius int, query string) (result *SearchResult, err error) {
+	ctx, span := rb.tracing.Start(ctx, "google.text_search", attribute.String("google.query", query))
+	defer func() { endSearchSpan(span, result, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	request := &maps.TextSearchRequest{
@@ -1071,7 +1511,7 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleTextSearchWithStats(lat, lon
 			Lat: lat,
 			Lng: lon,
 		},
-		Radius:   2000, // 2km radius
+		Radius:   uint(radius),
 		Language: "en",
 	}
 
@@ -1079,43 +1519,57 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleTextSearchWithStats(lat, lon
 	var nextPageToken string
 	stats := SearchStats{}
 
-	log.Printf("[TextSearch] Starting search for query='%s' at %.6f,%.6f", query, lat, lon)
+	logger := ctxLogger(ctx).With("query", query)
+	logger.Debug("[TextSearch] Starting search", logKeyLat, lat, logKeyLon, lon)
 
 	for page := 0; page < 3; page++ { // Maximum 3 pages (60 results)
+		// Don't send another request once the spend cap is reached, not even
+		// the first page of a sub-query that started after it
+		if exceeded, _ := rb.costs.BudgetExceeded(); exceeded {
+			stats.ProviderErrors = append(stats.ProviderErrors, newBudgetProviderError("google"))
+			break
+		}
 		if page > 0 {
 			request.PageToken = nextPageToken
-			time.Sleep(2 * time.Second)
+			if err := sleepContext(ctx, pageTokenDelay); err != nil {
+				stats.ProviderErrors = append(stats.ProviderErrors, newProviderError("google", err))
+				break
+			}
 		}
 
-		resp, err := rb.mapsClient.TextSearch(ctx, request)
+		pageCtx, pageSpan := rb.tracing.Start(ctx, "google.text_search.page", attribute.Int("google.page", page))
+		start := time.Now()
+		resp, err := rb.mapsClient.TextSearch(pageCtx, request)
+		rb.metrics.ObserveProviderCall(EndpointTextSearch, time.Since(start), err)
+		if err == nil {
+			pageSpan.SetAttributes(attribute.Int("google.results", len(resp.Results)))
+		}
+		endSpan(pageSpan, err)
 		if err != nil {
-			log.Printf("[TextSearch] Error on page %d for query='%s': %v", page, query, err)
+			logger.Warn("[TextSearch] Request failed", "page", page, "error", err)
 			if page == 0 {
 				return nil, fmt.Errorf("text search failed: %w", err)
 			}
+			// Keep the earlier pages, but report the loss
+			stats.ProviderErrors = append(stats.ProviderErrors, newProviderError("google", err))
 			break
 		}
+		rb.costs.Record(SKUTextSearch)
 
 		stats.GooglePagesSearched++
 		stats.GoogleResultsRaw += len(resp.Results)
 
-		log.Printf("[TextSearch] Page %d for query='%s': got %d results", page, query, len(resp.Results))
+		logger.Debug("[TextSearch] Got page", "page", page, "results", len(resp.Results))
 
-		// Log ALL raw API results for debugging
 		for i, place := range resp.Results {
-			log.Printf("[TextSearch][RAW] #%d: name='%s', placeID='%s', types=%v, rating=%.1f, reviews=%d, lat=%.6f, lon=%.6f",
-				i+1, place.Name, place.PlaceID, place.Types, place.Rating, place.UserRatingsTotal,
-				place.Geometry.Location.Lat, place.Geometry.Location.Lng)
-		}
-
-		for _, place := range resp.Results {
-			// Log every place we see (for debugging)
-			if strings.Contains(strings.ToLower(place.Name), "baba") {
-				log.Printf("[TextSearch] FOUND BABA: name='%s', placeID='%s', types=%v", place.Name, place.PlaceID, place.Types)
-			}
+			logger.Debug("[TextSearch] Raw result", "index", i+1, "name", place.Name, "place_id", place.PlaceID,
+				"types", place.Types, "rating", place.Rating, "reviews", place.UserRatingsTotal,
+				logKeyLat, place.Geometry.Location.Lat, logKeyLon, place.Geometry.Location.Lng)
+			rb.trace.Place(ctx, "raw:text:"+query, place.Name, place.PlaceID, "types", place.Types)
 
 			if !isFoodRelatedPlace(place.Types) {
-				log.Printf("[TextSearch] Filtered out: name='%s', types=%v", place.Name, place.Types)
+				logger.Debug("[TextSearch] Filtered out non-food place", "name", place.Name, "types", place.Types)
+				rb.trace.Place(ctx, "filtered_out", place.Name, place.PlaceID, "types", place.Types)
 				continue
 			}
 
@@ -1137,10 +1591,9 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleTextSearchWithStats(lat, lon
 			// - Restaurants with rating below 4.0
 			// - Restaurants with fewer than 5 reviews
 			rating := float64(place.Rating)
-			if shouldUseGenericPhoto(photoRef, rating, reviewCount) {
+			if rb.photos.shouldUseGenericPhoto(photoRef, rating, reviewCount) {
 				photoRef = genericPhotoReference
-				log.Printf("[TextSearch] Using generic photo for '%s' (rating=%.1f, reviews=%d)", 
-					place.Name, rating, reviewCount)
+				logger.Debug("[TextSearch] Using generic photo", "name", place.Name, "rating", rating, "reviews", reviewCount)
 			}
 
 			allRestaurants = append(allRestaurants, Restaurant{
@@ -1155,6 +1608,7 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleTextSearchWithStats(lat, lon
 				Distance:       distance,
 				PhotoReference: photoRef,
 				PlaceID:        place.PlaceID,
+				OpenNow:        placeOpenNow(place.OpeningHours),
 			})
 		}
 
@@ -1165,8 +1619,8 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleTextSearchWithStats(lat, lon
 	}
 
 	stats.GoogleResultsFiltered = len(allRestaurants)
-	log.Printf("[TextSearch] Completed query='%s': returning %d restaurants", query, len(allRestaurants))
-	
+	logger.Debug("[TextSearch] Completed", "pages", stats.GooglePagesSearched, "restaurants", len(allRestaurants))
+
 	return &SearchResult{
 		Restaurants: allRestaurants,
 		Stats:       stats,
@@ -1175,15 +1629,19 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleTextSearchWithStats(lat, lon
 
 // findNearbyRestaurantsGoogleByType searches for a specific place type with optional keyword
 func (rb *RestaurantBot) findNearbyRestaurantsGoogleByType(lat, lon float64, placeType maps.PlaceType, keyword string) ([]Restaurant, error) {
-	result, err := rb.findNearbyRestaurantsGoogleByTypeWithStats(lat, lon, placeType, keyword)
+	result, err := rb.findNearbyRestaurantsGoogleByTypeWithStats(context.Background(), lat, lon, defaultSearchRadius, placeType, keyword)
 	if err != nil {
 		return nil, err
 	}
 	return result.Restaurants, nil
 }
 
-func (rb *RestaurantBot) findNearbyRestaurantsGoogleByTypeWithStats(lat, lon float64, placeType maps.PlaceType, keyword string) (*SearchResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second) // Longer timeout for pagination
+func (rb *RestaurantBot) findNearbyRestaurantsGoogleByTypeWithStats(ctx context.Context, lat, lon float64, radius int, placeType maps.PlaceType, keyword string) (result *SearchResult, err error) {
+	ctx, span := rb.tracing.Start(ctx, "google.nearby_search",
+		attribute.String("google.place_type", string(placeType)), attribute.String("google.keyword", keyword))
+	defer func() { endSearchSpan(span, result, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second) // Longer timeout for pagination
 	defer cancel()
 
 	request := &maps.NearbySearchRequest{
@@ -1191,65 +1649,85 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleByTypeWithStats(lat, lon flo
 			Lat: lat,
 			Lng: lon,
 		},
-		Radius:   2000, // 2km radius
+		Radius:   uint(radius),
 		Type:     placeType,
 		Keyword:  keyword, // Optional keyword for cuisine/diet filtering
 		Language: "en",
 	}
 
-	log.Printf("[NearbySearch] Starting search type='%s' keyword='%s' at %.6f,%.6f", placeType, keyword, lat, lon)
+	logger := ctxLogger(ctx).With("type", placeType, "keyword", keyword)
+	logger.Debug("[NearbySearch] Starting search", logKeyLat, lat, logKeyLon, lon)
 
 	// Collect all restaurants from all pages (up to 60 results)
 	allRestaurants := make([]Restaurant, 0)
 	var nextPageToken string
 	stats := SearchStats{}
 
+	retrying := false
 	for page := 0; page < 3; page++ { // Maximum 3 pages (60 results)
+		// Don't send another request once the spend cap is reached, not even
+		// the first page of a sub-query that started after it
+		if exceeded, _ := rb.costs.BudgetExceeded(); exceeded {
+			stats.ProviderErrors = append(stats.ProviderErrors, newBudgetProviderError("google"))
+			break
+		}
 		if page > 0 {
 			request.PageToken = nextPageToken
 			// wait for next_page_token to become active
-			time.Sleep(2 * time.Second)
+			if err := sleepContext(ctx, pageTokenDelay); err != nil {
+				stats.ProviderErrors = append(stats.ProviderErrors, newProviderError("google", err))
+				break
+			}
 		}
 
-		resp, err := rb.mapsClient.NearbySearch(ctx, request)
+		pageCtx, pageSpan := rb.tracing.Start(ctx, "google.nearby_search.page",
+			attribute.Int("google.page", page), attribute.Bool("google.page_token_retry", retrying))
+		start := time.Now()
+		resp, err := rb.mapsClient.NearbySearch(pageCtx, request)
+		rb.metrics.ObserveProviderCall(EndpointNearbySearch, time.Since(start), err)
+		if err == nil {
+			pageSpan.SetAttributes(attribute.Int("google.results", len(resp.Results)))
+			retrying = false
+		}
+		endSpan(pageSpan, err)
 		if err != nil {
-			log.Printf("[NearbySearch] Error on page %d for type='%s': %v", page, placeType, err)
+			logger.Warn("[NearbySearch] Request failed", "page", page, "error", err)
 			if page > 0 && strings.Contains(strings.ToLower(err.Error()), "invalid_request") {
 				// next_page_token not ready yet, wait longer and retry same page
-				time.Sleep(2 * time.Second)
+				retrying = true
+				span.AddEvent("page token not ready", trace.WithAttributes(attribute.Int("google.page", page)))
+				if err := sleepContext(ctx, pageTokenDelay); err != nil {
+					stats.ProviderErrors = append(stats.ProviderErrors, newProviderError("google", err))
+					break
+				}
 				page--
 				continue
 			}
 			if page == 0 {
 				return nil, fmt.Errorf("nearby search failed: %w", err)
 			}
-			// If pagination fails, return what we have
+			// If pagination fails, return what we have and report the loss
+			stats.ProviderErrors = append(stats.ProviderErrors, newProviderError("google", err))
 			break
 		}
+		rb.costs.Record(SKUNearbySearch)
 
 		stats.GooglePagesSearched++
 		stats.GoogleResultsRaw += len(resp.Results)
 
-		log.Printf("[NearbySearch] Page %d for type='%s': got %d results", page, placeType, len(resp.Results))
-
-		// Log ALL raw API results for debugging
-		for i, place := range resp.Results {
-			log.Printf("[NearbySearch][RAW] #%d: name='%s', placeID='%s', types=%v, rating=%.1f, reviews=%d, lat=%.6f, lon=%.6f",
-				i+1, place.Name, place.PlaceID, place.Types, place.Rating, place.UserRatingsTotal,
-				place.Geometry.Location.Lat, place.Geometry.Location.Lng)
-		}
+		logger.Debug("[NearbySearch] Got page", "page", page, "results", len(resp.Results))
 
 		// Convert to unified Restaurant format
-		for _, place := range resp.Results {
-			// Log every place we see that contains "baba" (for debugging)
-			if strings.Contains(strings.ToLower(place.Name), "baba") {
-				log.Printf("[NearbySearch] FOUND BABA: name='%s', placeID='%s', types=%v, rating=%.1f, reviews=%d", 
-					place.Name, place.PlaceID, place.Types, place.Rating, place.UserRatingsTotal)
-			}
+		for i, place := range resp.Results {
+			logger.Debug("[NearbySearch] Raw result", "index", i+1, "name", place.Name, "place_id", place.PlaceID,
+				"types", place.Types, "rating", place.Rating, "reviews", place.UserRatingsTotal,
+				logKeyLat, place.Geometry.Location.Lat, logKeyLon, place.Geometry.Location.Lng)
+			rb.trace.Place(ctx, "raw:nearby:"+string(placeType), place.Name, place.PlaceID, "types", place.Types)
 
 			// Last-resort post-filter: skip if no food-related types at all
 			if !isFoodRelatedPlace(place.Types) {
-				log.Printf("[NearbySearch] Filtered out non-food place: %s (types: %v)", place.Name, place.Types)
+				logger.Debug("[NearbySearch] Filtered out non-food place", "name", place.Name, "types", place.Types)
+				rb.trace.Place(ctx, "filtered_out", place.Name, place.PlaceID, "types", place.Types)
 				continue
 			}
 
@@ -1273,10 +1751,9 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleByTypeWithStats(lat, lon flo
 			// - Restaurants with rating below 4.0
 			// - Restaurants with fewer than 5 reviews
 			rating := float64(place.Rating)
-			if shouldUseGenericPhoto(photoRef, rating, reviewCount) {
+			if rb.photos.shouldUseGenericPhoto(photoRef, rating, reviewCount) {
 				photoRef = genericPhotoReference
-				log.Printf("[NearbySearch] Using generic photo for '%s' (rating=%.1f, reviews=%d)", 
-					place.Name, rating, reviewCount)
+				logger.Debug("[NearbySearch] Using generic photo", "name", place.Name, "rating", rating, "reviews", reviewCount)
 			}
 
 			priceLevel := place.PriceLevel
@@ -1294,6 +1771,7 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleByTypeWithStats(lat, lon flo
 				Distance:       distance,
 				PhotoReference: photoRef,
 				PlaceID:        place.PlaceID,
+				OpenNow:        placeOpenNow(place.OpeningHours),
 			})
 		}
 
@@ -1316,6 +1794,40 @@ func (rb *RestaurantBot) findNearbyRestaurantsGoogleByTypeWithStats(lat, lon flo
 	}, nil
 }
 
+// placeOpenNow extracts Google's open-now flag (nil if not reported)
+func placeOpenNow(hours *maps.OpeningHours) *bool {
+	if hours == nil {
+		return nil
+	}
+	return hours.OpenNow
+}
+
+// osmOpenNow evaluates the OSM opening_hours tag. Only "24/7" is understood;
+// anything else is reported as unknown (nil).
+func osmOpenNow(openingHours string) *bool {
+	if strings.TrimSpace(openingHours) == "24/7" {
+		open := true
+		return &open
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// pageTokenDelay is how long Google needs before a next_page_token can be used;
+// tests shorten it
+var pageTokenDelay = 2 * time.Second
+
 // isFoodRelatedPlace checks if place has at least one food-related type (last-resort filter)
 func isFoodRelatedPlace(types []string) bool {
 	for _, t := range types {
@@ -1341,7 +1853,7 @@ func isFoodRelatedPlace(types []string) bool {
 }
 
 func (rb *RestaurantBot) findNearbyRestaurantsOSM(lat, lon float64, category FoodCategory) ([]Restaurant, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), rb.requestTimeout)
 	defer cancel()
 
 	// Get amenities for this category
@@ -1369,14 +1881,14 @@ func (rb *RestaurantBot) findNearbyRestaurantsOSM(lat, lon float64, category Foo
 	`, strings.Join(queryParts, "\n		  "))
 
 	// Use Overpass API endpoint
-	apiURL := "https://overpass-api.de/api/interpreter"
+	apiURL := overpassAPIURL
 	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: requestTimeout}
+	client := &http.Client{Timeout: rb.requestTimeout, Transport: rb.providerTransport(EndpointOverpass)}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("overpass API request failed: %w", err)
@@ -1458,6 +1970,7 @@ func (rb *RestaurantBot) findNearbyRestaurantsOSM(lat, lon float64, category Foo
 			Address:   address,
 			Type:      restaurantType,
 			Distance:  distance,
+			OSMID:     fmt.Sprintf("%s/%d", elem.Type, elem.ID),
 		})
 	}
 
@@ -1466,15 +1979,18 @@ func (rb *RestaurantBot) findNearbyRestaurantsOSM(lat, lon float64, category Foo
 
 // findNearbyRestaurantsOSMWithParams searches OSM with full params support
 func (rb *RestaurantBot) findNearbyRestaurantsOSMWithParams(params SearchParams) ([]Restaurant, error) {
-	result, err := rb.findNearbyRestaurantsOSMWithStats(params)
+	result, err := rb.findNearbyRestaurantsOSMWithStats(context.Background(), params)
 	if err != nil {
 		return nil, err
 	}
 	return result.Restaurants, nil
 }
 
-func (rb *RestaurantBot) findNearbyRestaurantsOSMWithStats(params SearchParams) (*SearchResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+func (rb *RestaurantBot) findNearbyRestaurantsOSMWithStats(ctx context.Context, params SearchParams) (result *SearchResult, err error) {
+	ctx, span := rb.tracing.Start(ctx, "search.osm")
+	defer func() { endSearchSpan(span, result, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, rb.requestTimeout)
 	defer cancel()
 
 	// Collect all amenities from selected categories
@@ -1495,12 +2011,21 @@ func (rb *RestaurantBot) findNearbyRestaurantsOSMWithStats(params SearchParams)
 	}
 
 	// Build Overpass API query dynamically
-	radius := 2000 // meters
-	var queryParts []string
+	location := fmt.Sprintf("(around:%d,%.6f,%.6f)", params.searchRadius(), params.Lat, params.Lon)
+	if params.Area != nil {
+		location = params.Area.OverpassFilter()
+	}
+	// Sorted so the same search always sends the same query (see fixtures.go)
+	amenities := make([]string, 0, len(amenitySet))
 	for amenity := range amenitySet {
+		amenities = append(amenities, amenity)
+	}
+	sort.Strings(amenities)
+	var queryParts []string
+	for _, amenity := range amenities {
 		queryParts = append(queryParts,
-			fmt.Sprintf(`node["amenity"="%s"](around:%d,%.6f,%.6f);`, amenity, radius, params.Lat, params.Lon),
-			fmt.Sprintf(`way["amenity"="%s"](around:%d,%.6f,%.6f);`, amenity, radius, params.Lat, params.Lon),
+			fmt.Sprintf(`node["amenity"="%s"]%s;`, amenity, location),
+			fmt.Sprintf(`way["amenity"="%s"]%s;`, amenity, location),
 		)
 	}
 
@@ -1509,14 +2034,14 @@ func (rb *RestaurantBot) findNearbyRestaurantsOSMWithStats(params SearchParams)
 	if keyword != "" {
 		// Add cuisine-specific queries
 		queryParts = append(queryParts,
-			fmt.Sprintf(`node["cuisine"~"%s",i](around:%d,%.6f,%.6f);`, keyword, radius, params.Lat, params.Lon),
-			fmt.Sprintf(`way["cuisine"~"%s",i](around:%d,%.6f,%.6f);`, keyword, radius, params.Lat, params.Lon),
+			fmt.Sprintf(`node["cuisine"~"%s",i]%s;`, keyword, location),
+			fmt.Sprintf(`way["cuisine"~"%s",i]%s;`, keyword, location),
 		)
 		// Add diet-specific queries for health keywords
 		if keyword == "vegan" || keyword == "vegetarian" || keyword == "halal" || keyword == "kosher" {
 			queryParts = append(queryParts,
-				fmt.Sprintf(`node["diet:%s"="yes"](around:%d,%.6f,%.6f);`, keyword, radius, params.Lat, params.Lon),
-				fmt.Sprintf(`way["diet:%s"="yes"](around:%d,%.6f,%.6f);`, keyword, radius, params.Lat, params.Lon),
+				fmt.Sprintf(`node["diet:%s"="yes"]%s;`, keyword, location),
+				fmt.Sprintf(`way["diet:%s"="yes"]%s;`, keyword, location),
 			)
 		}
 	}
@@ -1530,14 +2055,14 @@ func (rb *RestaurantBot) findNearbyRestaurantsOSMWithStats(params SearchParams)
 	`, strings.Join(queryParts, "\n		  "))
 
 	// Use Overpass API endpoint
-	apiURL := "https://overpass-api.de/api/interpreter"
+	apiURL := overpassAPIURL
 	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: requestTimeout}
+	client := &http.Client{Timeout: rb.requestTimeout, Transport: rb.providerTransport(EndpointOverpass)}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("overpass API request failed: %w", err)
@@ -1583,6 +2108,11 @@ func (rb *RestaurantBot) findNearbyRestaurantsOSMWithStats(params SearchParams)
 			elemLon = elem.Center.Lon
 		}
 
+		// Ways are matched by their geometry; clip by their center like Google results
+		if params.Area != nil && !params.Area.Contains(elemLat, elemLon) {
+			continue
+		}
+
 		// Filter by keyword in name or cuisine if keyword is set
 		if keywordLower != "" {
 			nameMatch := strings.Contains(strings.ToLower(elem.Tags["name"]), keywordLower)
@@ -1629,6 +2159,9 @@ func (rb *RestaurantBot) findNearbyRestaurantsOSMWithStats(params SearchParams)
 			Address:   address,
 			Type:      restaurantType,
 			Distance:  distance,
+			OpenNow:   osmOpenNow(elem.Tags["opening_hours"]),
+			Brand:     elem.Tags["brand"],
+			OSMID:     fmt.Sprintf("%s/%d", elem.Type, elem.ID),
 		})
 	}
 
@@ -1637,6 +2170,8 @@ func (rb *RestaurantBot) findNearbyRestaurantsOSMWithStats(params SearchParams)
 
 	stats.TotalAfterDedup = len(restaurants)
 	stats.TotalBeforeDedup = len(restaurants)
+	ctxLogger(ctx).Debug("[OSM] Search finished", "elements", stats.OSMResultsTotal, "restaurants", len(restaurants))
+	rb.trace.Restaurants(ctx, "results:osm", restaurants)
 
 	return &SearchResult{
 		Restaurants: restaurants,
@@ -1706,6 +2241,8 @@ I can help you find nearby restaurants based on your location.
 1. Share your location with me (use the 📎 attachment button)
 2. I'll find the closest restaurants near you
 
+Or type /near followed by a place or address.
+
 Use /help for more information.`
 
 	rb.sendMessage(chatID, message)
@@ -1717,6 +2254,7 @@ func (rb *RestaurantBot) sendHelpMessage(chatID int64) {
 *Commands:*
 /start - Start the bot
 /help - Show this help message
+/near <place> - Find restaurants near a place or address (e.g. /near Alexanderplatz)
 
 *How to find restaurants:*
 1. Tap the 📎 attachment button in Telegram
@@ -1729,6 +2267,10 @@ func (rb *RestaurantBot) sendHelpMessage(chatID int64) {
 	rb.sendMessage(chatID, message)
 }
 
+func (rb *RestaurantBot) sendRateLimitedMessage(chatID int64, retryAfter time.Duration) {
+	rb.sendTextMessage(chatID, fmt.Sprintf("⏳ You're searching too often. Please try again in %d seconds.", retryAfterSeconds(retryAfter)))
+}
+
 func (rb *RestaurantBot) sendTextMessage(chatID int64, text string) {
 	rb.sendMessage(chatID, text)
 }
@@ -1740,7 +2282,7 @@ func (rb *RestaurantBot) sendMessage(chatID int64, text string) {
 	msg.DisableWebPagePreview = false
 
 	if _, err := rb.telegramBot.Send(msg); err != nil {
-		log.Printf("Failed to send message to chat %d: %v", chatID, err)
+		slog.Error("Failed to send message", "chat_id", chatID, "error", err)
 	}
 }
 
@@ -1750,6 +2292,7 @@ func escapeMarkdownV2(text string) string {
 	// Escape all markdown special characters including asterisks
 	// The wrapping asterisks in the caller will still create bold formatting
 	replacer := strings.NewReplacer(
+		"\\", "\\\\",
 		"*", "\\*",
 		"_", "\\_",
 		"[", "\\[",
@@ -1774,8 +2317,9 @@ func escapeMarkdownV2(text string) string {
 
 // escapeMarkdown escapes all markdown special characters (for addresses and other text)
 func escapeMarkdown(text string) string {
-	// Escape all markdown special characters: * _ [ ] ( ) ~ ` > # + - = | { } . !
+	// Escape all markdown special characters: \ * _ [ ] ( ) ~ ` > # + - = | { } . !
 	replacer := strings.NewReplacer(
+		"\\", "\\\\",
 		"*", "\\*",
 		"_", "\\_",
 		"[", "\\[",
@@ -1798,6 +2342,88 @@ func escapeMarkdown(text string) string {
 	return replacer.Replace(text)
 }
 
+// parseSearchQuery parses the query parameters of a GET restaurant search
+func parseSearchQuery(q url.Values) (SearchParams, ResultFilters, int, int, *APIError) {
+	var params SearchParams
+	var err error
+	var page, limit int = 1, 20 // Default pagination: page 1, 20 items per page
+
+	latStr := q.Get("lat")
+	lonStr := q.Get("lon")
+	categoriesStr := q.Get("categories") // comma-separated: "restaurant,cafe"
+	keyword := q.Get("keyword")          // cuisine/diet filter
+	pageStr := q.Get("page")             // pagination: page number (1-indexed)
+	limitStr := q.Get("limit")           // pagination: items per page
+
+	// Legacy support: also check "category" (single)
+	if categoriesStr == "" {
+		categoriesStr = q.Get("category")
+	}
+
+	// An area takes precedence, then coordinates; otherwise q is geocoded by the handler
+	if bbox := q.Get("bbox"); bbox != "" {
+		area, err := parseBBox(bbox)
+		if err != nil {
+			return params, ResultFilters{}, 0, 0, &APIError{Code: ErrCodeInvalidArea, Message: err.Error()}
+		}
+		params.setArea(area)
+	} else if latStr == "" && lonStr == "" && strings.TrimSpace(q.Get("q")) != "" {
+		params.Place = strings.TrimSpace(q.Get("q"))
+		if len(params.Place) > maxGeocodeQueryLength {
+			return params, ResultFilters{}, 0, 0, &APIError{Code: ErrCodeInvalidRequest, Message: fmt.Sprintf("q must be at most %d characters", maxGeocodeQueryLength)}
+		}
+	} else {
+		if latStr == "" || lonStr == "" {
+			return params, ResultFilters{}, 0, 0, &APIError{Code: ErrCodeMissingParameter, Message: "lat and lon (or q) parameters are required"}
+		}
+		params.Lat, err = strconv.ParseFloat(latStr, 64)
+		if err != nil {
+			return params, ResultFilters{}, 0, 0, &APIError{Code: ErrCodeInvalidCoordinates, Message: "Invalid lat parameter"}
+		}
+		params.Lon, err = strconv.ParseFloat(lonStr, 64)
+		if err != nil {
+			return params, ResultFilters{}, 0, 0, &APIError{Code: ErrCodeInvalidCoordinates, Message: "Invalid lon parameter"}
+		}
+	}
+
+	// Parse pagination parameters
+	if pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	// Parse categories
+	if categoriesStr != "" && categoriesStr != "all" {
+		for _, c := range strings.Split(categoriesStr, ",") {
+			c = strings.TrimSpace(c)
+			if c != "" {
+				params.Categories = append(params.Categories, FoodCategory(c))
+			}
+		}
+	}
+	params.Keyword = keyword
+
+	filters, err := parseResultFiltersQuery(q)
+	if err != nil {
+		return params, filters, 0, 0, &APIError{Code: ErrCodeInvalidRequest, Message: err.Error()}
+	}
+	return params, filters, page, limit, nil
+}
+
+// validCoordinates reports whether lat/lon are finite and within WGS84 bounds
+func validCoordinates(lat, lon float64) bool {
+	if math.IsNaN(lat) || math.IsNaN(lon) {
+		return false
+	}
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
 // calculateDistance calculates the distance between two coordinates using Haversine formula
 func calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	const earthRadiusKm = 6371.0
@@ -1812,6 +2438,8 @@ func calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	a := math.Sin(dLatRad/2)*math.Sin(dLatRad/2) +
 		math.Cos(lat1Rad)*math.Cos(lat2Rad)*
 			math.Sin(dLonRad/2)*math.Sin(dLonRad/2)
+	// Rounding can push a just past 1 for antipodal points, making √(1-a) NaN
+	a = math.Min(a, 1)
 
 	// c = 2 * atan2(√a, √(1-a))
 	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
@@ -1829,400 +2457,116 @@ func formatDistance(distanceKm float64) string {
 }
 
 func main() {
-	// Get environment variables
-	enableTelegramBot := os.Getenv("ENABLE_TELEGRAM_BOT")
-	telegramEnabled := enableTelegramBot == "true" || enableTelegramBot == "1"
+	// Offline subcommands (search, cache, photos, config) run once and exit
+	if len(os.Args) > 1 && isCommand(os.Args[1]) {
+		os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr, os.Getenv))
+	}
 
-	googleMapsAPIKey := os.Getenv("GOOGLE_MAPS_API_KEY")
-	apiProvider := os.Getenv("API_PROVIDER") // "google", "osm", or "both", defaults to "google"
+	cfg, printConfig, err := LoadConfig(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		return
+	}
 
-	var bot *RestaurantBot
-	var err error
+	// Structured logging first, so everything below goes through it
+	slog.SetDefault(slog.New(NewLogHandler(os.Stderr, cfg.Log)))
 
-	if telegramEnabled {
-		telegramToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-		if telegramToken == "" {
-			log.Fatal("TELEGRAM_BOT_TOKEN environment variable is required when ENABLE_TELEGRAM_BOT is true")
-		}
+	if !cfg.Telegram.Enabled {
+		slog.Info("Telegram bot is disabled (set ENABLE_TELEGRAM_BOT=true to enable)")
+	}
+	bot, err := NewRestaurantBotFromConfig(cfg)
+	if err != nil {
+		slog.Error("Failed to create bot", "error", err)
+		os.Exit(1)
+	}
 
-		// Create bot
-		bot, err = NewRestaurantBot(telegramToken, googleMapsAPIKey, apiProvider)
-		if err != nil {
-			log.Fatalf("Failed to create bot: %v", err)
+	slog.Info("Using API provider", "provider", bot.apiProvider)
+	switch bot.apiProvider {
+	case "osm":
+		slog.Info("Using OpenStreetMap (FREE) - no API costs!")
+	case "fake":
+		slog.Info("Using the FAKE provider - made-up places, no network calls")
+	case "both":
+		slog.Info("Using BOTH Google Maps and OpenStreetMap - searching in parallel!")
+		if cfg.GoogleMapsAPIKey == "" {
+			slog.Warn("GOOGLE_MAPS_API_KEY not set, only OSM will be used")
 		}
+	default:
+		slog.Info("Using Google Maps API - costs apply per request")
+	}
+	if bot.trace != nil {
+		slog.Info("Tracing a place through searches at debug level", "trace_place", cfg.Log.TracePlace)
+	}
+	if cfg.Costs.DailyBudgetUSD > 0 || cfg.Costs.MonthlyBudgetUSD > 0 {
+		slog.Info("Google budget caps (0 = unlimited)", "daily_usd", cfg.Costs.DailyBudgetUSD, "monthly_usd", cfg.Costs.MonthlyBudgetUSD)
+	}
+	if bot.auth != nil {
+		slog.Info("API key authentication enabled", "keys", len(bot.auth.keys))
+	}
+	if bot.tracing != nil {
+		slog.Info("OpenTelemetry tracing enabled (OTLP/HTTP)")
+	}
 
-		log.Printf("Using API provider: %s", bot.apiProvider)
-		switch bot.apiProvider {
-		case "osm":
-			log.Printf("Using OpenStreetMap (FREE) - no API costs!")
-		case "both":
-			log.Printf("Using BOTH Google Maps and OpenStreetMap - searching in parallel!")
-			if googleMapsAPIKey == "" {
-				log.Printf("WARNING: GOOGLE_MAPS_API_KEY not set, only OSM will be used")
-			}
-		default:
-			log.Printf("Using Google Maps API - costs apply per request")
-		}
-	} else {
-		log.Printf("Telegram bot is disabled (set ENABLE_TELEGRAM_BOT=true to enable)")
-		// Create a minimal bot instance just for the HTTP server functionality
-		bot, err = NewRestaurantBot("", googleMapsAPIKey, apiProvider)
-		if err != nil {
-			log.Fatalf("Failed to create bot instance: %v", err)
-		}
-		log.Printf("Using API provider: %s", bot.apiProvider)
+	// Results cached before the last shutdown are served again
+	if restored, err := bot.cache.Load(); err != nil {
+		slog.Warn("[CACHE] Failed to restore cache", "error", err)
+	} else if restored > 0 {
+		slog.Info("[CACHE] Restored entries", "entries", restored, "file", cfg.Cache.File)
 	}
 
+	// SIGINT/SIGTERM start a graceful shutdown; a second signal kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Start HTTP server for web interface
+	server := NewServer(bot, bot, NewDiskPhotoStore(cfg.Photos.Dir))
+	httpServer := NewHTTPServer(cfg.HTTP, server)
+	serverErr := make(chan error, 1)
 	go func() {
-		http.HandleFunc("/api/restaurants", func(w http.ResponseWriter, r *http.Request) {
-			// Enable CORS
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			if r.Method != "GET" && r.Method != "POST" {
-				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-				return
-			}
-
-			// Get lat/lon/categories/keyword from query params or JSON body
-			var params SearchParams
-			var err error
-			var page, limit int = 1, 20 // Default pagination: page 1, 20 items per page
-
-			if r.Method == "GET" {
-				latStr := r.URL.Query().Get("lat")
-				lonStr := r.URL.Query().Get("lon")
-				categoriesStr := r.URL.Query().Get("categories") // comma-separated: "restaurant,cafe"
-				keyword := r.URL.Query().Get("keyword")          // cuisine/diet filter
-				pageStr := r.URL.Query().Get("page")             // pagination: page number (1-indexed)
-				limitStr := r.URL.Query().Get("limit")           // pagination: items per page
-				
-				// Legacy support: also check "category" (single)
-				if categoriesStr == "" {
-					categoriesStr = r.URL.Query().Get("category")
-				}
-				
-				if latStr == "" || lonStr == "" {
-					http.Error(w, "lat and lon parameters are required", http.StatusBadRequest)
-					return
-				}
-				params.Lat, err = strconv.ParseFloat(latStr, 64)
-				if err != nil {
-					http.Error(w, "Invalid lat parameter", http.StatusBadRequest)
-					return
-				}
-				params.Lon, err = strconv.ParseFloat(lonStr, 64)
-				if err != nil {
-					http.Error(w, "Invalid lon parameter", http.StatusBadRequest)
-					return
-				}
-				
-				// Parse pagination parameters
-				if pageStr != "" {
-					if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-						page = p
-					}
-				}
-				if limitStr != "" {
-					if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-						limit = l
-					}
-				}
-				
-				// Parse categories
-				if categoriesStr != "" && categoriesStr != "all" {
-					for _, c := range strings.Split(categoriesStr, ",") {
-						c = strings.TrimSpace(c)
-						if c != "" {
-							params.Categories = append(params.Categories, FoodCategory(c))
-						}
-					}
-				}
-				params.Keyword = keyword
-			} else {
-				var req struct {
-					Lat        float64  `json:"lat"`
-					Lon        float64  `json:"lon"`
-					Categories []string `json:"categories"` // array of categories
-					Category   string   `json:"category"`   // legacy single category
-					Keyword    string   `json:"keyword"`
-					Page       int      `json:"page"`       // pagination: page number
-					Limit      int      `json:"limit"`      // pagination: items per page
-				}
-				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-					http.Error(w, "Invalid JSON body", http.StatusBadRequest)
-					return
-				}
-				params.Lat = req.Lat
-				params.Lon = req.Lon
-				params.Keyword = req.Keyword
-				
-				// Parse pagination from JSON
-				if req.Page > 0 {
-					page = req.Page
-				}
-				if req.Limit > 0 && req.Limit <= 100 {
-					limit = req.Limit
-				}
-				
-				// Support both array and single category
-				if len(req.Categories) > 0 {
-					for _, c := range req.Categories {
-						params.Categories = append(params.Categories, FoodCategory(c))
-					}
-				} else if req.Category != "" && req.Category != "all" {
-					params.Categories = []FoodCategory{FoodCategory(req.Category)}
-				}
-			}
-
-			// Get all restaurants (from cache or fresh search)
-			var allRestaurants []Restaurant
-			var stats SearchStats
-
-			// Check cache first (only for searches without keyword filter for now)
-			if params.Keyword == "" {
-				if cached, cachedStats, found := bot.cache.Get(params.Lat, params.Lon); found {
-					log.Printf("API Cache hit for location %.6f,%.6f", params.Lat, params.Lon)
-					allRestaurants = cached
-					stats = *cachedStats
-				}
-			}
-
-			// If not cached, fetch fresh results
-			if allRestaurants == nil {
-				result, err := bot.findNearbyRestaurantsWithStats(params)
-				if err != nil {
-					log.Printf("Error finding restaurants: %v", err)
-					http.Error(w, fmt.Sprintf("Error finding restaurants: %v", err), http.StatusInternalServerError)
-					return
-				}
-				allRestaurants = result.Restaurants
-				stats = result.Stats
-
-				// Cache the results (only for searches without keyword filter)
-				if params.Keyword == "" {
-					bot.cache.Set(params.Lat, params.Lon, allRestaurants, stats)
-				}
-			}
-
-			// Apply pagination
-			totalItems := len(allRestaurants)
-			totalPages := (totalItems + limit - 1) / limit // Ceiling division
-			if totalPages == 0 {
-				totalPages = 1
-			}
-			
-			// Clamp page to valid range
-			if page > totalPages {
-				page = totalPages
-			}
-			
-			// Calculate slice indices
-			startIdx := (page - 1) * limit
-			endIdx := startIdx + limit
-			if endIdx > totalItems {
-				endIdx = totalItems
-			}
-			if startIdx > totalItems {
-				startIdx = totalItems
-			}
-			
-			// Get paginated slice
-			paginatedRestaurants := allRestaurants[startIdx:endIdx]
-
-			// Build paginated response
-			paginatedResult := PaginatedSearchResult{
-				Restaurants: paginatedRestaurants,
-				Stats:       stats,
-				Pagination: Pagination{
-					Page:       page,
-					Limit:      limit,
-					TotalItems: totalItems,
-					TotalPages: totalPages,
-					HasNext:    page < totalPages,
-					HasPrev:    page > 1,
-				},
-			}
-
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(paginatedResult)
-		})
-
-		// Proxy endpoint for Google Places photos with permanent disk storage
-		// Photos are saved indefinitely to avoid repeated API costs
-		// Google Places Photo API pricing: $7.00 per 1,000 requests = $0.007 (0.7 cents) per photo
-		//
-		// IMPORTANT: Photos are stored by place_id (stable restaurant identifier), NOT by photo_reference
-		// This ensures we never call the API twice for the same restaurant, even if Google
-		// returns different photo_references over time.
-		http.HandleFunc("/api/photo", func(w http.ResponseWriter, r *http.Request) {
-			if r.Method != "GET" {
-				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-				return
-			}
-
-			photoRef := r.URL.Query().Get("photo_reference")
-			placeID := r.URL.Query().Get("place_id")
-
-			// Require place_id for proper caching by restaurant
-			if placeID == "" {
-				http.Error(w, "place_id parameter is required", http.StatusBadRequest)
-				return
-			}
-
-			// Handle generic placeholder photo request
-			// This is used for restaurants without photos or with low rating/few reviews
-			// to avoid unnecessary Google API calls ($0.007 per photo)
-			if photoRef == genericPhotoReference || photoRef == "" {
-				log.Printf("[PHOTO][GENERIC] Serving generic placeholder image for place_id=%s - $0.00 cost", placeID)
-				placeholderData, err := getOrCreateGenericPlaceholder()
-				if err != nil {
-					log.Printf("[PHOTO][GENERIC][ERROR] Failed to generate placeholder: %v", err)
-					http.Error(w, "Failed to generate placeholder image", http.StatusInternalServerError)
-					return
-				}
-				w.Header().Set("Content-Type", "image/jpeg")
-				w.Header().Set("Cache-Control", "public, max-age=86400")
-				w.Header().Set("X-Photo-Source", "generic")
-				w.Header().Set("Access-Control-Expose-Headers", "X-Photo-Source")
-				w.Write(placeholderData)
-				return
-			}
-
-			// Use place_id as filename - this is stable and unique per restaurant
-			// This ensures we only fetch ONE photo per restaurant, ever
-			// Sanitize place_id to be safe for filesystem (remove any path separators)
-			safeePlaceID := strings.ReplaceAll(placeID, "/", "_")
-			safeePlaceID = strings.ReplaceAll(safeePlaceID, "\\", "_")
-			filename := safeePlaceID + ".jpg"
-			storedPath := filepath.Join(photoCachePath, filename)
-
-			// Check if photo exists on disk (permanent storage)
-			if fileInfo, err := os.Stat(storedPath); err == nil && fileInfo.Size() > 0 {
-				// Serve from disk - FREE, no API cost!
-				log.Printf("[PHOTO][DISK] Serving from disk: %s (size: %d bytes) - $0.00 cost", filename, fileInfo.Size())
-				w.Header().Set("Content-Type", "image/jpeg")
-				w.Header().Set("Cache-Control", "public, max-age=86400")
-				w.Header().Set("X-Photo-Source", "disk")
-				w.Header().Set("Access-Control-Expose-Headers", "X-Photo-Source")
-				http.ServeFile(w, r, storedPath)
-				return
-			}
-
-			// Photo not on disk - need to fetch from Google API
-			log.Printf("[PHOTO][API] Photo not found on disk, fetching from Google API: %s", filename)
-
-			if googleMapsAPIKey == "" {
-				http.Error(w, "Google Maps API key not configured", http.StatusServiceUnavailable)
-				return
-			}
-
-			// Fetch photo from Google Places Photo API
-			// Cost: $7.00 per 1,000 requests = $0.007 per request
-			photoURL := fmt.Sprintf("https://maps.googleapis.com/maps/api/place/photo?maxwidth=400&photoreference=%s&key=%s", photoRef, googleMapsAPIKey)
-
-			resp, err := http.Get(photoURL)
-			if err != nil {
-				log.Printf("[PHOTO][API][ERROR] Failed to fetch from Google API: %v - saving generic placeholder to prevent future API calls", err)
-				// Save generic placeholder so we don't keep trying this photo reference
-				saveGenericPlaceholderForFailedPhoto(storedPath, filename)
-				serveGenericPlaceholderOnError(w)
-				return
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				log.Printf("[PHOTO][API][ERROR] Google API returned status %d - saving generic placeholder to prevent future API calls", resp.StatusCode)
-				// Save generic placeholder so we don't keep trying this photo reference
-				saveGenericPlaceholderForFailedPhoto(storedPath, filename)
-				serveGenericPlaceholderOnError(w)
-				return
-			}
-
-			// Read the photo into memory
-			photoData, err := io.ReadAll(resp.Body)
-			if err != nil {
-				log.Printf("[PHOTO][API][ERROR] Failed to read photo data: %v - saving generic placeholder", err)
-				saveGenericPlaceholderForFailedPhoto(storedPath, filename)
-				serveGenericPlaceholderOnError(w)
-				return
-			}
-
-			// Check if we got actual image data (sometimes API returns empty or error HTML)
-			if len(photoData) < 1000 {
-				log.Printf("[PHOTO][API][ERROR] Photo data too small (%d bytes), likely invalid - saving generic placeholder", len(photoData))
-				saveGenericPlaceholderForFailedPhoto(storedPath, filename)
-				serveGenericPlaceholderOnError(w)
-				return
-			}
-
-			log.Printf("[PHOTO][API] Fetched from Google API: %s (size: %d bytes) - cost: $0.007", filename, len(photoData))
-
-			// Save to disk permanently (don't fail request if this doesn't work)
-			if err := os.MkdirAll(photoCachePath, 0755); err == nil {
-				if err := os.WriteFile(storedPath, photoData, 0644); err != nil {
-					log.Printf("[PHOTO][DISK][ERROR] Failed to save photo to disk: %v", err)
-				} else {
-					log.Printf("[PHOTO][DISK] Saved permanently to disk: %s (size: %d bytes)", filename, len(photoData))
-				}
-			} else {
-				log.Printf("[PHOTO][DISK][ERROR] Failed to create photo storage directory %s: %v", photoCachePath, err)
-			}
-
-			// Serve the photo
-			contentType := resp.Header.Get("Content-Type")
-			if contentType == "" {
-				contentType = "image/jpeg"
-			}
-			w.Header().Set("Content-Type", contentType)
-			w.Header().Set("Cache-Control", "public, max-age=86400")
-			w.Header().Set("X-Photo-Source", "api")
-			w.Header().Set("Access-Control-Expose-Headers", "X-Photo-Source")
-			w.Write(photoData)
-		})
-
-		// Serve index-new.html at hard-to-find URL
-		http.HandleFunc("/vwrk4DFEv1RQpl3PxmWSZUeCkSVjAc5kbDqnIIu4DqDYVdNnGiu1xBWIE8IgbJ3X.html", func(w http.ResponseWriter, r *http.Request) {
-			http.ServeFile(w, r, "index-new.html")
-		})
-
-		// Serve HTML page
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path != "/" {
-				http.NotFound(w, r)
-				return
-			}
-			http.ServeFile(w, r, "index.html")
-		})
-
-		port := os.Getenv("HTTP_PORT")
-		if port == "" {
-			port = "8080"
-		}
-		log.Printf("HTTP server starting on port %s", port)
-		log.Printf("Web interface available at http://localhost:%s", port)
-		if err := http.ListenAndServe(":"+port, nil); err != nil {
-			log.Printf("HTTP server error: %v", err)
+		slog.Info("HTTP server starting", "addr", cfg.HTTP.Addr())
+		slog.Info("Web interface available at http://localhost" + cfg.HTTP.Addr())
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
 	}()
 
 	// Start bot only if enabled
-	if telegramEnabled {
-		if err := bot.Start(); err != nil {
-			log.Fatalf("Bot error: %v", err)
-		}
+	botErr := make(chan error, 1)
+	if cfg.Telegram.Enabled {
+		go func() {
+			if err := bot.Start(); err != nil {
+				botErr <- err
+			}
+		}()
 	} else {
-		// Keep the program running for HTTP server
-		log.Printf("HTTP server running. Telegram bot disabled.")
-		select {} // Block forever to keep HTTP server running
+		slog.Info("HTTP server running. Telegram bot disabled.")
 	}
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		slog.Info("[SHUTDOWN] Received signal, shutting down")
+	case err := <-serverErr:
+		slog.Error("HTTP server error", "error", err)
+		exitCode = 1
+	case err := <-botErr:
+		slog.Error("Bot error", "error", err)
+		exitCode = 1
+	}
+	stop()
+
+	if err := shutdown(httpServer, bot, cfg.HTTP.ShutdownTimeout); err != nil {
+		exitCode = 1
+	}
+	os.Exit(exitCode)
 }
 
 // formatPlaceType converts Google place types (e.g., "health_food_store") into readable text.
@@ -2245,10 +2589,9 @@ func formatTypeString(value string) string {
 	value = strings.ReplaceAll(value, "_", " ")
 	parts := strings.Fields(value)
 	for i, part := range parts {
-		if len(part) == 0 {
-			continue
-		}
-		parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		// Split at the first rune, not byte, so "école" keeps its accent
+		_, size := utf8.DecodeRuneInString(part)
+		parts[i] = strings.ToUpper(part[:size]) + strings.ToLower(part[size:])
 	}
 	return strings.Join(parts, " ")
 }
@@ -0,0 +1,665 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+	"unicode/utf8"
+
+	"googlemaps.github.io/maps"
+)
+
+// earthHalfCircumferenceKm is the largest distance calculateDistance can return
+const earthHalfCircumferenceKm = math.Pi * 6371.0
+
+// latitude and longitude map arbitrary quick values onto valid coordinates
+func latitude(u uint32) float64  { return float64(u)/math.MaxUint32*180 - 90 }
+func longitude(u uint32) float64 { return float64(u)/math.MaxUint32*360 - 180 }
+
+func TestCalculateDistance(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		wantKm                 float64
+	}{
+		{"same point", 52.52, 13.405, 52.52, 13.405, 0},
+		{"Berlin to Paris", 52.52, 13.405, 48.8566, 2.3522, 877.5},
+		{"one degree of longitude at the equator", 0, 0, 0, 1, 111.19},
+		{"one degree of latitude", 10, 5, 11, 5, 111.19},
+		{"across the antimeridian", 0, 179.9, 0, -179.9, 22.24},
+		{"pole to pole", 90, 0, -90, 0, earthHalfCircumferenceKm},
+		{"antipodes", 10, 20, -10, -160, earthHalfCircumferenceKm},
+		{"Sydney to London", -33.8688, 151.2093, 51.5074, -0.1278, 16993.9},
+	}
+	for _, tt := range tests {
+		got := calculateDistance(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+		// Within 0.1%, or 10 m for short distances
+		if math.Abs(got-tt.wantKm) > math.Max(tt.wantKm*0.001, 0.01) {
+			t.Errorf("%s: %.3f km, want %.3f km", tt.name, got, tt.wantKm)
+		}
+	}
+}
+
+func TestCalculateDistanceProperties(t *testing.T) {
+	// Symmetric, never negative and at most half the Earth's circumference
+	bounded := func(a, b, c, d uint32) bool {
+		lat1, lon1, lat2, lon2 := latitude(a), longitude(b), latitude(c), longitude(d)
+		ab := calculateDistance(lat1, lon1, lat2, lon2)
+		return ab == calculateDistance(lat2, lon2, lat1, lon1) && ab >= 0 && ab <= earthHalfCircumferenceKm+1e-9
+	}
+	if err := quick.Check(bounded, nil); err != nil {
+		t.Error(err)
+	}
+
+	// The triangle inequality holds, allowing for rounding
+	triangle := func(a, b, c, d, e, f uint32) bool {
+		lat1, lon1, lat2, lon2, lat3, lon3 := latitude(a), longitude(b), latitude(c), longitude(d), latitude(e), longitude(f)
+		direct := calculateDistance(lat1, lon1, lat3, lon3)
+		via := calculateDistance(lat1, lon1, lat2, lon2) + calculateDistance(lat2, lon2, lat3, lon3)
+		return direct <= via+1e-6
+	}
+	if err := quick.Check(triangle, nil); err != nil {
+		t.Error(err)
+	}
+
+	// Moving the same point a full turn around the globe changes nothing
+	wrapped := func(a, b, c, d uint32) bool {
+		lat1, lon1, lat2, lon2 := latitude(a), longitude(b), latitude(c), longitude(d)
+		return math.Abs(calculateDistance(lat1, lon1, lat2, lon2)-calculateDistance(lat1, lon1+360, lat2, lon2)) < 1e-6
+	}
+	if err := quick.Check(wrapped, nil); err != nil {
+		t.Error(err)
+	}
+
+	// Antipodal points, where rounding can push the haversine term past 1
+	antipodes := func(a, b uint32) bool {
+		lat, lon := latitude(a), longitude(b)
+		d := calculateDistance(lat, lon, -lat, lon+180)
+		return math.Abs(d-earthHalfCircumferenceKm) < 1e-3
+	}
+	if err := quick.Check(antipodes, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCalculateWeightedScore(t *testing.T) {
+	tests := []struct {
+		rating  float64
+		reviews int
+		want    float64
+	}{
+		{0, 0, 3.5}, // unrated places get the prior
+		{5, 0, 3.5},
+		{4, 15, 3.75}, // 15 reviews weigh as much as the prior
+		{5, 15, 4.25},
+		{1, 15, 2.25},
+		{4.8, 10000, 4.798},
+	}
+	for _, tt := range tests {
+		if got := calculateWeightedScore(tt.rating, tt.reviews); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("calculateWeightedScore(%.1f, %d) = %.4f, want %.4f", tt.rating, tt.reviews, got, tt.want)
+		}
+	}
+
+	// A perfect score from a handful of reviews ranks below a near perfect
+	// score from thousands
+	if few, many := calculateWeightedScore(5, 1), calculateWeightedScore(4.8, 10000); few >= many {
+		t.Errorf("5.0 with 1 review scores %.3f, 4.8 with 10000 reviews %.3f", few, many)
+	}
+}
+
+func TestCalculateWeightedScoreProperties(t *testing.T) {
+	// rating maps an arbitrary value onto 0-5 in steps of 0.1
+	rating := func(u uint8) float64 { return float64(u%51) / 10 }
+
+	// The score lies between the rating and the 3.5 prior
+	between := func(r uint8, reviews uint16) bool {
+		score := calculateWeightedScore(rating(r), int(reviews))
+		return score >= math.Min(rating(r), 3.5)-1e-9 && score <= math.Max(rating(r), 3.5)+1e-9
+	}
+	if err := quick.Check(between, nil); err != nil {
+		t.Error(err)
+	}
+
+	// More reviews move the score towards the rating, and a higher rating
+	// never scores lower
+	monotonic := func(r uint8, reviews uint16) bool {
+		R, v := rating(r), int(reviews)
+		score, more := calculateWeightedScore(R, v), calculateWeightedScore(R, v+1)
+		if math.Abs(more-R) > math.Abs(score-R)+1e-9 {
+			return false
+		}
+		return calculateWeightedScore(math.Min(R+0.1, 5), v) >= score
+	}
+	if err := quick.Check(monotonic, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSortRestaurantsByRatingProperties(t *testing.T) {
+	sorted := func(seed int64, n uint8) bool {
+		rng := rand.New(rand.NewSource(seed))
+		restaurants := make([]Restaurant, n%40)
+		for i := range restaurants {
+			// Few distinct values, so equal scores and their distance tie-break occur
+			restaurants[i] = Restaurant{
+				Name:        string(rune('A' + i)),
+				Rating:      float64(rng.Intn(3)) + 3,
+				ReviewCount: rng.Intn(3) * 10,
+				Distance:    float64(rng.Intn(5)) / 10,
+			}
+		}
+		before := make(map[string]bool)
+		for _, r := range restaurants {
+			before[r.Name] = true
+		}
+
+		sortRestaurantsByRating(restaurants)
+		for i, r := range restaurants {
+			delete(before, r.Name)
+			if i == 0 {
+				continue
+			}
+			prev := restaurants[i-1]
+			ps, s := calculateWeightedScore(prev.Rating, prev.ReviewCount), calculateWeightedScore(r.Rating, r.ReviewCount)
+			if ps < s || (ps == s && prev.Distance > r.Distance) {
+				return false
+			}
+		}
+		return len(before) == 0
+	}
+	if err := quick.Check(sorted, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeduplicateRestaurants(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    []Restaurant
+		names []string
+	}{
+		{"empty", nil, nil},
+		{
+			"same place from both providers keeps the first",
+			[]Restaurant{
+				{Name: "[GOOGLE] Café Cosmos", Latitude: 52.52001, Longitude: 13.40502, Rating: 4.5},
+				{Name: "[OSM] café cosmos ", Latitude: 52.52003, Longitude: 13.40498},
+			},
+			[]string{"[GOOGLE] Café Cosmos"},
+		},
+		{
+			"same name far apart",
+			[]Restaurant{
+				{Name: "Coffee Fellows", Latitude: 52.5200, Longitude: 13.4050},
+				{Name: "Coffee Fellows", Latitude: 52.5300, Longitude: 13.4050},
+			},
+			[]string{"Coffee Fellows", "Coffee Fellows"},
+		},
+		{
+			"different names at the same spot",
+			[]Restaurant{
+				{Name: "Food Court Sushi", Latitude: 52.52, Longitude: 13.405},
+				{Name: "Food Court Pizza", Latitude: 52.52, Longitude: 13.405},
+			},
+			[]string{"Food Court Sushi", "Food Court Pizza"},
+		},
+		{
+			"exact duplicates",
+			[]Restaurant{
+				{Name: "Marietta", Latitude: 52.54, Longitude: 13.41},
+				{Name: "Curry 36", Latitude: 52.49, Longitude: 13.39},
+				{Name: "Marietta", Latitude: 52.54, Longitude: 13.41},
+			},
+			[]string{"Marietta", "Curry 36"},
+		},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, r := range deduplicateRestaurants(tt.in) {
+			names = append(names, r.Name)
+		}
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("%s: %q, want %q", tt.name, names, tt.names)
+		}
+	}
+}
+
+func TestDeduplicateRestaurantsProperties(t *testing.T) {
+	prefixes := []string{"", "[GOOGLE] ", "[OSM] "}
+	names := []string{"Marietta", "marietta", "Curry 36", "Café Cosmos"}
+	generate := func(seed int64, n uint8) []Restaurant {
+		rng := rand.New(rand.NewSource(seed))
+		restaurants := make([]Restaurant, n%30)
+		for i := range restaurants {
+			restaurants[i] = Restaurant{
+				Name:      prefixes[rng.Intn(len(prefixes))] + names[rng.Intn(len(names))],
+				Latitude:  52.52 + float64(rng.Intn(3))*0.001,
+				Longitude: 13.405,
+				PlaceID:   fmt.Sprintf("place-%02d", i),
+			}
+		}
+		return restaurants
+	}
+
+	// The result keeps the first of each place in input order, one per key,
+	// and deduplicating again changes nothing
+	prop := func(seed int64, n uint8) bool {
+		in := generate(seed, n)
+		out := deduplicateRestaurants(in)
+		first := make(map[string]string)
+		for _, r := range in {
+			if _, ok := first[dedupKey(r)]; !ok {
+				first[dedupKey(r)] = r.PlaceID
+			}
+		}
+		if len(out) != len(first) {
+			return false
+		}
+		for i, r := range out {
+			if first[dedupKey(r)] != r.PlaceID || (i > 0 && out[i-1].PlaceID >= r.PlaceID) {
+				return false
+			}
+		}
+		return reflect.DeepEqual(deduplicateRestaurants(out), out)
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIsFoodRelatedPlace(t *testing.T) {
+	tests := []struct {
+		types []string
+		want  bool
+	}{
+		{nil, true}, // no types: not filtered
+		{[]string{"restaurant", "point_of_interest", "establishment"}, true},
+		{[]string{"point_of_interest", "cafe"}, true},
+		{[]string{"vegan_restaurant"}, true},
+		{[]string{"hot_pot_restaurant"}, true}, // unknown cuisine type
+		{[]string{"cat_cafe"}, true},
+		{[]string{"fine_dining_venue"}, true},
+		{[]string{"wine_bar"}, true},
+		{[]string{"FOOD_TRUCK"}, true},
+		{[]string{"lodging", "point_of_interest", "establishment"}, false},
+		{[]string{"gas_station", "convenience_store"}, false},
+		{[]string{"supermarket", "grocery_or_supermarket"}, false},
+		{[]string{"museum"}, false},
+		{[]string{}, true},
+	}
+	for _, tt := range tests {
+		if got := isFoodRelatedPlace(tt.types); got != tt.want {
+			t.Errorf("isFoodRelatedPlace(%q) = %v, want %v", tt.types, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Marietta", "Marietta"},
+		{"Curry 36", "Curry 36"},
+		{"Café & Bar", "Café & Bar"},
+		{"Bäckerei & Café Gnadenlos!", `Bäckerei & Café Gnadenlos\!`},
+		{"*Best* Pizza_Place", `\*Best\* Pizza\_Place`},
+		{"Joe's (Downtown) - No. 1", `Joe's \(Downtown\) \- No\. 1`},
+		{"[GOOGLE] Zur letzten Instanz", `\[GOOGLE\] Zur letzten Instanz`},
+		{"a~b`c>d#e+f=g|h{i}j", "a\\~b\\`c\\>d\\#e\\+f\\=g\\|h\\{i\\}j"},
+		{`back\slash`, `back\\slash`},
+		{`a\*`, `a\\\*`},
+	}
+	for _, tt := range tests {
+		if got := escapeMarkdownV2(tt.in); got != tt.want {
+			t.Errorf("escapeMarkdownV2(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := escapeMarkdown(tt.in); got != tt.want {
+			t.Errorf("escapeMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// markdownV2Special are the characters Telegram's MarkdownV2 requires escaped
+const markdownV2Special = "\\_*[]()~`>#+-=|{}.!"
+
+// checkMarkdownEscaped reports whether escaped has every special character
+// escaped and unescapes back to text
+func checkMarkdownEscaped(t *testing.T, text, escaped string) {
+	t.Helper()
+	var unescaped strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c == '\\' {
+			if i+1 == len(escaped) || !strings.ContainsRune(markdownV2Special, rune(escaped[i+1])) {
+				t.Fatalf("escape of %q = %q has a dangling backslash at %d", text, escaped, i)
+			}
+			i++
+			unescaped.WriteByte(escaped[i])
+			continue
+		}
+		if strings.IndexByte(markdownV2Special, c) >= 0 {
+			t.Fatalf("escape of %q = %q has an unescaped %q at %d", text, escaped, c, i)
+		}
+		unescaped.WriteByte(c)
+	}
+	if unescaped.String() != text {
+		t.Fatalf("escape of %q = %q unescapes to %q", text, escaped, unescaped.String())
+	}
+}
+
+func FuzzEscapeMarkdown(f *testing.F) {
+	for _, seed := range []string{"", "Marietta", "*Best* Pizza_Place", `a\*`, `\\`, "Joe's (Downtown) - No. 1", "Café Cosmos 🍕", "\xff"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		escaped := escapeMarkdownV2(text)
+		checkMarkdownEscaped(t, text, escaped)
+		if other := escapeMarkdown(text); other != escaped {
+			t.Fatalf("escapeMarkdown(%q) = %q, escapeMarkdownV2 gives %q", text, other, escaped)
+		}
+	})
+}
+
+func TestFormatTypeString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"restaurant", "Restaurant"},
+		{"fast_food", "Fast Food"},
+		{"ITALIAN_RESTAURANT", "Italian Restaurant"},
+		{"ice_cream", "Ice Cream"},
+		{"__leading__and trailing_", "Leading And Trailing"},
+		{"coffee_shop;vegan", "Coffee Shop;vegan"},
+		{"école_française", "École Française"},
+		{"ñandú", "Ñandú"},
+		{"_", ""},
+	}
+	for _, tt := range tests {
+		if got := formatTypeString(tt.in); got != tt.want {
+			t.Errorf("formatTypeString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatTypeStringProperties(t *testing.T) {
+	// The result has no underscores or repeated spaces, keeps valid UTF-8
+	// valid, and formatting it again changes nothing
+	prop := func(value string) bool {
+		got := formatTypeString(value)
+		if strings.Contains(got, "_") || strings.Contains(got, "  ") || got != strings.TrimSpace(got) {
+			return false
+		}
+		if utf8.ValidString(value) && !utf8.ValidString(got) {
+			return false
+		}
+		return formatTypeString(got) == got
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func FuzzParseSearchQuery(f *testing.F) {
+	for _, seed := range []string{
+		"lat=52.52&lon=13.405",
+		"lat=52.52&lon=13.405&page=2&limit=50&categories=cafe,+bar,&keyword=vegan",
+		"lat=1&lon=2&page=-4&limit=101",
+		"lat=1&lon=2&page=9223372036854775807&limit=100",
+		"bbox=13.40,52.51,13.42,52.52",
+		"bbox=1,2,3",
+		"q=Alexanderplatz",
+		"lat=NaN&lon=Inf",
+		"lat=1&lon=2&sort=rating&min_rating=4.5&open_now=true",
+		"lat=1&lon=2&min_rating=6",
+		"category=all&lat=0&lon=0",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, rawQuery string) {
+		q, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			t.Skip()
+		}
+		params, _, page, limit, apiErr := parseSearchQuery(q)
+		if apiErr != nil {
+			if apiErr.Code == "" || apiErr.Message == "" {
+				t.Fatalf("incomplete error %+v for %q", apiErr, rawQuery)
+			}
+			return
+		}
+		if page < 1 || limit < 1 || limit > 100 {
+			t.Fatalf("page %d, limit %d for %q", page, limit, rawQuery)
+		}
+		if params.Area == nil && params.Place == "" && q.Get("lat") == "" {
+			t.Fatalf("no location for %q: %+v", rawQuery, params)
+		}
+		if len(params.Place) > maxGeocodeQueryLength {
+			t.Fatalf("place of %d characters accepted", len(params.Place))
+		}
+		for _, c := range params.Categories {
+			if c == "" || strings.ContainsAny(string(c), ",") || strings.TrimSpace(string(c)) != string(c) {
+				t.Fatalf("category %q from %q", c, rawQuery)
+			}
+		}
+
+		// The handler clamps the page to the results, so the offset stays in range
+		for _, n := range []int{0, 1, 45} {
+			result := paginateResults("fuzz", 1, makeRestaurants(n), SearchStats{}, ResultFilters{}, (clampPage(page, limit, n)-1)*limit, limit)
+			if len(result.Restaurants) > limit || result.Pagination.Page < 1 || result.Pagination.Page > result.Pagination.TotalPages {
+				t.Fatalf("page %d, limit %d of %d results: %+v", page, limit, n, result.Pagination)
+			}
+		}
+	})
+}
+
+// googlePlacesJSON returns a Nearby Search response with n cafes named prefix 1..n
+func googlePlacesJSON(prefix string, n int, nextPageToken string) string {
+	var results []string
+	for i := 1; i <= n; i++ {
+		results = append(results, fmt.Sprintf(`{"name":"%s %d","place_id":"%s-%d","types":["cafe","food"],"rating":4.5,"user_ratings_total":100,`+
+			`"geometry":{"location":{"lat":52.52,"lng":%f}}}`, prefix, i, prefix, i, 13.405+float64(i)/1000))
+	}
+	return fmt.Sprintf(`{"status":"OK","results":[%s],"next_page_token":%q}`, strings.Join(results, ","), nextPageToken)
+}
+
+// newGoogleTestBot returns a Google-only bot whose Maps client talks to handler
+func newGoogleTestBot(t *testing.T, handler http.HandlerFunc) *RestaurantBot {
+	t.Helper()
+	pageTokenDelay = time.Millisecond
+	t.Cleanup(func() { pageTokenDelay = 2 * time.Second })
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	bot, err := NewRestaurantBot("", "test-key", "google")
+	if err != nil {
+		t.Fatalf("NewRestaurantBot: %v", err)
+	}
+	if bot.mapsClient, err = maps.NewClient(maps.WithAPIKey("test-key"), maps.WithBaseURL(upstream.URL)); err != nil {
+		t.Fatalf("maps.NewClient: %v", err)
+	}
+	return bot
+}
+
+func TestFinalizeDegradation(t *testing.T) {
+	ok := func(p string) ProviderOutcome { return ProviderOutcome{Provider: p, Status: OutcomeOK} }
+	failed := func(p, status string) ProviderOutcome { return ProviderOutcome{Provider: p, Status: status} }
+	tests := []struct {
+		name         string
+		stats        SearchStats
+		wantDegraded bool
+		wantNotice   string
+	}{
+		{"all ok", SearchStats{Providers: []ProviderOutcome{ok("google"), ok("osm")}, SubQueries: []ProviderOutcome{ok("google:cafe")}}, false, ""},
+		{"OSM failed under both", SearchStats{Providers: []ProviderOutcome{ok("google"), failed("osm", OutcomeTimeout)}}, true,
+			"OpenStreetMap unavailable, showing Google Maps results only"},
+		{"Google failed under both", SearchStats{Providers: []ProviderOutcome{failed("google", OutcomeError), ok("osm")}}, true,
+			"Google Maps unavailable, showing OpenStreetMap results only"},
+		{"sub-query failed", SearchStats{Providers: []ProviderOutcome{ok("google")}, SubQueries: []ProviderOutcome{ok("google:cafe"), failed("google:bar", OutcomeError), ok("google:text:food")}}, true,
+			"1 of 3 Google searches failed, results may be incomplete"},
+		{"budget cut a sub-query short", SearchStats{Providers: []ProviderOutcome{ok("google")}, SubQueries: []ProviderOutcome{failed("google:cafe", OutcomeError)},
+			ProviderErrors: []ProviderError{newBudgetProviderError("google:cafe")}}, true,
+			"Google Maps budget reached during the search, results may be incomplete"},
+		{"budget reached before the search", SearchStats{Providers: []ProviderOutcome{ok("osm")}, BudgetExceeded: true}, true,
+			"Google Maps budget reached, showing OpenStreetMap results only"},
+	}
+	for _, tt := range tests {
+		stats := tt.stats
+		stats.Degraded, stats.Notice = !tt.wantDegraded, "stale"
+		stats.finalizeDegradation()
+		if stats.Degraded != tt.wantDegraded || stats.Notice != tt.wantNotice {
+			t.Errorf("%s: degraded=%v %q, want %v %q", tt.name, stats.Degraded, stats.Notice, tt.wantDegraded, tt.wantNotice)
+		}
+	}
+}
+
+func TestGoogleSubQueryFailuresDegradeTheSearch(t *testing.T) {
+	// Cafes fail on their third page, bars on their first
+	bot := newGoogleTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case q.Get("pagetoken") == "cafe-2":
+			fmt.Fprint(w, googlePlacesJSON("Cafe B", 2, "cafe-3"))
+		case q.Get("pagetoken") == "cafe-3", q.Get("type") == "bar":
+			fmt.Fprint(w, `{"status":"UNKNOWN_ERROR"}`)
+		default:
+			fmt.Fprint(w, googlePlacesJSON("Cafe A", 3, "cafe-2"))
+		}
+	})
+
+	result, err := bot.Search(context.Background(), SearchParams{Lat: 52.52, Lon: 13.405, Categories: []FoodCategory{CategoryCafe, CategoryBar}})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	stats := result.Stats
+	if len(result.Restaurants) != 5 || stats.GooglePagesSearched != 2 {
+		t.Errorf("%d restaurants from %d pages, want the 5 from the first two cafe pages", len(result.Restaurants), stats.GooglePagesSearched)
+	}
+	statuses := map[string]string{}
+	for _, q := range stats.SubQueries {
+		statuses[q.Provider] = q.Status
+	}
+	if statuses["google:cafe"] != OutcomeError || statuses["google:bar"] != OutcomeError {
+		t.Errorf("sub-queries = %+v", stats.SubQueries)
+	}
+	if len(stats.ProviderErrors) != 2 {
+		t.Errorf("provider errors = %+v", stats.ProviderErrors)
+	}
+	if !stats.Degraded || stats.Notice != "2 of 2 Google searches failed, results may be incomplete" {
+		t.Errorf("degraded=%v %q", stats.Degraded, stats.Notice)
+	}
+
+	// A single-category search reports its lost page the same way
+	result, err = bot.Search(context.Background(), SearchParams{Lat: 52.52, Lon: 13.405, Categories: []FoodCategory{CategoryCafe}})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(result.Restaurants) != 5 || len(result.Stats.SubQueries) != 1 || result.Stats.SubQueries[0].Provider != "google:cafe" ||
+		result.Stats.SubQueries[0].Results != 5 || !result.Stats.Degraded {
+		t.Errorf("single category: %d restaurants, stats %+v", len(result.Restaurants), result.Stats)
+	}
+}
+
+func TestGoogleBudgetCutsPaginationShort(t *testing.T) {
+	bot := newGoogleTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, googlePlacesJSON("Cafe "+r.URL.Query().Get("pagetoken"), 2, "next"))
+	})
+	// The first page spends the whole budget
+	costs, err := NewCostLedger("", googleSKUPrices[SKUNearbySearch], 0)
+	if err != nil {
+		t.Fatalf("NewCostLedger: %v", err)
+	}
+	bot.costs = costs
+
+	result, err := bot.Search(context.Background(), SearchParams{Lat: 52.52, Lon: 13.405, Categories: []FoodCategory{CategoryCafe}})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	stats := result.Stats
+	if len(result.Restaurants) != 2 || stats.GooglePagesSearched != 1 || stats.BudgetExceeded {
+		t.Errorf("%d restaurants, stats %+v", len(result.Restaurants), stats)
+	}
+	if len(stats.ProviderErrors) != 1 || stats.ProviderErrors[0].Code != ProviderErrBudget || stats.ProviderErrors[0].Provider != "google:cafe" {
+		t.Errorf("provider errors = %+v", stats.ProviderErrors)
+	}
+	if !stats.Degraded || !strings.Contains(stats.Notice, "budget reached during the search") {
+		t.Errorf("degraded=%v %q", stats.Degraded, stats.Notice)
+	}
+}
+
+func TestGoogleBudgetSkipsLaterSubQueries(t *testing.T) {
+	var requests atomic.Int32
+	bot := newGoogleTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, googlePlacesJSON("Cafe", 2, ""))
+	})
+	costs, err := NewCostLedger("", googleSKUPrices[SKUNearbySearch], 0)
+	if err != nil {
+		t.Fatalf("NewCostLedger: %v", err)
+	}
+	bot.costs = costs
+	// Another search spends the budget after this one passed its own check
+	costs.Record(SKUNearbySearch)
+
+	categories := []FoodCategory{CategoryCafe, CategoryBar, CategoryRestaurant}
+	result, err := bot.findNearbyRestaurantsGoogleMultipleWithStats(context.Background(), 52.52, 13.405, defaultSearchRadius, categories, "vegan")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	stats := result.Stats
+	if n := requests.Load(); n != 0 {
+		t.Errorf("%d Google requests after the budget was reached, want 0", n)
+	}
+	if stats.GoogleSearchQueries != 5 || len(stats.SubQueries) != 5 || len(stats.ProviderErrors) != 5 {
+		t.Fatalf("%d queries, sub-queries %+v, errors %+v", stats.GoogleSearchQueries, stats.SubQueries, stats.ProviderErrors)
+	}
+	for _, e := range stats.ProviderErrors {
+		if e.Code != ProviderErrBudget || !strings.HasPrefix(e.Provider, "google:") {
+			t.Errorf("provider error %+v, want a budget error per sub-query", e)
+		}
+	}
+	stats.finalizeDegradation()
+	if !stats.Degraded || !strings.Contains(stats.Notice, "budget reached during the search") {
+		t.Errorf("degraded=%v %q", stats.Degraded, stats.Notice)
+	}
+}
+
+func TestLocationCacheDegradedTTL(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	cache := NewLocationCache()
+	cache.now = clock.Now
+
+	cache.Set(52.52, 13.405, makeRestaurants(2), SearchStats{Degraded: true, Notice: "OSM failed"})
+	cache.Set(48.8566, 2.3522, makeRestaurants(3), SearchStats{})
+
+	clock.Advance(defaultDegradedCacheTTL - time.Second)
+	if _, stats, found := cache.Get(52.52, 13.405); !found || !stats.Degraded || !stats.CachedResult {
+		t.Fatalf("degraded entry within its TTL: found=%v stats=%+v", found, stats)
+	}
+	clock.Advance(2 * time.Second)
+	if _, _, found := cache.Get(52.52, 13.405); found {
+		t.Error("degraded entry served after the degraded TTL")
+	}
+	if _, _, found := cache.Get(48.8566, 2.3522); !found {
+		t.Error("complete entry expired with the degraded TTL")
+	}
+
+	// A complete search replaces the partial one and gets the full TTL
+	cache.Set(52.52, 13.405, makeRestaurants(4), SearchStats{})
+	clock.Advance(defaultDegradedCacheTTL * 2)
+	if restaurants, stats, found := cache.Get(52.52, 13.405); !found || len(restaurants) != 4 || stats.Degraded {
+		t.Errorf("complete entry: found=%v, %d restaurants", found, len(restaurants))
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes every exported metric
+const metricsNamespace = "restaurantbot"
+
+// Search entry points
+const (
+	EntryHTTP     = "http"
+	EntryStream   = "stream"
+	EntryRoute    = "route"
+	EntryTelegram = "telegram"
+)
+
+// Provider endpoint types
+const (
+	EndpointNearbySearch    = "nearby_search"
+	EndpointTextSearch      = "text_search"
+	EndpointPlaceDetails    = "place_details"
+	EndpointGoogleGeocoding = "google_geocoding"
+	EndpointPhoto           = "photo"
+	EndpointOverpass        = "overpass"
+	EndpointNominatim       = "nominatim"
+)
+
+// providerLatencyBuckets cover fast cache-like answers up to the slowest Overpass queries
+var providerLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30}
+
+// Metrics holds the Prometheus collectors of one bot. Each bot has its own
+// registry so tests and multiple instances do not share counters.
+type Metrics struct {
+	registry         *prometheus.Registry
+	searches         *prometheus.CounterVec
+	providerRequests *prometheus.CounterVec
+	providerLatency  *prometheus.HistogramVec
+	photos           *prometheus.CounterVec
+}
+
+// NewMetrics creates the collectors for rb, including cache and spend metrics
+// that are read from rb at scrape time
+func NewMetrics(rb *RestaurantBot) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		searches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "searches_total",
+			Help:      "Restaurant searches by entry point (http, stream, route, telegram), cached or not.",
+		}, []string{"entry_point"}),
+		providerRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "provider_requests_total",
+			Help:      "Upstream provider calls by endpoint type and status (ok, error, timeout, canceled).",
+		}, []string{"endpoint", "status"}),
+		providerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "provider_request_duration_seconds",
+			Help:      "Upstream provider call latency by endpoint type.",
+			Buckets:   providerLatencyBuckets,
+		}, []string{"endpoint"}),
+		photos: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "photos_served_total",
+			Help:      "Photos served by source: disk (stored), api (fetched from Google) or generic (placeholder).",
+		}, []string{"source"}),
+	}
+	m.registry.MustRegister(
+		m.searches, m.providerRequests, m.providerLatency, m.photos,
+		&botCollector{rb: rb},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// SearchStarted counts one search request from entryPoint
+func (m *Metrics) SearchStarted(entryPoint string) {
+	if m == nil {
+		return
+	}
+	m.searches.WithLabelValues(entryPoint).Inc()
+}
+
+// ObserveProviderCall records one upstream call and its outcome
+func (m *Metrics) ObserveProviderCall(endpoint string, latency time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.providerRequests.WithLabelValues(endpoint, providerCallStatus(err)).Inc()
+	m.providerLatency.WithLabelValues(endpoint).Observe(latency.Seconds())
+}
+
+// PhotoServed counts a photo response by its X-Photo-Source
+func (m *Metrics) PhotoServed(source string) {
+	if m == nil || source == "" {
+		return
+	}
+	m.photos.WithLabelValues(source).Inc()
+}
+
+// providerCallStatus classifies a provider error for the status label
+func providerCallStatus(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case isTimeoutError(err):
+		return OutcomeTimeout
+	default:
+		return OutcomeError
+	}
+}
+
+// Transport returns base (or http.DefaultTransport) instrumented as endpoint.
+// Latency is measured up to the response headers; 4xx/5xx count as errors.
+func (m *Metrics) Transport(endpoint string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if m == nil {
+		return base
+	}
+	return &instrumentedTransport{metrics: m, endpoint: endpoint, base: base}
+}
+
+type instrumentedTransport struct {
+	metrics  *Metrics
+	endpoint string
+	base     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	callErr := err
+	if err == nil && resp.StatusCode >= 400 {
+		callErr = fmt.Errorf("status %d", resp.StatusCode)
+	}
+	t.metrics.ObserveProviderCall(t.endpoint, time.Since(start), callErr)
+	return resp, err
+}
+
+// cacheCounters counts lookups and evictions (expired or over capacity) of an in-memory cache
+type cacheCounters struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+// lookup counts a cache hit or miss
+func (cc *cacheCounters) lookup(hit bool) {
+	if hit {
+		cc.hits.Add(1)
+	} else {
+		cc.misses.Add(1)
+	}
+}
+
+// Counts returns the lookups and evictions since start
+func (cc *cacheCounters) Counts() (hits, misses, evictions int64) {
+	return cc.hits.Load(), cc.misses.Load(), cc.evictions.Load()
+}
+
+var (
+	cacheRequestsDesc = prometheus.NewDesc(metricsNamespace+"_cache_requests_total",
+		"Cache lookups by cache and result (hit, miss).", []string{"cache", "result"}, nil)
+	cacheEvictionsDesc = prometheus.NewDesc(metricsNamespace+"_cache_evictions_total",
+		"Entries removed because they expired or the cache was full.", []string{"cache"}, nil)
+	cacheEntriesDesc = prometheus.NewDesc(metricsNamespace+"_cache_entries",
+		"Entries currently held, including expired ones not yet cleaned up.", []string{"cache"}, nil)
+	googleRequestsDesc = prometheus.NewDesc(metricsNamespace+"_google_billable_requests_total",
+		"Billable Google requests since start by SKU.", []string{"sku"}, nil)
+	googleSpendDesc = prometheus.NewDesc(metricsNamespace+"_google_spend_usd_total",
+		"Estimated Google spend in USD since start by SKU.", []string{"sku"}, nil)
+	googleSpendPeriodDesc = prometheus.NewDesc(metricsNamespace+"_google_spend_usd",
+		"Estimated Google spend in USD for the current UTC day or month, including earlier runs.", []string{"period"}, nil)
+	googleBudgetExceededDesc = prometheus.NewDesc(metricsNamespace+"_google_budget_exceeded",
+		"1 while a Google spend cap is reached and searches fall back to OpenStreetMap.", nil, nil)
+)
+
+// botCollector reads cache and spend counters the bot already keeps
+type botCollector struct {
+	rb *RestaurantBot
+}
+
+// cacheSample is one cache's counters and size at scrape time
+type cacheSample struct {
+	name     string
+	counters *cacheCounters
+	entries  int
+}
+
+// Describe implements prometheus.Collector
+func (c *botCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheRequestsDesc
+	ch <- cacheEvictionsDesc
+	ch <- cacheEntriesDesc
+	ch <- googleRequestsDesc
+	ch <- googleSpendDesc
+	ch <- googleSpendPeriodDesc
+	ch <- googleBudgetExceededDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *botCollector) Collect(ch chan<- prometheus.Metric) {
+	rb := c.rb
+	caches := []cacheSample{
+		{"locations", &rb.cache.counters, rb.cache.Len()},
+		{"place_details", &rb.details.counters, rb.details.Len()},
+		{"snapshots", &rb.snapshots.counters, rb.snapshots.Len()},
+	}
+	if cg, ok := rb.geocoder.(*CachingGeocoder); ok {
+		caches = append(caches, cacheSample{"geocodes", &cg.counters, cg.Len()})
+	}
+	for _, cache := range caches {
+		hits, misses, evictions := cache.counters.Counts()
+		ch <- prometheus.MustNewConstMetric(cacheRequestsDesc, prometheus.CounterValue, float64(hits), cache.name, "hit")
+		ch <- prometheus.MustNewConstMetric(cacheRequestsDesc, prometheus.CounterValue, float64(misses), cache.name, "miss")
+		ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(evictions), cache.name)
+		ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(cache.entries), cache.name)
+	}
+
+	for sku, count := range rb.costs.SessionRequests() {
+		ch <- prometheus.MustNewConstMetric(googleRequestsDesc, prometheus.CounterValue, float64(count), string(sku))
+		ch <- prometheus.MustNewConstMetric(googleSpendDesc, prometheus.CounterValue, float64(count)*googleSKUPrices[sku], string(sku))
+	}
+	report := rb.costs.Report()
+	ch <- prometheus.MustNewConstMetric(googleSpendPeriodDesc, prometheus.GaugeValue, report.Today.CostUSD, "day")
+	ch <- prometheus.MustNewConstMetric(googleSpendPeriodDesc, prometheus.GaugeValue, report.MonthToDateUSD, "month")
+	exceeded := 0.0
+	if report.BudgetExceeded {
+		exceeded = 1
+	}
+	ch <- prometheus.MustNewConstMetric(googleBudgetExceededDesc, prometheus.GaugeValue, exceeded)
+}
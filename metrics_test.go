@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape returns the /metrics exposition of server
+func scrape(t *testing.T, server *Server) string {
+	t.Helper()
+	rec := serve(server, "GET", "/metrics", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/metrics status %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+// expectMetrics fails for every line that is missing from the exposition
+func expectMetrics(t *testing.T, exposition string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(exposition, line+"\n") {
+			t.Errorf("missing %q", line)
+		}
+	}
+}
+
+func TestMetricsSearchesAndCaches(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(5)}
+	server := newTestServer(t, search)
+
+	serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405", "")
+	serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405", "")
+	serve(server, "GET", "/api/v1/restaurants/stream?lat=52.52&lon=13.405", "")
+	server.costs.Record(SKUNearbySearch)
+	server.costs.Record(SKUNearbySearch)
+
+	expectMetrics(t, scrape(t, server),
+		`restaurantbot_searches_total{entry_point="http"} 2`,
+		`restaurantbot_searches_total{entry_point="stream"} 1`,
+		`restaurantbot_cache_requests_total{cache="locations",result="hit"} 2`,
+		`restaurantbot_cache_requests_total{cache="locations",result="miss"} 1`,
+		`restaurantbot_cache_entries{cache="locations"} 1`,
+		`restaurantbot_google_billable_requests_total{sku="nearby_search"} 2`,
+		`restaurantbot_google_spend_usd_total{sku="nearby_search"} 0.064`,
+		`restaurantbot_google_spend_usd{period="day"} 0.064`,
+		`restaurantbot_google_budget_exceeded 0`,
+	)
+}
+
+func TestMetricsPhotosAndProviderCalls(t *testing.T) {
+	photoAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("photoreference") == "broken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(strings.Repeat("j", 2000)))
+	}))
+	defer photoAPI.Close()
+
+	server := newTestServer(t, &fakeSearch{})
+	server.photoAPIURL = photoAPI.URL
+	server.mapsAPIKey = "test-key"
+
+	serve(server, "GET", "/api/v1/photo?place_id=abc&photo_reference=ref", "")
+	serve(server, "GET", "/api/v1/photo?place_id=abc&photo_reference=ref", "")
+	serve(server, "GET", "/api/v1/photo?place_id=bad&photo_reference=broken", "")
+	serve(server, "GET", "/api/v1/photo?place_id=none&photo_reference="+genericPhotoReference, "")
+	serve(server, "GET", "/api/v1/photo", "") // invalid: not counted
+
+	expectMetrics(t, scrape(t, server),
+		`restaurantbot_photos_served_total{source="api"} 1`,
+		`restaurantbot_photos_served_total{source="disk"} 1`,
+		`restaurantbot_photos_served_total{source="generic"} 2`,
+		`restaurantbot_provider_requests_total{endpoint="photo",status="ok"} 1`,
+		`restaurantbot_provider_requests_total{endpoint="photo",status="error"} 1`,
+		`restaurantbot_provider_request_duration_seconds_count{endpoint="photo"} 2`,
+	)
+}
+
+func TestMetricsAreNotSharedBetweenBots(t *testing.T) {
+	first := newTestServer(t, &fakeSearch{})
+	second := newTestServer(t, &fakeSearch{})
+	first.metrics.SearchStarted(EntryTelegram)
+
+	if strings.Contains(scrape(t, second), `entry_point="telegram"`) {
+		t.Error("a search on one bot showed up in another bot's metrics")
+	}
+}
+
+func TestMetricsRequireAuthentication(t *testing.T) {
+	server := newTestServer(t, &fakeSearch{})
+	server.auth = NewAPIKeyAuth([]APIKey{{Name: "partner", Key: "secret"}}, false)
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without a metrics token, /metrics takes an API key like /api
+	if rec := get("", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no key: %d", rec.Code)
+	}
+	if rec := get("X-API-Key", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("API key: %d", rec.Code)
+	}
+
+	// With one, only the bearer token is accepted
+	server.metricsToken = "scrape-token"
+	for _, tt := range []struct{ header, value string }{
+		{"", ""},
+		{"X-API-Key", "secret"},
+		{"Authorization", "Bearer wrong"},
+		{"Authorization", "scrape-token"},
+	} {
+		rec := get(tt.header, tt.value)
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" || decodeError(t, rec).Code != ErrCodeUnauthorized {
+			t.Errorf("%s %q: %d %s", tt.header, tt.value, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := get("Authorization", "Bearer scrape-token"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "restaurantbot_") {
+		t.Errorf("bearer token: %d", rec.Code)
+	}
+}
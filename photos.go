@@ -0,0 +1,204 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PhotoStore keeps place photos by file name (e.g. "<place_id>.jpg") so each
+// photo is only fetched from Google once
+type PhotoStore interface {
+	// Get returns a stored, non-empty photo and when it was stored
+	Get(name string) ([]byte, time.Time, bool)
+	Put(name string, data []byte) error
+	// Usage returns how many photos are stored and their total size
+	Usage() (files int, bytes int64, err error)
+	// Writable returns an error if photos can't currently be stored
+	Writable() error
+}
+
+// DiskPhotoStore stores photos as files in a directory (permanent storage)
+type DiskPhotoStore struct {
+	dir string
+
+	// Usage is counted from disk once, then kept up to date by Put and Remove
+	mu      sync.Mutex
+	counted time.Time // zero until the directory has been counted
+	files   int
+	bytes   int64
+}
+
+// photoTempPrefix marks photos that are still being written
+const photoTempPrefix = ".tmp-"
+
+// photoUsageRecount is how often Usage counts the directory again, to pick up
+// photos removed by "photos gc" in another process
+const photoUsageRecount = 10 * time.Minute
+
+// NewDiskPhotoStore creates a store in dir; the directory is created on first write.
+// Temp files left behind by an interrupted write are removed.
+func NewDiskPhotoStore(dir string) *DiskPhotoStore {
+	stale, _ := filepath.Glob(filepath.Join(dir, photoTempPrefix+"*"))
+	for _, path := range stale {
+		if err := os.Remove(path); err == nil {
+			slog.Info("[PHOTO][DISK] Removed partial write", "file", filepath.Base(path))
+		}
+	}
+	return &DiskPhotoStore{dir: dir}
+}
+
+// Get reads a photo from disk
+func (ds *DiskPhotoStore) Get(name string) ([]byte, time.Time, bool) {
+	path := filepath.Join(ds.dir, name)
+	info, err := os.Stat(path)
+	if err != nil || info.Size() == 0 {
+		return nil, time.Time{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Error("[PHOTO][DISK] Failed to read photo", "file", name, "error", err)
+		return nil, time.Time{}, false
+	}
+	return data, info.ModTime(), true
+}
+
+// Put writes a photo to disk, creating the directory if needed. The photo is
+// written to a temp file and renamed into place, so readers (and restarts)
+// never see a half-written file.
+func (ds *DiskPhotoStore) Put(name string, data []byte) error {
+	if err := os.MkdirAll(ds.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(ds.dir, photoTempPrefix+name+"-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, 0644)
+	}
+	path := filepath.Join(ds.dir, name)
+	previous, statErr := os.Stat(path)
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if statErr == nil {
+		ds.addUsage(0, int64(len(data))-previous.Size())
+	} else {
+		ds.addUsage(1, int64(len(data)))
+	}
+	return nil
+}
+
+// addUsage adjusts the counted usage, if the directory has been counted yet
+func (ds *DiskPhotoStore) addUsage(files int, bytes int64) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if !ds.counted.IsZero() {
+		ds.files += files
+		ds.bytes += bytes
+	}
+}
+
+// Usage returns the number and total size of stored photos. The directory is
+// only listed on the first call and every photoUsageRecount after that; a
+// missing directory is empty.
+func (ds *DiskPhotoStore) Usage() (int, int64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if !ds.counted.IsZero() && time.Since(ds.counted) < photoUsageRecount {
+		return ds.files, ds.bytes, nil
+	}
+	list, err := ds.List()
+	if err != nil {
+		return 0, 0, err
+	}
+	ds.files, ds.bytes = 0, 0
+	for _, f := range list {
+		if f.Partial {
+			continue
+		}
+		ds.files++
+		ds.bytes += f.Size
+	}
+	ds.counted = time.Now()
+	return ds.files, ds.bytes, nil
+}
+
+// Writable checks that a photo could be stored by creating and removing an
+// empty temp file in the directory
+func (ds *DiskPhotoStore) Writable() error {
+	if err := os.MkdirAll(ds.dir, 0755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(ds.dir, photoTempPrefix+"probe-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// PhotoFile is a file in the photo directory
+type PhotoFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	Partial bool // a temp file from a write in progress or interrupted
+}
+
+// List returns the files in the directory; a missing directory is empty
+func (ds *DiskPhotoStore) List() ([]PhotoFile, error) {
+	entries, err := os.ReadDir(ds.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []PhotoFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, PhotoFile{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Partial: strings.HasPrefix(entry.Name(), photoTempPrefix),
+		})
+	}
+	return files, nil
+}
+
+// Remove deletes a file from the directory
+func (ds *DiskPhotoStore) Remove(name string) error {
+	path := filepath.Join(ds.dir, name)
+	info, statErr := os.Stat(path)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if statErr == nil && !strings.HasPrefix(name, photoTempPrefix) {
+		ds.addUsage(-1, -info.Size())
+	}
+	return nil
+}
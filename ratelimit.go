@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCachedRatePerMinute = 60.0 // Requests per minute that may be answered from cache
+	defaultCachedBurst         = 20
+	defaultSearchRatePerMinute = 4.0 // Fresh provider searches per minute (each may cost ~90 Google requests)
+	defaultSearchBurst         = 3
+	defaultRouteRatePerMinute  = 12.0 // Uncached route samples per minute, a budget of their own
+	defaultRouteBurst          = maxRouteSamples
+	rateLimiterIdleTTL         = 30 * time.Minute // Buckets unused for this long are dropped
+)
+
+// defaultTrustedProxyHeaders are consulted (in order) for the client IP when
+// the direct peer is a trusted proxy
+var defaultTrustedProxyHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter is a keyed token-bucket limiter. A nil *RateLimiter allows everything.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64 // bucket capacity
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perMinute requests per key with the given burst.
+// Returns nil (unlimited) if perMinute is 0.
+func NewRateLimiter(perMinute float64, burst int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	rl := &RateLimiter{
+		rate:    perMinute / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+	// Start cleanup goroutine
+	go rl.cleanup()
+	return rl
+}
+
+// Allow takes one token from key's bucket. If none is available it returns
+// false and how long the caller should wait before retrying.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	return rl.AllowN(key, 1)
+}
+
+// AllowN takes n tokens from key's bucket at once, or none if fewer are
+// available. n must not exceed the burst, or it is never allowed.
+func (rl *RateLimiter) AllowN(key string, n int) (bool, time.Duration) {
+	if rl == nil {
+		return true, 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, lastSeen: now}
+		rl.buckets[key] = b
+	} else {
+		// Refill based on elapsed time
+		elapsed := now.Sub(b.lastSeen).Seconds()
+		b.tokens = math.Min(rl.burst, b.tokens+elapsed*rl.rate)
+		b.lastSeen = now
+	}
+
+	if b.tokens >= float64(n) {
+		b.tokens -= float64(n)
+		return true, 0
+	}
+
+	wait := time.Duration((float64(n) - b.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+// Wait blocks until a token is available in key's bucket and takes it, or
+// returns ctx's error if ctx is done first
+func (rl *RateLimiter) Wait(ctx context.Context, key string) error {
+	for {
+		ok, wait := rl.Allow(key)
+		if ok {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Burst returns the most tokens a key can hold, 0 if unlimited
+func (rl *RateLimiter) Burst() int {
+	if rl == nil {
+		return 0
+	}
+	return int(rl.burst)
+}
+
+// cleanup removes buckets that have been idle long enough to be full again
+func (rl *RateLimiter) cleanup() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		rl.mu.Lock()
+		now := rl.now()
+		for key, b := range rl.buckets {
+			if now.Sub(b.lastSeen) > rateLimiterIdleTTL {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// ClientRateLimits holds the per-client limits shared by the HTTP API and Telegram bot.
+// Every request consumes a "cached" token; requests that miss the cache and trigger a
+// provider search additionally consume a "search" token. Uncached route samples
+// consume "route" tokens instead, so a long route fits with the default limits.
+type ClientRateLimits struct {
+	cached         *RateLimiter
+	search         *RateLimiter
+	route          *RateLimiter
+	trustedProxies []*net.IPNet
+	proxyHeaders   []string
+}
+
+// AllowRequest checks the limit applied to every request (cache hits included)
+func (crl *ClientRateLimits) AllowRequest(key string) (bool, time.Duration) {
+	if crl == nil {
+		return true, 0
+	}
+	return crl.cached.Allow(key)
+}
+
+// AllowSearch checks the stricter limit applied to fresh provider searches
+func (crl *ClientRateLimits) AllowSearch(key string) (bool, time.Duration) {
+	if crl == nil {
+		return true, 0
+	}
+	return crl.search.Allow(key)
+}
+
+// AllowSearches checks the search limit for n fresh provider searches made
+// by one request, such as the point searches along a route
+func (crl *ClientRateLimits) AllowSearches(key string, n int) (bool, time.Duration) {
+	if crl == nil {
+		return true, 0
+	}
+	return crl.search.AllowN(key, n)
+}
+
+// MaxSearches returns how many fresh searches one request may make, 0 if unlimited
+func (crl *ClientRateLimits) MaxSearches() int {
+	if crl == nil {
+		return 0
+	}
+	return crl.search.Burst()
+}
+
+// AllowRouteSamples checks the route limit for n uncached route samples
+func (crl *ClientRateLimits) AllowRouteSamples(key string, n int) (bool, time.Duration) {
+	if crl == nil {
+		return true, 0
+	}
+	return crl.route.AllowN(key, n)
+}
+
+// MaxRouteSamples returns how many uncached samples one route may search, 0 if unlimited
+func (crl *ClientRateLimits) MaxRouteSamples() int {
+	if crl == nil {
+		return 0
+	}
+	return crl.route.Burst()
+}
+
+// HTTPClientKey returns the rate limit key for an HTTP request
+func (crl *ClientRateLimits) HTTPClientKey(r *http.Request) string {
+	var proxies []*net.IPNet
+	headers := defaultTrustedProxyHeaders
+	if crl != nil {
+		proxies = crl.trustedProxies
+		headers = crl.proxyHeaders
+	}
+	return "ip:" + clientIP(r, proxies, headers)
+}
+
+// TelegramClientKey returns the rate limit key for a Telegram chat
+func TelegramClientKey(chatID int64) string {
+	return "chat:" + strconv.FormatInt(chatID, 10)
+}
+
+// clientIP determines the originating client IP. Forwarding headers are only
+// honoured when the direct peer is a trusted proxy; X-Forwarded-For is walked
+// right to left, skipping further trusted proxies.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet, headers []string) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+
+	if !isTrustedProxy(peer, trustedProxies) {
+		return peer
+	}
+
+	for _, header := range headers {
+		value := r.Header.Get(header)
+		if value == "" {
+			continue
+		}
+		hops := strings.Split(value, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				continue
+			}
+			if i > 0 && isTrustedProxy(hop, trustedProxies) {
+				continue
+			}
+			return hop
+		}
+	}
+	return peer
+}
+
+func isTrustedProxy(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a comma-separated list of IPs and CIDRs
+func parseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			if ip := net.ParseIP(part); ip != nil && ip.To4() != nil {
+				part += "/32"
+			} else {
+				part += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", part, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// RateLimitConfig sets the per-client limits. Every request uses the cached
+// limit; fresh provider searches also use the search limit, and uncached route
+// samples the route limit. A rate of 0 disables that limit.
+type RateLimitConfig struct {
+	CachedPerMinute     float64
+	CachedBurst         int
+	SearchPerMinute     float64
+	SearchBurst         int
+	RoutePerMinute      float64
+	RouteBurst          int
+	TrustedProxies      string // comma-separated IPs/CIDRs whose forwarding headers are trusted
+	TrustedProxyHeaders string // comma-separated header names, consulted in order
+}
+
+// NewClientRateLimits builds the per-client limits for cfg
+func NewClientRateLimits(cfg RateLimitConfig) (*ClientRateLimits, error) {
+	proxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	var headers []string
+	for _, h := range strings.Split(cfg.TrustedProxyHeaders, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, http.CanonicalHeaderKey(h))
+		}
+	}
+
+	return &ClientRateLimits{
+		cached:         NewRateLimiter(cfg.CachedPerMinute, cfg.CachedBurst),
+		search:         NewRateLimiter(cfg.SearchPerMinute, cfg.SearchBurst),
+		route:          NewRateLimiter(cfg.RoutePerMinute, cfg.RouteBurst),
+		trustedProxies: proxies,
+		proxyHeaders:   headers,
+	}, nil
+}
+
+// writeRateLimited sends a 429 response with a Retry-After header (whole seconds, rounded up)
+func writeRateLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	writeAPIError(w, http.StatusTooManyRequests, ErrCodeRateLimited, "Too many requests, please retry later")
+}
+
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
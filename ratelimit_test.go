@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies string
+		headers string
+		peer    string
+		header  map[string]string
+		want    string
+	}{
+		{"direct client", "", "", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"spoofed header from an untrusted peer", "10.0.0.0/8", "", "203.0.113.7:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"}, "203.0.113.7"},
+		{"no proxies configured", "", "", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1"}, "10.0.0.1"},
+		{"trusted proxy", "10.0.0.0/8", "", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"client prepends a fake hop", "10.0.0.0/8", "", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "192.0.2.9, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.0/8", "", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.3, 10.0.0.2"}, "198.51.100.1"},
+		{"only trusted hops", "10.0.0.0/8", "", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"garbage hops are skipped", "10.0.0.0/8", "", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1, unknown"}, "198.51.100.1"},
+		{"single trusted address", "10.0.0.1", "", "10.0.0.2:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1"}, "10.0.0.2"},
+		{"X-Real-IP fallback", "10.0.0.0/8", "", "10.0.0.1:5000",
+			map[string]string{"X-Real-IP": "198.51.100.2"}, "198.51.100.2"},
+		{"X-Forwarded-For wins over X-Real-IP", "10.0.0.0/8", "", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"}, "198.51.100.1"},
+		{"custom header", "10.0.0.0/8", "cf-connecting-ip", "10.0.0.1:5000",
+			map[string]string{"CF-Connecting-IP": "198.51.100.3", "X-Forwarded-For": "198.51.100.1"}, "198.51.100.3"},
+		{"unlisted header ignored", "10.0.0.0/8", "CF-Connecting-IP", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1"}, "10.0.0.1"},
+		{"IPv6 proxy", "fd00::/8", "", "[fd00::1]:5000",
+			map[string]string{"X-Forwarded-For": "2001:db8::1"}, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		headers := tt.headers
+		if headers == "" {
+			headers = strings.Join(defaultTrustedProxyHeaders, ",")
+		}
+		limits, err := NewClientRateLimits(RateLimitConfig{TrustedProxies: tt.proxies, TrustedProxyHeaders: headers})
+		if err != nil {
+			t.Fatalf("%s: NewClientRateLimits: %v", tt.name, err)
+		}
+		r := httptest.NewRequest("GET", "/api/restaurants", nil)
+		r.RemoteAddr = tt.peer
+		for name, value := range tt.header {
+			r.Header.Set(name, value)
+		}
+		if got := limits.HTTPClientKey(r); got != "ip:"+tt.want {
+			t.Errorf("%s: key = %q, want %q", tt.name, got, "ip:"+tt.want)
+		}
+	}
+
+	if _, err := parseTrustedProxies("10.0.0.0/8, not-an-ip"); err == nil {
+		t.Error("invalid trusted proxy accepted")
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	rl := NewRateLimiter(60, 2) // one token per second
+	rl.now = clock.Now
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := rl.Allow("a"); !ok {
+			t.Fatalf("request %d within the burst was refused", i+1)
+		}
+	}
+	if ok, wait := rl.Allow("a"); ok || wait != time.Second {
+		t.Errorf("empty bucket: allowed=%v wait=%v, want refused for 1s", ok, wait)
+	}
+	if ok, _ := rl.Allow("b"); !ok {
+		t.Error("keys share a bucket")
+	}
+	if ok, _ := rl.AllowN("b", 3); ok {
+		t.Error("AllowN above the burst was allowed")
+	}
+
+	clock.Advance(time.Second)
+	if ok, _ := rl.Allow("a"); !ok {
+		t.Error("bucket did not refill")
+	}
+
+	var unlimited *RateLimiter
+	if ok, _ := unlimited.AllowN("a", 1000); !ok || unlimited.Burst() != 0 {
+		t.Error("nil limiter is not unlimited")
+	}
+}
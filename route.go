@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"googlemaps.github.io/maps"
+)
+
+const (
+	defaultCorridorWidth = 500  // meters a place may be off the route
+	maxCorridorWidth     = 1500 // must stay well below defaultSearchRadius
+	maxRouteSamples      = 12   // point searches per route
+	maxRoutePoints       = 5000
+	routeSearchWorkers   = 3 // sample searches running at once
+	defaultRouteLimit    = 50
+	maxRouteLimit        = 200
+)
+
+// RouteRestaurant is a restaurant found along a route
+type RouteRestaurant struct {
+	Restaurant
+	RoutePosition float64 `json:"RoutePosition"` // meters from the start of the route to the closest point on it
+	Detour        float64 `json:"Detour"`        // meters of extra travel to visit: off the route and back
+}
+
+// RouteInfo describes how a route was searched
+type RouteInfo struct {
+	LengthMeters float64 `json:"lengthMeters"`
+	WidthMeters  int     `json:"widthMeters"` // corridor: max distance from the route
+	Samples      int     `json:"samples"`     // point searches along the route
+	CachedHits   int     `json:"cachedHits"`  // samples answered from the location cache
+}
+
+// RouteSearchResult is the JSON document served by /api/route
+type RouteSearchResult struct {
+	Restaurants []RouteRestaurant `json:"restaurants"` // ordered by RoutePosition
+	Stats       SearchStats       `json:"stats"`
+	Route       RouteInfo         `json:"route"`
+}
+
+// routeRequest holds the parsed parameters of a route search
+type routeRequest struct {
+	path       []LatLon
+	width      int
+	categories []FoodCategory
+	keyword    string
+	limit      int
+}
+
+// parseGeoJSONLineString parses a GeoJSON LineString geometry or a Feature containing one
+func parseGeoJSONLineString(raw json.RawMessage) ([]LatLon, error) {
+	var geo struct {
+		Type        string          `json:"type"`
+		Coordinates [][]float64     `json:"coordinates"`
+		Geometry    json.RawMessage `json:"geometry"`
+	}
+	if err := json.Unmarshal(raw, &geo); err != nil {
+		return nil, fmt.Errorf("line must be a GeoJSON LineString")
+	}
+	if geo.Type == "Feature" {
+		if len(geo.Geometry) == 0 {
+			return nil, fmt.Errorf("line feature has no geometry")
+		}
+		return parseGeoJSONLineString(geo.Geometry)
+	}
+	if geo.Type != "LineString" {
+		return nil, fmt.Errorf("line must be a GeoJSON LineString")
+	}
+	path := make([]LatLon, 0, len(geo.Coordinates))
+	for _, position := range geo.Coordinates {
+		if len(position) < 2 {
+			return nil, fmt.Errorf("line positions must be [lon, lat]")
+		}
+		path = append(path, LatLon{Lat: position[1], Lon: position[0]})
+	}
+	return path, nil
+}
+
+// decodeRoutePolyline decodes a Google encoded polyline
+func decodeRoutePolyline(encoded string) ([]LatLon, error) {
+	points, err := maps.DecodePolyline(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encoded polyline")
+	}
+	path := make([]LatLon, len(points))
+	for i, p := range points {
+		path[i] = LatLon{Lat: p.Lat, Lon: p.Lng}
+	}
+	return path, nil
+}
+
+// validateRoute checks the path and corridor width and returns the route length in meters
+func validateRoute(path []LatLon, width int) (float64, error) {
+	if len(path) < 2 {
+		return 0, fmt.Errorf("route must have at least 2 points")
+	}
+	if len(path) > maxRoutePoints {
+		return 0, fmt.Errorf("route must have at most %d points", maxRoutePoints)
+	}
+	for _, p := range path {
+		if !validCoordinates(p.Lat, p.Lon) {
+			return 0, fmt.Errorf("route coordinates out of range")
+		}
+	}
+	if width <= 0 || width > maxCorridorWidth {
+		return 0, fmt.Errorf("width must be between 1 and %d meters", maxCorridorWidth)
+	}
+
+	length := routeLength(path)
+	if maxLength := routeSampleSpacing(width) * float64(maxRouteSamples-1); length > maxLength {
+		return 0, fmt.Errorf("route too long: at most %.0f km for a %d m corridor", maxLength/1000, width)
+	}
+	return length, nil
+}
+
+// routeLength returns the length of a path in meters
+func routeLength(path []LatLon) float64 {
+	length := 0.0
+	for i := 1; i < len(path); i++ {
+		length += calculateDistance(path[i-1].Lat, path[i-1].Lon, path[i].Lat, path[i].Lon) * 1000
+	}
+	return length
+}
+
+// routeSampleSpacing returns the distance between point searches so that
+// consecutive search circles cover the whole corridor
+func routeSampleSpacing(width int) float64 {
+	r, w := float64(defaultSearchRadius), float64(width)
+	return 2 * math.Sqrt(r*r-w*w)
+}
+
+// sampleRoute returns points every spacing meters along the path, including both ends
+func sampleRoute(path []LatLon, spacing float64) []LatLon {
+	samples := []LatLon{path[0]}
+	next := spacing
+	traveled := 0.0
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		segment := calculateDistance(a.Lat, a.Lon, b.Lat, b.Lon) * 1000
+		for segment > 0 && traveled+segment >= next {
+			t := (next - traveled) / segment
+			samples = append(samples, LatLon{Lat: a.Lat + t*(b.Lat-a.Lat), Lon: a.Lon + t*(b.Lon-a.Lon)})
+			next += spacing
+		}
+		traveled += segment
+	}
+	if last := path[len(path)-1]; samples[len(samples)-1] != last {
+		samples = append(samples, last)
+	}
+	return samples
+}
+
+// projectOntoRoute returns how far along the path (meters) the point closest to p
+// lies, and the distance from p to that point (meters)
+func projectOntoRoute(path []LatLon, p LatLon) (position, offset float64) {
+	offset = math.MaxFloat64
+	traveled := 0.0
+	cosLat := math.Cos(p.Lat * math.Pi / 180)
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		segment := calculateDistance(a.Lat, a.Lon, b.Lat, b.Lon) * 1000
+
+		// Project in a local equirectangular plane
+		dx, dy := (b.Lon-a.Lon)*cosLat, b.Lat-a.Lat
+		t := 0.0
+		if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+			t = ((p.Lon-a.Lon)*cosLat*dx + (p.Lat-a.Lat)*dy) / lengthSq
+			t = math.Max(0, math.Min(1, t))
+		}
+		closest := LatLon{Lat: a.Lat + t*(b.Lat-a.Lat), Lon: a.Lon + t*(b.Lon-a.Lon)}
+		if d := calculateDistance(p.Lat, p.Lon, closest.Lat, closest.Lon) * 1000; d < offset {
+			offset, position = d, traveled+t*segment
+		}
+		traveled += segment
+	}
+	return position, offset
+}
+
+// parseRouteRequest reads a route search from query parameters (GET) or a JSON body (POST)
+func parseRouteRequest(r *http.Request) (*routeRequest, *APIError) {
+	req := &routeRequest{width: defaultCorridorWidth, limit: defaultRouteLimit}
+	var categories []string
+	var err error
+
+	if r.Method == "GET" {
+		q := r.URL.Query()
+		if q.Get("polyline") == "" {
+			return nil, &APIError{Code: ErrCodeMissingParameter, Message: "polyline parameter is required"}
+		}
+		if req.path, err = decodeRoutePolyline(q.Get("polyline")); err != nil {
+			return nil, &APIError{Code: ErrCodeInvalidRoute, Message: err.Error()}
+		}
+		if v := q.Get("width"); v != "" {
+			if req.width, err = strconv.Atoi(v); err != nil {
+				return nil, &APIError{Code: ErrCodeInvalidRoute, Message: "invalid width parameter"}
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			if req.limit, err = strconv.Atoi(v); err != nil {
+				return nil, &APIError{Code: ErrCodeInvalidRequest, Message: "invalid limit parameter"}
+			}
+		}
+		if v := q.Get("categories"); v != "" && v != "all" {
+			categories = strings.Split(v, ",")
+		}
+		req.keyword = q.Get("keyword")
+	} else {
+		var body struct {
+			Polyline   string          `json:"polyline"` // Google encoded polyline
+			Line       json.RawMessage `json:"line"`     // GeoJSON LineString (or Feature)
+			Width      int             `json:"width"`    // meters
+			Categories []string        `json:"categories"`
+			Keyword    string          `json:"keyword"`
+			Limit      int             `json:"limit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, &APIError{Code: ErrCodeInvalidRequest, Message: "Invalid JSON body"}
+		}
+		switch {
+		case len(body.Line) > 0:
+			req.path, err = parseGeoJSONLineString(body.Line)
+		case body.Polyline != "":
+			req.path, err = decodeRoutePolyline(body.Polyline)
+		default:
+			return nil, &APIError{Code: ErrCodeMissingParameter, Message: "polyline or line is required"}
+		}
+		if err != nil {
+			return nil, &APIError{Code: ErrCodeInvalidRoute, Message: err.Error()}
+		}
+		if body.Width != 0 {
+			req.width = body.Width
+		}
+		if body.Limit != 0 {
+			req.limit = body.Limit
+		}
+		categories = body.Categories
+		req.keyword = body.Keyword
+	}
+
+	for _, c := range categories {
+		if c = strings.TrimSpace(c); c != "" {
+			req.categories = append(req.categories, FoodCategory(c))
+		}
+	}
+	if req.limit <= 0 || req.limit > maxRouteLimit {
+		return nil, &APIError{Code: ErrCodeInvalidRequest, Message: fmt.Sprintf("limit must be between 1 and %d", maxRouteLimit)}
+	}
+	return req, nil
+}
+
+// sampleParams returns the point search run at a sample along the route
+func (req *routeRequest) sampleParams(point LatLon) SearchParams {
+	return SearchParams{Lat: point.Lat, Lon: point.Lon, Categories: req.categories, Keyword: req.keyword}
+}
+
+// freshSamples counts the samples that are not answered from the location
+// cache, each of which runs a full provider search
+func (s *Server) freshSamples(req *routeRequest, samples []LatLon) int {
+	fresh := 0
+	for _, point := range samples {
+		if params := req.sampleParams(point); !params.cacheable() || !s.cache.Has(point.Lat, point.Lon) {
+			fresh++
+		}
+	}
+	return fresh
+}
+
+// routeSample is the outcome of one point search along a route
+type routeSample struct {
+	index       int
+	restaurants []Restaurant
+	stats       SearchStats
+	cached      bool
+	err         error
+}
+
+// searchRoute runs a point search at every sample (reusing the location cache)
+// and merges the places within the corridor, ordered along the route
+func (s *Server) searchRoute(ctx context.Context, req *routeRequest, samples []LatLon) (*RouteSearchResult, error) {
+	results := make([]routeSample, len(samples))
+	sem := make(chan struct{}, routeSearchWorkers)
+	var wg sync.WaitGroup
+	for i, point := range samples {
+		wg.Add(1)
+		go func(i int, point LatLon) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			params := req.sampleParams(point)
+			sample := routeSample{index: i}
+			if params.cacheable() {
+				if cached, cachedStats, found := s.cache.Get(point.Lat, point.Lon); found {
+					sample.restaurants, sample.stats, sample.cached = cached, *cachedStats, true
+					results[i] = sample
+					return
+				}
+			}
+			result, err := s.search.Search(ctx, params)
+			if err != nil {
+				sample.err = err
+				results[i] = sample
+				return
+			}
+			if params.cacheable() && ctx.Err() == nil {
+				s.cache.Set(point.Lat, point.Lon, result.Restaurants, result.Stats)
+			}
+			sample.restaurants, sample.stats = result.Restaurants, result.Stats
+			results[i] = sample
+		}(i, point)
+	}
+	wg.Wait()
+
+	out := &RouteSearchResult{Restaurants: []RouteRestaurant{}}
+	stats := &out.Stats
+	seen := make(map[string]bool)
+	var errs []string
+	failed, degraded := 0, 0
+	for _, sample := range results {
+		name := fmt.Sprintf("route:%d", sample.index+1)
+		if sample.err != nil {
+			failed++
+			errs = append(errs, fmt.Sprintf("%s: %v", name, sample.err))
+			var failure *ProviderFailureError
+			if errors.As(sample.err, &failure) {
+				stats.ProviderErrors = append(stats.ProviderErrors, failure.Failures...)
+			} else {
+				stats.ProviderErrors = append(stats.ProviderErrors, newProviderError(name, sample.err))
+			}
+			stats.SubQueries = append(stats.SubQueries, newProviderOutcome(name, 0, 0, sample.err))
+			continue
+		}
+		if sample.cached {
+			out.Route.CachedHits++
+		} else {
+			stats.GooglePagesSearched += sample.stats.GooglePagesSearched
+			stats.GoogleSearchQueries += sample.stats.GoogleSearchQueries
+			stats.GoogleResultsRaw += sample.stats.GoogleResultsRaw
+			stats.GoogleResultsFiltered += sample.stats.GoogleResultsFiltered
+			stats.OSMResultsTotal += sample.stats.OSMResultsTotal
+		}
+		stats.BudgetExceeded = stats.BudgetExceeded || sample.stats.BudgetExceeded
+		if sample.stats.Degraded {
+			degraded++
+		}
+		stats.ProviderErrors = append(stats.ProviderErrors, sample.stats.ProviderErrors...)
+		stats.SubQueries = append(stats.SubQueries, newProviderOutcome(name, 0, len(sample.restaurants), nil))
+		stats.TotalBeforeDedup += len(sample.restaurants)
+
+		for _, restaurant := range sample.restaurants {
+			key := dedupKey(restaurant)
+			if seen[key] {
+				continue
+			}
+			position, offset := projectOntoRoute(req.path, LatLon{Lat: restaurant.Latitude, Lon: restaurant.Longitude})
+			if offset > float64(req.width) {
+				continue
+			}
+			seen[key] = true
+			restaurant.Distance = offset / 1000
+			out.Restaurants = append(out.Restaurants, RouteRestaurant{
+				Restaurant:    restaurant,
+				RoutePosition: math.Round(position),
+				Detour:        math.Round(2 * offset),
+			})
+		}
+	}
+
+	if failed == len(samples) {
+		return nil, &ProviderFailureError{
+			Failures: stats.ProviderErrors,
+			cause:    fmt.Sprintf("all route searches failed: %s", strings.Join(errs, "; ")),
+		}
+	}
+
+	sort.SliceStable(out.Restaurants, func(i, j int) bool {
+		return out.Restaurants[i].RoutePosition < out.Restaurants[j].RoutePosition
+	})
+	stats.TotalAfterDedup = len(out.Restaurants)
+	if len(out.Restaurants) > req.limit {
+		out.Restaurants = out.Restaurants[:req.limit]
+	}
+	stats.CachedResult = out.Route.CachedHits == len(samples)
+
+	switch {
+	case failed > 0:
+		stats.Degraded = true
+		stats.Notice = fmt.Sprintf("%d of %d route sections could not be searched, results may be incomplete", failed, len(samples))
+	case stats.BudgetExceeded:
+		stats.Degraded = true
+		stats.Notice = "Google Maps budget reached, showing OpenStreetMap results only"
+	case degraded > 0:
+		stats.Degraded = true
+		stats.Notice = fmt.Sprintf("Some providers failed for %d of %d route sections, results may be incomplete", degraded, len(samples))
+	}
+	return out, nil
+}
+
+// handleRouteSearch serves /api/route: places along a polyline within a corridor
+func (s *Server) handleRouteSearch(w http.ResponseWriter, r *http.Request) {
+	if !s.cors.Apply(w, r, "GET, POST, OPTIONS") {
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "POST" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	clientKey, ok := s.authorizeAPIRequest(w, r)
+	if !ok {
+		return
+	}
+
+	req, apiErr := parseRouteRequest(r)
+	if apiErr != nil {
+		writeAPIError(w, http.StatusBadRequest, apiErr.Code, apiErr.Message)
+		return
+	}
+	length, err := validateRoute(req.path, req.width)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidRoute, err.Error())
+		return
+	}
+	samples := sampleRoute(req.path, routeSampleSpacing(req.width))
+
+	// Every sample that is not cached is a full search against the client's route limit
+	if fresh := s.freshSamples(req, samples); fresh > 0 {
+		if max := s.limits.MaxRouteSamples(); max > 0 && fresh > max {
+			writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidRoute,
+				fmt.Sprintf("route needs %d new searches, at most %d are allowed at once; use a shorter route or a narrower corridor", fresh, max))
+			return
+		}
+		if ok, retryAfter := s.limits.AllowRouteSamples(clientKey, fresh); !ok {
+			ctxLogger(r.Context()).Warn("[RATELIMIT] Route limit exceeded", "client", clientKey, "searches", fresh)
+			writeRateLimited(w, retryAfter)
+			return
+		}
+	}
+
+	s.metrics.SearchStarted(EntryRoute)
+	ctxLogger(r.Context()).Info("[ROUTE] Searching route", "km", math.Round(length/100)/10, "samples", len(samples), "corridor_m", req.width)
+	result, err := s.searchRoute(r.Context(), req, samples)
+	if err != nil {
+		ctxLogger(r.Context()).Error("[ROUTE] Error searching route", "error", err)
+		budgetExceeded, _ := s.costs.BudgetExceeded()
+		writeSearchError(w, s.apiProvider, budgetExceeded, err)
+		return
+	}
+	result.Route.LengthMeters = math.Round(length)
+	result.Route.WidthMeters = req.width
+	result.Route.Samples = len(samples)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
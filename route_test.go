@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// eastward returns a straight route of km kilometers heading east from lat, lon
+func eastward(lat, lon, km float64, points int) []LatLon {
+	degrees := km / (111.195 * math.Cos(lat*math.Pi/180))
+	path := make([]LatLon, points)
+	for i := range path {
+		path[i] = LatLon{Lat: lat, Lon: lon + degrees*float64(i)/float64(points-1)}
+	}
+	return path
+}
+
+// routeBody is a POST /api/v1/route body for path
+func routeBody(path []LatLon, extra string) string {
+	var coords []string
+	for _, p := range path {
+		coords = append(coords, fmt.Sprintf("[%f,%f]", p.Lon, p.Lat))
+	}
+	return fmt.Sprintf(`{"line":{"type":"LineString","coordinates":[%s]}%s}`, strings.Join(coords, ","), extra)
+}
+
+func TestSampleRoute(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        []LatLon
+		spacing     float64
+		wantSamples int
+	}{
+		{"shorter than the spacing", eastward(52.5, 13.4, 1, 2), 3000, 2},
+		{"one segment", eastward(52.5, 13.4, 10, 2), 3000, 5},            // 0, 3, 6, 9 km and the end
+		{"many segments", eastward(52.5, 13.4, 10, 50), 3000, 5},         // the same, however the line is split
+		{"exact multiple", eastward(52.5, 13.4, 9, 4), 3000, 4},          // the last sample is the end
+		{"repeated points", []LatLon{{1, 1}, {1, 1}, {1, 1.01}}, 500, 4}, // zero-length segments are skipped
+	}
+	for _, tt := range tests {
+		samples := sampleRoute(tt.path, tt.spacing)
+		if len(samples) != tt.wantSamples {
+			t.Errorf("%s: %d samples, want %d", tt.name, len(samples), tt.wantSamples)
+			continue
+		}
+		if samples[0] != tt.path[0] || samples[len(samples)-1] != tt.path[len(tt.path)-1] {
+			t.Errorf("%s: samples do not include both ends: %v", tt.name, samples)
+		}
+		// No gap between samples is longer than the spacing
+		for i := 1; i < len(samples); i++ {
+			if gap := calculateDistance(samples[i-1].Lat, samples[i-1].Lon, samples[i].Lat, samples[i].Lon) * 1000; gap > tt.spacing+1 {
+				t.Errorf("%s: %.0f m between samples %d and %d", tt.name, gap, i-1, i)
+			}
+		}
+	}
+}
+
+func TestProjectOntoRoute(t *testing.T) {
+	path := eastward(52.5, 13.4, 10, 11)
+	length := routeLength(path)
+	tests := []struct {
+		name                     string
+		point                    LatLon
+		wantPosition, wantOffset float64
+	}{
+		{"start", path[0], 0, 0},
+		{"end", path[10], length, 0},
+		{"on the route", path[4], 4000, 0},
+		{"300 m north", LatLon{Lat: 52.5 + 0.3/111.195, Lon: path[4].Lon}, 4000, 300},
+		{"1 km south", LatLon{Lat: 52.5 - 1/111.195, Lon: path[7].Lon}, 7000, 1000},
+		{"before the start", LatLon{Lat: 52.5, Lon: path[0].Lon - (path[1].Lon - path[0].Lon)}, 0, 1000},
+		{"past the end", LatLon{Lat: 52.5, Lon: path[10].Lon + 2*(path[1].Lon-path[0].Lon)}, length, 2000},
+	}
+	for _, tt := range tests {
+		position, offset := projectOntoRoute(path, tt.point)
+		if math.Abs(position-tt.wantPosition) > 5 || math.Abs(offset-tt.wantOffset) > 5 {
+			t.Errorf("%s: position %.0f m, offset %.0f m; want %.0f m, %.0f m", tt.name, position, offset, tt.wantPosition, tt.wantOffset)
+		}
+	}
+}
+
+func TestValidateRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []LatLon
+		width   int
+		wantErr string
+	}{
+		{"valid", eastward(52.5, 13.4, 10, 5), 500, ""},
+		{"one point", []LatLon{{52.5, 13.4}}, 500, "at least 2 points"},
+		{"too many points", make([]LatLon, maxRoutePoints+1), 500, "at most"},
+		{"latitude out of range", []LatLon{{52.5, 13.4}, {91, 13.4}}, 500, "out of range"},
+		{"NaN", []LatLon{{52.5, 13.4}, {math.NaN(), 13.4}}, 500, "out of range"},
+		{"zero width", eastward(52.5, 13.4, 1, 2), 0, "width"},
+		{"width too large", eastward(52.5, 13.4, 1, 2), maxCorridorWidth + 1, "width"},
+		{"too long", eastward(52.5, 13.4, 50, 2), 500, "too long"},
+		{"long enough for a narrow corridor", eastward(52.5, 13.4, 40, 2), 100, ""},
+		{"too long for a wide corridor", eastward(52.5, 13.4, 40, 2), 1500, "too long"},
+	}
+	for _, tt := range tests {
+		length, err := validateRoute(tt.path, tt.width)
+		if tt.wantErr == "" {
+			if err != nil || length <= 0 {
+				t.Errorf("%s: %.0f m, %v", tt.name, length, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseGeoJSONLineString(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{`{"type":"LineString","coordinates":[[13.4,52.5],[13.5,52.5]]}`, 2, false},
+		{`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[13.4,52.5,34],[13.5,52.5,35]]}}`, 2, false},
+		{`{"type":"Feature"}`, 0, true},
+		{`{"type":"Point","coordinates":[13.4,52.5]}`, 0, true},
+		{`{"type":"LineString","coordinates":[[13.4],[13.5,52.5]]}`, 0, true},
+		{`[1,2]`, 0, true},
+	}
+	for _, tt := range tests {
+		path, err := parseGeoJSONLineString([]byte(tt.raw))
+		if (err != nil) != tt.wantErr || len(path) != tt.want {
+			t.Errorf("%s: %v, %v", tt.raw, path, err)
+		}
+	}
+	path, _ := parseGeoJSONLineString([]byte(`{"type":"LineString","coordinates":[[13.4,52.5],[13.5,52.6]]}`))
+	if path[1] != (LatLon{Lat: 52.6, Lon: 13.5}) {
+		t.Errorf("positions are [lon, lat]: %v", path)
+	}
+}
+
+func TestRouteSearchChargesEveryFreshSample(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(3)}
+	server := newTestServer(t, search)
+	server.limits = &ClientRateLimits{cached: NewRateLimiter(600, 100), route: NewRateLimiter(1, 6)}
+
+	// 10 km with a 500 m corridor is 4 samples
+	route := eastward(52.52, 13.405, 10, 3)
+	if n := len(sampleRoute(route, routeSampleSpacing(defaultCorridorWidth))); n != 4 {
+		t.Fatalf("%d samples, want 4", n)
+	}
+	if rec := serve(server, "POST", "/api/v1/route", routeBody(route, "")); rec.Code != http.StatusOK {
+		t.Fatalf("first route: %d %s", rec.Code, rec.Body.String())
+	}
+	if search.callCount() != 4 {
+		t.Errorf("%d searches, want 4", search.callCount())
+	}
+
+	// Cached samples are free, but a keyword search is never cached
+	if rec := serve(server, "POST", "/api/v1/route", routeBody(route, "")); rec.Code != http.StatusOK {
+		t.Errorf("cached route: %d %s", rec.Code, rec.Body.String())
+	}
+	rec := serve(server, "POST", "/api/v1/route", routeBody(route, `,"keyword":"vegan"`))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("route needing 4 searches with 2 tokens left: %d %s", rec.Code, rec.Body.String())
+	}
+	if search.callCount() != 4 {
+		t.Errorf("%d searches, want 4", search.callCount())
+	}
+
+	// More fresh samples than the burst can never be allowed
+	rec = serve(server, "POST", "/api/v1/route", routeBody(eastward(48.1, 11.5, 40, 2), `,"width":100`))
+	if rec.Code != http.StatusBadRequest || decodeError(t, rec).Code != ErrCodeInvalidRoute {
+		t.Errorf("route needing 12 searches: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRouteSearchWithDefaultLimits(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(3)}
+	server := newTestServer(t, search)
+	limits, err := NewClientRateLimits(DefaultConfig().RateLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.limits = limits
+
+	// A 30 km road trip is more samples than the search burst, but fits the route burst
+	route := eastward(48.1, 11.5, 30, 4)
+	samples := len(sampleRoute(route, routeSampleSpacing(defaultCorridorWidth)))
+	if samples <= defaultSearchBurst {
+		t.Fatalf("%d samples, want more than the search burst of %d", samples, defaultSearchBurst)
+	}
+	rec := serve(server, "POST", "/api/v1/route", routeBody(route, ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("30 km route: %d %s", rec.Code, rec.Body.String())
+	}
+	if search.callCount() != samples {
+		t.Errorf("%d searches, want %d", search.callCount(), samples)
+	}
+
+	// Route samples leave the client's search budget untouched
+	if rec := serve(server, "GET", "/api/restaurants?lat=40.0&lon=-3.0", ""); rec.Code != http.StatusOK {
+		t.Errorf("point search after the route: %d %s", rec.Code, rec.Body.String())
+	}
+}
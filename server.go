@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// googlePhotoAPIURL is the Google Places Photo endpoint used by /api/photo
+const googlePhotoAPIURL = "https://maps.googleapis.com/maps/api/place/photo"
+
+// SearchService runs a fresh restaurant search; caching is done by the caller
+type SearchService interface {
+	Search(ctx context.Context, params SearchParams) (*SearchResult, error)
+}
+
+// Search implements SearchService with the bot's configured providers
+func (rb *RestaurantBot) Search(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	return rb.findNearbyRestaurantsWithStats(ctx, params)
+}
+
+// Server serves the HTTP API and web interface on its own ServeMux. It shares
+// the caches, cost ledger, rate limits and access control of the bot it wraps;
+// searches, photo storage and the clock are injectable for tests.
+type Server struct {
+	*RestaurantBot
+	mux         *http.ServeMux
+	search      SearchService
+	photos      PhotoStore
+	photoClient *http.Client
+	photoAPIURL string
+	now         func() time.Time
+	startedAt   time.Time
+}
+
+// NewServer creates a server for bot that searches with search and keeps photos in photos
+func NewServer(bot *RestaurantBot, search SearchService, photos PhotoStore) *Server {
+	s := &Server{
+		RestaurantBot: bot,
+		mux:           http.NewServeMux(),
+		search:        search,
+		photos:        photos,
+		photoClient:   &http.Client{Timeout: bot.requestTimeout, Transport: bot.providerTransport(EndpointPhoto)},
+		photoAPIURL:   googlePhotoAPIURL,
+		now:           time.Now,
+	}
+	s.startedAt = s.now()
+	s.routes()
+	return s
+}
+
+// routes registers the API and the web interface
+func (s *Server) routes() {
+	// JSON API under /api/v1, with the unversioned /api paths as aliases
+	s.registerAPIRoutes()
+
+	// Load balancer probes, outside the API so they need no key
+	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.HandleFunc("/readyz", s.handleReadyz)
+
+	// Prometheus scrape endpoint, authenticated since it exposes spend
+	metrics := s.metrics.Handler()
+	s.mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if s.authenticateMetricsRequest(w, r) {
+			metrics.ServeHTTP(w, r)
+		}
+	})
+
+	// Serve index-new.html at hard-to-find URL
+	s.mux.HandleFunc("/vwrk4DFEv1RQpl3PxmWSZUeCkSVjAc5kbDqnIIu4DqDYVdNnGiu1xBWIE8IgbJ3X.html", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "index-new.html")
+	})
+
+	// Serve HTML page
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "index.html")
+	})
+}
+
+// ServeHTTP implements http.Handler; every request gets a request ID and an access log line
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	withRequestLogging(s.withTracing(s.mux)).ServeHTTP(w, r)
+}
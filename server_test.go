@@ -0,0 +1,654 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSearch is a SearchService returning fixed results and recording its calls
+type fakeSearch struct {
+	mu          sync.Mutex
+	restaurants []Restaurant
+	batches     [][]Restaurant // reported as Google sub-query progress before returning
+	stats       SearchStats
+	err         error
+	calls       []SearchParams
+}
+
+func (fs *fakeSearch) Search(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.calls = append(fs.calls, params)
+	for i, batch := range fs.batches {
+		sr := &SearchResult{Restaurants: batch}
+		reportSearchProgress(ctx, "google", fmt.Sprintf("google:batch%d", i+1), i+1, len(fs.batches), sr, nil)
+	}
+	if fs.err != nil {
+		return nil, fs.err
+	}
+	restaurants := make([]Restaurant, len(fs.restaurants))
+	copy(restaurants, fs.restaurants)
+	return &SearchResult{Restaurants: restaurants, Stats: fs.stats}, nil
+}
+
+func (fs *fakeSearch) callCount() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.calls)
+}
+
+func (fs *fakeSearch) lastCall() SearchParams {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.calls[len(fs.calls)-1]
+}
+
+// memoryPhotoStore is an in-memory PhotoStore
+type memoryPhotoStore struct {
+	mu     sync.Mutex
+	photos map[string][]byte
+}
+
+func newMemoryPhotoStore() *memoryPhotoStore {
+	return &memoryPhotoStore{photos: make(map[string][]byte)}
+}
+
+func (ms *memoryPhotoStore) Get(name string) ([]byte, time.Time, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	data, ok := ms.photos[name]
+	return data, time.Time{}, ok && len(data) > 0
+}
+
+func (ms *memoryPhotoStore) Put(name string, data []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.photos[name] = data
+	return nil
+}
+
+func (ms *memoryPhotoStore) Usage() (int, int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var bytes int64
+	for _, data := range ms.photos {
+		bytes += int64(len(data))
+	}
+	return len(ms.photos), bytes, nil
+}
+
+func (ms *memoryPhotoStore) Writable() error {
+	return nil
+}
+
+// testClock is a manually advanced clock
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestServer creates a server without providers or Telegram, with an
+// in-memory photo store
+func newTestServer(t *testing.T, search SearchService) *Server {
+	t.Helper()
+	server, _ := newTestServerWithClock(t, search)
+	return server
+}
+
+// newTestServerWithClock is newTestServer with the server and all caches on a manual clock
+func newTestServerWithClock(t *testing.T, search SearchService) (*Server, *testClock) {
+	t.Helper()
+	bot, err := NewRestaurantBot("", "", "osm")
+	if err != nil {
+		t.Fatalf("NewRestaurantBot: %v", err)
+	}
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	bot.cache.now = clock.Now
+	bot.snapshots.now = clock.Now
+	bot.details.now = clock.Now
+
+	server := NewServer(bot, search, newMemoryPhotoStore())
+	server.now = clock.Now
+	server.startedAt = clock.Now()
+	return server, clock
+}
+
+// makeRestaurants returns n places sorted by distance
+func makeRestaurants(n int) []Restaurant {
+	restaurants := make([]Restaurant, n)
+	for i := range restaurants {
+		restaurants[i] = Restaurant{
+			Name:        fmt.Sprintf("Place %02d", i),
+			Rating:      4.0 + float64(i%10)/10,
+			ReviewCount: 10 * (i + 1),
+			Latitude:    52.52 + float64(i)*0.0001,
+			Longitude:   13.405,
+			Address:     "Mitte",
+			Distance:    float64(i) * 0.01,
+			PlaceID:     fmt.Sprintf("place-%02d", i),
+		}
+	}
+	return restaurants
+}
+
+func decodePage(t *testing.T, rec *httptest.ResponseRecorder) PaginatedSearchResult {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var page PaginatedSearchResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return page
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) APIError {
+	t.Helper()
+	var resp apiErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid error JSON %q: %v", rec.Body.String(), err)
+	}
+	return resp.Error
+}
+
+func TestServerParameterParsing(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantCode   string
+		check      func(t *testing.T, p SearchParams)
+	}{
+		{name: "coordinates", method: "GET", target: "/api/v1/restaurants?lat=52.52&lon=13.405", wantStatus: 200,
+			check: func(t *testing.T, p SearchParams) {
+				if p.Lat != 52.52 || p.Lon != 13.405 || len(p.Categories) != 0 || p.Keyword != "" {
+					t.Errorf("params = %+v", p)
+				}
+			}},
+		{name: "categories and keyword", method: "GET", target: "/api/v1/restaurants?lat=1&lon=2&categories=cafe,+bar,&keyword=vegan", wantStatus: 200,
+			check: func(t *testing.T, p SearchParams) {
+				if len(p.Categories) != 2 || p.Categories[0] != CategoryCafe || p.Categories[1] != CategoryBar || p.Keyword != "vegan" {
+					t.Errorf("params = %+v", p)
+				}
+			}},
+		{name: "legacy category", method: "GET", target: "/api/restaurants?lat=1&lon=2&category=cafe", wantStatus: 200,
+			check: func(t *testing.T, p SearchParams) {
+				if len(p.Categories) != 1 || p.Categories[0] != CategoryCafe {
+					t.Errorf("params = %+v", p)
+				}
+			}},
+		{name: "category all", method: "GET", target: "/api/v1/restaurants?lat=1&lon=2&categories=all", wantStatus: 200,
+			check: func(t *testing.T, p SearchParams) {
+				if len(p.Categories) != 0 {
+					t.Errorf("categories = %v", p.Categories)
+				}
+			}},
+		{name: "bbox", method: "GET", target: "/api/v1/restaurants?bbox=13.40,52.51,13.42,52.52", wantStatus: 200,
+			check: func(t *testing.T, p SearchParams) {
+				if p.Area == nil || p.Area.Kind != "bbox" {
+					t.Errorf("area = %+v", p.Area)
+				}
+			}},
+		{name: "post body", method: "POST", target: "/api/v1/restaurants", body: `{"lat":48.1,"lon":11.5,"category":"bar","keyword":"beer"}`, wantStatus: 200,
+			check: func(t *testing.T, p SearchParams) {
+				if p.Lat != 48.1 || p.Lon != 11.5 || len(p.Categories) != 1 || p.Categories[0] != CategoryBar || p.Keyword != "beer" {
+					t.Errorf("params = %+v", p)
+				}
+			}},
+		{name: "post polygon", method: "POST", target: "/api/v1/restaurants",
+			body:       `{"polygon":{"type":"Polygon","coordinates":[[[13.40,52.51],[13.42,52.51],[13.42,52.52],[13.40,52.51]]]}}`,
+			wantStatus: 200,
+			check: func(t *testing.T, p SearchParams) {
+				if p.Area == nil || p.Area.Kind != "polygon" {
+					t.Errorf("area = %+v", p.Area)
+				}
+			}},
+		{name: "missing lat", method: "GET", target: "/api/v1/restaurants?lon=2", wantStatus: 400, wantCode: ErrCodeMissingParameter},
+		{name: "bad lat", method: "GET", target: "/api/v1/restaurants?lat=abc&lon=2", wantStatus: 400, wantCode: ErrCodeInvalidCoordinates},
+		{name: "lat out of range", method: "GET", target: "/api/v1/restaurants?lat=91&lon=2", wantStatus: 400, wantCode: ErrCodeInvalidCoordinates},
+		{name: "lon out of range", method: "POST", target: "/api/v1/restaurants", body: `{"lat":1,"lon":-181}`, wantStatus: 400, wantCode: ErrCodeInvalidCoordinates},
+		{name: "NaN", method: "GET", target: "/api/v1/restaurants?lat=NaN&lon=2", wantStatus: 400, wantCode: ErrCodeInvalidCoordinates},
+		{name: "bad bbox", method: "GET", target: "/api/v1/restaurants?bbox=1,2,3", wantStatus: 400, wantCode: ErrCodeInvalidArea},
+		{name: "bad post bbox", method: "POST", target: "/api/v1/restaurants", body: `{"bbox":[1,2]}`, wantStatus: 400, wantCode: ErrCodeInvalidArea},
+		{name: "bad sort", method: "GET", target: "/api/v1/restaurants?lat=1&lon=2&sort=random", wantStatus: 400, wantCode: ErrCodeInvalidRequest},
+		{name: "bad min_rating", method: "GET", target: "/api/v1/restaurants?lat=1&lon=2&min_rating=6", wantStatus: 400, wantCode: ErrCodeInvalidRequest},
+		{name: "q too long", method: "GET", target: "/api/v1/restaurants?q=" + strings.Repeat("a", maxGeocodeQueryLength+1), wantStatus: 400, wantCode: ErrCodeInvalidRequest},
+		{name: "q without geocoder", method: "GET", target: "/api/v1/restaurants?q=Alexanderplatz", wantStatus: 503, wantCode: ErrCodeNotConfigured},
+		{name: "invalid JSON", method: "POST", target: "/api/v1/restaurants", body: `{"lat":`, wantStatus: 400, wantCode: ErrCodeInvalidRequest},
+		{name: "method", method: "DELETE", target: "/api/v1/restaurants?lat=1&lon=2", wantStatus: 405, wantCode: ErrCodeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := &fakeSearch{restaurants: makeRestaurants(3)}
+			server := newTestServer(t, search)
+
+			rec := serve(server, tt.method, tt.target, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantCode != "" {
+				if code := decodeError(t, rec).Code; code != tt.wantCode {
+					t.Errorf("code %q, want %q", code, tt.wantCode)
+				}
+				if search.callCount() != 0 {
+					t.Errorf("search called for a rejected request")
+				}
+				return
+			}
+			if search.callCount() != 1 {
+				t.Fatalf("search called %d times, want 1", search.callCount())
+			}
+			tt.check(t, search.lastCall())
+		})
+	}
+}
+
+func TestServerPaginationEdgeCases(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(45)}
+	server := newTestServer(t, search)
+	base := "/api/v1/restaurants?lat=52.52&lon=13.405&sort=distance"
+
+	tests := []struct {
+		query     string
+		wantPage  int
+		wantLimit int
+		wantItems int
+		wantFirst string
+		hasNext   bool
+		hasPrev   bool
+	}{
+		{"", 1, 20, 20, "Place 00", true, false},
+		{"&page=2", 2, 20, 20, "Place 20", true, true},
+		{"&page=3", 3, 20, 5, "Place 40", false, true},
+		{"&page=99", 3, 20, 5, "Place 40", false, true}, // clamped to the last page
+		{"&page=0", 1, 20, 20, "Place 00", true, false},
+		{"&page=-4", 1, 20, 20, "Place 00", true, false},
+		{"&page=abc", 1, 20, 20, "Place 00", true, false},
+		{"&limit=100", 1, 100, 45, "Place 00", false, false},
+		{"&limit=101", 1, 20, 20, "Place 00", true, false}, // out of range falls back to the default
+		{"&limit=0", 1, 20, 20, "Place 00", true, false},
+		{"&limit=45", 1, 45, 45, "Place 00", false, false},
+		{"&limit=44&page=2", 2, 44, 1, "Place 44", false, true},
+	}
+	for _, tt := range tests {
+		page := decodePage(t, serve(server, "GET", base+tt.query, ""))
+		p := page.Pagination
+		if p.Page != tt.wantPage || p.Limit != tt.wantLimit || len(page.Restaurants) != tt.wantItems ||
+			p.HasNext != tt.hasNext || p.HasPrev != tt.hasPrev || p.TotalItems != 45 {
+			t.Errorf("%q: pagination %+v with %d items", tt.query, p, len(page.Restaurants))
+			continue
+		}
+		if page.Restaurants[0].Name != tt.wantFirst {
+			t.Errorf("%q: first item %q, want %q", tt.query, page.Restaurants[0].Name, tt.wantFirst)
+		}
+		if (p.NextCursor != "") != tt.hasNext || (p.PrevCursor != "") != tt.hasPrev {
+			t.Errorf("%q: cursors next=%q prev=%q", tt.query, p.NextCursor, p.PrevCursor)
+		}
+	}
+	if search.callCount() != 1 {
+		t.Errorf("search called %d times, want 1 (later pages come from the cache)", search.callCount())
+	}
+}
+
+func TestServerPaginationEmptyResults(t *testing.T) {
+	server := newTestServer(t, &fakeSearch{})
+
+	page := decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=1&lon=2&page=3", ""))
+	p := page.Pagination
+	if page.Restaurants == nil || len(page.Restaurants) != 0 {
+		t.Errorf("restaurants = %#v, want an empty array", page.Restaurants)
+	}
+	if p.Page != 1 || p.TotalPages != 1 || p.TotalItems != 0 || p.HasNext || p.HasPrev || p.NextCursor != "" {
+		t.Errorf("pagination = %+v", p)
+	}
+}
+
+func TestServerCursorWalk(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(45)}
+	server, clock := newTestServerWithClock(t, search)
+
+	seen := make(map[string]bool)
+	start := serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405&limit=10&sort=distance", "")
+	if body := start.Body.String(); !strings.Contains(body, `"nextCursor":"`) || strings.Contains(body, "next_cursor") {
+		t.Errorf("cursor field is not camelCase: %s", body)
+	}
+	page := decodePage(t, start)
+	pages := 1
+	for {
+		for _, r := range page.Restaurants {
+			if seen[r.Name] {
+				t.Fatalf("%s returned twice", r.Name)
+			}
+			seen[r.Name] = true
+		}
+		if page.Pagination.NextCursor == "" {
+			break
+		}
+		page = decodePage(t, serve(server, "GET", "/api/v1/restaurants?cursor="+url.QueryEscape(page.Pagination.NextCursor), ""))
+		pages++
+	}
+	if len(seen) != 45 || pages != 5 {
+		t.Errorf("walked %d items over %d pages, want 45 over 5", len(seen), pages)
+	}
+
+	// Walk back one page via prevCursor
+	prev := decodePage(t, serve(server, "POST", "/api/v1/restaurants", fmt.Sprintf(`{"cursor":%q}`, page.Pagination.PrevCursor)))
+	if prev.Pagination.Page != 4 || prev.Restaurants[0].Name != "Place 30" {
+		t.Errorf("prev page %d starting at %q", prev.Pagination.Page, prev.Restaurants[0].Name)
+	}
+
+	// Cursors survive a cache refresh: the snapshot keeps the old list
+	first := decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405&limit=10&sort=distance", ""))
+	server.cache.Set(52.52, 13.405, makeRestaurants(3), SearchStats{})
+	next := decodePage(t, serve(server, "GET", "/api/v1/restaurants?cursor="+url.QueryEscape(first.Pagination.NextCursor), ""))
+	if next.Pagination.TotalItems != 45 || next.Restaurants[0].Name != "Place 10" {
+		t.Errorf("after refresh: %d items starting at %q", next.Pagination.TotalItems, next.Restaurants[0].Name)
+	}
+
+	// Snapshots expire
+	clock.Advance(snapshotTTL + time.Minute)
+	rec := serve(server, "GET", "/api/v1/restaurants?cursor="+url.QueryEscape(first.Pagination.NextCursor), "")
+	if rec.Code != http.StatusGone || decodeError(t, rec).Code != ErrCodeCursorExpired {
+		t.Errorf("expired cursor: status %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(server, "GET", "/api/v1/restaurants?cursor=not-a-cursor", "")
+	if rec.Code != http.StatusBadRequest || decodeError(t, rec).Code != ErrCodeInvalidCursor {
+		t.Errorf("invalid cursor: status %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServerCacheBehaviour(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(5), stats: SearchStats{OSMResultsTotal: 5, TotalAfterDedup: 5}}
+	server, clock := newTestServerWithClock(t, search)
+
+	page := decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405", ""))
+	if page.Stats.CachedResult || search.callCount() != 1 {
+		t.Fatalf("first search: cached=%v calls=%d", page.Stats.CachedResult, search.callCount())
+	}
+
+	// Within defaultCacheRadiusMeters (about 11 m north) the cached list is reused
+	page = decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=52.5201&lon=13.405", ""))
+	if !page.Stats.CachedResult || search.callCount() != 1 {
+		t.Errorf("nearby search: cached=%v calls=%d", page.Stats.CachedResult, search.callCount())
+	}
+
+	// Sort and filters are applied to cached lists without a new search
+	page = decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405&sort=reviews&min_reviews=30", ""))
+	if len(page.Restaurants) != 3 || page.Restaurants[0].Name != "Place 04" || search.callCount() != 1 {
+		t.Errorf("filtered cached search: %d items, first %q, calls=%d", len(page.Restaurants), page.Restaurants[0].Name, search.callCount())
+	}
+
+	// About 110 m away is a different cache entry
+	decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=52.521&lon=13.405", ""))
+	if search.callCount() != 2 {
+		t.Errorf("distant search: calls=%d, want 2", search.callCount())
+	}
+
+	// Keyword and area searches bypass the cache
+	for i := 0; i < 2; i++ {
+		decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405&keyword=vegan", ""))
+		decodePage(t, serve(server, "GET", "/api/v1/restaurants?bbox=13.40,52.51,13.42,52.52", ""))
+	}
+	if search.callCount() != 6 {
+		t.Errorf("uncached searches: calls=%d, want 6", search.callCount())
+	}
+
+	// Entries expire after defaultCacheTTL
+	clock.Advance(defaultCacheTTL + time.Minute)
+	page = decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405", ""))
+	if page.Stats.CachedResult || search.callCount() != 7 {
+		t.Errorf("after expiry: cached=%v calls=%d", page.Stats.CachedResult, search.callCount())
+	}
+}
+
+func TestServerCachesDegradedResultsBriefly(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(2), stats: SearchStats{Degraded: true, Notice: "OSM failed"}}
+	server, clock := newTestServerWithClock(t, search)
+	target := "/api/v1/restaurants?lat=52.52&lon=13.405"
+
+	decodePage(t, serve(server, "GET", target, ""))
+	clock.Advance(defaultDegradedCacheTTL - time.Minute)
+	if page := decodePage(t, serve(server, "GET", target, "")); !page.Stats.CachedResult || !page.Stats.Degraded {
+		t.Errorf("within defaultDegradedCacheTTL: %+v", page.Stats)
+	}
+	clock.Advance(2 * time.Minute)
+	decodePage(t, serve(server, "GET", target, ""))
+	if search.callCount() != 2 {
+		t.Errorf("calls=%d, want 2 (degraded entry expired)", search.callCount())
+	}
+}
+
+func TestServerSearchFailureIsNotCached(t *testing.T) {
+	search := &fakeSearch{err: &ProviderFailureError{
+		Failures: []ProviderError{{Provider: "osm", Code: ProviderErrTimeout, Message: "provider did not respond in time"}},
+		cause:    "overpass: context deadline exceeded",
+	}}
+	server := newTestServer(t, search)
+	target := "/api/v1/restaurants?lat=52.52&lon=13.405"
+
+	rec := serve(server, "GET", target, "")
+	apiErr := decodeError(t, rec)
+	if rec.Code != http.StatusBadGateway || apiErr.Code != ErrCodeProviderUnavailable || len(apiErr.Details) != 1 {
+		t.Fatalf("status %d, error %+v", rec.Code, apiErr)
+	}
+	if strings.Contains(rec.Body.String(), "overpass") {
+		t.Error("raw upstream error leaked into the response")
+	}
+
+	search.mu.Lock()
+	search.err = nil
+	search.restaurants = makeRestaurants(1)
+	search.mu.Unlock()
+	if page := decodePage(t, serve(server, "GET", target, "")); len(page.Restaurants) != 1 || page.Stats.CachedResult {
+		t.Errorf("retry: %d items, cached=%v", len(page.Restaurants), page.Stats.CachedResult)
+	}
+}
+
+func TestServerDoesNotCacheAfterClientLeaves(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(1), stats: SearchStats{Degraded: true, Notice: "Google failed"}}
+	server := newTestServer(t, search)
+
+	// The providers return what they had when the request was cancelled
+	for _, target := range []string{"/api/v1/restaurants?lat=52.52&lon=13.405", "/api/v1/restaurants/stream?lat=52.52&lon=13.405"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil).WithContext(ctx))
+	}
+	if _, _, found := server.cache.Get(52.52, 13.405); found {
+		t.Error("partial result of a cancelled request was cached")
+	}
+
+	decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405", ""))
+	if _, _, found := server.cache.Get(52.52, 13.405); !found {
+		t.Error("result of a completed request was not cached")
+	}
+}
+
+func TestServerSearchRateLimitOnlyOnCacheMiss(t *testing.T) {
+	search := &fakeSearch{restaurants: makeRestaurants(1)}
+	server := newTestServer(t, search)
+	server.limits = &ClientRateLimits{cached: NewRateLimiter(600, 100), search: NewRateLimiter(1, 1)}
+
+	for i := 0; i < 3; i++ {
+		decodePage(t, serve(server, "GET", "/api/v1/restaurants?lat=52.52&lon=13.405", ""))
+	}
+	rec := serve(server, "GET", "/api/v1/restaurants?lat=48.1&lon=11.5", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("second fresh search: status %d", rec.Code)
+	}
+	if search.callCount() != 1 {
+		t.Errorf("calls=%d, want 1", search.callCount())
+	}
+}
+
+func TestServerPhotos(t *testing.T) {
+	var apiHits int
+	photoAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiHits++
+		if r.URL.Query().Get("photoreference") == "broken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte(strings.Repeat("j", 2000)))
+	}))
+	defer photoAPI.Close()
+
+	server := newTestServer(t, &fakeSearch{})
+	photos := server.photos.(*memoryPhotoStore)
+	server.photoAPIURL = photoAPI.URL
+	server.mapsAPIKey = "test-key"
+
+	rec := serve(server, "GET", "/api/v1/photo?place_id=abc&photo_reference=ref1", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Photo-Source") != "api" || rec.Body.Len() != 2000 {
+		t.Fatalf("first fetch: status %d, source %q", rec.Code, rec.Header().Get("X-Photo-Source"))
+	}
+	if _, _, ok := photos.Get("abc.jpg"); !ok {
+		t.Error("photo was not stored")
+	}
+
+	// A different reference for the same place is still served from storage
+	rec = serve(server, "GET", "/api/photo?place_id=abc&photo_reference=ref2", "")
+	if rec.Header().Get("X-Photo-Source") != "disk" || apiHits != 1 {
+		t.Errorf("second fetch: source %q, API hits %d", rec.Header().Get("X-Photo-Source"), apiHits)
+	}
+
+	// Additional photos are stored separately
+	serve(server, "GET", "/api/v1/photo?place_id=abc&photo_reference=ref3&index=2", "")
+	if _, _, ok := photos.Get("abc_2.jpg"); !ok || apiHits != 2 {
+		t.Errorf("indexed photo: stored=%v, API hits %d", ok, apiHits)
+	}
+
+	// Failures store the placeholder so the API is not asked again
+	rec = serve(server, "GET", "/api/v1/photo?place_id=bad/id&photo_reference=broken", "")
+	if rec.Header().Get("X-Photo-Source") != "generic" {
+		t.Errorf("failed fetch: source %q", rec.Header().Get("X-Photo-Source"))
+	}
+	serve(server, "GET", "/api/v1/photo?place_id=bad/id&photo_reference=broken", "")
+	if _, _, ok := photos.Get("bad_id.jpg"); !ok || apiHits != 3 {
+		t.Errorf("placeholder stored=%v, API hits %d", ok, apiHits)
+	}
+
+	// Generic references never reach the API
+	rec = serve(server, "GET", "/api/v1/photo?place_id=xyz&photo_reference="+genericPhotoReference, "")
+	if rec.Header().Get("X-Photo-Source") != "generic" || apiHits != 3 {
+		t.Errorf("generic: source %q, API hits %d", rec.Header().Get("X-Photo-Source"), apiHits)
+	}
+
+	// Without an API key uncached photos are unavailable
+	server.mapsAPIKey = ""
+	rec = serve(server, "GET", "/api/v1/photo?place_id=new&photo_reference=ref", "")
+	if rec.Code != http.StatusServiceUnavailable || decodeError(t, rec).Code != ErrCodeNotConfigured {
+		t.Errorf("no key: status %d", rec.Code)
+	}
+}
+
+func TestServerPhotoRequestEscapingAndCancel(t *testing.T) {
+	var got []url.Values
+	photoAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Query())
+		w.Write([]byte(strings.Repeat("j", 2000)))
+	}))
+	defer photoAPI.Close()
+
+	server := newTestServer(t, &fakeSearch{})
+	photos := server.photos.(*memoryPhotoStore)
+	server.photoAPIURL = photoAPI.URL
+	server.mapsAPIKey = "test-key"
+
+	// A reference cannot smuggle extra parameters into the Google request
+	ref := "ref&key=other+key#x"
+	rec := serve(server, "GET", "/api/v1/photo?place_id=abc&photo_reference="+url.QueryEscape(ref), "")
+	if rec.Code != http.StatusOK || len(got) != 1 {
+		t.Fatalf("fetch: status %d, %d API hits", rec.Code, len(got))
+	}
+	if q := got[0]; q.Get("photoreference") != ref || len(q["key"]) != 1 || q.Get("key") != "test-key" || q.Get("maxwidth") != "400" {
+		t.Errorf("Google query = %v", q)
+	}
+
+	// A client that went away neither reaches Google nor stores a placeholder
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/api/v1/photo?place_id=gone&photo_reference=ref", nil).WithContext(ctx)
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	if _, _, ok := photos.Get("gone.jpg"); ok || len(got) != 1 {
+		t.Errorf("canceled fetch: stored=%v, %d API hits", ok, len(got))
+	}
+}
+
+func TestServerPhotoFetchesAreRateLimited(t *testing.T) {
+	var apiHits int
+	photoAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiHits++
+		w.Write([]byte(strings.Repeat("j", 2000)))
+	}))
+	defer photoAPI.Close()
+
+	server := newTestServer(t, &fakeSearch{})
+	server.photoAPIURL = photoAPI.URL
+	server.mapsAPIKey = "test-key"
+	server.limits = &ClientRateLimits{cached: NewRateLimiter(1, 2), search: NewRateLimiter(1, 1)}
+
+	for _, place := range []string{"a", "b"} {
+		if rec := serve(server, "GET", "/api/v1/photo?place_id="+place+"&photo_reference=ref", ""); rec.Header().Get("X-Photo-Source") != "api" {
+			t.Fatalf("fetch %s: status %d, source %q", place, rec.Code, rec.Header().Get("X-Photo-Source"))
+		}
+	}
+	rec := serve(server, "GET", "/api/v1/photo?place_id=c&photo_reference=ref", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" || apiHits != 2 {
+		t.Errorf("third fetch: status %d, API hits %d", rec.Code, apiHits)
+	}
+
+	// Stored photos and placeholders are served without a token
+	for _, target := range []string{"/api/v1/photo?place_id=a&photo_reference=ref", "/api/v1/photo?place_id=c&photo_reference=" + genericPhotoReference} {
+		if rec := serve(server, "GET", target, ""); rec.Code != http.StatusOK {
+			t.Errorf("%s: status %d", target, rec.Code)
+		}
+	}
+
+	// Cost reports count against the request limit too
+	if rec := serve(server, "GET", "/api/v1/costs", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("costs: status %d", rec.Code)
+	}
+}
+
+func TestServerInstancesAreIndependent(t *testing.T) {
+	first := newTestServer(t, &fakeSearch{restaurants: makeRestaurants(1)})
+	second := newTestServer(t, &fakeSearch{err: errors.New("down")})
+
+	if rec := serve(first, "GET", "/api/v1/restaurants?lat=1&lon=2", ""); rec.Code != http.StatusOK {
+		t.Errorf("first server: status %d", rec.Code)
+	}
+	if rec := serve(second, "GET", "/api/v1/restaurants?lat=1&lon=2", ""); rec.Code != http.StatusBadGateway {
+		t.Errorf("second server: status %d", rec.Code)
+	}
+	if rec := serve(first, "GET", "/nope", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown path: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// spanFlushTimeout bounds exporting buffered spans at shutdown
+const spanFlushTimeout = 5 * time.Second
+
+// HTTPServerConfig holds the HTTP server port, timeouts and the shutdown deadline
+type HTTPServerConfig struct {
+	Port              int
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// WriteTimeout also bounds /restaurants/stream and /route, which write
+	// until their search finishes
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // how long in-flight requests get to finish
+}
+
+// Addr is the listen address for Port
+func (cfg HTTPServerConfig) Addr() string {
+	return ":" + strconv.Itoa(cfg.Port)
+}
+
+// NewHTTPServer creates an http.Server for handler with the configured timeouts
+func NewHTTPServer(cfg HTTPServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// goHandle runs a Telegram handler in the background unless the bot is stopping
+func (rb *RestaurantBot) goHandle(fn func()) {
+	rb.handlersMu.Lock()
+	defer rb.handlersMu.Unlock()
+	if rb.stopped {
+		return
+	}
+	rb.handlers.Add(1)
+	go func() {
+		defer rb.handlers.Done()
+		fn()
+	}()
+}
+
+// Stop stops the Telegram update loop and waits for running handlers until ctx is done
+func (rb *RestaurantBot) Stop(ctx context.Context) error {
+	rb.handlersMu.Lock()
+	if !rb.stopped {
+		rb.stopped = true
+		close(rb.stopping)
+		if rb.telegramBot != nil {
+			rb.telegramBot.StopReceivingUpdates()
+		}
+	}
+	rb.handlersMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		rb.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("telegram handlers still running: %w", ctx.Err())
+	}
+}
+
+// shutdown stops accepting Telegram updates and HTTP connections, drains in-flight
+// work until timeout, then flushes the cost ledger and saves the location cache
+func shutdown(srv *http.Server, bot *RestaurantBot, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	slog.Info("[SHUTDOWN] Draining in-flight requests", "timeout", timeout)
+
+	// Stop the update loop first so no new chats start while HTTP drains
+	botStopped := make(chan error, 1)
+	go func() { botStopped <- bot.Stop(ctx) }()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("[SHUTDOWN] HTTP server did not drain in time, closing connections", "error", err)
+		srv.Close()
+		errs = append(errs, err)
+	}
+	if err := <-botStopped; err != nil {
+		slog.Warn("[SHUTDOWN] Telegram bot did not stop cleanly", "error", err)
+		errs = append(errs, err)
+	}
+
+	if err := bot.costs.Flush(); err != nil {
+		slog.Error("[SHUTDOWN] Failed to save Google spend", "error", err)
+		errs = append(errs, err)
+	}
+	if err := bot.cache.Save(); err != nil {
+		slog.Error("[SHUTDOWN] Failed to save cache", "error", err)
+		errs = append(errs, err)
+	}
+
+	// Spans of the drained requests are still buffered; the drain may have used
+	// up ctx, so exporting gets its own deadline
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), spanFlushTimeout)
+	defer cancelFlush()
+	if err := bot.tracing.Shutdown(flushCtx); err != nil {
+		slog.Warn("[SHUTDOWN] Failed to export spans", "error", err)
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		slog.Info("[SHUTDOWN] Clean shutdown complete")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	bot, err := NewRestaurantBot("", "", "osm")
+	if err != nil {
+		t.Fatalf("NewRestaurantBot: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	srv := NewHTTPServer(HTTPServerConfig{ReadHeaderTimeout: time.Second}, handler)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go srv.Serve(ln)
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+	<-started
+
+	// A running Telegram handler must also be waited for
+	handlerDone := make(chan struct{})
+	bot.goHandle(func() {
+		<-release
+		close(handlerDone)
+	})
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- shutdown(srv, bot, 5*time.Second) }()
+
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("shutdown returned before in-flight work finished: %v", err)
+	default:
+	}
+	close(release)
+
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	select {
+	case <-handlerDone:
+	default:
+		t.Error("shutdown returned before the Telegram handler finished")
+	}
+	if res := <-responses; res.err != nil || res.body != "done" {
+		t.Errorf("in-flight request = %q, %v; want it to complete", res.body, res.err)
+	}
+
+	// Handlers are no longer started once stopping
+	ran := make(chan struct{}, 1)
+	bot.goHandle(func() { ran <- struct{}{} })
+	if err := bot.Stop(context.Background()); err != nil {
+		t.Fatalf("second Stop: %v", err)
+	}
+	select {
+	case <-ran:
+		t.Error("handler started after Stop")
+	default:
+	}
+}
+
+func TestShutdownGivesUpAfterTimeout(t *testing.T) {
+	bot, err := NewRestaurantBot("", "", "osm")
+	if err != nil {
+		t.Fatalf("NewRestaurantBot: %v", err)
+	}
+	release := make(chan struct{})
+	defer close(release)
+	bot.goHandle(func() { <-release })
+
+	srv := NewHTTPServer(HTTPServerConfig{}, http.NotFoundHandler())
+	start := time.Now()
+	if err := shutdown(srv, bot, 50*time.Millisecond); err == nil {
+		t.Error("expected an error for a handler that outlives the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("shutdown took %s, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestHTTPServerConfigFromEnv(t *testing.T) {
+	env := map[string]string{"HTTP_PORT": "9090", "HTTP_WRITE_TIMEOUT": "0", "SHUTDOWN_TIMEOUT": "3s"}
+	loaded, err := loadTestConfig(t, env)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	cfg := loaded.HTTP
+	if cfg.Addr() != ":9090" || cfg.WriteTimeout != 0 || cfg.ShutdownTimeout != 3*time.Second || cfg.ReadHeaderTimeout != 5*time.Second {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	for _, value := range []string{"soon", "-1s", "10"} {
+		env["HTTP_IDLE_TIMEOUT"] = value
+		if _, err := loadTestConfig(t, env); err == nil {
+			t.Errorf("HTTP_IDLE_TIMEOUT=%q: expected an error", value)
+		}
+	}
+}
+
+func TestDiskPhotoStoreWritesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, photoTempPrefix+"abc.jpg-123")
+	if err := os.WriteFile(stale, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewDiskPhotoStore(dir)
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("partial write %s was not removed", stale)
+	}
+
+	if err := store.Put("abc.jpg", []byte("jpeg")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	data, _, ok := store.Get("abc.jpg")
+	if !ok || string(data) != "jpeg" {
+		t.Errorf("Get = %q, %v", data, ok)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only the photo", len(entries))
+	}
+}
+
+func TestDiskPhotoStoreUsage(t *testing.T) {
+	dir := t.TempDir()
+	store := NewDiskPhotoStore(filepath.Join(dir, "photos"))
+	if files, bytes, err := store.Usage(); files != 0 || bytes != 0 || err != nil {
+		t.Errorf("missing directory: %d files, %d bytes, %v", files, bytes, err)
+	}
+
+	store.Put("a.jpg", make([]byte, 100))
+	store.Put("b.jpg", make([]byte, 50))
+	store.Put("a.jpg", make([]byte, 70)) // replaced, not added
+	store.Remove("b.jpg")
+	if err := store.Writable(); err != nil {
+		t.Errorf("Writable: %v", err)
+	}
+	if files, bytes, _ := store.Usage(); files != 1 || bytes != 70 {
+		t.Errorf("after Put and Remove: %d files, %d bytes; want 1, 70", files, bytes)
+	}
+
+	// Files removed by another process show up at the next recount
+	os.Remove(filepath.Join(dir, "photos", "a.jpg"))
+	if files, _, _ := store.Usage(); files != 1 {
+		t.Errorf("%d files before the recount, want the counted 1", files)
+	}
+	store.counted = time.Now().Add(-photoUsageRecount)
+	if files, bytes, _ := store.Usage(); files != 0 || bytes != 0 {
+		t.Errorf("after the recount: %d files, %d bytes", files, bytes)
+	}
+
+	// A photo directory that is a file can't be written
+	notDir := filepath.Join(dir, "file")
+	os.WriteFile(notDir, []byte("x"), 0644)
+	if err := NewDiskPhotoStore(notDir).Writable(); err == nil {
+		t.Error("Writable succeeded for a file")
+	}
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// providerSampleWindow is how many recent calls per provider the latency
+// percentiles and recent error rate are computed from
+const providerSampleWindow = 200
+
+// telegramPollStaleAfter marks the Telegram loop stale when no long poll
+// (60s each) has completed for this long
+const telegramPollStaleAfter = 3 * time.Minute
+
+// maxStatusErrorLength truncates provider errors shown in /api/status
+const maxStatusErrorLength = 200
+
+// Provider and Telegram states reported by /api/status
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"  // the last call failed
+	StatusUnknown  = "unknown"  // no calls yet
+	StatusDegraded = "degraded" // overall: a provider is failing or Telegram is not polling
+	StatusDisabled = "disabled"
+	StatusStarting = "starting"
+	StatusStale    = "stale"
+	StatusError    = "error"
+	StatusStopped  = "stopped"
+)
+
+// ProviderMonitor records the outcome and latency of every provider search
+type ProviderMonitor struct {
+	mu        sync.Mutex
+	providers map[string]*providerRecord
+	now       func() time.Time
+}
+
+type providerRecord struct {
+	requests    int64
+	failures    int64
+	timeouts    int64
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+	samples     []providerSample // ring buffer of the latest calls
+	next        int
+}
+
+type providerSample struct {
+	latency time.Duration
+	failed  bool
+}
+
+// NewProviderMonitor creates an empty monitor
+func NewProviderMonitor() *ProviderMonitor {
+	return &ProviderMonitor{providers: make(map[string]*providerRecord), now: time.Now}
+}
+
+// Record stores one provider call; searches abandoned by the client are ignored
+func (pm *ProviderMonitor) Record(provider string, latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	rec := pm.providers[provider]
+	if rec == nil {
+		rec = &providerRecord{}
+		pm.providers[provider] = rec
+	}
+	rec.requests++
+	if err != nil {
+		rec.failures++
+		if isTimeoutError(err) {
+			rec.timeouts++
+		}
+		rec.lastFailure = pm.now()
+		rec.lastError = err.Error()
+		if len(rec.lastError) > maxStatusErrorLength {
+			rec.lastError = rec.lastError[:maxStatusErrorLength] + "…"
+		}
+	} else {
+		rec.lastSuccess = pm.now()
+	}
+
+	sample := providerSample{latency: latency, failed: err != nil}
+	if len(rec.samples) < providerSampleWindow {
+		rec.samples = append(rec.samples, sample)
+	} else {
+		rec.samples[rec.next] = sample
+		rec.next = (rec.next + 1) % providerSampleWindow
+	}
+}
+
+// LatencyPercentiles are nearest-rank percentiles over the recent calls
+type LatencyPercentiles struct {
+	P50     int64 `json:"p50"`
+	P90     int64 `json:"p90"`
+	P99     int64 `json:"p99"`
+	Samples int   `json:"samples"`
+}
+
+// ProviderStatus summarises one provider for /api/status
+type ProviderStatus struct {
+	Provider        string             `json:"provider"`
+	Status          string             `json:"status"` // "ok", "failing" or "unknown"
+	Requests        int64              `json:"requests"`
+	Failures        int64              `json:"failures"`
+	Timeouts        int64              `json:"timeouts"`
+	ErrorRate       float64            `json:"errorRate"`       // since start
+	RecentErrorRate float64            `json:"recentErrorRate"` // over the recent calls
+	LatencyMs       LatencyPercentiles `json:"latencyMs"`
+	LastSuccessAt   *time.Time         `json:"lastSuccessAt"`
+	LastFailureAt   *time.Time         `json:"lastFailureAt"`
+	LastError       string             `json:"lastError,omitempty"`
+}
+
+// Snapshot returns the status of the given providers plus any others that were called
+func (pm *ProviderMonitor) Snapshot(providers ...string) []ProviderStatus {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	names := append([]string(nil), providers...)
+	for name := range pm.providers {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	statuses := make([]ProviderStatus, 0, len(names))
+	for _, name := range names {
+		rec := pm.providers[name]
+		if rec == nil {
+			statuses = append(statuses, ProviderStatus{Provider: name, Status: StatusUnknown})
+			continue
+		}
+		status := ProviderStatus{
+			Provider:      name,
+			Status:        StatusOK,
+			Requests:      rec.requests,
+			Failures:      rec.failures,
+			Timeouts:      rec.timeouts,
+			ErrorRate:     float64(rec.failures) / float64(rec.requests),
+			LastSuccessAt: optionalTime(rec.lastSuccess),
+			LastFailureAt: optionalTime(rec.lastFailure),
+			LastError:     rec.lastError,
+		}
+		if rec.lastFailure.After(rec.lastSuccess) {
+			status.Status = StatusFailing
+		}
+
+		latencies := make([]time.Duration, len(rec.samples))
+		failed := 0
+		for i, sample := range rec.samples {
+			latencies[i] = sample.latency
+			if sample.failed {
+				failed++
+			}
+		}
+		status.RecentErrorRate = float64(failed) / float64(len(rec.samples))
+		status.LatencyMs = latencyPercentiles(latencies)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// latencyPercentiles computes nearest-rank percentiles in milliseconds
+func latencyPercentiles(latencies []time.Duration) LatencyPercentiles {
+	p := LatencyPercentiles{Samples: len(latencies)}
+	if len(latencies) == 0 {
+		return p
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := func(q float64) int64 {
+		i := int(math.Ceil(q*float64(len(latencies)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return latencies[i].Milliseconds()
+	}
+	p.P50, p.P90, p.P99 = rank(0.50), rank(0.90), rank(0.99)
+	return p
+}
+
+// TelegramMonitor records the long polls of the Telegram update loop
+type TelegramMonitor struct {
+	mu          sync.Mutex
+	started     bool
+	lastPoll    time.Time
+	lastUpdate  time.Time
+	lastErrorAt time.Time
+	lastError   string
+	now         func() time.Time
+}
+
+// NewTelegramMonitor creates a monitor for a loop that has not started yet
+func NewTelegramMonitor() *TelegramMonitor {
+	return &TelegramMonitor{now: time.Now}
+}
+
+// RecordPoll stores the result of one getUpdates call
+func (tm *TelegramMonitor) RecordPoll(updates int, err error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.started = true
+	if err != nil {
+		tm.lastErrorAt = tm.now()
+		tm.lastError = err.Error()
+		return
+	}
+	tm.lastPoll = tm.now()
+	if updates > 0 {
+		tm.lastUpdate = tm.lastPoll
+	}
+}
+
+// TelegramStatus reports whether the update loop is alive
+type TelegramStatus struct {
+	Status       string     `json:"status"` // "disabled", "starting", "ok", "stale", "error" or "stopped"
+	LastPollAt   *time.Time `json:"lastPollAt"`
+	LastUpdateAt *time.Time `json:"lastUpdateAt"`
+	LastErrorAt  *time.Time `json:"lastErrorAt"`
+	LastError    string     `json:"lastError,omitempty"`
+}
+
+// Snapshot returns the loop status; stopped is true once shutdown began
+func (tm *TelegramMonitor) Snapshot(stopped bool) TelegramStatus {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	status := TelegramStatus{
+		Status:       StatusOK,
+		LastPollAt:   optionalTime(tm.lastPoll),
+		LastUpdateAt: optionalTime(tm.lastUpdate),
+		LastErrorAt:  optionalTime(tm.lastErrorAt),
+		LastError:    tm.lastError,
+	}
+	switch {
+	case stopped:
+		status.Status = StatusStopped
+	case !tm.started:
+		status.Status = StatusStarting
+	case !tm.lastErrorAt.IsZero() && !tm.lastErrorAt.Before(tm.lastPoll):
+		status.Status = StatusError
+	case tm.now().Sub(tm.lastPoll) > telegramPollStaleAfter:
+		status.Status = StatusStale
+	}
+	return status
+}
+
+// pollUpdates long-polls Telegram into updates until the bot stops, recording
+// every poll so /api/status can tell whether the loop is alive
+func (rb *RestaurantBot) pollUpdates(config tgbotapi.UpdateConfig, updates chan<- tgbotapi.Update) {
+	defer close(updates)
+	for {
+		select {
+		case <-rb.stopping:
+			return
+		default:
+		}
+
+		batch, err := rb.telegramBot.GetUpdates(config)
+		rb.telegramHealth.RecordPoll(len(batch), err)
+		if err != nil {
+			slog.Error("[TELEGRAM] Failed to get updates, retrying in 3 seconds", "error", err)
+			select {
+			case <-rb.stopping:
+				return
+			case <-time.After(3 * time.Second):
+			}
+			continue
+		}
+
+		for _, update := range batch {
+			if update.UpdateID >= config.Offset {
+				config.Offset = update.UpdateID + 1
+			}
+			select {
+			case updates <- update:
+			case <-rb.stopping:
+				return
+			}
+		}
+	}
+}
+
+// isStopping reports whether Stop was called
+func (rb *RestaurantBot) isStopping() bool {
+	rb.handlersMu.Lock()
+	defer rb.handlersMu.Unlock()
+	return rb.stopped
+}
+
+// configuredProviders lists the search providers apiProvider uses
+func (rb *RestaurantBot) configuredProviders() []string {
+	switch rb.apiProvider {
+	case "osm":
+		return []string{"osm"}
+	case "both":
+		return []string{"google", "osm"}
+	case "fake":
+		return []string{"fake"}
+	default:
+		return []string{"google"}
+	}
+}
+
+// LocationCacheStats counts cached searches and lookups since start
+type LocationCacheStats struct {
+	Entries int     `json:"entries"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+}
+
+// CacheStatus reports the size of the in-memory caches
+type CacheStatus struct {
+	Locations    LocationCacheStats `json:"locations"`
+	PlaceDetails int                `json:"placeDetails"`
+	Snapshots    int                `json:"snapshots"`
+	Geocodes     int                `json:"geocodes"`
+}
+
+// PhotoStoreStatus reports how much the photo cache holds
+type PhotoStoreStatus struct {
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+}
+
+// StatusReport is the /api/status response
+type StatusReport struct {
+	Status         string           `json:"status"` // "ok" or "degraded"
+	StartedAt      time.Time        `json:"startedAt"`
+	UptimeSeconds  int64            `json:"uptimeSeconds"`
+	APIProvider    string           `json:"apiProvider"`
+	Providers      []ProviderStatus `json:"providers"`
+	Telegram       TelegramStatus   `json:"telegram"`
+	Caches         CacheStatus      `json:"caches"`
+	Photos         PhotoStoreStatus `json:"photos"`
+	BudgetExceeded bool             `json:"budgetExceeded"`
+}
+
+// statusReport collects the current status of providers, Telegram, caches and photos
+func (s *Server) statusReport() StatusReport {
+	now := s.now()
+	report := StatusReport{
+		Status:         StatusOK,
+		StartedAt:      s.startedAt.UTC(),
+		UptimeSeconds:  int64(now.Sub(s.startedAt).Seconds()),
+		APIProvider:    s.apiProvider,
+		Providers:      s.providerHealth.Snapshot(s.configuredProviders()...),
+		Telegram:       TelegramStatus{Status: StatusDisabled},
+		BudgetExceeded: s.costs.Report().BudgetExceeded,
+	}
+	if s.telegramBot != nil {
+		report.Telegram = s.telegramHealth.Snapshot(s.isStopping())
+	}
+
+	for _, p := range report.Providers {
+		if p.Status == StatusFailing {
+			report.Status = StatusDegraded
+		}
+	}
+	if report.Telegram.Status == StatusStale || report.Telegram.Status == StatusError {
+		report.Status = StatusDegraded
+	}
+
+	hits, misses, _ := s.cache.counters.Counts()
+	report.Caches.Locations = LocationCacheStats{Entries: s.cache.Len(), Hits: hits, Misses: misses}
+	if hits+misses > 0 {
+		report.Caches.Locations.HitRate = float64(hits) / float64(hits+misses)
+	}
+	report.Caches.PlaceDetails = s.details.Len()
+	report.Caches.Snapshots = s.snapshots.Len()
+	if cg, ok := s.geocoder.(*CachingGeocoder); ok {
+		report.Caches.Geocodes = cg.Len()
+	}
+
+	files, bytes, err := s.photos.Usage()
+	report.Photos = PhotoStoreStatus{Files: files, Bytes: bytes}
+	if err != nil {
+		report.Photos.Error = err.Error()
+	}
+	return report
+}
+
+// ReadinessReport is the /readyz response; every check is "ok" when ready
+type ReadinessReport struct {
+	Status string            `json:"status"` // "ready" or "not_ready"
+	Checks map[string]string `json:"checks"`
+}
+
+// readiness checks that the search providers, geocoder, cost ledger and photo
+// store are usable and that the server is not shutting down
+func (s *Server) readiness() ReadinessReport {
+	checks := map[string]string{
+		"providers":  StatusOK,
+		"geocoder":   StatusOK,
+		"costLedger": StatusOK,
+		"photoStore": StatusOK,
+		"shutdown":   StatusOK,
+	}
+	if s.search == nil {
+		checks["providers"] = "no search service configured"
+	}
+	if s.geocoder == nil {
+		checks["geocoder"] = StatusDisabled
+	}
+	if s.costs == nil {
+		checks["costLedger"] = "not loaded"
+	}
+	if err := s.photos.Writable(); err != nil {
+		checks["photoStore"] = err.Error()
+	}
+	if s.isStopping() {
+		checks["shutdown"] = "shutting down"
+	}
+
+	report := ReadinessReport{Status: "ready", Checks: checks}
+	for _, result := range checks {
+		if result != StatusOK && result != StatusDisabled {
+			report.Status = "not_ready"
+		}
+	}
+	return report
+}
+
+// handleHealthz reports that the process is up; it never checks dependencies
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeProbe(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// handleReadyz returns 503 until the server can take traffic, and again once shutdown begins
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	report := s.readiness()
+	status := http.StatusOK
+	if report.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeProbe(w, status, report)
+}
+
+// handleStatus serves the provider, Telegram, cache and photo store status
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.cors.Apply(w, r, "GET, OPTIONS") {
+		return
+	}
+
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if _, ok := s.authorizeAPIRequest(w, r); !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(s.statusReport())
+}
+
+// writeProbe writes an uncached health check response
+func writeProbe(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// optionalTime returns nil for the zero time so it is encoded as null
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProviderMonitor(t *testing.T) {
+	pm := NewProviderMonitor()
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	pm.now = clock.Now
+
+	for i := 1; i <= 100; i++ {
+		pm.Record("osm", time.Duration(i)*time.Millisecond, nil)
+	}
+	clock.Advance(time.Minute)
+	pm.Record("osm", 15*time.Second, context.DeadlineExceeded)
+	pm.Record("osm", time.Second, context.Canceled) // client went away: ignored
+
+	statuses := pm.Snapshot("google", "osm")
+	if len(statuses) != 2 || statuses[0].Provider != "google" || statuses[1].Provider != "osm" {
+		t.Fatalf("unexpected providers %+v", statuses)
+	}
+	if statuses[0].Status != StatusUnknown || statuses[0].LastSuccessAt != nil {
+		t.Errorf("google without calls = %+v, want unknown", statuses[0])
+	}
+
+	osm := statuses[1]
+	if osm.Status != StatusFailing || osm.Requests != 101 || osm.Failures != 1 || osm.Timeouts != 1 {
+		t.Errorf("osm = %+v", osm)
+	}
+	if osm.LatencyMs.P50 != 51 || osm.LatencyMs.P90 != 91 || osm.LatencyMs.P99 != 100 || osm.LatencyMs.Samples != 101 {
+		t.Errorf("percentiles = %+v", osm.LatencyMs)
+	}
+	if osm.LastFailureAt == nil || !osm.LastFailureAt.Equal(clock.Now()) || osm.LastError == "" {
+		t.Errorf("last failure = %v %q", osm.LastFailureAt, osm.LastError)
+	}
+
+	// The recent window forgets old calls; totals do not
+	for i := 0; i < providerSampleWindow; i++ {
+		pm.Record("osm", 10*time.Millisecond, nil)
+	}
+	osm = pm.Snapshot()[0]
+	if osm.Status != StatusOK || osm.RecentErrorRate != 0 || osm.ErrorRate == 0 || osm.LatencyMs.Samples != providerSampleWindow {
+		t.Errorf("after recovery = %+v", osm)
+	}
+}
+
+func TestTelegramMonitor(t *testing.T) {
+	tm := NewTelegramMonitor()
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	tm.now = clock.Now
+
+	steps := []struct {
+		do   func()
+		want string
+	}{
+		{func() {}, StatusStarting},
+		{func() { tm.RecordPoll(0, nil) }, StatusOK},
+		{func() { tm.RecordPoll(0, errors.New("bad gateway")) }, StatusError},
+		{func() { clock.Advance(time.Second); tm.RecordPoll(2, nil) }, StatusOK},
+		{func() { clock.Advance(telegramPollStaleAfter + time.Second) }, StatusStale},
+	}
+	for i, step := range steps {
+		step.do()
+		if got := tm.Snapshot(false).Status; got != step.want {
+			t.Errorf("step %d: status %q, want %q", i, got, step.want)
+		}
+	}
+	if got := tm.Snapshot(true).Status; got != StatusStopped {
+		t.Errorf("stopped status %q", got)
+	}
+}
+
+func TestReadinessFailsOnceShutdownBegins(t *testing.T) {
+	server := newTestServer(t, &fakeSearch{})
+
+	rec := serve(server, "GET", "/readyz", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d before shutdown: %s", rec.Code, rec.Body.String())
+	}
+	if err := server.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	rec = serve(server, "GET", "/readyz", "")
+	var report ReadinessReport
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	if rec.Code != http.StatusServiceUnavailable || report.Checks["shutdown"] == StatusOK {
+		t.Errorf("status %d, report %+v; want 503 while shutting down", rec.Code, report)
+	}
+
+	// Liveness is unaffected
+	if rec := serve(server, "GET", "/healthz", ""); rec.Code != http.StatusOK {
+		t.Errorf("healthz status %d", rec.Code)
+	}
+}
+
+func TestReadinessChecksPhotoStoreIsWritable(t *testing.T) {
+	server := newTestServer(t, &fakeSearch{})
+	notDir := filepath.Join(t.TempDir(), "photos")
+	os.WriteFile(notDir, []byte("x"), 0644)
+	server.photos = NewDiskPhotoStore(notDir)
+
+	rec := serve(server, "GET", "/readyz", "")
+	var report ReadinessReport
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	if rec.Code != http.StatusServiceUnavailable || report.Checks["photoStore"] == StatusOK {
+		t.Errorf("status %d, report %+v; want 503 for an unwritable photo directory", rec.Code, report)
+	}
+}
+
+func TestStatusIsRateLimited(t *testing.T) {
+	server := newTestServer(t, &fakeSearch{})
+	server.limits = &ClientRateLimits{cached: NewRateLimiter(1, 2)}
+	for i := 0; i < 2; i++ {
+		if rec := serve(server, "GET", "/api/v1/status", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, rec.Code)
+		}
+	}
+	if rec := serve(server, "GET", "/api/v1/status", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status %d after the burst, want 429", rec.Code)
+	}
+}
+
+func TestStatusReport(t *testing.T) {
+	server := newTestServer(t, &fakeSearch{})
+	server.cache.Set(52.52, 13.405, makeRestaurants(3), SearchStats{})
+	server.cache.Get(52.52, 13.405)
+	server.cache.Get(48.85, 2.35)
+	server.photos.Put("a.jpg", make([]byte, 1000))
+	server.providerHealth.Record("osm", 200*time.Millisecond, errors.New("overpass: 504"))
+
+	rec := serve(server, "GET", "/api/v1/status", "")
+	var report StatusReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.Status != StatusDegraded || report.Telegram.Status != StatusDisabled {
+		t.Errorf("status %q, telegram %q", report.Status, report.Telegram.Status)
+	}
+	locations := report.Caches.Locations
+	if locations.Entries != 1 || locations.Hits != 1 || locations.Misses != 1 || locations.HitRate != 0.5 {
+		t.Errorf("location cache = %+v", locations)
+	}
+	if report.Photos.Files != 1 || report.Photos.Bytes != 1000 {
+		t.Errorf("photos = %+v", report.Photos)
+	}
+}
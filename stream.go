@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SearchProgress is reported each time a provider or Google sub-query completes
+type SearchProgress struct {
+	Provider    string // "google" or "osm"
+	Source      string // sub-query, e.g. "google:cafe", "google:text:food", "osm"
+	Status      string // "ok", "error" or "timeout"
+	Completed   int    // sub-queries of this provider completed so far
+	Total       int    // sub-queries of this provider in total
+	Restaurants []Restaurant
+}
+
+type searchProgressKey struct{}
+
+// withSearchProgress returns a context whose searches report progress to fn.
+// fn may be called concurrently from different providers.
+func withSearchProgress(ctx context.Context, fn func(SearchProgress)) context.Context {
+	return context.WithValue(ctx, searchProgressKey{}, fn)
+}
+
+// reportSearchProgress calls the progress callback attached to ctx, if any
+func reportSearchProgress(ctx context.Context, provider, source string, completed, total int, sr *SearchResult, err error) {
+	fn, ok := ctx.Value(searchProgressKey{}).(func(SearchProgress))
+	if !ok {
+		return
+	}
+	progress := SearchProgress{
+		Provider:  provider,
+		Source:    source,
+		Status:    newProviderOutcome(source, 0, 0, err).Status,
+		Completed: completed,
+		Total:     total,
+	}
+	if err == nil && sr != nil {
+		progress.Restaurants = sr.Restaurants
+	}
+	fn(progress)
+}
+
+// streamBatch is the payload of a "batch" event
+type streamBatch struct {
+	Provider    string       `json:"provider"`
+	Source      string       `json:"source"`
+	Status      string       `json:"status"`
+	Completed   int          `json:"completed"`   // sub-queries of this provider completed
+	Total       int          `json:"total"`       // sub-queries of this provider in total
+	Results     int          `json:"results"`     // results returned by this sub-query, before filters
+	UniqueSoFar int          `json:"uniqueSoFar"` // distinct restaurants streamed so far
+	Restaurants []Restaurant `json:"restaurants"` // filtered restaurants not seen in earlier batches
+}
+
+// writeSSE writes one Server-Sent Event and flushes it to the client
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, event string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("[STREAM] Failed to encode event", "event", event, "error", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	flusher.Flush()
+}
+
+// handleRestaurantsStream serves /api/restaurants/stream. It accepts the same query
+// parameters as GET /api/restaurants and emits:
+//
+//	event: batch  - a provider/sub-query finished; carries new filtered, deduplicated restaurants and progress counts
+//	event: result - the final ranked, filtered first page (same shape as /api/restaurants)
+//	event: error  - the search failed (same envelope as API errors)
+func (s *Server) handleRestaurantsStream(w http.ResponseWriter, r *http.Request) {
+	if !s.cors.Apply(w, r, "GET, OPTIONS") {
+		return
+	}
+
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	clientKey, ok := s.authorizeAPIRequest(w, r)
+	if !ok {
+		return
+	}
+
+	params, filters, _, limit, apiErr := parseSearchQuery(r.URL.Query())
+	if apiErr != nil {
+		writeAPIError(w, http.StatusBadRequest, apiErr.Code, apiErr.Message)
+		return
+	}
+	location, ok := s.resolveSearchLocation(r.Context(), w, &params)
+	if !ok {
+		return
+	}
+	if !validCoordinates(params.Lat, params.Lon) {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeInvalidCoordinates, "lat must be within [-90, 90] and lon within [-180, 180]")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, "Streaming not supported")
+		return
+	}
+
+	s.metrics.SearchStarted(EntryStream)
+
+	// Cache hits are answered with a single result event
+	if params.cacheable() {
+		if cached, cachedStats, version, found := s.cache.GetVersioned(params.Lat, params.Lon); found {
+			ctxLogger(r.Context()).Info("[STREAM] Cache hit", logKeyLat, params.Lat, logKeyLon, params.Lon)
+			startSSE(w, flusher)
+			s.writeStreamResult(w, flusher, version, params, filters, limit, location, cached, *cachedStats)
+			return
+		}
+	}
+
+	if !s.allowSearch(w, r, clientKey, params) {
+		return
+	}
+
+	startSSE(w, flusher)
+
+	// In "both" mode the final list prefixes names with their source; do the same for batches
+	budgetExceeded, _ := s.costs.BudgetExceeded()
+	prefixSource := s.apiProvider == "both" && !budgetExceeded
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	ctx := withSearchProgress(r.Context(), func(p SearchProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		batch := streamBatch{
+			Provider:    p.Provider,
+			Source:      p.Source,
+			Status:      p.Status,
+			Completed:   p.Completed,
+			Total:       p.Total,
+			Results:     len(p.Restaurants),
+			Restaurants: []Restaurant{},
+		}
+		// Only stream what the final result can contain, so places don't vanish
+		for _, restaurant := range applyResultFilters(p.Restaurants, filters) {
+			key := dedupKey(restaurant)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if prefixSource {
+				restaurant.Name = fmt.Sprintf("[%s] %s", strings.ToUpper(p.Provider), restaurant.Name)
+			}
+			batch.Restaurants = append(batch.Restaurants, restaurant)
+		}
+		batch.UniqueSoFar = len(seen)
+		writeSSE(w, flusher, "batch", batch)
+	})
+
+	start := time.Now()
+	result, err := s.search.Search(ctx, params)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err != nil {
+		if r.Context().Err() != nil {
+			ctxLogger(r.Context()).Info("[STREAM] Client went away", "after", time.Since(start))
+			return
+		}
+		ctxLogger(r.Context()).Error("[STREAM] Error finding restaurants", "error", err)
+		budgetExceeded, _ := s.costs.BudgetExceeded()
+		_, apiErr := searchAPIError(s.apiProvider, budgetExceeded, err)
+		writeSSE(w, flusher, "error", apiErrorResponse{Error: apiErr})
+		return
+	}
+
+	// Cache the results (only for point searches without keyword filter),
+	// unless the client went away and the result may be partial
+	var source cacheVersion
+	if params.cacheable() && r.Context().Err() == nil {
+		source = s.cache.Set(params.Lat, params.Lon, result.Restaurants, result.Stats)
+	} else {
+		source = cacheVersion{Key: fmt.Sprintf("uncached:%d", s.now().UnixNano())}
+	}
+	s.writeStreamResult(w, flusher, source, params, filters, limit, location, result.Restaurants, result.Stats)
+}
+
+// startSSE sends the event stream headers
+func startSSE(w http.ResponseWriter, flusher http.Flusher) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+}
+
+// writeStreamResult ranks and filters the merged list, snapshots it for cursor
+// pagination and sends the final result event
+func (s *Server) writeStreamResult(w http.ResponseWriter, flusher http.Flusher, source cacheVersion, params SearchParams, filters ResultFilters, limit int, location *GeocodeResult, restaurants []Restaurant, stats SearchStats) {
+	ranked := applyResultFilters(restaurants, filters)
+	key := snapshotKey(source, params, filters)
+	s.snapshots.Put(key, source.Version, ranked, stats, filters)
+	result := paginateResults(key, source.Version, ranked, stats, filters, 0, limit)
+	result.Location = location
+	writeSSE(w, flusher, "result", result)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sseEvent is one decoded Server-Sent Event
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSE splits a recorded event stream into its events
+func readSSE(t *testing.T, rec *httptest.ResponseRecorder) []sseEvent {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q, status %d: %s", ct, rec.Code, rec.Body.String())
+	}
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if name, ok := strings.CutPrefix(line, "event: "); ok {
+				event.name = name
+			} else if data, ok := strings.CutPrefix(line, "data: "); ok {
+				event.data = data
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func restaurantNames(restaurants []Restaurant) []string {
+	names := make([]string, len(restaurants))
+	for i, r := range restaurants {
+		names[i] = r.Name
+	}
+	return names
+}
+
+func TestServerStreamsFilteredDeduplicatedBatches(t *testing.T) {
+	places := makeRestaurants(4)
+	places[1].Rating = 3.0 // below min_rating
+	search := &fakeSearch{
+		// Place 02 is found by both sub-queries
+		batches:     [][]Restaurant{{places[0], places[1], places[2]}, {places[2], places[3]}},
+		restaurants: places,
+	}
+	server := newTestServer(t, search)
+	const query = "lat=52.52&lon=13.405&min_rating=4&sort=rating&limit=2"
+
+	events := readSSE(t, serve(server, "GET", "/api/v1/restaurants/stream?"+query, ""))
+	if len(events) != 3 || events[0].name != "batch" || events[1].name != "batch" || events[2].name != "result" {
+		t.Fatalf("events = %+v", events)
+	}
+
+	var batches [2]streamBatch
+	for i := range batches {
+		if err := json.Unmarshal([]byte(events[i].data), &batches[i]); err != nil {
+			t.Fatalf("batch %d: %v", i+1, err)
+		}
+	}
+	if got := restaurantNames(batches[0].Restaurants); !reflect.DeepEqual(got, []string{"Place 02", "Place 00"}) {
+		t.Errorf("first batch = %v, want the places passing min_rating", got)
+	}
+	if got := restaurantNames(batches[1].Restaurants); !reflect.DeepEqual(got, []string{"Place 03"}) {
+		t.Errorf("second batch = %v, want only the place not streamed yet", got)
+	}
+	if batches[0].Results != 3 || batches[1].UniqueSoFar != 3 || batches[1].Completed != 2 || batches[1].Total != 2 {
+		t.Errorf("batch counts = %+v / %+v", batches[0], batches[1])
+	}
+
+	// The final event is the same first page /api/restaurants returns
+	var result PaginatedSearchResult
+	if err := json.Unmarshal([]byte(events[2].data), &result); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	page := decodePage(t, serve(server, "GET", "/api/v1/restaurants?"+query, ""))
+	if got, want := restaurantNames(result.Restaurants), restaurantNames(page.Restaurants); !reflect.DeepEqual(got, want) || len(got) != 2 {
+		t.Errorf("stream result %v, /api/restaurants %v", got, want)
+	}
+	if result.Pagination.TotalItems != 3 || result.Pagination.TotalItems != page.Pagination.TotalItems {
+		t.Errorf("stream pagination %+v, /api/restaurants %+v", result.Pagination, page.Pagination)
+	}
+	if search.callCount() != 1 {
+		t.Errorf("%d searches, want 1 (the second request is a cache hit)", search.callCount())
+	}
+}
+
+func TestServerStreamsSearchErrors(t *testing.T) {
+	server := newTestServer(t, &fakeSearch{err: errors.New("overpass: 504 from upstream")})
+
+	rec := serve(server, "GET", "/api/v1/restaurants/stream?lat=52.52&lon=13.405", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200 (the stream starts before the search)", rec.Code)
+	}
+	events := readSSE(t, rec)
+	if len(events) != 1 || events[0].name != "error" {
+		t.Fatalf("events = %+v", events)
+	}
+	var resp apiErrorResponse
+	if err := json.Unmarshal([]byte(events[0].data), &resp); err != nil {
+		t.Fatalf("error event: %v", err)
+	}
+	if resp.Error.Code != ErrCodeProviderUnavailable || strings.Contains(events[0].data, "504") {
+		t.Errorf("error event = %s", events[0].data)
+	}
+	if _, _, found := server.cache.Get(52.52, 13.405); found {
+		t.Error("failed search was cached")
+	}
+}
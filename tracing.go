@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName identifies the bot's instrumentation in exported spans
+const tracerName = "telegram-restaurant-bot"
+
+// defaultServiceName names the service on exported spans unless OTEL_SERVICE_NAME is set
+const defaultServiceName = "restaurant-bot"
+
+// noopTracer is used while tracing is disabled; its spans record nothing
+var noopTracer = noop.NewTracerProvider().Tracer(tracerName)
+
+// Tracing creates spans for searches, provider calls and photo fetches.
+// A nil *Tracing disables tracing.
+type Tracing struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// NewTracing traces through provider; tests pass one with an in-memory exporter
+func NewTracing(provider *sdktrace.TracerProvider) *Tracing {
+	return &Tracing{provider: provider, tracer: provider.Tracer(tracerName)}
+}
+
+// TracingConfig selects the span exporter. The OTLP/HTTP exporter takes its
+// endpoint, headers and timeout from the standard OTEL_EXPORTER_OTLP_*
+// environment variables.
+type TracingConfig struct {
+	Exporter    string  // "none" or "otlp"
+	SampleRatio float64 // fraction of new traces to record, 0-1
+	ServiceName string  // service name on exported spans
+}
+
+// NewTracingFromConfig creates the exporting tracer for cfg, or nil when tracing is off
+func NewTracingFromConfig(cfg TracingConfig) (*Tracing, error) {
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+	default:
+		return nil, fmt.Errorf("invalid TRACING_EXPORTER %q: must be none or otlp", cfg.Exporter)
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("invalid TRACING_SAMPLE_RATIO %v: must be between 0 and 1", cfg.SampleRatio)
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	otlp, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(otlp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	return NewTracing(provider), nil
+}
+
+// Start starts a span named name as a child of ctx's span
+func (t *Tracing) Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := noopTracer
+	if t != nil {
+		tracer = t.tracer
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Shutdown exports buffered spans and stops the exporter
+func (t *Tracing) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.provider.Shutdown(ctx)
+}
+
+// endSpan records err (if any) on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// endSearchSpan records the number of restaurants found (or err) and ends span
+func endSearchSpan(span trace.Span, result *SearchResult, err error) {
+	if result != nil {
+		span.SetAttributes(attribute.Int("search.results", len(result.Restaurants)))
+	}
+	endSpan(span, err)
+}
+
+// Transport returns base (or http.DefaultTransport) with a client span per request
+func (t *Tracing) Transport(endpoint string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t == nil {
+		return base
+	}
+	return &tracedTransport{tracing: t, endpoint: endpoint, base: base}
+}
+
+type tracedTransport struct {
+	tracing  *Tracing
+	endpoint string
+	base     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper. Only the host and path are recorded;
+// provider URLs carry API keys and coordinates in the query.
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracing.tracer.Start(req.Context(), "provider."+t.endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("provider.endpoint", t.endpoint),
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.path", req.URL.Path),
+		))
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	callErr := err
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			callErr = fmt.Errorf("status %d", resp.StatusCode)
+		}
+	}
+	endSpan(span, callErr)
+	return resp, err
+}
+
+// providerTransport is the transport for outgoing calls to endpoint: traced,
+// counted in the provider metrics, and recorded or replayed when fixtures are on
+func (rb *RestaurantBot) providerTransport(endpoint string) http.RoundTripper {
+	return rb.tracing.Transport(endpoint, rb.metrics.Transport(endpoint, rb.fixtures.Transport(endpoint)))
+}
+
+// withTracing wraps every request (except probes and scrapes) in a server span
+// named after the matched route, continuing a trace from a traceparent header
+func (s *Server) withTracing(next http.Handler) http.Handler {
+	if s.tracing == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if quietPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		_, route := s.mux.Handler(r)
+		ctx := propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := s.tracing.tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("request.id", requestIDFrom(ctx)),
+			))
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
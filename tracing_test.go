@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"googlemaps.github.io/maps"
+)
+
+// newTracedBot returns a bot recording spans in memory
+func newTracedBot(t *testing.T, provider string) (*RestaurantBot, *tracetest.InMemoryExporter) {
+	t.Helper()
+	bot, err := NewRestaurantBot("", "test-key", provider)
+	if err != nil {
+		t.Fatalf("NewRestaurantBot: %v", err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	bot.tracing = NewTracing(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	return bot, exporter
+}
+
+// spanAttr returns the value of attribute key on span, or nil
+func spanAttr(span tracetest.SpanStub, key string) any {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+// fakePlacesAPI serves Nearby Search: cafes have a second page whose token
+// is not ready on the first try, bars have a single page
+func fakePlacesAPI(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	tokenTries := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		q := r.URL.Query()
+		place := func(name string) map[string]any {
+			return map[string]any{
+				"name": name, "place_id": name, "types": []string{"cafe", "food"},
+				"geometry": map[string]any{"location": map[string]any{"lat": 52.52, "lng": 13.405}},
+			}
+		}
+		resp := map[string]any{"status": "OK"}
+		switch {
+		case q.Get("pagetoken") == "cafes-2":
+			if tokenTries++; tokenTries == 1 {
+				resp = map[string]any{"status": "INVALID_REQUEST"}
+			} else {
+				resp["results"] = []any{place("Cafe Two")}
+			}
+		case q.Get("type") == "cafe":
+			resp["results"] = []any{place("Cafe One")}
+			resp["next_page_token"] = "cafes-2"
+		case q.Get("type") == "bar":
+			resp["results"] = []any{place("Bar One")}
+		default:
+			t.Errorf("unexpected Places request %s", r.URL)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestTracingCoversSearchFanOut(t *testing.T) {
+	pageTokenDelay = time.Millisecond
+	t.Cleanup(func() { pageTokenDelay = 2 * time.Second })
+	places := fakePlacesAPI(t)
+	defer places.Close()
+
+	bot, exporter := newTracedBot(t, "google")
+	var err error
+	bot.mapsClient, err = maps.NewClient(maps.WithAPIKey("test-key"), maps.WithBaseURL(places.URL))
+	if err != nil {
+		t.Fatalf("maps.NewClient: %v", err)
+	}
+	server := NewServer(bot, bot, newMemoryPhotoStore())
+
+	req := httptest.NewRequest("POST", "/api/v1/restaurants", strings.NewReader(`{"lat":52.52,"lon":13.405,"categories":["cafe","bar"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	spans := exporter.GetSpans()
+	byName := map[string][]tracetest.SpanStub{}
+	for _, span := range spans {
+		if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("span %s is not part of the caller's trace", span.Name)
+		}
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	counts := map[string]int{
+		"POST /api/v1/restaurants":  1,
+		"search":                    1,
+		"search.google":             1,
+		"google.nearby_search":      2,
+		"google.nearby_search.page": 4, // cafes: page 0, page 1 not ready, page 1; bars: page 0
+		"dedup":                     1,
+		"rank":                      1,
+	}
+	for name, want := range counts {
+		if got := len(byName[name]); got != want {
+			t.Errorf("%d %q spans, want %d", got, name, want)
+		}
+	}
+
+	root := byName["POST /api/v1/restaurants"][0]
+	if spanAttr(root, "http.route") != "/api/v1/restaurants" || spanAttr(root, "http.response.status_code") != int64(200) {
+		t.Errorf("server span attributes %v", root.Attributes)
+	}
+	if search := byName["search"][0]; search.Parent.SpanID() != root.SpanContext.SpanID() || spanAttr(search, "search.results") != int64(3) {
+		t.Errorf("search span parent %s, results %v", search.Parent.SpanID(), spanAttr(search, "search.results"))
+	}
+
+	var retried, failed int
+	for _, page := range byName["google.nearby_search.page"] {
+		if spanAttr(page, "google.page_token_retry") == true {
+			retried++
+		}
+		if page.Status.Code == codes.Error {
+			failed++
+		}
+	}
+	if retried != 1 || failed != 1 {
+		t.Errorf("%d retried and %d failed page spans, want 1 and 1", retried, failed)
+	}
+	if dedup := byName["dedup"][0]; spanAttr(dedup, "restaurants.before") != int64(3) {
+		t.Errorf("dedup attributes %v", dedup.Attributes)
+	}
+}
+
+func TestTracingProviderTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("photoreference") == "broken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("jpeg"))
+	}))
+	defer upstream.Close()
+
+	bot, exporter := newTracedBot(t, "osm")
+	client := &http.Client{Transport: bot.providerTransport(EndpointPhoto)}
+	for _, ref := range []string{"ok", "broken"} {
+		resp, err := client.Get(upstream.URL + "/photo?key=secret&photoreference=" + ref)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans, want 2", len(spans))
+	}
+	for i, wantErr := range []bool{false, true} {
+		span := spans[i]
+		if span.Name != "provider.photo" || (span.Status.Code == codes.Error) != wantErr {
+			t.Errorf("span %d: %s status %v", i, span.Name, span.Status)
+		}
+		for _, kv := range span.Attributes {
+			if strings.Contains(fmt.Sprint(kv.Value.AsInterface()), "secret") {
+				t.Errorf("span %d leaks the API key in %s", i, kv.Key)
+			}
+		}
+	}
+	// Provider metrics are still counted underneath the span
+	expectMetrics(t, scrape(t, NewServer(bot, bot, newMemoryPhotoStore())),
+		`restaurantbot_provider_requests_total{endpoint="photo",status="ok"} 1`,
+		`restaurantbot_provider_requests_total{endpoint="photo",status="error"} 1`,
+	)
+}
+
+func TestTracingDisabledByDefault(t *testing.T) {
+	cfg, err := loadTestConfig(t, nil)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	tracing, err := NewTracingFromConfig(cfg.Tracing)
+	if err != nil || tracing != nil {
+		t.Fatalf("NewTracingFromConfig() = %v, %v; want disabled", tracing, err)
+	}
+	// A nil Tracing hands out spans that record nothing
+	_, span := tracing.Start(context.Background(), "search")
+	if span.IsRecording() {
+		t.Error("disabled tracing recorded a span")
+	}
+	span.End()
+	if err := tracing.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+
+	for name, value := range map[string]string{"TRACING_EXPORTER": "jaeger", "TRACING_SAMPLE_RATIO": "2"} {
+		t.Run(name, func(t *testing.T) {
+			env := map[string]string{"TRACING_EXPORTER": "otlp", name: value}
+			if _, err := loadTestConfig(t, env); err == nil {
+				t.Errorf("%s=%s accepted", name, value)
+			}
+		})
+	}
+}